@@ -88,6 +88,56 @@ func NewState(config *configs.Config) *State {
 	}
 }
 
+// DeepCopy returns a new State object that has the same check statuses and
+// failure messages as the receiver, but shares no mutable data with it.
+//
+// This is useful for retaining a snapshot of the check results after a
+// graph walk has completed, without any risk of later modifications from
+// either side affecting the other.
+func (c *State) DeepCopy() *State {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ret := &State{
+		statuses:    addrs.MakeMap[addrs.ConfigCheckable, *configCheckableState](),
+		failureMsgs: addrs.MakeMap[addrs.CheckRule, string](),
+	}
+	for _, elem := range c.statuses.Elems {
+		ret.statuses.Put(elem.Key, elem.Value.deepCopy())
+	}
+	for _, elem := range c.failureMsgs.Elems {
+		ret.failureMsgs.Put(elem.Key, elem.Value)
+	}
+	return ret
+}
+
+func (s *configCheckableState) deepCopy() *configCheckableState {
+	ret := &configCheckableState{}
+	if s.checkTypes != nil {
+		ret.checkTypes = make(map[addrs.CheckRuleType]int, len(s.checkTypes))
+		for k, v := range s.checkTypes {
+			ret.checkTypes[k] = v
+		}
+	}
+	// A nil objects map has the special meaning that we don't yet know
+	// the checkable objects, so we must preserve that distinction.
+	if s.objects.Elems != nil {
+		ret.objects = addrs.MakeMap[addrs.Checkable, map[addrs.CheckRuleType][]Status]()
+		for _, elem := range s.objects.Elems {
+			statuses := make(map[addrs.CheckRuleType][]Status, len(elem.Value))
+			for checkType, checks := range elem.Value {
+				statuses[checkType] = append([]Status(nil), checks...)
+			}
+			ret.objects.Put(elem.Key, statuses)
+		}
+	}
+	return ret
+}
+
 // ConfigHasChecks returns true if and only if the given address refers to
 // a configuration object that this State object is expecting to receive
 // statuses for.
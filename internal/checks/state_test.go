@@ -229,3 +229,64 @@ func TestChecksHappyPath(t *testing.T) {
 		}
 	}
 }
+
+func TestStateDeepCopy(t *testing.T) {
+	resourceA := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "null_resource",
+		Name: "a",
+	}.InModule(addrs.RootModule)
+	resourceB := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "null_resource",
+		Name: "b",
+	}.InModule(addrs.RootModule)
+	resourceInstA := resourceA.Resource.Absolute(addrs.RootModuleInstance).Instance(addrs.NoKey)
+
+	newState := func() *State {
+		return &State{
+			statuses: addrs.MakeMap[addrs.ConfigCheckable, *configCheckableState](
+				addrs.MakeMapElem[addrs.ConfigCheckable](resourceA, &configCheckableState{
+					checkTypes: map[addrs.CheckRuleType]int{
+						addrs.ResourcePostcondition: 2,
+					},
+				}),
+				addrs.MakeMapElem[addrs.ConfigCheckable](resourceB, &configCheckableState{
+					checkTypes: map[addrs.CheckRuleType]int{
+						addrs.ResourcePostcondition: 1,
+					},
+				}),
+			),
+		}
+	}
+
+	original := newState()
+	original.ReportCheckableObjects(resourceA, addrs.MakeSet[addrs.Checkable](resourceInstA))
+	original.ReportCheckFailure(resourceInstA, addrs.ResourcePostcondition, 0, "failed")
+
+	copied := original.DeepCopy()
+
+	// Reporting the remaining result on the copy must not affect the
+	// original, and vice-versa.
+	copied.ReportCheckResult(resourceInstA, addrs.ResourcePostcondition, 1, StatusPass)
+	original.ReportCheckResult(resourceInstA, addrs.ResourcePostcondition, 1, StatusError)
+
+	if got, want := original.ObjectCheckStatus(resourceInstA), StatusError; got != want {
+		t.Errorf("wrong status in original: got %s, want %s", got, want)
+	}
+	if got, want := copied.ObjectCheckStatus(resourceInstA), StatusFail; got != want {
+		t.Errorf("wrong status in copy: got %s, want %s", got, want)
+	}
+	if got, want := copied.ObjectFailureMessages(resourceInstA), []string{"failed"}; !cmp.Equal(got, want) {
+		t.Errorf("wrong failure messages in copy\n%s", cmp.Diff(want, got))
+	}
+
+	// A configuration object whose checkable objects are not yet known
+	// must remain unknown in the copy.
+	if got, want := copied.AggregateCheckStatus(resourceB), StatusUnknown; got != want {
+		t.Errorf("wrong aggregate status for %s in copy: got %s, want %s", resourceB, got, want)
+	}
+	if (*State)(nil).DeepCopy() != nil {
+		t.Errorf("DeepCopy of nil State returned non-nil result")
+	}
+}
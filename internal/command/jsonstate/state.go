@@ -38,6 +38,10 @@ type State struct {
 	TerraformVersion string          `json:"terraform_version,omitempty"`
 	Values           *StateValues    `json:"values,omitempty"`
 	Checks           json.RawMessage `json:"checks,omitempty"`
+
+	// RunMetadata describes the run that most recently applied changes to
+	// the state, if that run recorded any.
+	RunMetadata map[string]string `json:"run_metadata,omitempty"`
 }
 
 // StateValues is the common representation of resolved values for both the prior
@@ -188,6 +192,11 @@ func MarshalForLog(sf *statefile.File, schemas *tofu.Schemas) (*State, error) {
 		output.Checks = jsonchecks.MarshalCheckStates(sf.State.CheckResults)
 	}
 
+	// output.RunMetadata
+	if len(sf.State.RunMetadata) > 0 {
+		output.RunMetadata = sf.State.RunMetadata
+	}
+
 	return output, nil
 }
 
@@ -18,6 +18,7 @@ import (
 	"github.com/opentofu/opentofu/internal/lang/marks"
 	"github.com/opentofu/opentofu/internal/providers"
 	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/states/statefile"
 	"github.com/opentofu/opentofu/internal/tofu"
 )
 
@@ -613,6 +614,21 @@ func TestMarshalResources(t *testing.T) {
 	}
 }
 
+func TestMarshalForLog_runMetadata(t *testing.T) {
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetOutputValue(addrs.OutputValue{Name: "foo"}.Absolute(addrs.RootModuleInstance), cty.StringVal("bar"), false)
+	})
+	state.RunMetadata = map[string]string{"run_id": "run-1"}
+
+	got, err := MarshalForLog(statefile.New(state, "lineage", 1), testSchemas())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(state.RunMetadata, got.RunMetadata); diff != "" {
+		t.Errorf("wrong run metadata\n%s", diff)
+	}
+}
+
 func TestMarshalModules_basic(t *testing.T) {
 	childModule, _ := addrs.ParseModuleInstanceStr("module.child")
 	subModule, _ := addrs.ParseModuleInstanceStr("module.submodule")
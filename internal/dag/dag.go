@@ -23,6 +23,11 @@ type AcyclicGraph struct {
 // WalkFunc is the callback used for walking the graph.
 type WalkFunc func(Vertex) tfdiags.Diagnostics
 
+// SkipFunc is the callback used for vertices that are skipped during a walk
+// because one of their dependencies failed. The second argument is the
+// failed vertex that caused the skip.
+type SkipFunc func(v Vertex, cause Vertex) tfdiags.Diagnostics
+
 // DepthWalkFunc is a walk function that also receives the current depth of the
 // walk as an argument
 type DepthWalkFunc func(Vertex, int) error
@@ -125,17 +130,8 @@ func (g *AcyclicGraph) Validate() error {
 
 	// Look for cycles of more than 1 component
 	var err error
-	cycles := g.Cycles()
-	if len(cycles) > 0 {
-		for _, cycle := range cycles {
-			cycleStr := make([]string, len(cycle))
-			for j, vertex := range cycle {
-				cycleStr[j] = VertexName(vertex)
-			}
-
-			err = multierror.Append(err, fmt.Errorf(
-				"Cycle: %s", strings.Join(cycleStr, ", ")))
-		}
+	for _, cycle := range g.Cycles() {
+		err = multierror.Append(err, &CycleError{Vertices: cycle})
 	}
 
 	// Look for cycles to self
@@ -149,6 +145,22 @@ func (g *AcyclicGraph) Validate() error {
 	return err
 }
 
+// CycleError is the error that Validate reports for each cycle of more than
+// one vertex, so that callers can describe the cycle in more detail.
+type CycleError struct {
+	// Vertices are the members of the strongly-connected component that
+	// forms the cycle, in no particular order.
+	Vertices []Vertex
+}
+
+func (e *CycleError) Error() string {
+	names := make([]string, len(e.Vertices))
+	for i, v := range e.Vertices {
+		names[i] = VertexName(v)
+	}
+	return fmt.Sprintf("Cycle: %s", strings.Join(names, ", "))
+}
+
 // Cycles reports any cycles between graph nodes.
 // Self-referencing nodes are not reported, and must be detected separately.
 func (g *AcyclicGraph) Cycles() [][]Vertex {
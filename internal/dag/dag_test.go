@@ -6,6 +6,7 @@
 package dag
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -214,9 +215,17 @@ func TestAcyclicGraphValidate_cycle(t *testing.T) {
 	g.Connect(BasicEdge(1, 2))
 	g.Connect(BasicEdge(2, 1))
 
-	if err := g.Validate(); err == nil {
+	err := g.Validate()
+	if err == nil {
 		t.Fatal("should error")
 	}
+	var cycleErr *CycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("error does not include a CycleError: %s", err)
+	}
+	if got, want := len(cycleErr.Vertices), 2; got != want {
+		t.Errorf("wrong number of vertices in cycle %d; want %d", got, want)
+	}
 }
 
 func TestAcyclicGraphValidate_cycleSelf(t *testing.T) {
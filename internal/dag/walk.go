@@ -43,6 +43,15 @@ type Walker struct {
 	// Callback is what is called for each vertex
 	Callback WalkFunc
 
+	// SkipCallback, if set, is called instead of Callback for each vertex
+	// that is skipped because one of its dependencies failed, along with
+	// the vertex whose own failure caused the skip. If that dependency was
+	// itself skipped then the cause is the vertex that caused it to be
+	// skipped, and so on. Unlike other diagnostics for skipped vertices,
+	// the diagnostics returned by SkipCallback are included in the result
+	// of Wait.
+	SkipCallback SkipFunc
+
 	// Reverse, if true, causes the source of an edge to depend on a target.
 	// When false (default), the target depends on the source.
 	Reverse bool
@@ -67,6 +76,13 @@ type Walker struct {
 	// Readers and writers of either map must hold diagsLock.
 	diagsMap       map[Vertex]tfdiags.Diagnostics
 	upstreamFailed map[Vertex]struct{}
+
+	// skipCauses records the vertex that caused each skipped vertex to be
+	// skipped, and skipDiags contains the diagnostics returned from
+	// SkipCallback for each skipped vertex. Readers and writers of either
+	// map must hold diagsLock.
+	skipCauses map[Vertex]Vertex
+	skipDiags  map[Vertex]tfdiags.Diagnostics
 }
 
 func (w *Walker) init() {
@@ -132,6 +148,9 @@ func (w *Walker) Wait() tfdiags.Diagnostics {
 		}
 		diags = diags.Append(vDiags)
 	}
+	for _, vDiags := range w.skipDiags {
+		diags = diags.Append(vDiags)
+	}
 	w.diagsLock.Unlock()
 
 	return diags
@@ -391,6 +410,21 @@ func (w *Walker) walkVertex(v Vertex, info *walkerVertex) {
 		// the failures will cascade downstream.
 		diags = diags.Append(errors.New("upstream dependencies failed"))
 		upstreamFailed = true
+
+		if w.SkipCallback != nil {
+			w.diagsLock.Lock()
+			cause := w.skipCauses[v]
+			w.diagsLock.Unlock()
+			if cause != nil {
+				skipDiags := w.SkipCallback(v, cause)
+				w.diagsLock.Lock()
+				if w.skipDiags == nil {
+					w.skipDiags = make(map[Vertex]tfdiags.Diagnostics)
+				}
+				w.skipDiags[v] = skipDiags
+				w.diagsLock.Unlock()
+			}
+		}
 	}
 
 	// Record the result (we must do this after execution because we mustn't
@@ -453,13 +487,32 @@ func (w *Walker) waitDeps(
 	// Dependencies satisfied! We need to check if any errored
 	w.diagsLock.Lock()
 	defer w.diagsLock.Unlock()
+	var cause Vertex
 	for dep := range deps {
-		if w.diagsMap[dep].HasErrors() {
-			// One of our dependencies failed, so return false
-			doneCh <- false
-			return
+		if !w.diagsMap[dep].HasErrors() {
+			continue
+		}
+		// If the dependency was itself skipped then we blame whatever
+		// caused it to be skipped, so that the cause is always a vertex
+		// that actually failed. We choose by name between several failed
+		// dependencies so that the result doesn't vary between walks.
+		depCause := dep
+		if _, upstream := w.upstreamFailed[dep]; upstream && w.skipCauses[dep] != nil {
+			depCause = w.skipCauses[dep]
+		}
+		if cause == nil || VertexName(depCause) < VertexName(cause) {
+			cause = depCause
 		}
 	}
+	if cause != nil {
+		// One of our dependencies failed, so return false
+		if w.skipCauses == nil {
+			w.skipCauses = make(map[Vertex]Vertex)
+		}
+		w.skipCauses[v] = cause
+		doneCh <- false
+		return
+	}
 
 	// All dependencies satisfied and successful
 	doneCh <- true
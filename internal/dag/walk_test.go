@@ -8,6 +8,7 @@ package dag
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -100,6 +101,60 @@ func TestWalker_error(t *testing.T) {
 	}
 }
 
+func TestWalker_skipCallback(t *testing.T) {
+	var g AcyclicGraph
+	g.Add(1)
+	g.Add(2)
+	g.Add(3)
+	g.Add(4)
+	g.Add(5)
+	g.Connect(BasicEdge(1, 2))
+	g.Connect(BasicEdge(2, 3))
+	g.Connect(BasicEdge(3, 4))
+	g.Connect(BasicEdge(1, 5))
+
+	cb := func(v Vertex) tfdiags.Diagnostics {
+		var diags tfdiags.Diagnostics
+		if v == 2 {
+			diags = diags.Append(fmt.Errorf("error"))
+		}
+		return diags
+	}
+
+	var lock sync.Mutex
+	causes := map[Vertex]Vertex{}
+	skipCb := func(v Vertex, cause Vertex) tfdiags.Diagnostics {
+		lock.Lock()
+		defer lock.Unlock()
+		causes[v] = cause
+
+		var diags tfdiags.Diagnostics
+		diags = diags.Append(tfdiags.SimpleWarning(fmt.Sprintf("%v skipped because of %v", v, cause)))
+		return diags
+	}
+
+	w := &Walker{Callback: cb, SkipCallback: skipCb}
+	w.Update(&g)
+	diags := w.Wait()
+
+	// Both skipped vertices blame vertex 2, because vertex 3 was itself
+	// skipped rather than failing.
+	expected := map[Vertex]Vertex{3: 2, 4: 2}
+	if !reflect.DeepEqual(causes, expected) {
+		t.Errorf("wrong skip causes\ngot:  %#v\nwant: %#v", causes, expected)
+	}
+
+	var got []string
+	for _, diag := range diags {
+		got = append(got, diag.Description().Summary)
+	}
+	sort.Strings(got)
+	want := []string{"3 skipped because of 2", "4 skipped because of 2", "error"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong diagnostics\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
 func TestWalker_newVertex(t *testing.T) {
 	var g AcyclicGraph
 	g.Add(1)
@@ -185,6 +185,12 @@ func (s *Scope) Functions() map[string]function.Function {
 			s.funcs["plantimestamp"] = funcs.MakeStaticTimestampFunc(s.PlanTimestamp)
 		}
 
+		for name, f := range s.FunctionOverrides {
+			if _, exists := s.funcs[name]; exists {
+				s.funcs[name] = f
+			}
+		}
+
 		if s.PureOnly {
 			// Force our few impure functions to return unknown so that we
 			// can defer evaluating them until a later pass.
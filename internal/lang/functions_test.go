@@ -17,6 +17,7 @@ import (
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
 
 	"github.com/opentofu/opentofu/internal/experiments"
 	"github.com/opentofu/opentofu/internal/lang/marks"
@@ -1338,6 +1339,41 @@ func TestFunctions(t *testing.T) {
 	}
 }
 
+func TestFunctionOverrides(t *testing.T) {
+	fixed := function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.StringVal("fixed"), nil
+		},
+	})
+	scope := &Scope{
+		Data: &dataForTests{},
+		FunctionOverrides: map[string]function.Function{
+			"uuid":           fixed,
+			"not_a_function": fixed,
+		},
+	}
+
+	for _, src := range []string{"uuid()", "core::uuid()"} {
+		expr, parseDiags := hclsyntax.ParseExpression([]byte(src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+		if parseDiags.HasErrors() {
+			t.Fatal(parseDiags.Error())
+		}
+		got, diags := scope.EvalExpr(expr, cty.String)
+		if diags.HasErrors() {
+			t.Fatal(diags.Err())
+		}
+		if want := cty.StringVal("fixed"); !want.RawEquals(got) {
+			t.Errorf("wrong result for %s\ngot:  %#v\nwant: %#v", src, got, want)
+		}
+	}
+
+	// Overrides only replace built-in functions.
+	if _, exists := scope.Functions()["not_a_function"]; exists {
+		t.Error("override added a function that isn't built in")
+	}
+}
+
 const (
 	CipherBase64 = "eczGaDhXDbOFRZGhjx2etVzWbRqWDlmq0bvNt284JHVbwCgObiuyX9uV0LSAMY707IEgMkExJqXmsB4OWKxvB7epRB9G/3+F+pcrQpODlDuL9oDUAsa65zEpYF0Wbn7Oh7nrMQncyUPpyr9WUlALl0gRWytOA23S+y5joa4M34KFpawFgoqTu/2EEH4Xl1zo+0fy73fEto+nfkUY+meuyGZ1nUx/+DljP7ZqxHBFSlLODmtuTMdswUbHbXbWneW51D7Jm7xB8nSdiA2JQNK5+Sg5x8aNfgvFTt/m2w2+qpsyFa5Wjeu6fZmXSl840CA07aXbk9vN4I81WmJyblD/ZA=="
 	PrivateKey   = `
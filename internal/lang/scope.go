@@ -73,6 +73,11 @@ type Scope struct {
 	PlanTimestamp time.Time
 
 	ProviderFunctions ProviderFunction
+
+	// FunctionOverrides, if set, replace the built-in functions of the same
+	// names, such as to make uuid return a fixed value. Names that aren't
+	// the names of built-in functions are ignored.
+	FunctionOverrides map[string]function.Function
 }
 
 type ProviderFunction func(addrs.ProviderFunction, tfdiags.SourceRange) (*function.Function, tfdiags.Diagnostics)
@@ -245,6 +245,12 @@ type ResourceInstanceChange struct {
 	// currently survive a round-trip through a saved plan file.
 	RequiredReplace cty.PathSet
 
+	// IgnoreChanges records the effect of the resource's ignore_changes
+	// argument at the time this change was planned, so that the apply step
+	// can detect if the configuration has changed it since. This is always
+	// nil for changes that are not for managed resources.
+	IgnoreChanges *IgnoreChanges
+
 	// Private allows a provider to stash any extra data that is opaque to
 	// OpenTofu that relates to this change. OpenTofu will save this
 	// byte-for-byte and return it to the provider in the apply call.
@@ -273,6 +279,7 @@ func (rc *ResourceInstanceChange) Encode(ty cty.Type) (*ResourceInstanceChangeSr
 		ChangeSrc:       *cs,
 		ActionReason:    rc.ActionReason,
 		RequiredReplace: rc.RequiredReplace,
+		IgnoreChanges:   rc.IgnoreChanges,
 		Private:         rc.Private,
 	}, err
 }
@@ -369,6 +376,26 @@ func (rc *ResourceInstanceChange) Simplify(destroying bool) *ResourceInstanceCha
 	return rc
 }
 
+// IgnoreChanges describes the attributes that a managed resource's
+// ignore_changes argument told OpenTofu to disregard when planning a change.
+type IgnoreChanges struct {
+	// All is true if ignore_changes was set to "all".
+	All bool
+
+	// Paths are the paths of the individual attributes that ignore_changes
+	// covered. This is empty if All is true.
+	Paths cty.PathSet
+}
+
+// Equal returns true if the receiver and the other given IgnoreChanges
+// describe the same set of ignored attributes.
+func (ic *IgnoreChanges) Equal(other *IgnoreChanges) bool {
+	if ic == nil || other == nil {
+		return ic == other
+	}
+	return ic.All == other.All && ic.Paths.Equal(other.Paths)
+}
+
 // ResourceInstanceChangeActionReason allows for some extra user-facing
 // reasoning for why a particular change action was chosen for a particular
 // resource instance.
@@ -510,6 +537,15 @@ func (oc *OutputChange) Encode() (*OutputChangeSrc, error) {
 type Importing struct {
 	// ID is the original ID of the imported resource.
 	ID string
+
+	// Identity, if not cty.NilVal or null, is a structured identity for the
+	// imported resource, for providers that identify remote objects by more
+	// than a single ID string, such as by a combination of attributes. ID
+	// is still set alongside it.
+	//
+	// Identity may be of any type, and its type is saved with it so that
+	// it can be decoded without a schema.
+	Identity cty.Value
 }
 
 // Change describes a single change with a given action.
@@ -581,6 +617,12 @@ func (c *Change) Encode(ty cty.Type) (*ChangeSrc, error) {
 	var importing *ImportingSrc
 	if c.Importing != nil {
 		importing = &ImportingSrc{ID: c.Importing.ID}
+		if identity := c.Importing.Identity; identity != cty.NilVal && !identity.IsNull() {
+			importing.Identity, err = NewDynamicValue(identity, cty.DynamicPseudoType)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return &ChangeSrc{
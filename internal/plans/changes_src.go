@@ -70,6 +70,11 @@ type ResourceInstanceChangeSrc struct {
 	// Replace.
 	RequiredReplace cty.PathSet
 
+	// IgnoreChanges records the effect of the resource's ignore_changes
+	// argument at the time this change was planned. See the field of the
+	// same name in ResourceInstanceChange for more details.
+	IgnoreChanges *IgnoreChanges
+
 	// Private allows a provider to stash any extra data that is opaque to
 	// OpenTofu that relates to this change. OpenTofu will save this
 	// byte-for-byte and return it to the provider in the apply call.
@@ -98,6 +103,7 @@ func (rcs *ResourceInstanceChangeSrc) Decode(ty cty.Type) (*ResourceInstanceChan
 		Change:          *change,
 		ActionReason:    rcs.ActionReason,
 		RequiredReplace: rcs.RequiredReplace,
+		IgnoreChanges:   rcs.IgnoreChanges,
 		Private:         rcs.Private,
 	}, nil
 }
@@ -118,6 +124,13 @@ func (rcs *ResourceInstanceChangeSrc) DeepCopy() *ResourceInstanceChangeSrc {
 
 	ret.RequiredReplace = cty.NewPathSet(ret.RequiredReplace.List()...)
 
+	if ret.IgnoreChanges != nil {
+		ret.IgnoreChanges = &IgnoreChanges{
+			All:   ret.IgnoreChanges.All,
+			Paths: cty.NewPathSet(ret.IgnoreChanges.Paths.List()...),
+		}
+	}
+
 	if len(ret.Private) != 0 {
 		private := make([]byte, len(ret.Private))
 		copy(private, ret.Private)
@@ -127,6 +140,12 @@ func (rcs *ResourceInstanceChangeSrc) DeepCopy() *ResourceInstanceChangeSrc {
 	ret.ChangeSrc.Before = ret.ChangeSrc.Before.Copy()
 	ret.ChangeSrc.After = ret.ChangeSrc.After.Copy()
 
+	if ret.Importing != nil {
+		importing := *ret.Importing
+		importing.Identity = importing.Identity.Copy()
+		ret.Importing = &importing
+	}
+
 	return &ret
 }
 
@@ -195,6 +214,11 @@ func (ocs *OutputChangeSrc) DeepCopy() *OutputChangeSrc {
 type ImportingSrc struct {
 	// ID is the original ID of the imported resource.
 	ID string
+
+	// Identity corresponds to the field of the same name in Importing, but
+	// has not yet been decoded from the serialized value used for storage.
+	// It is nil if there is no structured identity.
+	Identity DynamicValue
 }
 
 // ChangeSrc is a not-yet-decoded Change.
@@ -256,6 +280,12 @@ func (cs *ChangeSrc) Decode(ty cty.Type) (*Change, error) {
 	var importing *Importing
 	if cs.Importing != nil {
 		importing = &Importing{ID: cs.Importing.ID}
+		if len(cs.Importing.Identity) > 0 {
+			importing.Identity, err = cs.Importing.Identity.Decode(cty.DynamicPseudoType)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding import identity: %w", err)
+			}
+		}
 	}
 
 	return &Change{
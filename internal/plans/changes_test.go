@@ -162,3 +162,83 @@ func TestChangeEncodeSensitive(t *testing.T) {
 		})
 	}
 }
+
+func TestChangeEncodeImportIdentity(t *testing.T) {
+	identity := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("region-a"),
+		"names":  cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.NumberIntVal(1)}),
+	})
+	ty := cty.Object(map[string]cty.Type{"id": cty.String})
+
+	for name, identity := range map[string]cty.Value{
+		"composite": identity,
+		"none":      cty.NilVal,
+	} {
+		t.Run(name, func(t *testing.T) {
+			change := Change{
+				Action:    NoOp,
+				Before:    cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal("a")}),
+				After:     cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal("a")}),
+				Importing: &Importing{ID: "a", Identity: identity},
+			}
+
+			encoded, err := change.Encode(ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := len(encoded.Importing.Identity) > 0, identity != cty.NilVal; got != want {
+				t.Fatalf("encoded identity present is %t; want %t", got, want)
+			}
+
+			decoded, err := encoded.Decode(ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := decoded.Importing.Identity; !identity.RawEquals(got) {
+				t.Fatalf("wrong identity\ngot:  %#v\nwant: %#v", got, identity)
+			}
+		})
+	}
+}
+
+func TestIgnoreChangesEqual(t *testing.T) {
+	foo := cty.GetAttrPath("foo")
+	bar := cty.GetAttrPath("bar")
+
+	tests := map[string]struct {
+		a, b *IgnoreChanges
+		want bool
+	}{
+		"both nil": {
+			nil, nil, true,
+		},
+		"one nil": {
+			&IgnoreChanges{}, nil, false,
+		},
+		"same paths in different order": {
+			&IgnoreChanges{Paths: cty.NewPathSet(foo, bar)},
+			&IgnoreChanges{Paths: cty.NewPathSet(bar, foo)},
+			true,
+		},
+		"different paths": {
+			&IgnoreChanges{Paths: cty.NewPathSet(foo)},
+			&IgnoreChanges{Paths: cty.NewPathSet(bar)},
+			false,
+		},
+		"all": {
+			&IgnoreChanges{All: true},
+			&IgnoreChanges{},
+			false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.a.Equal(test.b); got != test.want {
+				t.Errorf("wrong result %t; want %t", got, test.want)
+			}
+			if got := test.b.Equal(test.a); got != test.want {
+				t.Errorf("wrong result %t in reverse; want %t", got, test.want)
+			}
+		})
+	}
+}
@@ -322,6 +322,20 @@ func resourceChangeFromTfplan(rawChange *planproto.ResourceInstanceChange) (*pla
 		ret.RequiredReplace.Add(path)
 	}
 
+	if rawChange.IgnoreChanges != nil {
+		ret.IgnoreChanges = &plans.IgnoreChanges{
+			All:   rawChange.IgnoreChanges.All,
+			Paths: cty.NewPathSet(),
+		}
+		for _, p := range rawChange.IgnoreChanges.Paths {
+			path, err := pathFromTfplan(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path in ignore changes: %w", err)
+			}
+			ret.IgnoreChanges.Paths.Add(path)
+		}
+	}
+
 	change, err := changeFromTfplan(rawChange.Change)
 	if err != nil {
 		return nil, fmt.Errorf("invalid plan for resource %s: %w", ret.Addr, err)
@@ -445,6 +459,13 @@ func changeFromTfplan(rawChange *planproto.Change) (*plans.ChangeSrc, error) {
 		ret.Importing = &plans.ImportingSrc{
 			ID: rawChange.Importing.Id,
 		}
+		if rawChange.Importing.Identity != nil {
+			identity, err := valueFromTfplan(rawChange.Importing.Identity)
+			if err != nil {
+				return nil, fmt.Errorf("invalid import identity: %w", err)
+			}
+			ret.Importing.Identity = identity
+		}
 	}
 	ret.GeneratedConfig = rawChange.GeneratedConfig
 
@@ -723,6 +744,21 @@ func resourceChangeToTfplan(change *plans.ResourceInstanceChangeSrc) (*planproto
 		ret.RequiredReplace = append(ret.RequiredReplace, path)
 	}
 
+	if change.IgnoreChanges != nil {
+		ignorePaths := change.IgnoreChanges.Paths.List()
+		ret.IgnoreChanges = &planproto.IgnoreChanges{
+			All:   change.IgnoreChanges.All,
+			Paths: make([]*planproto.Path, 0, len(ignorePaths)),
+		}
+		for _, p := range ignorePaths {
+			path, err := pathToTfplan(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path in ignore changes: %w", err)
+			}
+			ret.IgnoreChanges.Paths = append(ret.IgnoreChanges.Paths, path)
+		}
+	}
+
 	valChange, err := changeToTfplan(&change.ChangeSrc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize resource %s change: %w", change.Addr, err)
@@ -790,7 +826,9 @@ func changeToTfplan(change *plans.ChangeSrc) (*planproto.Change, error) {
 		ret.Importing = &planproto.Importing{
 			Id: change.Importing.ID,
 		}
-
+		if len(change.Importing.Identity) > 0 {
+			ret.Importing.Identity = valueToTfplan(change.Importing.Identity)
+		}
 	}
 	ret.GeneratedConfig = change.GeneratedConfig
 
@@ -99,6 +99,11 @@ func TestTFPlanRoundTrip(t *testing.T) {
 					RequiredReplace: cty.NewPathSet(
 						cty.GetAttrPath("boop"),
 					),
+					IgnoreChanges: &plans.IgnoreChanges{
+						Paths: cty.NewPathSet(
+							cty.GetAttrPath("tags").IndexString("owner"),
+						),
+					},
 					ActionReason: plans.ResourceInstanceReplaceBecauseCannotUpdate,
 				},
 				{
@@ -123,6 +128,10 @@ func TestTFPlanRoundTrip(t *testing.T) {
 							"id": cty.StringVal("bar-baz-foo"),
 						}), objTy),
 					},
+					IgnoreChanges: &plans.IgnoreChanges{
+						All:   true,
+						Paths: cty.NewPathSet(),
+					},
 				},
 				{
 					Addr: addrs.Resource{
@@ -173,6 +182,38 @@ func TestTFPlanRoundTrip(t *testing.T) {
 						GeneratedConfig: "resource \\\"test_thing\\\" \\\"importing\\\" {}",
 					},
 				},
+				{
+					Addr: addrs.Resource{
+						Mode: addrs.ManagedResourceMode,
+						Type: "test_thing",
+						Name: "importing",
+					}.Instance(addrs.IntKey(2)).Absolute(addrs.RootModuleInstance),
+					PrevRunAddr: addrs.Resource{
+						Mode: addrs.ManagedResourceMode,
+						Type: "test_thing",
+						Name: "importing",
+					}.Instance(addrs.IntKey(2)).Absolute(addrs.RootModuleInstance),
+					ProviderAddr: addrs.AbsProviderConfig{
+						Provider: addrs.NewDefaultProvider("test"),
+						Module:   addrs.RootModule,
+					},
+					ChangeSrc: plans.ChangeSrc{
+						Action: plans.NoOp,
+						Before: mustNewDynamicValue(cty.ObjectVal(map[string]cty.Value{
+							"id": cty.StringVal("region-a/composite"),
+						}), objTy),
+						After: mustNewDynamicValue(cty.ObjectVal(map[string]cty.Value{
+							"id": cty.StringVal("region-a/composite"),
+						}), objTy),
+						Importing: &plans.ImportingSrc{
+							ID: "region-a/composite",
+							Identity: mustNewDynamicValue(cty.ObjectVal(map[string]cty.Value{
+								"region": cty.StringVal("region-a"),
+								"name":   cty.StringVal("composite"),
+							}), cty.DynamicPseudoType),
+						},
+					},
+				},
 			},
 		},
 		DriftedResources: []*plans.ResourceInstanceChangeSrc{
@@ -31,6 +31,24 @@ import (
 // ApplyMoves expects exclusive access to the given state while it's running.
 // Don't read or write any part of the state structure until ApplyMoves returns.
 func ApplyMoves(stmts []MoveStatement, state *states.State) MoveResults {
+	return ApplyMovesWithResolver(stmts, state, nil)
+}
+
+// MoveResolver is a function that can choose a different destination for an
+// object that a move statement matches, for ApplyMovesWithResolver.
+//
+// It is called with the address of the module instance, resource or resource
+// instance that is about to move, and returns the address to move it to and
+// true, or false to use the destination from the move statement. The result
+// must be the same kind of address as the given one, or it is ignored.
+// Returning the given address leaves the object where it is.
+type MoveResolver func(from addrs.AbsMoveable) (addrs.AbsMoveable, bool)
+
+// ApplyMovesWithResolver is like ApplyMoves, but calls the given resolver,
+// if it isn't nil, for each object that one of the statements matches so
+// that the caller can choose where it moves to, such as to resolve a move
+// that would otherwise be blocked by an existing object at its destination.
+func ApplyMovesWithResolver(stmts []MoveStatement, state *states.State, resolver MoveResolver) MoveResults {
 	ret := makeMoveResults()
 
 	if len(stmts) == 0 {
@@ -105,6 +123,11 @@ func ApplyMoves(stmts []MoveStatement, state *states.State) MoveResults {
 				// For a module endpoint we just try the module address
 				// directly, and execute the moves if it matches.
 				if newAddr, matches := modAddr.MoveDestination(stmt.From, stmt.To); matches {
+					newAddr = resolveMove(resolver, modAddr, newAddr)
+					if newAddr.Equal(modAddr) {
+						log.Printf("[TRACE] refactoring.ApplyMoves: %s stays where it is, as resolved", modAddr)
+						continue
+					}
 					log.Printf("[TRACE] refactoring.ApplyMoves: %s has moved to %s", modAddr, newAddr)
 
 					// If we already have a module at the new address then
@@ -143,6 +166,11 @@ func ApplyMoves(stmts []MoveStatement, state *states.State) MoveResults {
 				for _, rs := range ms.Resources {
 					rAddr := rs.Addr
 					if newAddr, matches := rAddr.MoveDestination(stmt.From, stmt.To); matches {
+						newAddr = resolveMove(resolver, rAddr, newAddr)
+						if newAddr.Equal(rAddr) {
+							log.Printf("[TRACE] refactoring.ApplyMoves: resource %s stays where it is, as resolved", rAddr)
+							continue
+						}
 						log.Printf("[TRACE] refactoring.ApplyMoves: resource %s has moved to %s", rAddr, newAddr)
 
 						// If we already have a resource at the new address then
@@ -165,6 +193,11 @@ func ApplyMoves(stmts []MoveStatement, state *states.State) MoveResults {
 					for key := range rs.Instances {
 						iAddr := rAddr.Instance(key)
 						if newAddr, matches := iAddr.MoveDestination(stmt.From, stmt.To); matches {
+							newAddr = resolveMove(resolver, iAddr, newAddr)
+							if newAddr.Equal(iAddr) {
+								log.Printf("[TRACE] refactoring.ApplyMoves: resource instance %s stays where it is, as resolved", iAddr)
+								continue
+							}
 							log.Printf("[TRACE] refactoring.ApplyMoves: resource instance %s has moved to %s", iAddr, newAddr)
 
 							// If we already have a resource instance at the new
@@ -192,6 +225,25 @@ func ApplyMoves(stmts []MoveStatement, state *states.State) MoveResults {
 	return ret
 }
 
+// resolveMove returns the destination that the given resolver chooses for
+// the object at the given address, or the given destination if the resolver
+// is nil, doesn't choose one, or chooses a different kind of address.
+func resolveMove[T addrs.AbsMoveable](resolver MoveResolver, from addrs.AbsMoveable, to T) T {
+	if resolver == nil {
+		return to
+	}
+	got, ok := resolver(from)
+	if !ok {
+		return to
+	}
+	ret, ok := got.(T)
+	if !ok {
+		log.Printf("[WARN] refactoring.ApplyMoves: ignoring resolved destination %s for %s, because it's not the same kind of address", got, from)
+		return to
+	}
+	return ret
+}
+
 // buildMoveStatementGraph constructs a dependency graph of the given move
 // statements, where the nodes are all pointers to statements in the given
 // slice and the edges represent either chaining or nesting relationships.
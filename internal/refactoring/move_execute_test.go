@@ -667,6 +667,92 @@ func TestApplyMoves(t *testing.T) {
 	}
 }
 
+func TestApplyMovesWithResolver(t *testing.T) {
+	providerAddr := addrs.AbsProviderConfig{
+		Module:   addrs.RootModule,
+		Provider: addrs.MustParseProviderSourceString("example.com/foo/bar"),
+	}
+	mustParseInstAddr := func(s string) addrs.AbsResourceInstance {
+		addr, err := addrs.ParseAbsResourceInstanceStr(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+
+	state := states.BuildState(func(s *states.SyncState) {
+		for _, addr := range []string{"module.a.foo.x", "foo.from[0]", "foo.from[1]", "foo.to[0]", "bar.from"} {
+			s.SetResourceInstanceCurrent(
+				mustParseInstAddr(addr),
+				&states.ResourceInstanceObjectSrc{
+					Status:    states.ObjectReady,
+					AttrsJSON: []byte(`{}`),
+				},
+				providerAddr,
+				addrs.NoKey,
+			)
+		}
+	})
+	stmts := []MoveStatement{
+		testMoveStatement(t, "", "module.a", "module.b"),
+		testMoveStatement(t, "", "foo.from[0]", "foo.to[0]"),
+		testMoveStatement(t, "", "foo.from[1]", "foo.to[1]"),
+		testMoveStatement(t, "", "bar.from", "bar.to"),
+	}
+
+	var resolved []string
+	gotResults := ApplyMovesWithResolver(stmts, state, func(from addrs.AbsMoveable) (addrs.AbsMoveable, bool) {
+		resolved = append(resolved, from.String())
+		switch from.String() {
+		case "module.a":
+			return addrs.RootModuleInstance.Child("c", addrs.NoKey), true
+		case "foo.from[0]":
+			// The statement's destination is taken, so we choose another.
+			return mustParseInstAddr("foo.to[2]"), true
+		case "foo.from[1]":
+			return from, true
+		case "bar.from":
+			// A resource can't move to a module, so this is ignored.
+			return addrs.RootModuleInstance.Child("c", addrs.NoKey), true
+		}
+		return nil, false
+	})
+
+	sort.Strings(resolved)
+	if diff := cmp.Diff([]string{"bar.from", "foo.from[0]", "foo.from[1]", "module.a"}, resolved); diff != "" {
+		t.Errorf("wrong resolver calls\n%s", diff)
+	}
+
+	var gotMoves []string
+	for _, elem := range gotResults.Changes.Elems {
+		gotMoves = append(gotMoves, fmt.Sprintf("%s -> %s", elem.Value.From, elem.Value.To))
+	}
+	sort.Strings(gotMoves)
+	wantMoves := []string{
+		"bar.from -> bar.to",
+		"foo.from[0] -> foo.to[2]",
+		"module.a.foo.x -> module.c.foo.x",
+	}
+	if diff := cmp.Diff(wantMoves, gotMoves); diff != "" {
+		t.Errorf("wrong moves\n%s", diff)
+	}
+	if gotResults.Blocked.Len() != 0 {
+		t.Errorf("unexpected blocked moves: %s", spew.Sdump(gotResults.Blocked.Elems))
+	}
+
+	gotInstAddrs := allResourceInstanceAddrsInState(state)
+	wantInstAddrs := []string{
+		"bar.to",
+		"foo.from[1]",
+		"foo.to[0]",
+		"foo.to[2]",
+		"module.c.foo.x",
+	}
+	if diff := cmp.Diff(wantInstAddrs, gotInstAddrs); diff != "" {
+		t.Errorf("wrong resource instances in final state\n%s", diff)
+	}
+}
+
 func testMoveStatement(t *testing.T, module string, from string, to string) MoveStatement {
 	t.Helper()
 
@@ -43,6 +43,12 @@ type State struct {
 	// created by a version of OpenTofu that didn't yet support checks
 	// then this field will be nil.
 	CheckResults *CheckResults
+
+	// RunMetadata describes the run that most recently applied changes to
+	// this state, such as a run ID, the actor who started it, or a link to
+	// a CI job, as arbitrary keys and string values. It is nil if the most
+	// recent apply recorded no metadata.
+	RunMetadata map[string]string
 }
 
 // NewState constructs a minimal empty state, containing an empty root module.
@@ -389,6 +395,27 @@ func (s *State) PruneResourceHusks() {
 	}
 }
 
+// PreviewPruneResourceHusks returns the addresses of the resources that
+// PruneResourceHusks would remove from the receiving state, sorted by
+// address, without modifying the state.
+//
+// This method MUST NOT be called concurrently with writers of the receiving
+// state.
+func (s *State) PreviewPruneResourceHusks() []addrs.AbsResource {
+	var ret []addrs.AbsResource
+	for _, m := range s.Modules {
+		for _, rs := range m.Resources {
+			if len(rs.Instances) == 0 {
+				ret = append(ret, rs.Addr)
+			}
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Less(ret[j])
+	})
+	return ret
+}
+
 // SyncWrapper returns a SyncState object wrapping the receiver.
 func (s *State) SyncWrapper() *SyncState {
 	return &SyncState{
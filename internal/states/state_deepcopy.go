@@ -33,9 +33,17 @@ func (s *State) DeepCopy() *State {
 	for k, m := range s.Modules {
 		modules[k] = m.DeepCopy()
 	}
+	var runMetadata map[string]string
+	if s.RunMetadata != nil {
+		runMetadata = make(map[string]string, len(s.RunMetadata))
+		for k, v := range s.RunMetadata {
+			runMetadata[k] = v
+		}
+	}
 	return &State{
 		Modules:      modules,
 		CheckResults: s.CheckResults.DeepCopy(),
+		RunMetadata:  runMetadata,
 	}
 }
 
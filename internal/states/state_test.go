@@ -427,6 +427,52 @@ func TestStateHasResourceInstanceObjects(t *testing.T) {
 
 }
 
+func TestStatePreviewPruneResourceHusks(t *testing.T) {
+	providerConfig := addrs.AbsProviderConfig{
+		Module:   addrs.RootModule,
+		Provider: addrs.MustParseProviderSourceString("test/test"),
+	}
+
+	state := NewState()
+	root := state.RootModule()
+	root.SetResourceProvider(mustAbsResourceAddr("test.husk").Resource, providerConfig)
+	root.SetResourceInstanceCurrent(
+		mustAbsResourceAddr("test.kept").Resource.Instance(addrs.NoKey),
+		&ResourceInstanceObjectSrc{
+			AttrsJSON: []byte(`{}`),
+			Status:    ObjectReady,
+		},
+		providerConfig,
+		addrs.NoKey,
+	)
+	child := state.EnsureModule(addrs.RootModuleInstance.Child("child", addrs.NoKey))
+	child.SetResourceProvider(mustAbsResourceAddr("test.husk").Resource, providerConfig)
+
+	got := state.PreviewPruneResourceHusks()
+	want := []addrs.AbsResource{
+		mustAbsResourceAddr("test.husk"),
+		mustAbsResourceAddr("module.child.test.husk"),
+	}
+	for _, problem := range deep.Equal(got, want) {
+		t.Errorf("wrong preview: %s", problem)
+	}
+
+	// The preview must not modify the state, and must match what
+	// PruneResourceHusks then actually removes.
+	if state.Resource(mustAbsResourceAddr("test.husk")) == nil {
+		t.Fatal("preview removed test.husk")
+	}
+	state.PruneResourceHusks()
+	for _, addr := range want {
+		if state.Resource(addr) != nil {
+			t.Errorf("%s was not pruned", addr)
+		}
+	}
+	if state.Resource(mustAbsResourceAddr("test.kept")) == nil {
+		t.Error("test.kept was pruned")
+	}
+}
+
 func TestState_MoveAbsResource(t *testing.T) {
 	// Set up a starter state for the embedded tests, which should start from a copy of this state.
 	state := NewState()
@@ -353,6 +353,10 @@ func prepareStateV4(sV4 *stateV4) (*File, tfdiags.Diagnostics) {
 		diags = diags.Append(moreDiags)
 	}
 
+	if len(sV4.RunMetadata) > 0 {
+		state.RunMetadata = sV4.RunMetadata
+	}
+
 	file.State = state
 	return file, diags
 }
@@ -477,6 +481,9 @@ func writeStateV4(file *File, w io.Writer, enc encryption.StateEncryption) tfdia
 	}
 
 	sV4.CheckResults = encodeCheckResultsV4(file.State.CheckResults)
+	if len(file.State.RunMetadata) > 0 {
+		sV4.RunMetadata = file.State.RunMetadata
+	}
 
 	sV4.normalize()
 
@@ -748,6 +755,12 @@ type stateV4 struct {
 	RootOutputs      map[string]outputStateV4 `json:"outputs"`
 	Resources        []resourceStateV4        `json:"resources"`
 	CheckResults     []checkResultsV4         `json:"check_results"`
+
+	// RunMetadata was added to format version 4 after its initial release,
+	// so it's optional and omitted when empty: states without run metadata
+	// are written exactly as before, and older versions of OpenTofu ignore
+	// the property when reading a state that has it.
+	RunMetadata map[string]string `json:"run_metadata,omitempty"`
 }
 
 // normalize makes some in-place changes to normalize the way items are
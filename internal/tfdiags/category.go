@@ -0,0 +1,96 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tfdiags
+
+// Category is a broad classification of what caused a diagnostic, which
+// callers can use to group or filter diagnostics in their UI.
+type Category string
+
+const (
+	// CategoryNone is the zero value of Category, used for diagnostics that
+	// have not been classified.
+	CategoryNone Category = ""
+
+	// CategoryProvider classifies diagnostics returned by a provider.
+	CategoryProvider Category = "provider-error"
+
+	// CategoryConfig classifies diagnostics caused by a problem in the
+	// configuration.
+	CategoryConfig Category = "config-error"
+
+	// CategoryCore classifies diagnostics caused by a problem inside
+	// OpenTofu Core itself, such as a failure to build a graph.
+	CategoryCore Category = "core-error"
+
+	// CategoryHook classifies diagnostics caused by a hook that was
+	// registered by the caller.
+	CategoryHook Category = "hook-error"
+)
+
+// DiagnosticExtraCategorized is an interface implemented by values in the
+// Extra field of Diagnostic when the diagnostic has been classified using
+// Categorize.
+type DiagnosticExtraCategorized interface {
+	// DiagnosticCategory returns the category of the associated diagnostic.
+	DiagnosticCategory() Category
+}
+
+// DiagnosticCategory returns the category that the given diagnostic was
+// classified into, or CategoryNone if it hasn't been classified.
+func DiagnosticCategory(diag Diagnostic) Category {
+	maybe := ExtraInfo[DiagnosticExtraCategorized](diag)
+	if maybe == nil {
+		return CategoryNone
+	}
+	return maybe.DiagnosticCategory()
+}
+
+// Categorize returns a copy of the given diagnostics where each diagnostic
+// that has no category yet is classified into the given category.
+//
+// Diagnostics that were already classified keep their existing category, so
+// that the code closest to the cause of a problem can classify it more
+// precisely than a caller further up the stack.
+func Categorize(diags Diagnostics, category Category) Diagnostics {
+	if len(diags) == 0 {
+		return diags
+	}
+
+	ret := make(Diagnostics, len(diags))
+	for i, diag := range diags {
+		if DiagnosticCategory(diag) != CategoryNone {
+			ret[i] = diag
+			continue
+		}
+		ret[i] = Override(diag, diag.Severity(), func() DiagnosticExtraWrapper {
+			return &categoryExtra{category: category}
+		})
+	}
+	return ret
+}
+
+// categoryExtra is the extra info used by Categorize, which wraps any extra
+// info the original diagnostic already had.
+type categoryExtra struct {
+	category Category
+	wrapped  interface{}
+}
+
+var _ DiagnosticExtraCategorized = (*categoryExtra)(nil)
+var _ DiagnosticExtraWrapper = (*categoryExtra)(nil)
+var _ DiagnosticExtraUnwrapper = (*categoryExtra)(nil)
+
+func (e *categoryExtra) DiagnosticCategory() Category {
+	return e.category
+}
+
+func (e *categoryExtra) WrapDiagnosticExtra(inner interface{}) {
+	e.wrapped = inner
+}
+
+func (e *categoryExtra) UnwrapDiagnosticExtra() interface{} {
+	return e.wrapped
+}
@@ -0,0 +1,55 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tfdiags
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestCategorize(t *testing.T) {
+	var diags Diagnostics
+	diags = diags.Append(errors.New("native"))
+	diags = diags.Append(Sourceless(Warning, "sourceless", "detail"))
+	diags = diags.Append(&hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "hcl",
+		Extra:    "extra",
+	})
+	diags = diags.Append(Categorize(diags.Append(errors.New("provider")), CategoryProvider)[3])
+
+	got := Categorize(diags, CategoryCore)
+	if len(got) != len(diags) {
+		t.Fatalf("wrong number of diagnostics %d; want %d", len(got), len(diags))
+	}
+
+	wantCategories := []Category{CategoryCore, CategoryCore, CategoryCore, CategoryProvider}
+	for i, diag := range got {
+		if got, want := DiagnosticCategory(diag), wantCategories[i]; got != want {
+			t.Errorf("wrong category for diagnostic %d: got %q, want %q", i, got, want)
+		}
+		if got, want := diag.Severity(), diags[i].Severity(); got != want {
+			t.Errorf("wrong severity for diagnostic %d: got %s, want %s", i, got, want)
+		}
+		if got, want := diag.Description(), diags[i].Description(); !got.Equal(want) {
+			t.Errorf("wrong description for diagnostic %d: got %#v, want %#v", i, got, want)
+		}
+	}
+
+	// The original extra info must still be reachable.
+	if got := ExtraInfo[string](got[2]); got != "extra" {
+		t.Errorf("wrong wrapped extra info %q", got)
+	}
+
+	// The given diagnostics must not be modified.
+	for i, diag := range diags[:3] {
+		if got := DiagnosticCategory(diag); got != CategoryNone {
+			t.Errorf("original diagnostic %d was modified to have category %q", i, got)
+		}
+	}
+}
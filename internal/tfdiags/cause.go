@@ -0,0 +1,75 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tfdiags
+
+// DiagnosticExtraCausedBy is an interface implemented by values in the
+// Extra field of Diagnostic when the diagnostic has been associated with
+// an underlying Go error using WithCause.
+type DiagnosticExtraCausedBy interface {
+	// DiagnosticCause returns the error that caused the associated
+	// diagnostic.
+	DiagnosticCause() error
+}
+
+// DiagnosticCause returns the Go error that caused the given diagnostic, or
+// nil if there is none.
+//
+// A diagnostic has a cause either if it was created by appending a plain
+// error to Diagnostics, or if it was associated with an error using
+// WithCause.
+func DiagnosticCause(diag Diagnostic) error {
+	if maybe := ExtraInfo[DiagnosticExtraCausedBy](diag); maybe != nil {
+		return maybe.DiagnosticCause()
+	}
+	// A native error may have been overridden, such as by Categorize, and
+	// so we need to look through any overrides to find it.
+	for {
+		switch d := diag.(type) {
+		case nativeError:
+			return d.err
+		case overriddenDiagnostic:
+			diag = d.original
+		default:
+			return nil
+		}
+	}
+}
+
+// WithCause returns a copy of the given diagnostic that is associated with
+// the given error as its cause, so that callers can recognize the problem
+// without relying on the diagnostic's message.
+//
+// The error returned by Diagnostics.Err unwraps to the causes of all of its
+// diagnostics, and so callers can use errors.Is and errors.As to match
+// them.
+func WithCause(diag Diagnostic, err error) Diagnostic {
+	return Override(diag, diag.Severity(), func() DiagnosticExtraWrapper {
+		return &causeExtra{cause: err}
+	})
+}
+
+// causeExtra is the extra info used by WithCause, which wraps any extra
+// info the original diagnostic already had.
+type causeExtra struct {
+	cause   error
+	wrapped interface{}
+}
+
+var _ DiagnosticExtraCausedBy = (*causeExtra)(nil)
+var _ DiagnosticExtraWrapper = (*causeExtra)(nil)
+var _ DiagnosticExtraUnwrapper = (*causeExtra)(nil)
+
+func (e *causeExtra) DiagnosticCause() error {
+	return e.cause
+}
+
+func (e *causeExtra) WrapDiagnosticExtra(inner interface{}) {
+	e.wrapped = inner
+}
+
+func (e *causeExtra) UnwrapDiagnosticExtra() interface{} {
+	return e.wrapped
+}
@@ -0,0 +1,47 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tfdiags
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWithCause(t *testing.T) {
+	errCause := errors.New("cause")
+	errNative := errors.New("native")
+	errOther := errors.New("other")
+
+	var diags Diagnostics
+	diags = diags.Append(WithCause(Sourceless(Error, "caused", "detail"), errCause))
+	diags = diags.Append(errNative)
+	diags = diags.Append(Sourceless(Warning, "uncaused", "detail"))
+	diags = Categorize(diags, CategoryCore)
+
+	if got, want := DiagnosticCause(diags[0]), errCause; got != want {
+		t.Errorf("wrong cause for first diagnostic: got %v, want %v", got, want)
+	}
+	if got := DiagnosticCause(diags[2]); got != nil {
+		t.Errorf("unexpected cause for third diagnostic: %v", got)
+	}
+	if got, want := DiagnosticCategory(diags[0]), CategoryCore; got != want {
+		t.Errorf("cause lost category: got %q, want %q", got, want)
+	}
+	if got, want := diags[0].Description().Summary, "caused"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+
+	err := diags.Err()
+	if !errors.Is(err, errCause) {
+		t.Errorf("error does not match cause")
+	}
+	if !errors.Is(err, errNative) {
+		t.Errorf("error does not match native error")
+	}
+	if errors.Is(err, errOther) {
+		t.Errorf("error matches unrelated error")
+	}
+}
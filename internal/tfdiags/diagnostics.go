@@ -259,6 +259,19 @@ func (dae diagnosticsAsError) WrappedErrors() []error {
 	return errs
 }
 
+// Unwrap returns the causes of any of the diagnostics that have one, as
+// reported by DiagnosticCause, so that errors.Is and errors.As can match
+// them.
+func (dae diagnosticsAsError) Unwrap() []error {
+	var errs []error
+	for _, diag := range dae.Diagnostics {
+		if err := DiagnosticCause(diag); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
 // NonFatalError is a special error type, returned by
 // Diagnostics.ErrWithWarnings and Diagnostics.NonFatalErr,
 // that indicates that the wrapped diagnostics should be treated as non-fatal.
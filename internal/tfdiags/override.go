@@ -5,6 +5,10 @@
 
 package tfdiags
 
+import (
+	"github.com/hashicorp/hcl/v2"
+)
+
 // overriddenDiagnostic implements the Diagnostic interface by wrapping another
 // Diagnostic while overriding the severity of the original Diagnostic.
 type overriddenDiagnostic struct {
@@ -14,6 +18,7 @@ type overriddenDiagnostic struct {
 }
 
 var _ Diagnostic = overriddenDiagnostic{}
+var _ contextualFromConfigBody = overriddenDiagnostic{}
 
 // OverrideAll accepts a set of Diagnostics and wraps them with a new severity
 // and, optionally, a new ExtraInfo.
@@ -45,15 +50,19 @@ func Override(original Diagnostic, severity Severity, createExtra func() Diagnos
 // UndoOverride will return the original diagnostic that was overridden within
 // the OverrideAll function.
 //
-// If the provided Diagnostic was never overridden then it is simply returned
-// unchanged.
+// If the provided Diagnostic was overridden more than once then this undoes
+// all of the overrides. If it was never overridden then it is simply
+// returned unchanged.
 func UndoOverride(diag Diagnostic) Diagnostic {
-	if override, ok := diag.(overriddenDiagnostic); ok {
-		return override.original
+	for {
+		override, ok := diag.(overriddenDiagnostic)
+		if !ok {
+			// Then it isn't overridden, so we'll just return the diag
+			// unchanged.
+			return diag
+		}
+		diag = override.original
 	}
-
-	// Then it wasn't overridden, so we'll just return the diag unchanged.
-	return diag
 }
 
 func (o overriddenDiagnostic) Severity() Severity {
@@ -75,3 +84,13 @@ func (o overriddenDiagnostic) FromExpr() *FromExpr {
 func (o overriddenDiagnostic) ExtraInfo() interface{} {
 	return o.extra
 }
+
+// ElaborateFromConfigBody elaborates the original diagnostic if it is a
+// contextual diagnostic, keeping the overridden severity and extra info, so
+// that overriding a diagnostic doesn't prevent placing it in context later.
+func (o overriddenDiagnostic) ElaborateFromConfigBody(body hcl.Body, addr string) Diagnostic {
+	if cd, ok := o.original.(contextualFromConfigBody); ok {
+		o.original = cd.ElaborateFromConfigBody(body, addr)
+	}
+	return o
+}
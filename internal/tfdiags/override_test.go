@@ -9,6 +9,8 @@ import (
 	"testing"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestOverride_UpdatesSeverity(t *testing.T) {
@@ -66,6 +68,16 @@ func TestUndoOverride(t *testing.T) {
 	}
 }
 
+func TestUndoOverride_Nested(t *testing.T) {
+	original := Sourceless(Error, "summary", "detail")
+	override := Override(Override(original, Warning, nil), Warning, nil)
+	restored := UndoOverride(override)
+
+	if restored.Severity() != Error {
+		t.Errorf("expected error but was %s", restored.Severity())
+	}
+}
+
 func TestUndoOverride_NotOverridden(t *testing.T) {
 	original := Sourceless(Error, "summary", "detail")
 	restored := UndoOverride(original) // Shouldn't do anything bad.
@@ -75,6 +87,26 @@ func TestUndoOverride_NotOverridden(t *testing.T) {
 	}
 }
 
+func TestOverride_ElaboratesFromConfigBody(t *testing.T) {
+	f, parseDiags := hclsyntax.ParseConfig([]byte("simple_attr = \"val\"\n"), "test.tf", hcl.Pos{Line: 1, Column: 1})
+	if len(parseDiags) != 0 {
+		t.Fatal(parseDiags)
+	}
+
+	original := AttributeValue(Warning, "summary", "detail", cty.GetAttrPath("simple_attr"))
+	var diags Diagnostics
+	diags = diags.Append(Override(original, Error, nil))
+	diags = diags.InConfigBody(f.Body, "")
+
+	if got := diags[0].Severity(); got != Error {
+		t.Errorf("expected error but was %s", got)
+	}
+	subject := diags[0].Source().Subject
+	if subject == nil || subject.Start.Column != 15 {
+		t.Errorf("override was not elaborated in config body; subject is %#v", subject)
+	}
+}
+
 type extraWrapper struct {
 	mine     string
 	original interface{}
@@ -0,0 +1,74 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// withAddressRenames returns a copy of the given plan whose prior state has
+// each resource instance that is a key of the given map moved to the
+// corresponding address, for ApplyOpts.AddressRenames.
+//
+// It returns errors, and the given plan unchanged, if any of the renames
+// could conflict with another rename, with an existing object, or with a
+// change in the plan.
+func withAddressRenames(plan *plans.Plan, renames addrs.Map[addrs.AbsResourceInstance, addrs.AbsResourceInstance]) (*plans.Plan, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	planned := addrs.MakeSet[addrs.AbsResourceInstance]()
+	for _, rc := range plan.Changes.Resources {
+		if rc.Action != plans.NoOp {
+			planned.Add(rc.Addr)
+		}
+	}
+
+	targets := addrs.MakeSet[addrs.AbsResourceInstance]()
+	for _, elem := range renames.Elems {
+		from, to := elem.Key, elem.Value
+		invalid := func(detail string, args ...any) {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid address rename",
+				fmt.Sprintf("Cannot rename %s to %s: %s.", from, to, fmt.Sprintf(detail, args...)),
+			))
+		}
+
+		switch {
+		case from.Resource.Resource.Mode != addrs.ManagedResourceMode || to.Resource.Resource.Mode != addrs.ManagedResourceMode:
+			invalid("only managed resource instances can be renamed")
+		case from.Resource.Resource.Type != to.Resource.Resource.Type:
+			invalid("the resource types %q and %q are different", from.Resource.Resource.Type, to.Resource.Resource.Type)
+		case plan.PriorState.ResourceInstance(from) == nil:
+			invalid("there is no object for %s in the prior state", from)
+		case plan.PriorState.ResourceInstance(to) != nil:
+			invalid("there is already an object for %s in the prior state", to)
+		case renames.Has(to):
+			invalid("%s is also renamed", to)
+		case targets.Has(to):
+			invalid("another resource instance is also renamed to %s", to)
+		case planned.Has(from) || planned.Has(to):
+			invalid("the plan includes changes for these resource instances, which were planned using their original addresses")
+		}
+		targets.Add(to)
+	}
+	if diags.HasErrors() {
+		return plan, diags
+	}
+
+	priorState := plan.PriorState.DeepCopy()
+	for _, elem := range renames.Elems {
+		priorState.MoveAbsResourceInstance(elem.Key, elem.Value)
+	}
+
+	ret := *plan
+	ret.PriorState = priorState
+	return &ret, diags
+}
@@ -0,0 +1,43 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+)
+
+// breakpointHook is a Hook used internally during the apply walk to
+// implement ApplyOpts.Breakpoints.
+type breakpointHook struct {
+	NilHook
+
+	breakpoints addrs.Map[addrs.AbsResourceInstance, func(*states.State)]
+	state       *states.SyncState
+}
+
+var _ Hook = (*breakpointHook)(nil)
+
+func (h *breakpointHook) PreApply(addr addrs.AbsResourceInstance, _ states.Generation, _ plans.Action, _, _ cty.Value) (HookAction, error) {
+	callback := h.breakpoints.Get(addr)
+	if callback == nil {
+		return HookActionContinue, nil
+	}
+
+	// Other resource instance operations may be modifying the working state
+	// concurrently, so we take a copy while holding the lock and then run
+	// the callback without it. That way the callback sees a consistent
+	// snapshot and can take as long as it likes without blocking anything
+	// except the operation it's paused.
+	state := h.state.Lock().DeepCopy()
+	h.state.Unlock()
+
+	callback(state)
+	return HookActionContinue, nil
+}
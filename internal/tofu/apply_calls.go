@@ -0,0 +1,287 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	ctymsgpack "github.com/zclconf/go-cty/cty/msgpack"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// applyCallRecord is the serialization of a single ApplyResourceChange call
+// and its response, as written by ApplyOpts.RecordApplyCalls and read by
+// ApplyOpts.PlaybackApplyCalls.
+//
+// A recording is a stream of these records in JSON format, one per line, in
+// the order that the calls completed.
+type applyCallRecord struct {
+	Address  string            `json:"address"`
+	TypeName string            `json:"type_name"`
+	Request  applyCallRequest  `json:"request"`
+	Response applyCallResponse `json:"response"`
+}
+
+type applyCallRequest struct {
+	PriorState     *applyCallValue `json:"prior_state,omitempty"`
+	Config         *applyCallValue `json:"config,omitempty"`
+	PlannedState   *applyCallValue `json:"planned_state,omitempty"`
+	PlannedPrivate []byte          `json:"planned_private,omitempty"`
+}
+
+type applyCallResponse struct {
+	NewState         *applyCallValue       `json:"new_state,omitempty"`
+	Private          []byte                `json:"private,omitempty"`
+	Diagnostics      []applyCallDiagnostic `json:"diagnostics,omitempty"`
+	LegacyTypeSystem bool                  `json:"legacy_type_system,omitempty"`
+}
+
+// applyCallValue is a self-describing serialization of a cty.Value.
+//
+// We use msgpack for the value itself because, unlike JSON, it can represent
+// the unknown values that appear in planned states.
+type applyCallValue struct {
+	Type    json.RawMessage `json:"type"`
+	MsgPack []byte          `json:"msgpack"`
+}
+
+type applyCallDiagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+func encodeApplyCallValue(v cty.Value) (*applyCallValue, error) {
+	if v == cty.NilVal {
+		return nil, nil
+	}
+	ty, err := ctyjson.MarshalType(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ctymsgpack.Marshal(v, v.Type())
+	if err != nil {
+		return nil, err
+	}
+	return &applyCallValue{Type: ty, MsgPack: raw}, nil
+}
+
+func (v *applyCallValue) decode() (cty.Value, error) {
+	if v == nil {
+		return cty.NilVal, nil
+	}
+	ty, err := ctyjson.UnmarshalType(v.Type)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctymsgpack.Unmarshal(v.MsgPack, ty)
+}
+
+func encodeApplyCallDiagnostics(diags tfdiags.Diagnostics) []applyCallDiagnostic {
+	if len(diags) == 0 {
+		return nil
+	}
+	ret := make([]applyCallDiagnostic, len(diags))
+	for i, diag := range diags {
+		desc := diag.Description()
+		severity := "error"
+		if diag.Severity() == tfdiags.Warning {
+			severity = "warning"
+		}
+		ret[i] = applyCallDiagnostic{
+			Severity: severity,
+			Summary:  desc.Summary,
+			Detail:   desc.Detail,
+		}
+	}
+	return ret
+}
+
+func decodeApplyCallDiagnostics(recorded []applyCallDiagnostic) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	for _, diag := range recorded {
+		severity := tfdiags.Error
+		if diag.Severity == "warning" {
+			severity = tfdiags.Warning
+		}
+		diags = diags.Append(tfdiags.Sourceless(severity, diag.Summary, diag.Detail))
+	}
+	return diags
+}
+
+// applyCallRecorder is a ProviderCallMiddleware source that writes each
+// call it observes to an io.Writer, for ApplyOpts.RecordApplyCalls.
+type applyCallRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func newApplyCallRecorder(w io.Writer) *applyCallRecorder {
+	return &applyCallRecorder{enc: json.NewEncoder(w)}
+}
+
+func (r *applyCallRecorder) Middleware(next ProviderCall) ProviderCall {
+	return func(addr addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+		resp := next(addr, req)
+		r.record(addr, req, resp)
+		return resp
+	}
+}
+
+func (r *applyCallRecorder) record(addr addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest, resp providers.ApplyResourceChangeResponse) {
+	rec := applyCallRecord{
+		Address:  addr.String(),
+		TypeName: req.TypeName,
+		Request: applyCallRequest{
+			PlannedPrivate: req.PlannedPrivate,
+		},
+		Response: applyCallResponse{
+			Private:          resp.Private,
+			Diagnostics:      encodeApplyCallDiagnostics(resp.Diagnostics),
+			LegacyTypeSystem: resp.LegacyTypeSystem,
+		},
+	}
+	var errs []error
+	var err error
+	rec.Request.PriorState, err = encodeApplyCallValue(req.PriorState)
+	errs = append(errs, err)
+	rec.Request.Config, err = encodeApplyCallValue(req.Config)
+	errs = append(errs, err)
+	rec.Request.PlannedState, err = encodeApplyCallValue(req.PlannedState)
+	errs = append(errs, err)
+	rec.Response.NewState, err = encodeApplyCallValue(resp.NewState)
+	errs = append(errs, err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		// Once we've failed to write one record the recording is incomplete
+		// anyway, so we don't try to write any more.
+		return
+	}
+	if err := errors.Join(errs...); err != nil {
+		r.err = fmt.Errorf("failed to encode provider call for %s: %w", addr, err)
+		return
+	}
+	if err := r.enc.Encode(rec); err != nil {
+		r.err = fmt.Errorf("failed to write provider call for %s: %w", addr, err)
+	}
+}
+
+// Diagnostics returns an error diagnostic if the recorder was unable to
+// record any of the calls it observed.
+func (r *applyCallRecorder) Diagnostics() tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to record provider calls",
+			fmt.Sprintf("The apply completed, but the recording of its provider calls is incomplete: %s.", r.err),
+		))
+	}
+	return diags
+}
+
+// applyCallPlayback is a ProviderCallMiddleware source that returns
+// responses from an earlier recording instead of calling the provider, for
+// ApplyOpts.PlaybackApplyCalls.
+type applyCallPlayback struct {
+	mu sync.Mutex
+
+	// records are the recorded calls for each resource instance address, in
+	// the order that they were recorded. Each is removed once played back,
+	// so that an instance with more than one call, such as when it is
+	// replaced, receives its responses in the original order.
+	records map[string][]applyCallRecord
+}
+
+// readApplyCallPlayback reads a recording previously written by
+// applyCallRecorder.
+func readApplyCallPlayback(r io.Reader) (*applyCallPlayback, error) {
+	dec := json.NewDecoder(r)
+	ret := &applyCallPlayback{
+		records: make(map[string][]applyCallRecord),
+	}
+	for {
+		var rec applyCallRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret.records[rec.Address] = append(ret.records[rec.Address], rec)
+	}
+}
+
+func (p *applyCallPlayback) Middleware(_ ProviderCall) ProviderCall {
+	return func(addr addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		rec, ok := p.next(addr)
+		if !ok || rec.TypeName != req.TypeName {
+			resp.Diagnostics = resp.Diagnostics.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"No recorded provider response",
+				fmt.Sprintf("The provider call recording has no response for the %s operation on %s.", req.TypeName, addr),
+			))
+			return resp
+		}
+
+		newState, err := rec.Response.NewState.decode()
+		if err != nil {
+			resp.Diagnostics = resp.Diagnostics.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid recorded provider response",
+				fmt.Sprintf("The recorded new state for %s is invalid: %s.", addr, err),
+			))
+			return resp
+		}
+		resp.NewState = newState
+		resp.Private = rec.Response.Private
+		resp.LegacyTypeSystem = rec.Response.LegacyTypeSystem
+		resp.Diagnostics = decodeApplyCallDiagnostics(rec.Response.Diagnostics)
+		return resp
+	}
+}
+
+func (p *applyCallPlayback) next(addr addrs.AbsResourceInstance) (applyCallRecord, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := addr.String()
+	recs := p.records[key]
+	if len(recs) == 0 {
+		return applyCallRecord{}, false
+	}
+	p.records[key] = recs[1:]
+	return recs[0], true
+}
+
+// chainProviderCallMiddleware returns a middleware that applies outer around
+// inner, treating a nil middleware as passing calls straight through.
+func chainProviderCallMiddleware(outer, inner ProviderCallMiddleware) ProviderCallMiddleware {
+	switch {
+	case outer == nil:
+		return inner
+	case inner == nil:
+		return outer
+	default:
+		return func(next ProviderCall) ProviderCall {
+			return outer(inner(next))
+		}
+	}
+}
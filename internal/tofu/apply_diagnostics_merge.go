@@ -0,0 +1,117 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"slices"
+
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// WorkspaceDiagnostics are the diagnostics returned by an apply operation
+// in a particular workspace, for MergeApplyDiagnostics.
+type WorkspaceDiagnostics struct {
+	Workspace   string
+	Diagnostics tfdiags.Diagnostics
+}
+
+// DiagnosticExtraWorkspaces is an interface implemented by values in the
+// Extra field of the diagnostics returned by MergeApplyDiagnostics.
+type DiagnosticExtraWorkspaces interface {
+	// DiagnosticWorkspaces returns the names of the workspaces whose
+	// applies returned the associated diagnostic, in the order they were
+	// given to MergeApplyDiagnostics.
+	DiagnosticWorkspaces() []string
+}
+
+// DiagnosticWorkspaces returns the names of the workspaces whose applies
+// returned the given diagnostic, if it was returned by MergeApplyDiagnostics,
+// or nil otherwise.
+func DiagnosticWorkspaces(diag tfdiags.Diagnostic) []string {
+	if maybe := tfdiags.ExtraInfo[DiagnosticExtraWorkspaces](diag); maybe != nil {
+		return maybe.DiagnosticWorkspaces()
+	}
+	return nil
+}
+
+// MergeApplyDiagnostics combines the diagnostics from the applies in several
+// workspaces into a single report, such as for an orchestrator that applies
+// the same configuration in many workspaces at once.
+//
+// Diagnostics with the same severity, description and source location are
+// reported only once, in the order they first appear, and each is annotated
+// with the names of all of the workspaces that returned it, which callers
+// can retrieve using DiagnosticWorkspaces. Diagnostics that ask not to be
+// consolidated, such as those for check results, are never merged with any
+// others.
+func MergeApplyDiagnostics(results ...WorkspaceDiagnostics) tfdiags.Diagnostics {
+	type merged struct {
+		diag       tfdiags.Diagnostic
+		workspaces []string
+	}
+	var all []*merged
+
+	for _, result := range results {
+		for _, diag := range result.Diagnostics {
+			var existing *merged
+			if !tfdiags.DoNotConsolidateDiagnostic(diag) {
+				for _, m := range all {
+					if sameDiagnostic(m.diag, diag) {
+						existing = m
+						break
+					}
+				}
+			}
+			if existing == nil {
+				all = append(all, &merged{diag: diag, workspaces: []string{result.Workspace}})
+				continue
+			}
+			if !slices.Contains(existing.workspaces, result.Workspace) {
+				existing.workspaces = append(existing.workspaces, result.Workspace)
+			}
+		}
+	}
+
+	var diags tfdiags.Diagnostics
+	for _, m := range all {
+		diags = diags.Append(tfdiags.Override(m.diag, m.diag.Severity(), func() tfdiags.DiagnosticExtraWrapper {
+			return &workspacesExtra{workspaces: m.workspaces}
+		}))
+	}
+	return diags
+}
+
+// sameDiagnostic returns true if the given diagnostics have the same
+// content, using the same comparison as tfdiags.StrictDeduplicateMerge.
+func sameDiagnostic(a, b tfdiags.Diagnostic) bool {
+	if tfdiags.DoNotConsolidateDiagnostic(a) {
+		return false
+	}
+	return a.Severity() == b.Severity() && a.Description().Equal(b.Description()) && a.Source().Equal(b.Source())
+}
+
+// workspacesExtra is the extra info used by MergeApplyDiagnostics, which
+// wraps any extra info the original diagnostic already had.
+type workspacesExtra struct {
+	workspaces []string
+	wrapped    interface{}
+}
+
+var _ DiagnosticExtraWorkspaces = (*workspacesExtra)(nil)
+var _ tfdiags.DiagnosticExtraWrapper = (*workspacesExtra)(nil)
+var _ tfdiags.DiagnosticExtraUnwrapper = (*workspacesExtra)(nil)
+
+func (e *workspacesExtra) DiagnosticWorkspaces() []string {
+	return slices.Clone(e.workspaces)
+}
+
+func (e *workspacesExtra) WrapDiagnosticExtra(inner interface{}) {
+	e.wrapped = inner
+}
+
+func (e *workspacesExtra) UnwrapDiagnosticExtra() interface{} {
+	return e.wrapped
+}
@@ -0,0 +1,88 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+func TestMergeApplyDiagnostics(t *testing.T) {
+	subject := func(line int) *hcl.Range {
+		return &hcl.Range{
+			Filename: "main.tf",
+			Start:    hcl.Pos{Line: line, Column: 1, Byte: 0},
+			End:      hcl.Pos{Line: line, Column: 5, Byte: 4},
+		}
+	}
+	quota := &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Quota exceeded",
+		Detail:   "The account has no more capacity.",
+		Subject:  subject(3),
+	}
+	// The same problem at a different location is a different diagnostic.
+	quotaElsewhere := &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Quota exceeded",
+		Detail:   "The account has no more capacity.",
+		Subject:  subject(7),
+	}
+	deprecated := tfdiags.Sourceless(tfdiags.Warning, "Deprecated attribute", "Use something else.")
+
+	var prod, staging tfdiags.Diagnostics
+	prod = prod.Append(quota)
+	prod = prod.Append(tfdiags.Categorize(tfdiags.Diagnostics(nil).Append(deprecated), tfdiags.CategoryProvider))
+	staging = staging.Append(deprecated)
+	staging = staging.Append(quota)
+	staging = staging.Append(quota)
+	staging = staging.Append(quotaElsewhere)
+
+	diags := MergeApplyDiagnostics(
+		WorkspaceDiagnostics{Workspace: "prod", Diagnostics: prod},
+		WorkspaceDiagnostics{Workspace: "staging", Diagnostics: staging},
+		WorkspaceDiagnostics{Workspace: "empty"},
+	)
+
+	type report struct {
+		Summary    string
+		Line       int
+		Workspaces []string
+	}
+	var got []report
+	for _, diag := range diags {
+		r := report{
+			Summary:    diag.Description().Summary,
+			Workspaces: DiagnosticWorkspaces(diag),
+		}
+		if subject := diag.Source().Subject; subject != nil {
+			r.Line = subject.Start.Line
+		}
+		got = append(got, r)
+	}
+	want := []report{
+		{Summary: "Quota exceeded", Line: 3, Workspaces: []string{"prod", "staging"}},
+		{Summary: "Deprecated attribute", Workspaces: []string{"prod", "staging"}},
+		{Summary: "Quota exceeded", Line: 7, Workspaces: []string{"staging"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong merged diagnostics\n%s", diff)
+	}
+
+	// The annotation wraps, rather than replaces, any extra info that the
+	// first occurrence of each diagnostic already had.
+	if got, want := tfdiags.DiagnosticCategory(diags[1]), tfdiags.CategoryProvider; got != want {
+		t.Errorf("wrong category %q; want %q", got, want)
+	}
+
+	if got := DiagnosticWorkspaces(deprecated); got != nil {
+		t.Errorf("unexpected workspaces for an unmerged diagnostic: %v", got)
+	}
+}
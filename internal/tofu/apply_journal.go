@@ -0,0 +1,424 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/states/statefile"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// applyJournalEntry is the serialization of a single completed operation,
+// as written to the file named by ApplyOpts.JournalPath.
+type applyJournalEntry struct {
+	Address string `json:"address"`
+	Deposed string `json:"deposed,omitempty"`
+	Failed  bool   `json:"failed,omitempty"`
+
+	// State is a state snapshot containing only the resource instance,
+	// with all of its objects, as it was just after the operation, or is
+	// omitted if the operation left the instance with no objects.
+	State json.RawMessage `json:"state,omitempty"`
+}
+
+// applyJournal writes the entries of an apply journal, for
+// ApplyOpts.JournalPath.
+type applyJournal struct {
+	file *os.File
+	enc  encryption.StateEncryption
+
+	mu      sync.Mutex
+	forgets map[applyProgressKey]bool
+	err     error
+
+	// running are the generations of the objects that each resource
+	// instance is currently applying, keyed by address, because
+	// PostApply doesn't report which object an operation was for.
+	running map[string]states.Generation
+}
+
+// openApplyJournal opens the journal file at the given path for appending,
+// creating it if it doesn't already exist, so that an apply resumed from
+// the journal adds its own entries to the same file.
+//
+// If the file ends with an incomplete entry, left by an apply that was
+// terminated while writing it, then that entry is discarded so that the
+// new entries begin on a line of their own.
+func openApplyJournal(path string, changes *plans.Changes, enc encryption.StateEncryption) (*applyJournal, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err == nil {
+		var existing []byte
+		existing, err = io.ReadAll(f)
+		if err == nil && len(existing) > 0 && existing[len(existing)-1] != '\n' {
+			err = f.Truncate(int64(bytes.LastIndexByte(existing, '\n') + 1))
+		}
+		if err != nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to open apply journal",
+			fmt.Sprintf("Couldn't open the apply journal %q: %s.", path, err),
+		))
+		return nil, diags
+	}
+
+	j := &applyJournal{
+		file:    f,
+		enc:     enc,
+		forgets: make(map[applyProgressKey]bool),
+		running: make(map[string]states.Generation),
+	}
+	for _, rc := range plannedForgets(changes) {
+		j.forgets[applyProgressKey{rc.Addr.String(), rc.DeposedKey.Generation()}] = true
+	}
+	return j, diags
+}
+
+// Close closes the journal file, returning an error diagnostic if the
+// journal couldn't be closed or if any of its entries couldn't be written.
+func (j *applyJournal) Close() tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.file.Close(); err != nil && j.err == nil {
+		j.err = err
+	}
+	if j.err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to write apply journal",
+			fmt.Sprintf("The apply journal %q is incomplete, and so can't be used to resume this apply: %s.", j.file.Name(), j.err),
+		))
+	}
+	return diags
+}
+
+// write appends an entry for the given resource instance to the journal,
+// using the snapshot of the instance from the given state.
+//
+// Entries are flushed to disk before write returns, so that an entry is
+// never lost for an operation that the apply has gone on to depend on.
+func (j *applyJournal) write(state *states.SyncState, addr addrs.AbsResourceInstance, gen states.Generation, failed bool) error {
+	entry := applyJournalEntry{
+		Address: addr.String(),
+		Failed:  failed,
+	}
+	if dk, ok := gen.(states.DeposedKey); ok {
+		entry.Deposed = dk.String()
+	}
+
+	if rs := state.Resource(addr.ContainingResource()); rs != nil {
+		if is := rs.Instance(addr.Resource.Key); is != nil && is.HasObjects() {
+			snapshot := states.NewState()
+			ms := snapshot.EnsureModule(addr.Module)
+			ms.SetResourceProvider(addr.Resource.Resource, rs.ProviderConfig)
+			ms.Resource(addr.Resource.Resource).Instances[addr.Resource.Key] = is
+
+			var buf bytes.Buffer
+			if err := statefile.Write(statefile.New(snapshot, "", 0), &buf, j.enc); err != nil {
+				return j.fail(fmt.Errorf("failed to encode %s: %w", addr, err))
+			}
+			entry.State = buf.Bytes()
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return j.fail(fmt.Errorf("failed to encode %s: %w", addr, err))
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err != nil {
+		return j.err
+	}
+	if _, err := j.file.Write(line); err != nil {
+		j.err = fmt.Errorf("failed to write entry for %s: %w", addr, err)
+		return j.err
+	}
+	if err := j.file.Sync(); err != nil {
+		j.err = fmt.Errorf("failed to write entry for %s: %w", addr, err)
+		return j.err
+	}
+	return nil
+}
+
+func (j *applyJournal) fail(err error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err == nil {
+		j.err = err
+	}
+	return err
+}
+
+// applyJournalHook is a Hook used internally during the apply walk to
+// implement ApplyOpts.JournalPath.
+//
+// It appends a journal entry each time an operation on a managed resource
+// instance object finishes, whether or not it succeeded, and each time an object
+// that the plan proposed to forget is removed from the working state.
+//
+// Unlike the other hooks, the hook returns an error if it can't write an
+// entry, because resuming from a journal that is missing an entry could
+// repeat a change that has already been made.
+type applyJournalHook struct {
+	NilHook
+
+	journal *applyJournal
+	state   *states.SyncState
+}
+
+var _ Hook = (*applyJournalHook)(nil)
+var _ forgetListener = (*applyJournalHook)(nil)
+
+func (h *applyJournalHook) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, _ plans.Action, _, _ cty.Value) (HookAction, error) {
+	if addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+		return HookActionContinue, nil
+	}
+	h.journal.mu.Lock()
+	defer h.journal.mu.Unlock()
+	h.journal.running[addr.String()] = gen
+	return HookActionContinue, nil
+}
+
+func (h *applyJournalHook) PostApply(addr addrs.AbsResourceInstance, _ states.Generation, _ cty.Value, err error) (HookAction, error) {
+	// Data resources read during the apply are also reported as operations,
+	// but they aren't planned changes and are read again on every run, so
+	// the journal records only managed resources.
+	if addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+		return HookActionContinue, nil
+	}
+	h.journal.mu.Lock()
+	gen := h.journal.running[addr.String()]
+	delete(h.journal.running, addr.String())
+	h.journal.mu.Unlock()
+
+	if err := h.journal.write(h.state, addr, gen, err != nil); err != nil {
+		return HookActionHalt, err
+	}
+	return HookActionContinue, nil
+}
+
+func (h *applyJournalHook) objectForgotten(addr addrs.AbsResourceInstance, gen states.Generation, old *states.ResourceInstanceObject) (HookAction, error) {
+	// Forgetting an object doesn't involve the provider and so doesn't
+	// produce a PostApply call, but it is complete as soon as the object
+	// is removed from the state.
+	h.journal.mu.Lock()
+	forget := h.journal.forgets[applyProgressKey{addr.String(), gen}]
+	h.journal.mu.Unlock()
+	if !forget {
+		return HookActionContinue, nil
+	}
+
+	if err := h.journal.write(h.state, addr, gen, false); err != nil {
+		return HookActionHalt, err
+	}
+	return HookActionContinue, nil
+}
+
+// ResumeFromJournal returns a plan for the changes in the given plan that
+// were not completed by an apply that recorded its progress in the apply
+// journal at the given path, using ApplyOpts.JournalPath, such as one that
+// was interrupted or that failed part way through.
+//
+// The given plan must be the one that the journaled apply began from, such
+// as by reading it again from the same saved plan file, rather than one
+// that has since been passed to Apply. The returned plan's prior state is
+// the plan's prior state updated with the journaled objects, and it
+// excludes the planned changes that the journal records as completed. As
+// with RetryFailed, ResumeFromJournal returns errors if any of the failed
+// changes left their objects different from what the plan expected,
+// because those need a new plan instead.
+//
+// The caller can apply the returned plan with the same JournalPath, and
+// then resume again from the original plan if that apply doesn't complete
+// either, since the journal includes the entries from both applies.
+//
+// A create_before_destroy replace counts as completed once its new object
+// is created, so if the journaled apply stopped before destroying the
+// deposed object then that object stays in the state of the returned plan
+// for the next plan to destroy.
+func (c *Context) ResumeFromJournal(plan *plans.Plan, path string) (*plans.Plan, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	enc := c.encryption
+	if enc == nil {
+		enc = encryption.Disabled()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to read apply journal",
+			fmt.Sprintf("Couldn't open the apply journal %q: %s.", path, err),
+		))
+		return nil, diags
+	}
+	defer f.Close()
+	entries, err := readApplyJournal(f)
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Invalid apply journal",
+			fmt.Sprintf("Couldn't read the apply journal %q: %s.", path, err),
+		))
+		return nil, diags
+	}
+
+	planned := make(map[string]bool, len(plan.Changes.Resources))
+	for _, rc := range plan.Changes.Resources {
+		planned[rc.Addr.String()] = true
+	}
+
+	priorState := plan.PriorState.DeepCopy()
+	outcomes := make(map[applyProgressKey]bool)
+	for i, entry := range entries {
+		addr, err := applyJournalEntryState(priorState, entry, enc.State())
+		if err == nil && !planned[addr.String()] {
+			err = fmt.Errorf("the plan has no change for %s", addr)
+		}
+		if err != nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid apply journal",
+				fmt.Sprintf("Entry %d of the apply journal %q is not valid for this plan: %s.", i+1, path, err),
+			))
+			return nil, diags
+		}
+		gen := states.CurrentGen
+		if entry.Deposed != "" {
+			gen = states.DeposedKey(entry.Deposed)
+		}
+		outcomes[applyProgressKey{addr.String(), gen}] = entry.Failed
+	}
+
+	ret := copyPlanForRetry(plan, priorState)
+	remaining := ret.Changes.Resources[:0]
+	for _, rc := range ret.Changes.Resources {
+		failed, ok := outcomes[applyProgressKey{rc.Addr.String(), rc.DeposedKey.Generation()}]
+		switch {
+		case !ok:
+			remaining = append(remaining, rc)
+			continue
+		case !failed:
+			log.Printf("[TRACE] ResumeFromJournal: %s was completed by the journaled apply", rc.Addr)
+			continue
+		}
+
+		before := storedObject(plan.PriorState, rc.Addr, rc.DeposedKey)
+		after := storedObject(priorState, rc.Addr, rc.DeposedKey)
+		if !sameStoredObject(before, after) {
+			objName := rc.Addr.String()
+			if rc.DeposedKey != states.NotDeposed {
+				objName = fmt.Sprintf("%s deposed object %s", rc.Addr, rc.DeposedKey)
+			}
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Cannot resume change",
+				fmt.Sprintf("The planned change for %s cannot be resumed, because the journaled apply changed the object it was planned against.\n\nCreate a new plan to apply the remaining changes instead.", objName),
+			))
+			continue
+		}
+		remaining = append(remaining, rc)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	ret.Changes.Resources = remaining
+	return ret, diags
+}
+
+// readApplyJournal returns the entries of the apply journal read from the
+// given reader, in the order they were written.
+//
+// The last line is ignored if it is incomplete, since that's what we'd
+// expect to find if OpenTofu was terminated while writing it.
+func readApplyJournal(r io.Reader) ([]applyJournalEntry, error) {
+	var ret []applyJournalEntry
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				log.Printf("[WARN] readApplyJournal: ignoring incomplete entry on line %d", n)
+			}
+			return ret, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry applyJournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		ret = append(ret, entry)
+	}
+}
+
+// applyJournalEntryState replaces the resource instance recorded by the
+// given journal entry in the given state with its snapshot from the entry,
+// returning the instance's address.
+func applyJournalEntryState(state *states.State, entry applyJournalEntry, enc encryption.StateEncryption) (addrs.AbsResourceInstance, error) {
+	addr, diags := addrs.ParseAbsResourceInstanceStr(entry.Address)
+	if diags.HasErrors() {
+		return addr, diags.Err()
+	}
+	if addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+		return addr, fmt.Errorf("%s is not a managed resource instance", addr)
+	}
+
+	var is *states.ResourceInstance
+	var provider addrs.AbsProviderConfig
+	if len(entry.State) > 0 {
+		file, err := statefile.Read(bytes.NewReader(entry.State), enc)
+		if err != nil {
+			return addr, err
+		}
+		rs := file.State.Resource(addr.ContainingResource())
+		if rs != nil {
+			is = rs.Instance(addr.Resource.Key)
+			provider = rs.ProviderConfig
+		}
+		if is == nil {
+			return addr, fmt.Errorf("the snapshot doesn't include %s", addr)
+		}
+	}
+
+	if ms := state.Module(addr.Module); ms != nil {
+		ms.ForgetResourceInstanceAll(addr.Resource)
+	}
+	if is == nil {
+		return addr, nil
+	}
+	ms := state.EnsureModule(addr.Module)
+	ms.SetResourceProvider(addr.Resource.Resource, provider)
+	ms.Resource(addr.Resource.Resource).Instances[addr.Resource.Key] = is
+	return addr, nil
+}
@@ -0,0 +1,32 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// PolicyEvaluator makes the final decision whether each change to a managed
+// resource instance may be applied, for ApplyOpts.PolicyEvaluator.
+//
+// Evaluate is called just before each create, update or delete of the
+// current object of a managed resource instance, after the ApplyValidator
+// hooks. The planned value is the planned new value of the object, including
+// any sensitive marks and any values that became known earlier in the same
+// apply, or a null value for a delete. OpenTofu applies a replacement as a
+// separate delete and create, and so evaluates each half separately.
+// Destroying deposed objects is never evaluated.
+//
+// The returned diagnostics are included in the result of the apply. If any
+// are errors then OpenTofu doesn't apply the change, so the diagnostics
+// should explain which policy denied it. Evaluate may be called concurrently
+// for different resource instances, and so must be safe for concurrent use.
+type PolicyEvaluator interface {
+	Evaluate(addr addrs.AbsResourceInstance, plannedValue cty.Value) tfdiags.Diagnostics
+}
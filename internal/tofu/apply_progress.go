@@ -0,0 +1,483 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// ApplyChangeCounts summarizes how many of the resource instance changes in
+// a plan were actually applied by an apply operation.
+type ApplyChangeCounts struct {
+	// Planned is the number of resource instance changes in the plan,
+	// excluding no-op changes.
+	Planned int
+
+	// Applied is the number of planned changes that completed successfully.
+	Applied int
+
+	// Failed is the number of planned changes that OpenTofu attempted to
+	// apply but which returned errors.
+	Failed int
+
+	// Skipped is the number of planned creates that a hook declined at
+	// apply time, using ApplyGate.
+	Skipped int
+}
+
+// NotReached returns the number of planned changes that OpenTofu did not
+// attempt to apply at all, typically because something they depend on
+// failed.
+func (c ApplyChangeCounts) NotReached() int {
+	return c.Planned - c.Applied - c.Failed - c.Skipped
+}
+
+// ApplyStatusSnapshot describes the status of an apply operation at a
+// particular moment while it is running, as returned by Context.ApplyStatus.
+type ApplyStatusSnapshot struct {
+	// Running are the resource instances whose operations have started but
+	// not yet finished, sorted by address.
+	Running []addrs.AbsResourceInstance
+
+	// Completed is the number of planned changes that have finished so far,
+	// whether successfully or not.
+	Completed int
+
+	// Total is the number of resource instance changes in the plan,
+	// excluding no-op changes.
+	Total int
+
+	// Errors are the errors returned by resource instance operations that
+	// have failed so far, in the order they failed. These do not include
+	// any errors that occur outside of resource instance operations, which
+	// Apply returns only once it has finished.
+	Errors []error
+}
+
+// ApplyProgressSnapshot describes which of the planned changes of an apply
+// operation have finished, as returned by Context.ApplyProgressSnapshot.
+//
+// Each list includes a resource instance only once, even if the plan changes
+// more than one of its objects, and is sorted by address.
+type ApplyProgressSnapshot struct {
+	// RunID is the run ID of the apply operation.
+	RunID string
+
+	// Finished is true if the apply operation has returned, in which case
+	// the snapshot is final.
+	Finished bool
+
+	// Completed are the resource instances whose planned changes have all
+	// finished successfully.
+	Completed []addrs.AbsResourceInstance
+
+	// Errored are the resource instances for which at least one of the
+	// planned changes failed.
+	Errored []addrs.AbsResourceInstance
+
+	// Skipped are the resource instances whose planned creates a hook
+	// declined, using ApplyGate.
+	Skipped []addrs.AbsResourceInstance
+
+	// Remaining are the resource instances with planned changes that have
+	// not yet finished, either because they are still running or because
+	// OpenTofu has not yet started them. Once the apply has finished, these
+	// are the changes it didn't reach.
+	Remaining []addrs.AbsResourceInstance
+}
+
+// ApplyProgressEstimate describes how far an apply operation has progressed
+// through the changes in its plan, for the Hook.ApplyProgress event.
+type ApplyProgressEstimate struct {
+	// Completed is the number of planned changes that have finished so far,
+	// whether successfully or not.
+	Completed int
+
+	// Total is the number of resource instance changes in the plan,
+	// excluding no-op changes.
+	Total int
+
+	// Elapsed is the time since the apply walk began.
+	Elapsed time.Duration
+
+	// Remaining is a rough estimate of the time until all of the planned
+	// changes will have finished, based on the average rate at which the
+	// changes finished so far in the same apply. It is zero once all of
+	// the planned changes have finished.
+	Remaining time.Duration
+}
+
+// Fraction returns the proportion of the planned changes that have
+// finished, between 0 and 1.
+func (e ApplyProgressEstimate) Fraction() float64 {
+	if e.Total == 0 {
+		return 1
+	}
+	return float64(e.Completed) / float64(e.Total)
+}
+
+// applyProgressKey identifies a single planned change for an object.
+type applyProgressKey struct {
+	addr string
+	gen  states.Generation
+}
+
+// applyProgressHook is a Hook that tracks which of the changes in a plan are
+// completed during an apply walk, so that afterwards we can report how many
+// were not reached.
+//
+// It also notifies any of the given hooks that implement
+// ApplyProgressListener of the progress each time another of the planned
+// changes finishes.
+type applyProgressHook struct {
+	NilHook
+
+	listeners []ApplyProgressListener
+	start     time.Time
+	now       func() time.Time
+
+	mu      sync.Mutex
+	planned map[applyProgressKey]plans.Action
+	failed  map[applyProgressKey]bool
+	skipped map[applyProgressKey]bool
+	running map[applyProgressKey]addrs.AbsResourceInstance
+	errs    []error
+
+	// gens are the generations of the objects that each resource instance
+	// is currently applying, keyed by address, because PostApply always
+	// reports the current object, even when destroying a deposed one.
+	gens map[string]states.Generation
+
+	// plannedAddrs are the resource instance addresses of the planned
+	// changes, for ApplyProgressSnapshot.
+	plannedAddrs map[applyProgressKey]addrs.AbsResourceInstance
+}
+
+var _ Hook = (*applyProgressHook)(nil)
+var _ applySkipListener = (*applyProgressHook)(nil)
+var _ forgetListener = (*applyProgressHook)(nil)
+
+// applySkipListener is implemented by internal hooks that need to know when
+// a planned create is skipped because of an ApplyGate, since in that case
+// there are no PreApply or PostApply calls for the object.
+type applySkipListener interface {
+	applySkipped(addr addrs.AbsResourceInstance)
+}
+
+func newApplyProgressHook(changes *plans.Changes, hooks []Hook) *applyProgressHook {
+	var listeners []ApplyProgressListener
+	for _, hook := range hooks {
+		if l, ok := hook.(ApplyProgressListener); ok {
+			listeners = append(listeners, l)
+		}
+	}
+	h := &applyProgressHook{
+		listeners:    listeners,
+		start:        time.Now(),
+		now:          time.Now,
+		planned:      make(map[applyProgressKey]plans.Action),
+		plannedAddrs: make(map[applyProgressKey]addrs.AbsResourceInstance),
+		failed:       make(map[applyProgressKey]bool),
+		skipped:      make(map[applyProgressKey]bool),
+		running:      make(map[applyProgressKey]addrs.AbsResourceInstance),
+		gens:         make(map[string]states.Generation),
+	}
+	for _, rc := range changes.Resources {
+		if rc.Action == plans.NoOp {
+			continue
+		}
+		if rc.Addr.Resource.Resource.Mode == addrs.DataResourceMode && rc.Action == plans.Delete {
+			// Destroying a data resource instance only removes it from the
+			// state, without any hook calls, and so it would never be
+			// counted as completed.
+			continue
+		}
+		key := applyProgressKey{rc.Addr.String(), rc.DeposedKey.Generation()}
+		h.planned[key] = rc.Action
+		h.plannedAddrs[key] = rc.Addr
+	}
+	return h
+}
+
+func (h *applyProgressHook) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.running[applyProgressKey{addr.String(), gen}] = addr
+	h.gens[addr.String()] = gen
+	return HookActionContinue, nil
+}
+
+func (h *applyProgressHook) PostApply(addr addrs.AbsResourceInstance, gen states.Generation, newState cty.Value, err error) (HookAction, error) {
+	h.mu.Lock()
+	if prev, ok := h.gens[addr.String()]; ok {
+		gen = prev
+		delete(h.gens, addr.String())
+	}
+	key := applyProgressKey{addr.String(), gen}
+	delete(h.running, key)
+	if err != nil {
+		h.errs = append(h.errs, err)
+	}
+	h.mu.Unlock()
+	h.record(key, err != nil)
+	return HookActionContinue, nil
+}
+
+func (h *applyProgressHook) objectForgotten(addr addrs.AbsResourceInstance, gen states.Generation, old *states.ResourceInstanceObject) (HookAction, error) {
+	// Forgetting an object doesn't involve the provider and so doesn't
+	// produce a PostApply call, but it is complete as soon as the object
+	// is removed from the state.
+	key := applyProgressKey{addr.String(), gen}
+	h.mu.Lock()
+	action := h.planned[key]
+	h.mu.Unlock()
+	if action == plans.Forget {
+		h.record(key, false)
+	}
+	return HookActionContinue, nil
+}
+
+func (h *applyProgressHook) applySkipped(addr addrs.AbsResourceInstance) {
+	key := applyProgressKey{addr.String(), states.CurrentGen}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.planned[key]; !ok || h.skipped[key] {
+		return
+	}
+	h.skipped[key] = true
+	h.notifyListeners()
+}
+
+func (h *applyProgressHook) record(key applyProgressKey, failed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.planned[key]; !ok {
+		// We only track the objects that the plan proposed to change,
+		// and not any that are created as a side-effect of applying,
+		// such as the deposed objects during a create_before_destroy
+		// replace.
+		return
+	}
+
+	// A replace action involves more than one provider operation for the
+	// same object, and so a failure of any of them makes the whole change
+	// failed.
+	prev, seen := h.failed[key]
+	h.failed[key] = prev || failed
+
+	if !seen {
+		h.notifyListeners()
+	}
+}
+
+// notifyListeners reports the current progress estimate to the listeners.
+//
+// The caller must hold h.mu, so that the listeners see each estimate in
+// order, without any concurrent calls.
+func (h *applyProgressHook) notifyListeners() {
+	if len(h.listeners) == 0 {
+		return
+	}
+	estimate := h.estimate()
+	for _, l := range h.listeners {
+		l.ApplyProgress(estimate)
+	}
+}
+
+// estimate returns the current progress estimate. The caller must hold h.mu.
+func (h *applyProgressHook) estimate() ApplyProgressEstimate {
+	ret := ApplyProgressEstimate{
+		Completed: len(h.failed) + len(h.skipped),
+		Total:     len(h.planned),
+		Elapsed:   h.now().Sub(h.start),
+	}
+	if ret.Completed > 0 {
+		// Changes can run concurrently, so we use the overall rate at which
+		// they've been completing rather than the duration of each one.
+		perChange := ret.Elapsed / time.Duration(ret.Completed)
+		ret.Remaining = perChange * time.Duration(ret.Total-ret.Completed)
+	}
+	return ret
+}
+
+// Status returns a snapshot of the apply's progress so far.
+func (h *applyProgressHook) Status() *ApplyStatusSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ret := &ApplyStatusSnapshot{
+		Completed: len(h.failed) + len(h.skipped),
+		Total:     len(h.planned),
+	}
+	seen := make(map[string]bool, len(h.running))
+	for key, addr := range h.running {
+		// A create_before_destroy replace may be running operations for
+		// both the current and a deposed object at once, but we only
+		// report each resource instance once.
+		if !seen[key.addr] {
+			seen[key.addr] = true
+			ret.Running = append(ret.Running, addr)
+		}
+	}
+	sort.Slice(ret.Running, func(i, j int) bool {
+		return ret.Running[i].Less(ret.Running[j])
+	})
+	if len(h.errs) > 0 {
+		ret.Errors = make([]error, len(h.errs))
+		copy(ret.Errors, h.errs)
+	}
+	return ret
+}
+
+// Progress returns a snapshot of which planned changes have finished so far,
+// for the apply with the given run ID.
+func (h *applyProgressHook) Progress(runID string, finished bool) *ApplyProgressSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Each resource instance takes the least-finished status of its planned
+	// changes, with a failure of any of them taking precedence.
+	const (
+		completed = iota
+		skipped
+		remaining
+		errored
+	)
+	statuses := make(map[string]int)
+	instAddrs := make(map[string]addrs.AbsResourceInstance)
+	for key := range h.planned {
+		status := completed
+		if failed, done := h.failed[key]; failed {
+			status = errored
+		} else if h.skipped[key] {
+			status = skipped
+		} else if !done {
+			status = remaining
+		}
+		if prev, seen := statuses[key.addr]; !seen || status > prev {
+			statuses[key.addr] = status
+		}
+		instAddrs[key.addr] = h.plannedAddrs[key]
+	}
+
+	ret := &ApplyProgressSnapshot{
+		RunID:    runID,
+		Finished: finished,
+	}
+	for key, status := range statuses {
+		addr := instAddrs[key]
+		switch status {
+		case completed:
+			ret.Completed = append(ret.Completed, addr)
+		case skipped:
+			ret.Skipped = append(ret.Skipped, addr)
+		case remaining:
+			ret.Remaining = append(ret.Remaining, addr)
+		case errored:
+			ret.Errored = append(ret.Errored, addr)
+		}
+	}
+	for _, list := range [][]addrs.AbsResourceInstance{ret.Completed, ret.Skipped, ret.Remaining, ret.Errored} {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Less(list[j])
+		})
+	}
+	return ret
+}
+
+// Counts returns the change counts recorded so far.
+func (h *applyProgressHook) Counts() ApplyChangeCounts {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ret := ApplyChangeCounts{
+		Planned: len(h.planned),
+		Skipped: len(h.skipped),
+	}
+	for _, failed := range h.failed {
+		if failed {
+			ret.Failed++
+		} else {
+			ret.Applied++
+		}
+	}
+	return ret
+}
+
+// finalCounts returns the change counts for a finished apply walk.
+//
+// Any planned change that began but never reported a result, such as a data
+// resource read whose provider call failed, is counted as failed rather
+// than as not reached, because OpenTofu did attempt it.
+func (h *applyProgressHook) finalCounts() ApplyChangeCounts {
+	ret := h.Counts()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key := range h.running {
+		if _, planned := h.planned[key]; !planned || h.skipped[key] {
+			continue
+		}
+		if _, done := h.failed[key]; !done {
+			ret.Failed++
+		}
+	}
+	return ret
+}
+
+// Unfinished returns the planned changes that have not completed
+// successfully so far, with the value for each being true if OpenTofu
+// attempted the change but it failed, or false if it was not reached.
+// Changes that were skipped using ApplyGate are not included.
+func (h *applyProgressHook) Unfinished() map[applyProgressKey]bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ret := make(map[applyProgressKey]bool)
+	for key := range h.planned {
+		if h.skipped[key] {
+			continue
+		}
+		if failed, done := h.failed[key]; !done || failed {
+			ret[key] = failed
+		}
+	}
+	return ret
+}
+
+// incompleteApplyWarning returns a warning summarizing the given counts if
+// any of the planned changes were not reached, or no diagnostics otherwise.
+// Changes go unreached both when an apply is stopped and when a change that
+// they depend on fails, so the warning doesn't say which.
+//
+// We don't return a warning if all of the changes that weren't applied
+// failed, because the errors for those failures already explain what
+// happened.
+func incompleteApplyWarning(counts ApplyChangeCounts) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if counts.NotReached() == 0 {
+		return diags
+	}
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Warning,
+		"Apply incomplete",
+		fmt.Sprintf(
+			"OpenTofu applied %d of the %d planned changes. %d failed and %d were not reached. Run \"tofu plan\" to see the changes that are still pending.",
+			counts.Applied, counts.Planned, counts.Failed, counts.NotReached(),
+		),
+	))
+	return diags
+}
@@ -0,0 +1,134 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// refreshBeforeApply refreshes the prior state of the given plan before it
+// is applied, for ApplyOpts.RequireStateMatch and ApplyOpts.RefreshOnly.
+//
+// If opts.RefreshOnly is set then only those resource instances, along with
+// anything they depend on, are refreshed, and the returned plan is a copy
+// of the given plan whose prior state has their refreshed objects.
+// Otherwise, the whole prior state is refreshed but only to check it, and
+// the given plan is returned unchanged.
+//
+// If opts.RequireStateMatch is set then refreshBeforeApply returns an error
+// listing any managed resource instances whose refreshed objects no longer
+// match the prior state.
+func (c *Context) refreshBeforeApply(ctx context.Context, plan *plans.Plan, config *configs.Config, opts *ApplyOpts) (*plans.Plan, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	// We report only errors here, because applyGraph will return the
+	// same warnings when it decodes the variables again.
+	variables, varDiags := planInputValues(plan, config, opts.VariableFallbacks)
+	if varDiags.HasErrors() {
+		diags = diags.Append(varDiags)
+		return plan, diags
+	}
+
+	refreshOpts := &PlanOpts{
+		Mode:         plans.RefreshOnlyMode,
+		SetVariables: variables,
+		Targets:      plan.TargetAddrs,
+		Excludes:     plan.ExcludeAddrs,
+	}
+	if len(opts.RefreshOnly) > 0 {
+		targets := make([]addrs.Targetable, len(opts.RefreshOnly))
+		for i, addr := range opts.RefreshOnly {
+			targets[i] = addr
+		}
+		refreshOpts.Targets = targets
+		refreshOpts.Excludes = nil
+	}
+
+	log.Printf("[DEBUG] Refreshing prior state before applying")
+	refreshPlan, refreshDiags := c.refreshOnlyPlan(ctx, config, plan.PriorState.DeepCopy(), refreshOpts)
+	diags = diags.Append(refreshDiags)
+	if diags.HasErrors() {
+		return plan, diags
+	}
+
+	if opts.RequireStateMatch {
+		diags = diags.Append(checkNoDrift(refreshPlan))
+		if diags.HasErrors() {
+			return plan, diags
+		}
+	}
+
+	if len(opts.RefreshOnly) == 0 {
+		return plan, diags
+	}
+	return withRefreshedInstances(plan, refreshPlan.PriorState, opts.RefreshOnly), diags
+}
+
+// checkNoDrift returns an error listing the managed resource instances that
+// the given refresh-only plan found to have changed outside of OpenTofu,
+// if there are any.
+func checkNoDrift(refreshPlan *plans.Plan) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	var drifted []string
+	for _, rc := range refreshPlan.DriftedResources {
+		if rc.Action == plans.NoOp {
+			// Drift reporting also includes objects that only moved, which
+			// a refresh of the prior state can never produce.
+			continue
+		}
+		drifted = append(drifted, rc.Addr.String())
+	}
+	if len(drifted) == 0 {
+		return diags
+	}
+	sort.Strings(drifted)
+
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Error,
+		"State changed since plan",
+		fmt.Sprintf(
+			"The following resource instances have changed outside of OpenTofu since the plan was created, and so the plan cannot be applied:\n  - %s\n\nCreate a new plan to take these changes into account.",
+			strings.Join(drifted, "\n  - "),
+		),
+	))
+	return diags
+}
+
+// withRefreshedInstances returns a copy of the given plan whose prior state
+// has the current objects for the given resource instances taken from the
+// given refreshed state, and is otherwise unchanged.
+func withRefreshedInstances(plan *plans.Plan, refreshed *states.State, instAddrs []addrs.AbsResourceInstance) *plans.Plan {
+	priorState := plan.PriorState.DeepCopy()
+	sync := priorState.SyncWrapper()
+	for _, addr := range instAddrs {
+		rs := refreshed.Resource(addr.ContainingResource())
+		is := refreshed.ResourceInstance(addr)
+		if rs == nil || is == nil {
+			// An instance that the refresh found to no longer exist is
+			// removed, but might also have not been in the state at all.
+			if prior := priorState.Resource(addr.ContainingResource()); prior != nil {
+				sync.SetResourceInstanceCurrent(addr, nil, prior.ProviderConfig, addrs.NoKey)
+			}
+			continue
+		}
+		sync.SetResourceInstanceCurrent(addr, is.Current, rs.ProviderConfig, is.ProviderKey)
+	}
+
+	ret := *plan
+	ret.PriorState = priorState
+	return &ret
+}
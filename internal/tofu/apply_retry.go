@@ -0,0 +1,202 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// ApplyFailures describes the planned changes that an apply did not
+// complete, as returned by Context.LastApplyFailures, so that
+// Context.RetryFailed can attempt them again without creating a new plan.
+type ApplyFailures struct {
+	// Failed are the resource instances whose planned changes OpenTofu
+	// attempted but which returned errors, sorted by address.
+	Failed []addrs.AbsResourceInstance
+
+	// NotReached are the resource instances whose planned changes OpenTofu
+	// did not attempt at all, typically because something they depend on
+	// failed, sorted by address.
+	NotReached []addrs.AbsResourceInstance
+
+	// plan is a copy of the plan that was applied, including all of its
+	// original changes, and with the prior state that the apply walk
+	// began from.
+	plan *plans.Plan
+
+	// state is a copy of the state that the apply returned.
+	state *states.State
+
+	// unfinished are the planned changes in plan that did not complete.
+	unfinished map[applyProgressKey]bool
+}
+
+// newApplyFailures returns an ApplyFailures for the given outcomes of
+// applying the given plan, or nil if all of the planned changes either
+// completed or were deliberately skipped.
+func newApplyFailures(plan *plans.Plan, state *states.State, progress *applyProgressHook) *ApplyFailures {
+	unfinished := progress.Unfinished()
+	if len(unfinished) == 0 {
+		return nil
+	}
+
+	ret := &ApplyFailures{
+		plan:       plan,
+		state:      state.DeepCopy(),
+		unfinished: unfinished,
+	}
+	failed := addrs.MakeSet[addrs.AbsResourceInstance]()
+	notReached := addrs.MakeSet[addrs.AbsResourceInstance]()
+	for _, rc := range plan.Changes.Resources {
+		wasFailed, ok := unfinished[applyProgressKey{rc.Addr.String(), rc.DeposedKey.Generation()}]
+		switch {
+		case !ok:
+			continue
+		case wasFailed:
+			failed.Add(rc.Addr)
+		default:
+			notReached.Add(rc.Addr)
+		}
+	}
+	for _, addr := range failed {
+		// A replace can have changes for both the current and a deposed
+		// object, but we report each resource instance only once, as
+		// failed if either of them failed.
+		notReached.Remove(addr)
+	}
+	ret.Failed = sortedResourceInstanceAddrs(failed)
+	ret.NotReached = sortedResourceInstanceAddrs(notReached)
+	return ret
+}
+
+// LastApplyFailures returns the planned changes that the most recent call
+// to Apply on this context did not complete, or nil if that apply did not
+// set ApplyOpts.RecordFailures, completed all of its planned changes, or
+// failed before the graph walk began.
+func (c *Context) LastApplyFailures() *ApplyFailures {
+	return c.lastApplyResults().failures
+}
+
+// RetryFailed applies again the planned changes from an earlier apply that
+// failed or were not reached, as described by the given ApplyFailures,
+// without creating a new plan.
+//
+// The planned changes that the earlier apply completed are not repeated,
+// and the retried changes are applied to the state that the earlier apply
+// returned. RetryFailed returns errors without applying anything if any of
+// the objects to retry were changed by the earlier apply, such as a create
+// that failed after partially creating its object, because their planned
+// changes would no longer be correct. Those need a new plan instead.
+//
+// The retry records its own failures, so if it is still incomplete then
+// the caller can use LastApplyFailures to retry again.
+func (c *Context) RetryFailed(ctx context.Context, prev *ApplyFailures, config *configs.Config) (*states.State, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+	if prev == nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Nothing to retry",
+			"There are no failed changes to retry. The previous apply either completed all of its changes or did not record its failures.",
+		))
+		return nil, diags
+	}
+
+	plan := copyPlanForRetry(prev.plan, prev.state.DeepCopy())
+	retry := plan.Changes.Resources[:0]
+	for _, rc := range plan.Changes.Resources {
+		if _, ok := prev.unfinished[applyProgressKey{rc.Addr.String(), rc.DeposedKey.Generation()}]; !ok {
+			continue
+		}
+
+		planned := storedObject(prev.plan.PriorState, rc.Addr, rc.DeposedKey)
+		current := storedObject(prev.state, rc.Addr, rc.DeposedKey)
+		if !sameStoredObject(planned, current) {
+			objName := rc.Addr.String()
+			if rc.DeposedKey != states.NotDeposed {
+				objName = fmt.Sprintf("%s deposed object %s", rc.Addr, rc.DeposedKey)
+			}
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Cannot retry change",
+				fmt.Sprintf("The planned change for %s cannot be retried, because the failed apply changed the object it was planned against.\n\nCreate a new plan to apply the remaining changes instead.", objName),
+			))
+			continue
+		}
+		retry = append(retry, rc)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	plan.Changes.Resources = retry
+
+	state, moreDiags := c.ApplyWithOpts(ctx, plan, config, &ApplyOpts{
+		RecordFailures: true,
+	})
+	diags = diags.Append(moreDiags)
+	return state, diags
+}
+
+// copyPlanForRetry returns a copy of the given plan that keeps its own copy
+// of all of the planned changes, since applying a plan removes each change
+// from it as it completes, and that has the given prior state.
+func copyPlanForRetry(plan *plans.Plan, priorState *states.State) *plans.Plan {
+	changes := *plan.Changes
+	changes.Resources = make([]*plans.ResourceInstanceChangeSrc, len(plan.Changes.Resources))
+	for i, rc := range plan.Changes.Resources {
+		changes.Resources[i] = rc.DeepCopy()
+	}
+	changes.Outputs = make([]*plans.OutputChangeSrc, len(plan.Changes.Outputs))
+	for i, oc := range plan.Changes.Outputs {
+		changes.Outputs[i] = oc.DeepCopy()
+	}
+
+	ret := *plan
+	ret.Changes = &changes
+	ret.PriorState = priorState
+	return &ret
+}
+
+// storedObject returns the object for the given resource instance and
+// deposed key in the given state, or nil if there is no such object.
+func storedObject(state *states.State, addr addrs.AbsResourceInstance, key states.DeposedKey) *states.ResourceInstanceObjectSrc {
+	is := state.ResourceInstance(addr)
+	if is == nil {
+		return nil
+	}
+	return is.GetGeneration(key.Generation())
+}
+
+// sameStoredObject returns true if the two given objects are both absent,
+// or are both present with the same status and attribute values.
+func sameStoredObject(a, b *states.ResourceInstanceObjectSrc) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Status == b.Status && bytes.Equal(a.AttrsJSON, b.AttrsJSON)
+}
+
+func sortedResourceInstanceAddrs(set addrs.Set[addrs.AbsResourceInstance]) []addrs.AbsResourceInstance {
+	if len(set) == 0 {
+		return nil
+	}
+	ret := make([]addrs.AbsResourceInstance, 0, len(set))
+	for _, addr := range set {
+		ret = append(ret, addr)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Less(ret[j])
+	})
+	return ret
+}
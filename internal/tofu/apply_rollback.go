@@ -0,0 +1,141 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// rollbackPlan records the planned changes that ApplyOpts.RollbackOnError
+// needs to know about, captured before the apply walk removes them from
+// the plan.
+type rollbackPlan struct {
+	// creates are the managed resource instances planned to be created
+	// without replacing any existing object.
+	creates []addrs.AbsResourceInstance
+
+	// others are the other planned changes to managed resource instances
+	// that change remote objects, which can't be rolled back.
+	others map[applyProgressKey]addrs.AbsResourceInstance
+}
+
+func newRollbackPlan(changes *plans.Changes) *rollbackPlan {
+	ret := &rollbackPlan{
+		others: make(map[applyProgressKey]addrs.AbsResourceInstance),
+	}
+	for _, rc := range changes.Resources {
+		if rc.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+			continue
+		}
+		switch rc.Action {
+		case plans.Create:
+			if rc.DeposedKey == states.NotDeposed {
+				ret.creates = append(ret.creates, rc.Addr)
+			}
+		case plans.Update, plans.Delete, plans.DeleteThenCreate, plans.CreateThenDelete:
+			ret.others[applyProgressKey{rc.Addr.String(), rc.DeposedKey.Generation()}] = rc.Addr
+		}
+	}
+	return ret
+}
+
+// rollbackFailedApply destroys the objects that the apply walk for the given
+// plan created for the planned creates in the given rollback plan, and
+// returns the resulting state along with warnings describing what was and
+// was not rolled back.
+func (c *Context) rollbackFailedApply(ctx context.Context, rollback *rollbackPlan, plan *plans.Plan, config *configs.Config, state *states.State, progress *applyProgressHook) (*states.State, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	created := addrs.MakeSet[addrs.AbsResourceInstance]()
+	for _, addr := range rollback.creates {
+		if prior := plan.PriorState.ResourceInstance(addr); prior != nil && prior.Current != nil {
+			// A create for an address that already has an object in the
+			// prior state would be a bug in the plan, but we certainly
+			// mustn't destroy an object that existed before this apply.
+			continue
+		}
+		// A create that failed can still leave behind a tainted object,
+		// which is a real object and so also needs to be destroyed.
+		if is := state.ResourceInstance(addr); is != nil && is.Current != nil {
+			created.Add(addr)
+		}
+	}
+
+	unfinished := progress.Unfinished()
+	notRolledBack := addrs.MakeSet[addrs.AbsResourceInstance]()
+	for key, addr := range rollback.others {
+		if _, ok := unfinished[key]; !ok {
+			notRolledBack.Add(addr)
+		}
+	}
+	if kept := sortedResourceInstanceAddrs(notRolledBack); len(kept) > 0 {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Warning,
+			"Changes not rolled back",
+			fmt.Sprintf(
+				"OpenTofu can roll back only the objects it created, so the following resource instances keep the changes that were applied to them before the apply failed:\n  - %s",
+				joinResourceInstanceAddrs(kept),
+			),
+		))
+	}
+
+	addrList := sortedResourceInstanceAddrs(created)
+	if len(addrList) == 0 {
+		return state, diags
+	}
+
+	log.Printf("[WARN] Context.Apply: rolling back created objects after failed apply: %v", addrList)
+	newState, moreDiags := c.destroyResourceInstances(ctx, plan, config, state, addrList)
+	diags = diags.Append(moreDiags)
+
+	var destroyed, remaining []addrs.AbsResourceInstance
+	for _, addr := range addrList {
+		if is := newState.ResourceInstance(addr); is == nil || is.Current == nil {
+			destroyed = append(destroyed, addr)
+		} else {
+			remaining = append(remaining, addr)
+		}
+	}
+	if len(destroyed) > 0 {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Warning,
+			"Rolled back created resources",
+			fmt.Sprintf(
+				"Because the apply failed, OpenTofu destroyed the following resource instances that it had created during the apply:\n  - %s",
+				joinResourceInstanceAddrs(destroyed),
+			),
+		))
+	}
+	if len(remaining) > 0 {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Rollback incomplete",
+			fmt.Sprintf(
+				"OpenTofu could not destroy the following resource instances that it created before the apply failed, and so they remain in the state:\n  - %s",
+				joinResourceInstanceAddrs(remaining),
+			),
+		))
+	}
+
+	return newState, diags
+}
+
+func joinResourceInstanceAddrs(addrList []addrs.AbsResourceInstance) string {
+	strs := make([]string, len(addrList))
+	for i, addr := range addrList {
+		strs[i] = addr.String()
+	}
+	return strings.Join(strs, "\n  - ")
+}
@@ -0,0 +1,114 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/go-uuid"
+)
+
+// newApplyRunID generates a run ID for an apply operation whose caller
+// didn't choose one using ApplyOpts.RunID.
+func newApplyRunID() string {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		panic(fmt.Errorf("failed to generate apply run ID: %w", err))
+	}
+	return id
+}
+
+// setApplyRunID records the run ID of the apply operation that is starting,
+// or clears it and the apply's progress if runID is empty.
+func (c *Context) setApplyRunID(runID string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.applyRunID = runID
+	c.applyProgress = nil
+}
+
+func (c *Context) setApplyProgress(progress *applyProgressHook) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.applyProgress = progress
+}
+
+// CancelRun asks the apply operation with the given run ID to stop as soon
+// as possible, in the same way as Stop, and reports whether that apply was
+// running on this context.
+//
+// A context runs only one operation at a time, so CancelRun can only cancel
+// the apply in progress, and does nothing if the given run ID belongs to an
+// apply that has already finished or has not yet started. This allows a
+// caller that shares a context between many users to cancel one user's apply
+// without risking stopping someone else's.
+//
+// Unlike Stop, CancelRun doesn't wait for the apply to finish. The apply
+// itself returns an error once it has stopped.
+func (c *Context) CancelRun(runID string) bool {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if runID == "" || runID != c.applyRunID {
+		return false
+	}
+	log.Printf("[WARN] tofu: CancelRun called for apply %s, initiating interrupt sequence", runID)
+	c.interruptRun()
+
+	// Notify all of the hooks that we're stopping, just as Stop does.
+	c.notifyStopping()
+	return true
+}
+
+// LastApplyRunID returns the run ID of the most recent call to Apply on this
+// context, which is either the ApplyOpts.RunID that the caller chose or one
+// that Apply generated, or an empty string if there has not yet been an
+// apply.
+func (c *Context) LastApplyRunID() string {
+	return c.lastApplyResults().runID
+}
+
+// ApplyProgressSnapshot returns a snapshot of which planned changes of the
+// apply operation with the given run ID have finished, or nil if that apply
+// is neither running on this context nor the most recent one to finish.
+//
+// This is intended for user interfaces that may disconnect and reconnect
+// while an apply is running, and so need to fetch its progress at any time,
+// including just after it finished. The snapshot of an apply that is still
+// preparing its changes, or that failed before preparing them, lists no
+// resource instances at all.
+//
+// ApplyProgressSnapshot is safe to call concurrently with Apply. Each call
+// returns a new snapshot owned by the caller.
+func (c *Context) ApplyProgressSnapshot(runID string) *ApplyProgressSnapshot {
+	if runID == "" {
+		return nil
+	}
+
+	c.l.Lock()
+	var progress *applyProgressHook
+	finished := false
+	switch {
+	case runID == c.applyRunID:
+		progress = c.applyProgress
+	case c.lastApply != nil && runID == c.lastApply.runID:
+		progress = c.lastApply.progress
+		finished = true
+	default:
+		c.l.Unlock()
+		return nil
+	}
+	c.l.Unlock()
+
+	if progress == nil {
+		return &ApplyProgressSnapshot{
+			RunID:    runID,
+			Finished: finished,
+		}
+	}
+	return progress.Progress(runID, finished)
+}
@@ -0,0 +1,216 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/dag"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// ApplyTraceRecorder is a Hook that records the order in which an apply
+// begins each change to a resource instance, producing a trace that
+// Context.ReplayApplyTrace can later follow to reproduce that order.
+//
+// The trace is a sequence of JSON objects, one per line. Its format is an
+// implementation detail that may change in future versions of OpenTofu,
+// and so a trace should be replayed only by the same version that
+// recorded it.
+type ApplyTraceRecorder struct {
+	NilHook
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+var _ Hook = (*ApplyTraceRecorder)(nil)
+
+// NewApplyTraceRecorder returns an ApplyTraceRecorder that writes its trace
+// to the given writer. Register it using ContextOpts.Hooks.
+func NewApplyTraceRecorder(w io.Writer) *ApplyTraceRecorder {
+	return &ApplyTraceRecorder{
+		enc: json.NewEncoder(w),
+	}
+}
+
+// applyTraceEntry is the serialization of a single entry in an apply trace.
+type applyTraceEntry struct {
+	Address string `json:"address"`
+	Deposed string `json:"deposed,omitempty"`
+	Action  string `json:"action"`
+}
+
+func (r *ApplyTraceRecorder) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
+	entry := applyTraceEntry{
+		Address: addr.String(),
+		Action:  action.String(),
+	}
+	if dk, ok := gen.(states.DeposedKey); ok {
+		entry.Deposed = dk.String()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.enc.Encode(entry)
+	}
+	return HookActionContinue, nil
+}
+
+// Err returns the first error that the recorder encountered while writing
+// its trace, if any, in which case the trace is incomplete.
+func (r *ApplyTraceRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// ReplayApplyTrace applies the given plan again, following the order in
+// the given trace, which an ApplyTraceRecorder wrote during an earlier apply
+// of the same plan. This is for reproducing problems that depend on the
+// order in which OpenTofu applied the changes.
+//
+// The replay uses the given providers, which are typically mocks, instead
+// of the Context's own. The Context's hooks and other settings still apply.
+//
+// The managed resource instances in the trace are applied one after another
+// in the order of their first entries, so the replay is deterministic even
+// though the recorded apply was not. ReplayApplyTrace returns an error
+// without applying anything if that order conflicts with the dependencies
+// between the resource instances, such as if the trace was recorded for a
+// different plan.
+//
+// This is intended only for OpenTofu Core developers debugging problems
+// that depend on the order of the apply.
+func (c *Context) ReplayApplyTrace(ctx context.Context, trace io.Reader, plan *plans.Plan, config *configs.Config, mockProviders map[addrs.Provider]providers.Factory) (*states.State, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	order, err := readApplyTraceOrder(trace)
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to read apply trace",
+			fmt.Sprintf("Cannot replay the recorded apply trace: %s.", err),
+		))
+		return nil, diags
+	}
+
+	replay, moreDiags := NewContext(&ContextOpts{
+		Meta:         c.meta,
+		Hooks:        c.callerHooks,
+		Providers:    mockProviders,
+		Provisioners: c.plugins.provisionerFactories,
+		Encryption:   c.encryption,
+		UIInput:      c.uiInput,
+	})
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+
+	state, moreDiags := replay.ApplyWithOpts(ctx, plan, config, &ApplyOpts{
+		traceOrder: order,
+	})
+	diags = diags.Append(moreDiags)
+	return state, diags
+}
+
+// readApplyTraceOrder returns the managed resource instances in the given
+// apply trace in the order of their first entries.
+func readApplyTraceOrder(trace io.Reader) ([]addrs.AbsResourceInstance, error) {
+	var order []addrs.AbsResourceInstance
+	seen := addrs.MakeSet[addrs.AbsResourceInstance]()
+	dec := json.NewDecoder(trace)
+	for {
+		var entry applyTraceEntry
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		addr, addrDiags := addrs.ParseAbsResourceInstanceStr(entry.Address)
+		if addrDiags.HasErrors() {
+			return nil, fmt.Errorf("invalid resource instance address %q: %w", entry.Address, addrDiags.Err())
+		}
+		if addr.Resource.Resource.Mode != addrs.ManagedResourceMode || seen.Has(addr) {
+			continue
+		}
+		seen.Add(addr)
+		order = append(order, addr)
+	}
+	return order, nil
+}
+
+// applyTraceOrderTransformer is a GraphTransformer that makes the nodes for
+// each resource instance in Order depend on the nodes for the one before
+// it, so that the walk applies them one at a time in that order, for
+// Context.ReplayApplyTrace.
+//
+// Resource instances without any nodes in the graph are skipped, and any
+// resource instances not in Order are ordered only by their dependencies as
+// usual. An order that conflicts with the existing dependencies is an error.
+type applyTraceOrderTransformer struct {
+	Order []addrs.AbsResourceInstance
+}
+
+func (t *applyTraceOrderTransformer) Transform(g *Graph) error {
+	if len(t.Order) == 0 {
+		return nil
+	}
+
+	nodes := addrs.MakeMap[addrs.AbsResourceInstance, []dag.Vertex]()
+	for _, v := range g.Vertices() {
+		if rn, ok := v.(GraphNodeResourceInstance); ok {
+			addr := rn.ResourceInstanceAddr()
+			nodes.Put(addr, append(nodes.Get(addr), v))
+		}
+	}
+
+	var prev []dag.Vertex
+	for _, addr := range t.Order {
+		current := nodes.Get(addr)
+		if len(current) == 0 {
+			continue
+		}
+		for _, to := range current {
+			dependents, err := g.Descendents(to)
+			if err != nil {
+				return err
+			}
+			for _, from := range prev {
+				if dependents.Include(from) {
+					var diags tfdiags.Diagnostics
+					diags = diags.Append(tfdiags.Sourceless(
+						tfdiags.Error,
+						"Apply trace conflicts with dependencies",
+						fmt.Sprintf("The apply trace orders %s before %s, but %s depends on %s.", dag.VertexName(from), dag.VertexName(to), dag.VertexName(from), dag.VertexName(to)),
+					))
+					return diags.Err()
+				}
+			}
+			for _, from := range prev {
+				g.Connect(dag.BasicEdge(to, from))
+			}
+		}
+		prev = current
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/states/statefile"
+)
+
+func TestContext2Apply_replayApplyTrace(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+}
+
+resource "test_object" "c" {
+  test_string = "c-${test_object.a.test_string}"
+}
+`,
+	})
+
+	// orderedProvider returns a mock provider that applies each planned
+	// change as-is and reports the order of its ApplyResourceChange calls.
+	orderedProvider := func() (*MockProvider, func() []string) {
+		var mu sync.Mutex
+		var order []string
+		p := simpleMockProvider()
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			mu.Lock()
+			order = append(order, req.PlannedState.GetAttr("test_string").AsString())
+			mu.Unlock()
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		return p, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return order
+		}
+	}
+
+	p, _ := orderedProvider()
+	var trace bytes.Buffer
+	recorder := NewApplyTraceRecorder(&trace)
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{recorder},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	recordedState, diags := ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("failed to record trace: %s", err)
+	}
+	if got, want := strings.Count(trace.String(), "\n"), 3; got != want {
+		t.Fatalf("wrong number of trace entries: got %d, want %d\n%s", got, want, trace.String())
+	}
+
+	// Applying a plan consumes it, so each replay needs a new plan.
+	newPlan := func(t *testing.T) *plans.Plan {
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		return plan
+	}
+
+	t.Run("recorded order", func(t *testing.T) {
+		plan := newPlan(t)
+		mock, order := orderedProvider()
+		replayedState, diags := ctx.ReplayApplyTrace(context.Background(), bytes.NewReader(trace.Bytes()), plan, m, map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(mock),
+		})
+		assertNoErrors(t, diags)
+		if !statefile.StatesMarshalEqual(replayedState, recordedState) {
+			t.Errorf("replayed state differs from recorded state\nreplayed:\n%s\nrecorded:\n%s", replayedState, recordedState)
+		}
+		if got := len(order()); got != 3 {
+			t.Errorf("wrong number of provider calls: got %d, want 3", got)
+		}
+	})
+
+	t.Run("given order", func(t *testing.T) {
+		plan := newPlan(t)
+		trace := strings.Join([]string{
+			`{"address":"test_object.b","action":"Create"}`,
+			`{"address":"test_object.a","action":"Create"}`,
+			`{"address":"test_object.c","action":"Create"}`,
+		}, "\n")
+		mock, order := orderedProvider()
+		replayedState, diags := ctx.ReplayApplyTrace(context.Background(), strings.NewReader(trace), plan, m, map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(mock),
+		})
+		assertNoErrors(t, diags)
+		if diff := cmp.Diff([]string{"b", "a", "c-a"}, order()); diff != "" {
+			t.Errorf("wrong apply order\n%s", diff)
+		}
+		if !statefile.StatesMarshalEqual(replayedState, recordedState) {
+			t.Errorf("replayed state differs from recorded state\nreplayed:\n%s\nrecorded:\n%s", replayedState, recordedState)
+		}
+	})
+
+	t.Run("conflicting order", func(t *testing.T) {
+		plan := newPlan(t)
+		trace := strings.Join([]string{
+			`{"address":"test_object.c","action":"Create"}`,
+			`{"address":"test_object.a","action":"Create"}`,
+		}, "\n")
+		mock, order := orderedProvider()
+		_, diags := ctx.ReplayApplyTrace(context.Background(), strings.NewReader(trace), plan, m, map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(mock),
+		})
+		if !diags.HasErrors() {
+			t.Fatal("replay succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Apply trace conflicts with dependencies"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant: message containing %q", got, want)
+		}
+		if got := order(); len(got) != 0 {
+			t.Errorf("replay applied changes despite the conflict: %v", got)
+		}
+	})
+
+	t.Run("invalid trace", func(t *testing.T) {
+		plan := newPlan(t)
+		_, diags := ctx.ReplayApplyTrace(context.Background(), strings.NewReader("not json"), plan, m, nil)
+		if !diags.HasErrors() {
+			t.Fatal("replay succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Failed to read apply trace"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant: message containing %q", got, want)
+		}
+	})
+}
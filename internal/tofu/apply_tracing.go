@@ -0,0 +1,92 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// applyTracer produces OpenTelemetry spans describing an apply operation,
+// as requested using ApplyOpts.Tracer.
+//
+// A nil *applyTracer is valid and produces no spans at all, so that callers
+// don't need to check whether the current operation is being traced.
+type applyTracer struct {
+	tracer trace.Tracer
+
+	// ctx carries the span for the overall apply operation, which is the
+	// parent of all of the resource operation spans.
+	ctx  context.Context
+	span trace.Span
+}
+
+// startApplyTracer starts the span for an overall apply operation, returning
+// a tracer that will produce child spans for each resource operation and the
+// context to use for the remainder of the apply.
+//
+// If the given tracer is nil then startApplyTracer returns a nil
+// *applyTracer and the given context unchanged.
+func startApplyTracer(ctx context.Context, tracer trace.Tracer, plan *plans.Plan) (*applyTracer, context.Context) {
+	if tracer == nil {
+		return nil, ctx
+	}
+	ctx, span := tracer.Start(ctx, "apply", trace.WithAttributes(
+		attribute.String("opentofu.plan.mode", plan.UIMode.String()),
+	))
+	return &applyTracer{
+		tracer: tracer,
+		ctx:    ctx,
+		span:   span,
+	}, ctx
+}
+
+// End ends the span for the overall apply operation, marking it as failed
+// if the given diagnostics contain errors.
+func (t *applyTracer) End(diags tfdiags.Diagnostics) {
+	if t == nil {
+		return
+	}
+	endSpan(t.span, diags)
+}
+
+// StartResourceSpan starts a span for an operation on a single resource
+// instance object, returning a function that must be called with the
+// diagnostics from that operation once it is complete.
+func (t *applyTracer) StartResourceSpan(addr addrs.AbsResourceInstance, deposedKey states.DeposedKey, action plans.Action) func(tfdiags.Diagnostics) {
+	if t == nil {
+		return func(tfdiags.Diagnostics) {}
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("opentofu.resource.address", addr.String()),
+		attribute.String("opentofu.resource.action", action.String()),
+	}
+	if deposedKey != states.NotDeposed {
+		attrs = append(attrs, attribute.String("opentofu.resource.deposed_key", deposedKey.String()))
+	}
+	_, span := t.tracer.Start(t.ctx, addr.String(), trace.WithAttributes(attrs...))
+	return func(diags tfdiags.Diagnostics) {
+		endSpan(span, diags)
+	}
+}
+
+func endSpan(span trace.Span, diags tfdiags.Diagnostics) {
+	if diags.HasErrors() {
+		err := diags.Err()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,73 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"sort"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/checks"
+	"github.com/opentofu/opentofu/internal/states"
+)
+
+// CheckStatusChange describes a checkable object whose check status after
+// an apply differs from its status in the plan that was applied.
+type CheckStatusChange struct {
+	Addr addrs.Checkable
+
+	// Planned and Applied are the aggregate statuses of all of the object's
+	// checks in the plan and after the apply respectively. An object that
+	// was only known in one of the two has checks.StatusUnknown in the
+	// other.
+	Planned checks.Status
+	Applied checks.Status
+}
+
+// checkStatusChanges compares the object check statuses in the given plan
+// and apply results, returning the changes sorted by object address.
+func checkStatusChanges(planned, applied *states.CheckResults) []CheckStatusChange {
+	plannedStatuses := checkObjectStatuses(planned)
+	appliedStatuses := checkObjectStatuses(applied)
+
+	var ret []CheckStatusChange
+	for _, elem := range plannedStatuses.Elems {
+		if appliedStatus := appliedStatuses.Get(elem.Key); appliedStatus != elem.Value {
+			ret = append(ret, CheckStatusChange{
+				Addr:    elem.Key,
+				Planned: elem.Value,
+				Applied: appliedStatus,
+			})
+		}
+	}
+	for _, elem := range appliedStatuses.Elems {
+		if plannedStatuses.Has(elem.Key) || elem.Value == checks.StatusUnknown {
+			continue
+		}
+		ret = append(ret, CheckStatusChange{
+			Addr:    elem.Key,
+			Planned: checks.StatusUnknown,
+			Applied: elem.Value,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Addr.String() < ret[j].Addr.String()
+	})
+	return ret
+}
+
+func checkObjectStatuses(results *states.CheckResults) addrs.Map[addrs.Checkable, checks.Status] {
+	ret := addrs.MakeMap[addrs.Checkable, checks.Status]()
+	if results == nil {
+		return ret
+	}
+	for _, aggr := range results.ConfigResults.Elems {
+		for _, obj := range aggr.Value.ObjectResults.Elems {
+			ret.Put(obj.Key, obj.Value.Status)
+		}
+	}
+	return ret
+}
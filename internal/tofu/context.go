@@ -14,6 +14,7 @@ import (
 
 	"github.com/opentofu/opentofu/internal/addrs"
 	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/depsfile"
 	"github.com/opentofu/opentofu/internal/encryption"
 	"github.com/opentofu/opentofu/internal/logging"
 	"github.com/opentofu/opentofu/internal/providers"
@@ -46,6 +47,12 @@ type ContextOpts struct {
 	Provisioners map[string]provisioners.Factory
 	Encryption   encryption.Encryption
 
+	// ProviderLocks, if set, describes the versions of the providers in
+	// Providers, typically from the working directory's dependency lock
+	// file. ApplyPlanFile checks them against the provider versions that a
+	// saved plan was created with.
+	ProviderLocks *depsfile.Locks
+
 	UIInput UIInput
 }
 
@@ -85,6 +92,10 @@ type Context struct {
 	sh      *stopHook
 	uiInput UIInput
 
+	// callerHooks are the hooks from ContextOpts.Hooks, without the stop
+	// hook that NewContext adds to hooks.
+	callerHooks []Hook
+
 	parallelSem         Semaphore
 	l                   sync.Mutex // Lock acquired during any task
 	providerInputConfig map[string]map[string]cty.Value
@@ -92,7 +103,34 @@ type Context struct {
 	runContext          context.Context
 	runContextCancel    context.CancelFunc
 
+	// lastApply retains some supplemental results from the most recent call
+	// to Apply, for use by the LastApply-prefixed accessor methods. Access
+	// only while holding l.
+	lastApply *lastApplyResults
+
+	// applyStatus tracks the progress of the apply walk currently in
+	// progress, if any, for use by ApplyStatus. Access only while holding l.
+	applyStatus *applyProgressHook
+
+	// applyRunID is the run ID of the apply operation currently in
+	// progress, if any, for use by CancelRun. Access only while holding l.
+	applyRunID string
+
+	// applyProgress tracks the progress of the apply operation currently
+	// in progress, once it has prepared its changes, for use by
+	// ApplyProgressSnapshot. Unlike applyStatus, it remains set until the
+	// apply returns. Access only while holding l.
+	applyProgress *applyProgressHook
+
+	// applyPerResourceHooks are the ApplyOpts.PerResourceHooks of the apply
+	// operation currently in progress, if any, so that Stop and CancelRun
+	// can notify them too. Access only while holding l.
+	applyPerResourceHooks *perResourceHooks
+
 	encryption encryption.Encryption
+
+	// providerLocks are the locks from ContextOpts.ProviderLocks, if any.
+	providerLocks *depsfile.Locks
 }
 
 // (additional methods on Context can be found in context_*.go files.)
@@ -143,13 +181,16 @@ func NewContext(opts *ContextOpts) (*Context, tfdiags.Diagnostics) {
 		meta:    opts.Meta,
 		uiInput: opts.UIInput,
 
+		callerHooks: opts.Hooks,
+
 		plugins: plugins,
 
 		parallelSem:         NewSemaphore(par),
 		providerInputConfig: make(map[string]map[string]cty.Value),
 		sh:                  sh,
 
-		encryption: opts.Encryption,
+		encryption:    opts.Encryption,
+		providerLocks: opts.ProviderLocks,
 	}, diags
 }
 
@@ -185,23 +226,11 @@ func (c *Context) Stop() {
 	c.l.Lock()
 	defer c.l.Unlock()
 
-	// If we're running, then stop
-	if c.runContextCancel != nil {
-		log.Printf("[WARN] tofu: run context exists, stopping")
-
-		// Tell the hook we want to stop
-		c.sh.Stop()
-
-		// Stop the context
-		c.runContextCancel()
-		c.runContextCancel = nil
-	}
+	c.interruptRun()
 
 	// Notify all of the hooks that we're stopping, in case they want to try
 	// to flush in-memory state to disk before a subsequent hard kill.
-	for _, hook := range c.hooks {
-		hook.Stopping()
-	}
+	c.notifyStopping()
 
 	// Grab the condition var before we exit
 	if cond := c.runCond; cond != nil {
@@ -212,11 +241,47 @@ func (c *Context) Stop() {
 	log.Printf("[WARN] tofu: stop complete")
 }
 
+// notifyStopping calls Stopping on each of the context's hooks, and on the
+// per-resource hooks of the apply in progress, if any.
+//
+// The caller must hold c.l.
+func (c *Context) notifyStopping() {
+	for _, hook := range c.hooks {
+		hook.Stopping()
+	}
+	if c.applyPerResourceHooks != nil {
+		c.applyPerResourceHooks.Stopping()
+	}
+}
+
+func (c *Context) setApplyPerResourceHooks(hooks *perResourceHooks) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.applyPerResourceHooks = hooks
+}
+
+// interruptRun asks the running task, if any, to stop as soon as possible,
+// without waiting for it to complete.
+//
+// The caller must hold c.l.
+func (c *Context) interruptRun() {
+	// If we're running, then stop
+	if c.runContextCancel != nil {
+		log.Printf("[WARN] tofu: run context exists, stopping")
+
+		// Tell the hook we want to stop
+		c.sh.Stop()
+
+		// Stop the context
+		c.runContextCancel()
+		c.runContextCancel = nil
+	}
+}
+
 func (c *Context) acquireRun(phase string) func() {
 	// With the run lock held, grab the context lock to make changes
 	// to the run context.
 	c.l.Lock()
-	defer c.l.Unlock()
 
 	// Wait until we're no longer running
 	for c.runCond != nil {
@@ -232,7 +297,26 @@ func (c *Context) acquireRun(phase string) func() {
 	// Reset the stop hook so we're not stopped
 	c.sh.Reset()
 
-	return c.releaseRun
+	c.l.Unlock()
+
+	// We notify the run listeners only after releasing the context lock, so
+	// that they are free to call back into the context if needed.
+	c.notifyRunListeners(phase, RunListener.OnRunAcquired)
+
+	return func() {
+		c.releaseRun()
+		c.notifyRunListeners(phase, RunListener.OnRunReleased)
+	}
+}
+
+// notifyRunListeners calls the given method on each of the context's hooks
+// that implements RunListener.
+func (c *Context) notifyRunListeners(phase string, notify func(RunListener, string)) {
+	for _, h := range c.hooks {
+		if l, ok := h.(RunListener); ok {
+			notify(l, phase)
+		}
+	}
 }
 
 func (c *Context) releaseRun() {
@@ -7,18 +7,805 @@ package tofu
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/checks"
 	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/dag"
+	"github.com/opentofu/opentofu/internal/depsfile"
+	"github.com/opentofu/opentofu/internal/encryption"
 	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/plans/planfile"
+	"github.com/opentofu/opentofu/internal/providers"
 	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/states/statefile"
 	"github.com/opentofu/opentofu/internal/tfdiags"
 )
 
+// ErrPlanErrored is the cause of the error diagnostic that Apply returns
+// when given a plan that was produced by a planning operation that failed.
+//
+// Callers can detect this situation using errors.Is on the result of
+// calling Err on the returned diagnostics.
+var ErrPlanErrored = errors.New("cannot apply failed plan")
+
+// ApplyOpts are the various options that affect the details of how OpenTofu
+// will apply a plan.
+//
+// The zero value of ApplyOpts, and also a nil *ApplyOpts, both represent
+// the default behavior of Context.Apply.
+type ApplyOpts struct {
+	// HookContext is a set of request-scoped values, such as a run ID or the
+	// identity of the user who requested the apply, that will be made
+	// available to any hooks which implement HookContextReceiver.
+	//
+	// OpenTofu Core does not interpret these values in any way.
+	HookContext map[string]any
+
+	// AdditionalExternalReferences are references to objects that should
+	// always be evaluated and retained during the apply walk, in addition to
+	// any external references that were recorded in the plan.
+	//
+	// This is intended for callers embedding OpenTofu that need access to
+	// values, such as local values, that would otherwise be pruned from the
+	// apply graph because nothing in the configuration refers to them.
+	AdditionalExternalReferences []addrs.Referenceable
+
+	// ForgetArchive, if set, is a state that will receive the last-known
+	// objects of any resource instances that this apply forgets, just before
+	// they are removed from the main state. This allows the caller to retain
+	// enough information to reverse the forget operation later.
+	//
+	// The given state is modified in-place, and so the caller must not
+	// access it while the apply operation is running.
+	ForgetArchive *states.State
+
+	// ProviderCallWarningThreshold, if greater than zero, causes the apply
+	// to produce a warning for each resource instance on whose behalf
+	// OpenTofu made more than this number of provider calls in total.
+	//
+	// To retrieve the call counts themselves, set CaptureProviderCalls.
+	ProviderCallWarningThreshold int
+
+	// PlanConfigVerifier, if set, is asked to confirm that the given
+	// configuration is compatible with the plan before OpenTofu begins
+	// applying any changes. If it returns any errors then the apply is
+	// halted before making any changes.
+	//
+	// If this is nil, OpenTofu performs no additional verification beyond
+	// the consistency checks it always makes during the apply walk.
+	PlanConfigVerifier PlanConfigVerifier
+
+	// ProviderCallMiddleware, if set, wraps every call OpenTofu makes to a
+	// provider's ApplyResourceChange operation during the apply walk. This
+	// allows callers to add behaviors such as metrics, tracing, or circuit
+	// breaking without modifying the providers themselves.
+	//
+	// The middleware may be called concurrently for different resource
+	// instances, and so it must be safe for concurrent use.
+	ProviderCallMiddleware ProviderCallMiddleware
+
+	// ReadinessCheck, if set, is polled after each successful change that
+	// leaves a remote object for a resource instance, and OpenTofu
+	// considers the change complete only once it reports that the object
+	// is ready. This supports providers that report success before the
+	// objects they create or update are fully usable.
+	//
+	// OpenTofu polls the check immediately and then with exponential
+	// backoff starting at ReadinessCheckInterval, up to 30 seconds between
+	// polls, for as long as it takes or until the apply is interrupted.
+	// If the check returns an error then the change fails with that error.
+	// The check may be called concurrently for different resource
+	// instances, and so it must be safe for concurrent use.
+	ReadinessCheck ReadinessCheck
+
+	// ReadinessCheckInterval is how long OpenTofu waits before polling
+	// ReadinessCheck for the second time for each change. If it is not
+	// positive then OpenTofu waits one second.
+	ReadinessCheckInterval time.Duration
+
+	// RandomSeed, if set, is offered to the provider of each resource
+	// instance so that providers that generate random values, such as
+	// resource names or passwords, can produce the same values on every
+	// apply. This is useful for reproducible tests.
+	//
+	// The seed is passed as the "random_seed" attribute of the provider
+	// meta object, overriding any value set for it in a provider_meta
+	// block, but only for providers whose provider_meta schema declares a
+	// number attribute of that name. Other providers behave as normal.
+	// Whether and how a provider uses the seed is up to that provider, so
+	// determinism is only best-effort.
+	RandomSeed *int64
+
+	// PolicyEvaluator, if set, is asked to evaluate each change to a managed
+	// resource instance just before OpenTofu applies it, with the planned
+	// new value as updated by anything learned during the apply. If it
+	// returns errors then OpenTofu doesn't apply that change, and so also
+	// doesn't apply anything that depends on it, but still applies the
+	// other changes. See PolicyEvaluator for details.
+	PolicyEvaluator PolicyEvaluator
+
+	// TolerateCorruptChanges, if set, causes Apply to skip any planned
+	// resource instance changes whose values cannot be decoded using the
+	// current provider schemas, returning a warning for each one, and to
+	// apply the remaining changes as normal.
+	//
+	// This is intended only for recovering from a partially-corrupted saved
+	// plan. Any resource instances whose changes are skipped remain as they
+	// were in the prior state, and other objects that depend on them will
+	// see their prior values.
+	TolerateCorruptChanges bool
+
+	// ReturnPriorState, if set, causes Apply to retain a snapshot of the
+	// exact prior state that the apply walk began from, which the caller
+	// can then retrieve using Context.LastApplyPriorState.
+	//
+	// This is useful for callers that want to compare the state before and
+	// after the apply without having to keep their own copy of the plan's
+	// prior state.
+	ReturnPriorState bool
+
+	// RecordFailures, if set, causes Apply to retain a copy of the plan and
+	// of the new state if any of the planned changes fail or are not
+	// reached, which the caller can then retrieve using
+	// Context.LastApplyFailures and pass to Context.RetryFailed to attempt
+	// those changes again without creating a new plan.
+	RecordFailures bool
+
+	// LazyProviders, if set, causes OpenTofu to defer calling each provider
+	// instance's ConfigureProvider operation until a resource that belongs
+	// to that provider instance first needs it, rather than configuring all
+	// provider instances up front. Provider instances that are not needed
+	// by any resource operation during the apply are never configured.
+	//
+	// The provider configuration is still validated up front as usual. Any
+	// errors returned from ConfigureProvider are reported against the first
+	// resource operation that required the provider.
+	LazyProviders bool
+
+	// Tracer, if set, is used to produce OpenTelemetry spans describing the
+	// apply: one span for the overall apply operation, with a child span for
+	// each operation on a resource instance object. A span whose operation
+	// failed has its status set to codes.Error and records the error.
+	//
+	// The graph walk does not proceed in discrete topological levels, and
+	// so the resource operation spans are all direct children of the
+	// overall apply span. Their start and end times reflect the order in
+	// which the operations actually ran.
+	Tracer trace.Tracer
+
+	// MaxStateBytes, if greater than zero, is the maximum size in bytes of
+	// the new state that Apply may produce, measured as the size of the
+	// unencrypted state snapshot that would be written to a state file.
+	//
+	// If the new state exceeds this size then Apply returns an error
+	// diagnostic, but still returns the new state so that the caller can
+	// decide whether to persist it anyway. The apply has already been
+	// completed at this point, so the state describes the real remote
+	// objects either way.
+	MaxStateBytes int64
+
+	// StateTransform, if set, is called with the new state at the end of
+	// the apply, and Apply returns the state it returns instead. This
+	// allows callers to normalize or annotate the state before persisting
+	// it, such as by removing attributes they don't want to store.
+	//
+	// StateTransform is called whether or not the apply succeeded, before
+	// the state is checked against MaxStateBytes and RequireNonNullOutputs,
+	// and may modify and return the state it is given. If it returns an
+	// error, or a nil state, then Apply returns an error diagnostic along
+	// with the untransformed state.
+	StateTransform func(*states.State) (*states.State, error)
+
+	// RunMetadata, if set, describes this apply run, such as a run ID, the
+	// actor who started it, or a link to a CI job, and is recorded in the
+	// RunMetadata of the returned state so that it's saved along with it.
+	//
+	// When this is set, Apply replaces any metadata recorded by an earlier
+	// apply rather than merging with it. When it is nil, the returned state
+	// keeps whatever metadata the prior state had. The metadata is recorded
+	// before calling StateTransform.
+	RunMetadata map[string]string
+
+	// RequireNonNullOutputs, if set, are the names of root module output
+	// values that must not be null after the apply. If any of them is null
+	// then Apply returns an error diagnostic for each, but still returns
+	// the new state because the changes have already been applied.
+	//
+	// This is checked only if the apply otherwise succeeded, and not when
+	// applying a destroy plan, which always removes all of the outputs. It
+	// is an error to name an output value that isn't declared in the root
+	// module, and Apply returns that error before making any changes.
+	RequireNonNullOutputs []string
+
+	// GraphDumpOnError, if set, receives the apply graph that Apply walked,
+	// rendered in the same "dot" format as the "tofu graph" command, if
+	// Apply returns any errors. Nothing is written if the apply succeeds,
+	// nor if it fails before the graph is built, such as when the plan is
+	// not applyable.
+	//
+	// The rendering includes all of the nodes in the graph, including those
+	// that "tofu graph" shows only in verbose mode, so that it exactly
+	// describes the apply for a post-mortem.
+	GraphDumpOnError io.Writer
+
+	// ModuleParallelism, if set, limits how many resource instance
+	// operations in each of the given modules Apply may run at once, in
+	// addition to the overall limit given in ContextOpts.Parallelism. This
+	// limits the blast radius of an apply in modules that manage
+	// sensitive infrastructure without slowing down the others.
+	//
+	// The keys are static module addresses as returned by
+	// addrs.Module.String, such as "module.network", with the empty string
+	// representing the root module. Each limit applies only to the
+	// resources declared directly in that module, and not to those in its
+	// child modules, and is shared between all instances of the module.
+	// Apply returns an error before making any changes if a key is not a
+	// module in the configuration or a limit is less than one.
+	ModuleParallelism map[string]int
+
+	// CleanupDependentsOnFailure, if set, causes Apply to destroy the prior
+	// objects of any resource instances that were skipped because a resource
+	// instance they depend on failed to be created.
+	//
+	// This is an advanced recovery behavior for callers that would rather
+	// reach a minimal consistent state than retain objects that may refer to
+	// something that no longer exists. The destroy operations run only after
+	// the main apply walk is complete, and only for dependents that already
+	// existed in the plan's prior state.
+	CleanupDependentsOnFailure bool
+
+	// RollbackOnError, if set, causes Apply to destroy the objects it created
+	// for planned creates if the apply fails, so that a failed apply adds
+	// as few new objects as possible.
+	//
+	// Only objects for resource instances that did not exist in the plan's
+	// prior state are destroyed. Completed updates, replacements, and
+	// destroys can't be rolled back like this and so remain in effect,
+	// and Apply returns a warning listing them. The rollback runs only
+	// after the main apply walk is complete, and after any destroys for
+	// CleanupDependentsOnFailure.
+	RollbackOnError bool
+
+	// WarnIncompleteApply, if set, causes Apply to return a warning that
+	// summarizes how many of the planned changes were applied, failed, and
+	// not reached, if any of them were not reached, whether because the
+	// apply was stopped or because changes they depended on failed.
+	//
+	// The same counts are available regardless of this setting, using
+	// Context.LastApplyChangeCounts after the apply has completed.
+	WarnIncompleteApply bool
+
+	// CaptureSchemas, if set, causes Apply to retain the provider schemas it
+	// used, which the caller can then retrieve using
+	// Context.LastApplyProviderSchemas.
+	//
+	// This allows a caller to keep a record of exactly which schemas the
+	// objects in the new state conform to, so that they can be decoded
+	// later even if the providers are no longer installed.
+	CaptureSchemas bool
+
+	// CaptureProviderCalls, if set, causes Apply to count the provider calls
+	// that OpenTofu makes on behalf of each resource instance, which the
+	// caller can then retrieve using Context.LastApplyProviderCalls.
+	CaptureProviderCalls bool
+
+	// CaptureReadSources, if set, causes Apply to record where it got the
+	// current object of each resource instance, which the caller can then
+	// retrieve using Context.LastApplyReadSources.
+	CaptureReadSources bool
+
+	// CaptureDiagnostics, if set, causes Apply to record which resource
+	// instance each diagnostic belongs to and which diagnostics came from
+	// providers, so that the caller can then use
+	// Context.LastApplyDiagnosticsByResource,
+	// Context.LastApplyDiagnosticsSARIF, and
+	// Context.LastApplyProviderWarnings.
+	//
+	// This also classifies the diagnostics that Apply returns from providers
+	// as tfdiags.CategoryProvider.
+	CaptureDiagnostics bool
+
+	// CaptureReferencedVariables, if set, causes Apply to record which input
+	// variables the expressions it evaluates refer to, which the caller can
+	// then retrieve using Context.LastApplyReferencedVariables.
+	CaptureReferencedVariables bool
+
+	// CaptureCheckDiff, if set, causes Apply to keep a copy of the check
+	// results from the plan, so that the caller can then compare them with
+	// the results of the apply using Context.LastApplyCheckDiff.
+	CaptureCheckDiff bool
+
+	// CapturePrunedResourceHusks, if set, causes Apply to record which
+	// resources with no instances it removed from the state, which the
+	// caller can then retrieve using Context.LastApplyPrunedResourceHusks.
+	CapturePrunedResourceHusks bool
+
+	// CaptureConsumedVariables, if set, causes Apply to record which root
+	// module input variables its graph refers to, which the caller can then
+	// retrieve using Context.LastApplyConsumedVariables.
+	CaptureConsumedVariables bool
+
+	// IgnorePriorState, if set, causes Apply to behave as if the plan's prior
+	// state contained no managed resource objects at all, and so to create
+	// new objects for all of the managed resource instances that the plan
+	// would otherwise have created, updated, replaced, or left unchanged.
+	// Planned destroy and forget actions are skipped, because there is
+	// nothing to destroy or forget.
+	//
+	// This is intended for bootstrapping test environments or recovering
+	// after a state has been lost entirely. Any remote objects that still
+	// exist are not tracked by the new state, and so this is likely to
+	// create duplicate objects. Apply always returns a warning saying so.
+	//
+	// Data resource objects from the prior state are retained, because
+	// they do not represent remote objects that OpenTofu manages.
+	IgnorePriorState bool
+
+	// BatchForgetHooks, if set, causes Apply to call any ForgetBatchListener
+	// hooks once before and once after the apply walk with the addresses of
+	// all of the resource instances that the plan forgets, for callers that would
+	// rather handle the forgotten objects together than one at a time.
+	BatchForgetHooks bool
+
+	// Breakpoints, if set, are callbacks that Apply calls just before it
+	// begins each operation on the corresponding resource instances, passing
+	// a snapshot of the partially-updated state at that moment.
+	//
+	// This is intended as a debugging aid for understanding the order in
+	// which OpenTofu processes resource instances. The operation on the
+	// resource instance waits until its callback returns, but other
+	// independent operations may continue concurrently, so the callbacks
+	// must be safe for concurrent use. Each callback receives its own copy
+	// of the state, which it may retain and modify freely.
+	Breakpoints addrs.Map[addrs.AbsResourceInstance, func(*states.State)]
+
+	// PerResourceHooks, if set, are hooks that Apply notifies only of the
+	// events that concern their corresponding resource instances, in
+	// addition to notifying the hooks given in ContextOpts of all events.
+	// This allows instrumenting specific resource instances without
+	// filtering the events for all of the others.
+	//
+	// Events that don't concern a single resource instance, such as
+	// PostStateUpdate and ApplyProgress, are not passed to these hooks.
+	// The hooks may implement ApplyGate to decide whether their resource
+	// instance is created.
+	PerResourceHooks addrs.Map[addrs.AbsResourceInstance, Hook]
+
+	// RecordApplyCalls, if set, receives a serialization of each call that
+	// OpenTofu makes to a provider's ApplyResourceChange operation during
+	// the apply, along with the provider's response, so that the apply can
+	// be reproduced later using PlaybackApplyCalls.
+	//
+	// Only the ApplyResourceChange calls are recorded. Other provider
+	// operations, such as configuring the provider, fetching its schema,
+	// and reading resources and data sources, are not.
+	//
+	// The recording includes the full configuration and state values,
+	// including any sensitive values, and so the caller must protect it
+	// accordingly. If any call cannot be recorded then Apply returns an
+	// error after completing the apply.
+	RecordApplyCalls io.Writer
+
+	// PlaybackApplyCalls, if set, is a recording previously written to
+	// RecordApplyCalls, whose responses OpenTofu uses instead of calling
+	// the providers' ApplyResourceChange operations.
+	//
+	// Each resource instance receives its recorded responses in the order
+	// they were recorded. An operation for which there is no recorded
+	// response fails with an error. OpenTofu still uses the configured
+	// providers for their schemas and for all other operations, so this
+	// allows reproducing an apply without making any changes to remote
+	// objects, but not without the providers.
+	PlaybackApplyCalls io.Reader
+
+	// SuppressAttributes, if set, lists attribute paths, by resource
+	// address, whose new values OpenTofu does not write to the state when
+	// it updates an instance of that resource. The state instead retains
+	// the prior value of each suppressed attribute, as though it had not
+	// changed. The addresses match resources of that address in any module.
+	//
+	// This is intended for working around providers that report spurious
+	// changes to certain attributes. It affects only the state: the
+	// provider still applies the planned change, and so the state may no
+	// longer match the remote object. Suppression applies only to updates,
+	// because a newly-created object has no prior values to retain.
+	//
+	// Attributes that the provider schema marks as required cannot be
+	// suppressed, because their values must always match the
+	// configuration. Apply returns a warning for any such path, or any path
+	// that does not refer to an attribute, and ignores it.
+	SuppressAttributes map[addrs.Resource][]cty.Path
+
+	// ForgetAuditWriter, if set, receives an audit record for each resource
+	// instance object that the apply forgets, at the moment it is removed
+	// from the state.
+	//
+	// Each record is a JSON object on its own line, with the properties
+	// "address", "type" (the resource type), "attributes" (the last-known
+	// attribute values, with any sensitive values replaced by the string
+	// "(sensitive value)") and "timestamp" (in RFC 3339 format). Records
+	// for deposed objects also have a "deposed" property giving the deposed
+	// key. If any record cannot be written then Apply returns an error
+	// after completing the apply.
+	ForgetAuditWriter io.Writer
+
+	// ProviderLocks, if set, describes the provider versions that are
+	// available to the Context, typically from the working directory's
+	// dependency lock file. It overrides ContextOpts.ProviderLocks.
+	//
+	// When applying a saved plan file with ApplyPlanFileWithOpts, the
+	// version of each provider recorded in the plan file's dependency locks
+	// is compared against these locks, and the apply fails without taking
+	// any actions if any of them differ, because a different provider
+	// version might not produce the results that were planned. If neither
+	// these options nor the Context have any locks to compare against then
+	// the apply fails too, unless the plan file records no providers.
+	//
+	// The other apply methods cannot make this check, because a plan alone
+	// does not record provider versions, and so they return an error if
+	// ProviderLocks is set rather than silently ignoring it.
+	ProviderLocks *depsfile.Locks
+
+	// AllowProviderUpgrade disables the check of the current provider
+	// versions against those recorded in a saved plan file.
+	AllowProviderUpgrade bool
+
+	// Scheduler, if set, chooses which of the graph nodes that are ready
+	// to execute is given each available parallel execution slot during
+	// the apply walk, instead of OpenTofu's default unspecified order.
+	//
+	// A Scheduler can change only the order in which independent nodes
+	// execute, and never allows a node to execute before its dependencies
+	// or more nodes to execute at once than the Context's parallelism.
+	Scheduler Scheduler
+
+	// ShuffleSeed, if nonzero, makes the apply walk give the available
+	// parallel execution slots to the graph nodes that are ready to execute
+	// in a pseudo-random order derived from the seed, instead of OpenTofu's
+	// default order.
+	//
+	// This is intended for testing, to find ordering assumptions between
+	// resources that don't depend on each other. The same seed always makes
+	// the same choice between the same set of waiting nodes, and so setting
+	// Parallelism to 1 in the ContextOpts makes order-dependent problems
+	// more reproducible. ShuffleSeed cannot be used with Scheduler.
+	ShuffleSeed int64
+
+	// ReverseOrder, if set, makes the apply walk give the available
+	// parallel execution slots to the graph nodes that are ready to execute
+	// in reverse dependency order, preferring the nodes with the longest
+	// chains of dependencies, instead of OpenTofu's default order.
+	//
+	// This is intended only for testing, to find resources that rely on
+	// being applied before or after other resources they don't depend on,
+	// and Apply always returns a warning when it is set. Like Scheduler,
+	// it cannot make a node execute before its dependencies, and so setting
+	// Parallelism to 1 in the ContextOpts makes it most effective.
+	// ReverseOrder cannot be used with ShuffleSeed or Scheduler.
+	ReverseOrder bool
+
+	// LockChecker, if set, is called periodically during the apply walk to
+	// verify that the caller still holds its lock on the state, such as
+	// by renewing a lease. If it returns an error then OpenTofu stops the
+	// apply as if it had been interrupted, so that it won't make further
+	// changes that could conflict with another process that has since
+	// taken the lock. Apply then returns the partial state along with an
+	// error diagnostic that has the returned error as its cause.
+	//
+	// LockChecker is called from a separate goroutine, so it must be safe
+	// to call concurrently with the apply.
+	LockChecker func() error
+
+	// LockCheckInterval is how often LockChecker is called. If it is not
+	// positive then LockChecker is called every ten seconds.
+	LockCheckInterval time.Duration
+
+	// GoroutineLimit, if positive, makes OpenTofu periodically count the
+	// goroutines in its own process during the apply walk, and return a
+	// warning if the count ever exceeds the limit. This is intended for
+	// long-running programs that embed OpenTofu, to notice a plugin that
+	// is leaking resources before it exhausts them.
+	//
+	// Provider plugins usually run in separate processes, and so this
+	// counts only the goroutines used to communicate with them, along with
+	// those of OpenTofu itself and of the program that called Apply.
+	GoroutineLimit int
+
+	// GoroutineCheckInterval is how often the goroutines are counted when
+	// GoroutineLimit is set. If it is not positive then they are counted
+	// every second.
+	GoroutineCheckInterval time.Duration
+
+	// AbortOnGoroutineLimit, if set, makes OpenTofu stop the apply as if it
+	// had been interrupted as soon as the count exceeds GoroutineLimit, and
+	// return an error instead of a warning.
+	AbortOnGoroutineLimit bool
+
+	// OnDiagnostic, if set, is called with each diagnostic as soon as it
+	// is produced during the apply, so that callers can report problems
+	// with a long apply before it completes. Diagnostics that are not
+	// produced by a specific operation, such as those from validating the
+	// plan before applying it, are passed to OnDiagnostic just before Apply
+	// returns.
+	//
+	// OnDiagnostic is called once for each diagnostic that Apply returns,
+	// and Apply still returns all of them as normal. It is never called
+	// concurrently, but may be called from a different goroutine than the
+	// one that called Apply, and blocks the apply while it runs.
+	OnDiagnostic func(tfdiags.Diagnostic)
+
+	// ExplainSkippedChanges, if set, makes Apply return a warning for each
+	// resource instance change that was skipped because one of the changes
+	// it depends on failed, naming the dependency that failed. If the
+	// dependency was itself skipped then the warning names the failure that
+	// caused that skip instead, so that the warnings always lead back to
+	// an error that Apply also returns.
+	ExplainSkippedChanges bool
+
+	// TransferPriorState, if set, makes Apply hand its own working copy of
+	// the plan's prior state over to the apply walk, which then updates that
+	// copy in place instead of making a second copy of it first. Apply
+	// doesn't use its copy again once it has handed it over, and it never
+	// modifies the plan's prior state itself either way.
+	//
+	// BenchmarkContext2Apply_transferPriorState measures the allocations
+	// this saves for a large prior state.
+	TransferPriorState bool
+
+	// DataSourceResults, if set, are fixed results to use for the
+	// corresponding data resource instances whenever they are read during
+	// the apply walk, instead of asking their providers to read them. This
+	// is intended for hermetic testing.
+	//
+	// Most data resources are read during planning and so are not read
+	// again during apply. This affects only those that were deferred to the
+	// apply step, such as because their configuration depended on values
+	// that were not known until apply, and those nested in check blocks.
+	//
+	// Each value must conform to the schema of its data source, after the
+	// usual type conversions. Apply returns an error for any that don't.
+	DataSourceResults addrs.Map[addrs.AbsResourceInstance, cty.Value]
+
+	// VariableFallbacks, if set, are values to use for the root module input
+	// variables of the same names whose values recorded in the plan cannot
+	// be decoded, such as because the plan file is corrupt. Apply returns a
+	// warning for each fallback it uses, instead of an error.
+	//
+	// Each fallback must conform to the type constraint of its variable,
+	// after the usual type conversions. Apply returns an error for any that
+	// don't.
+	VariableFallbacks map[string]cty.Value
+
+	// RequireStateMatch, if set, makes Apply refresh the plan's prior state
+	// before making any changes, and return an error without applying
+	// anything if any managed resource instance's remote object has changed
+	// since the plan was created.
+	//
+	// This costs an extra read of every resource instance in the prior
+	// state, but ensures that a plan is only applied to the same objects it
+	// was created against.
+	RequireStateMatch bool
+
+	// RefreshOnly, if set, makes Apply refresh only the given resource
+	// instances, and any that they depend on, before making any changes,
+	// and then apply the plan to their refreshed objects instead of the
+	// objects recorded in the plan's prior state. All other resource
+	// instances are not refreshed, and are taken from the prior state as
+	// normal.
+	//
+	// If RequireStateMatch is also set then only these resource instances
+	// are checked for changes since the plan was created.
+	//
+	// This is unrelated to the refresh-only planning mode.
+	RefreshOnly []addrs.AbsResourceInstance
+
+	// AddressRenames, if set, relocates objects in the plan's prior state
+	// before applying it, moving the object for each resource instance
+	// address that is a key to the corresponding value. This allows
+	// reconciling a rename that the plan didn't capture, such as a
+	// resource that was renamed in the configuration without a moved block
+	// while the plan was targeted elsewhere.
+	//
+	// Each rename must be between managed resource instances of the same
+	// type, its source must exist in the prior state, and no other object
+	// may already have or be renamed to its destination. The plan must not
+	// have any changes for either address, because those would have been
+	// planned against the original addresses. Apply returns errors without
+	// making any changes if any rename is invalid.
+	AddressRenames addrs.Map[addrs.AbsResourceInstance, addrs.AbsResourceInstance]
+
+	// ForbidTarget, if set, makes Apply return an error without applying
+	// anything if the plan was created with the -target or -exclude option
+	// in effect, instead of applying it and warning that the changes may be
+	// incomplete. This allows automation to enforce that only complete
+	// plans are applied.
+	ForbidTarget bool
+
+	// TypeQuotas, if set, are the maximum numbers of managed resource
+	// instances of each resource type, keyed by type name, that may exist
+	// after the apply. Apply returns an error without applying anything if
+	// the instances in the plan's prior state, plus those the plan creates
+	// and minus those it destroys or forgets, would exceed any quota.
+	//
+	// Quotas count resource instances across all modules. Resource types
+	// without a quota are not limited.
+	TypeQuotas map[string]int
+
+	// RunID, if set, identifies this apply for Context.CancelRun, so that a
+	// caller can cancel it from another goroutine while it's running. If
+	// it is not set then Apply generates a random run ID. Either way, the
+	// ID is available afterwards from Context.LastApplyRunID.
+	RunID string
+
+	// ErrorRateThreshold, if positive, makes OpenTofu stop the apply as if
+	// it had been interrupted as soon as more than this proportion of the
+	// planned changes have failed, such as 0.1 to stop once more than a
+	// tenth of them have failed. It must be less than 1.
+	//
+	// The proportion is of all of the changes in the plan, excluding no-op
+	// changes, rather than of those attempted so far, so that a few
+	// failures early in a large apply don't stop it. The changes that are
+	// already running when the threshold is exceeded are allowed to finish.
+	ErrorRateThreshold float64
+
+	// ForgetLast, if set, makes OpenTofu forget the resource instances that
+	// the plan forgets only after all of the plan's creates, updates and
+	// destroys have succeeded, rather than in whatever order the
+	// dependencies allow. If any of those changes fail then nothing is
+	// forgotten, so that objects aren't dropped from the state part way
+	// through a migration.
+	ForgetLast bool
+
+	// TaintOnWarning, if set, makes OpenTofu mark as tainted in the new
+	// state each managed resource instance whose apply produced a warning,
+	// so that the next plan replaces it. Apply adds a warning listing the
+	// resource instances that it tainted.
+	TaintOnWarning bool
+
+	// ResourceTimeouts, if set, are the longest that OpenTofu waits for the
+	// provider to apply the changes to each of the given resource instances
+	// before asking the provider to stop. A zero or negative duration means
+	// no limit at all.
+	//
+	// For resource instances not in the map, OpenTofu uses the timeout for
+	// the planned action from the resource's "timeouts" block, if any, so
+	// that a provider that doesn't enforce that block itself can't keep the
+	// apply waiting forever.
+	//
+	// Stopping a provider interrupts all of the operations that provider
+	// instance has in progress, not just the one that timed out. OpenTofu
+	// then waits for the provider to respond, reports an error, and records
+	// the object as the provider reported it, so that a timed out create
+	// can't leave a remote object that the state doesn't track.
+	ResourceTimeouts addrs.Map[addrs.AbsResourceInstance, time.Duration]
+
+	// RefreshUpdatesOnly, if set, makes Apply skip all of the plan's changes
+	// to managed resource instances other than no-op changes, so that it
+	// only writes the objects as they were refreshed during planning into
+	// the new state, as ApplyRefreshOnly does for a refresh-only plan. Apply
+	// adds a warning listing the changes that it skipped. The root module
+	// output values also keep their values from the plan's prior state.
+	RefreshUpdatesOnly bool
+
+	// FunctionOverrides, if set, replace the built-in functions of the same
+	// names while evaluating the configuration during the apply, such as to
+	// make uuid or timestamp return fixed values for a reproducible apply.
+	// Overriding a function also overrides its "core::" alias.
+	//
+	// Each override must be named after a built-in function and must take
+	// the same parameters, or Apply returns an error. The values that the
+	// plan already recorded aren't affected, and so this matters only for
+	// values that the plan left unknown until apply.
+	FunctionOverrides map[string]function.Function
+
+	// StatePartitioner, if set, is called for each resource instance in the
+	// new state to choose which of several partitions it belongs in, such
+	// as for a backend that stores each shard of the state separately.
+	// After the apply, LastApplyPartitionedStates returns the new state
+	// split into a separate state for each partition key.
+	//
+	// The empty key is the default partition, which also holds everything
+	// in the state that isn't a resource instance, like the output values.
+	// Apply itself still returns the whole new state.
+	StatePartitioner func(addr addrs.AbsResourceInstance) string
+
+	// ExtraEdges are orderings between resource instances to enforce during
+	// the apply in addition to those implied by their dependencies, for
+	// when something outside of the configuration requires that one
+	// object is changed before another. An edge whose resource instances
+	// don't both have changes in the plan has no effect.
+	//
+	// Apply returns an error without making any changes if an edge orders
+	// an instance before itself or before something it depends on, since
+	// that would create a dependency cycle.
+	ExtraEdges []ApplyEdge
+
+	// MaxProviderInstances, if positive, limits how many provider instances
+	// OpenTofu has open at once during the apply, to bound the memory used
+	// by provider plugins. Once that many are open, another provider is
+	// started only after one of them has been closed, when all of the
+	// resource instances that use it are done, and so the resource
+	// instances that use a provider that isn't yet started wait until it is.
+	//
+	// Each provider configuration counts as one instance, even if it uses
+	// for_each to create several. Apply returns an error if it can't find
+	// an order that keeps within the limit, such as if the resources of
+	// more providers than that depend on each other.
+	MaxProviderInstances int
+
+	// OutputTypes, if set, are type constraints for the root module output
+	// values of the same names, which OpenTofu checks each value against as
+	// it is evaluated during the apply. A value that doesn't conform is an
+	// error, and so a change to the configuration can't save a value of an
+	// unexpected type in the state. A value that can be converted to the
+	// type, such as a number for a string constraint, is converted, in the
+	// same way as for input variables.
+	//
+	// Each name must be declared as an output value by the root module.
+	OutputTypes map[string]cty.Type
+
+	// JournalPath, if set, is the path of a file to which Apply appends an
+	// entry as each operation on a resource instance object finishes,
+	// including a snapshot of the object as it was afterwards. Each entry
+	// is written to disk before the apply continues, and so if the apply is
+	// interrupted, even by OpenTofu itself being terminated, the caller can
+	// pass the journal and the original plan to Context.ResumeFromJournal
+	// to apply only the changes that weren't completed.
+	//
+	// The file is created if it doesn't exist, and appended to if it does,
+	// so that a resumed apply can use the same journal. The snapshots are
+	// encrypted in the same way as the state, if state encryption is
+	// configured.
+	JournalPath string
+
+	// operation, if set to anything other than walkInvalid, overrides the
+	// walk operation that would otherwise be derived from the plan's mode.
+	//
+	// This is intended only for OpenTofu Core developers experimenting with
+	// new kinds of operation, and so is deliberately not exported. Apply
+	// returns an error if this is not one of the known walk operations.
+	operation walkOperation
+
+	// countGoroutines, if set, is used instead of runtime.NumGoroutine to
+	// count goroutines for GoroutineLimit. This is for testing only.
+	countGoroutines func() int
+
+	// traceOrder, if set, is the order in which to apply the changes to
+	// these resource instances, for Context.ReplayApplyTrace.
+	traceOrder []addrs.AbsResourceInstance
+}
+
+// PlanConfigVerifier is implemented by callers that wish to decide whether
+// a configuration is compatible enough with a saved plan to apply it.
+//
+// Different callers embedding OpenTofu may have different rules for this,
+// and so this allows each to apply its own policy. See
+// ApplyOpts.PlanConfigVerifier.
+type PlanConfigVerifier interface {
+	// Verify checks whether the given configuration is suitable for applying
+	// the given plan. Returning error diagnostics prevents the apply from
+	// proceeding, while any warnings are returned along with the apply
+	// result.
+	//
+	// Verify must not modify either the plan or the configuration.
+	Verify(plan *plans.Plan, config *configs.Config) tfdiags.Diagnostics
+}
+
 // Apply performs the actions described by the given Plan object and returns
 // the resulting updated state.
 //
@@ -28,25 +815,77 @@ import (
 // Even if the returned diagnostics contains errors, Apply always returns the
 // resulting state which is likely to have been partially-updated.
 func (c *Context) Apply(ctx context.Context, plan *plans.Plan, config *configs.Config) (*states.State, tfdiags.Diagnostics) {
+	return c.ApplyWithOpts(ctx, plan, config, nil)
+}
+
+// ApplyWithOpts is a variant of Apply which additionally accepts options
+// that customize the apply process. Passing nil opts is equivalent to
+// calling Apply.
+func (c *Context) ApplyWithOpts(ctx context.Context, plan *plans.Plan, config *configs.Config, opts *ApplyOpts) (_ *states.State, diags tfdiags.Diagnostics) {
 	defer c.acquireRun("apply")()
 
+	if opts == nil {
+		opts = &ApplyOpts{}
+	}
+
+	// We always replace the previous results, even if we return early
+	// below, so that callers can't mistake results from an earlier apply
+	// for the results of this one.
+	results := &lastApplyResults{runID: opts.RunID}
+	if results.runID == "" {
+		results.runID = newApplyRunID()
+	}
+	// We forget the run ID only after publishing the results, so that
+	// ApplyProgressSnapshot can always find the run.
+	c.setApplyRunID(results.runID)
+	defer c.setApplyRunID("")
+	defer c.setLastApplyResults(results)
+	if opts.CaptureDiagnostics {
+		defer func() {
+			results.diagsByResource, results.otherDiags = groupResourceDiagnostics(diags)
+			results.providerWarns = providerWarnings(diags)
+		}()
+	}
+	var diagStream *diagnosticStream
+	if opts.OnDiagnostic != nil {
+		diagStream = newDiagnosticStream(opts.OnDiagnostic)
+		defer func() { diagStream.Flush(diags) }()
+	}
+
+	propagateHookContext(c.hooks, opts.HookContext)
+
+	tracer, ctx := startApplyTracer(ctx, opts.Tracer, plan)
+	defer func() { tracer.End(diags) }()
+
 	log.Printf("[DEBUG] Building and walking apply graph for %s plan", plan.UIMode)
 
-	if plan.Errored {
-		var diags tfdiags.Diagnostics
-		diags = diags.Append(tfdiags.Sourceless(
-			tfdiags.Error,
-			"Cannot apply failed plan",
-			`The given plan is incomplete due to errors during planning, and so it cannot be applied.`,
-		))
+	scheduler, moreDiags := checkApplyOpts(plan, config, opts)
+	diags = diags.Append(moreDiags)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	plan, moreDiags = c.prepareApplyPlan(ctx, plan, config, opts)
+	diags = diags.Append(moreDiags)
+	if diags.HasErrors() {
 		return nil, diags
 	}
+	c.propagatePlannedActions(plan.Changes)
+
+	perResourceHooks := newPerResourceHooks(opts.PerResourceHooks)
+	importHooks := c.hooks
+	if perResourceHooks != nil {
+		importHooks = append(append([]Hook(nil), c.hooks...), perResourceHooks)
+		perResourceHooks.SetHookContext(opts.HookContext)
+		c.setApplyPerResourceHooks(perResourceHooks)
+		defer c.setApplyPerResourceHooks(nil)
+	}
 
 	for _, rc := range plan.Changes.Resources {
 		// Import is a no-op change during an apply (all the real action happens during the plan) but we'd
 		// like to show some helpful output that mirrors the way we show other changes.
 		if rc.Importing != nil {
-			for _, h := range c.hooks {
+			for _, h := range importHooks {
 				// In future, we may need to call PostApplyImport separately elsewhere in the apply
 				// operation. For now, though, we'll call Pre and Post hooks together.
 				h.PreApplyImport(rc.Addr, *rc.Importing)
@@ -57,16 +896,51 @@ func (c *Context) Apply(ctx context.Context, plan *plans.Plan, config *configs.C
 
 	providerFunctionTracker := make(ProviderFunctionMapping)
 
-	graph, operation, diags := c.applyGraph(plan, config, true, providerFunctionTracker)
+	graph, operation, graphDiags := c.applyGraph(plan, config, opts, true, true, providerFunctionTracker)
+	diags = diags.Append(graphDiags)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if opts.GraphDumpOnError != nil {
+		defer func() {
+			if diags.HasErrors() {
+				diags = diags.Append(writeGraphDump(opts.GraphDumpOnError, graph))
+			}
+		}()
+	}
+	if opts.ReverseOrder {
+		scheduler = newReverseScheduler(&graph.AcyclicGraph)
+	}
+	results.graphNodes = len(graph.Vertices())
+	results.graphEdges = len(graph.Edges())
+	diags = diags.Append(c.graphBuiltHook(graph))
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if opts.CaptureConsumedVariables {
+		results.consumedVars = consumedRootVariables(graph)
+	}
+
+	walk, moreDiags := c.prepareApplyWalk(plan, config, opts, graph, perResourceHooks, results)
+	diags = diags.Append(moreDiags)
 	if diags.HasErrors() {
 		return nil, diags
 	}
 
-	workingState := plan.PriorState.DeepCopy()
+	c.setApplyStatus(walk.progress)
+	walk.lockWatch = c.watchLock(opts.LockChecker, opts.LockCheckInterval)
+	walk.goroutineWatch = c.monitorGoroutines(opts.GoroutineLimit, opts.GoroutineCheckInterval, opts.AbortOnGoroutineLimit, opts.countGoroutines)
+	inputState := walk.inputState
+	if opts.TransferPriorState {
+		// The walk now owns this copy and updates it in place, so we
+		// mustn't use it for anything else.
+		walk.inputState = nil
+	}
 	walker, walkDiags := c.walk(ctx, graph, operation, &graphWalkOpts{
-		Config:     config,
-		InputState: workingState,
-		Changes:    plan.Changes,
+		Config:          config,
+		InputState:      inputState,
+		InputStateOwned: opts.TransferPriorState,
+		Changes:         plan.Changes,
 
 		// We need to propagate the check results from the plan phase,
 		// because that will tell us which checkable objects we're expecting
@@ -76,15 +950,407 @@ func (c *Context) Apply(ctx context.Context, plan *plans.Plan, config *configs.C
 		// We also want to propagate the timestamp from the plan file.
 		PlanTimeTimestamp:       plan.Timestamp,
 		ProviderFunctionTracker: providerFunctionTracker,
+
+		ForgetArchive:          opts.ForgetArchive,
+		ProviderCallCounter:    walk.callCounter,
+		ReadSourceRecorder:     walk.readSources,
+		ProviderCallMiddleware: walk.middleware,
+		LazyProviders:          opts.LazyProviders,
+		ApplyTracer:            tracer,
+		AdditionalHooks:        walk.hooks,
+		Breakpoints:            opts.Breakpoints,
+		Journal:                walk.journal,
+		SuppressAttributes:     opts.SuppressAttributes,
+		DataSourceResults:      opts.DataSourceResults,
+		DiagnosticStream:       diagStream,
+		Scheduler:              scheduler,
+		ModuleParallelism:      opts.ModuleParallelism,
+		ExplainSkippedChanges:  opts.ExplainSkippedChanges,
+		VariableReads:          walk.variableReads,
+		RandomSeed:             opts.RandomSeed,
+		PolicyEvaluator:        opts.PolicyEvaluator,
+		ResourceTimeouts:       opts.ResourceTimeouts,
+		OutputTypes:            opts.OutputTypes,
+		FunctionOverrides:      opts.FunctionOverrides,
+
+		// TaintOnWarning and LastApplyDiagnosticsByResource need to know
+		// which resource instance each diagnostic belongs to, and
+		// LastApplyProviderWarnings needs to tell the provider diagnostics
+		// apart from the others.
+		TagResourceDiagnostics:  opts.CaptureDiagnostics || opts.TaintOnWarning,
+		CategorizeProviderDiags: opts.CaptureDiagnostics,
 	})
+	c.setApplyStatus(nil)
+
+	newState, moreDiags := c.finishApplyWalk(ctx, walk, walker, walkDiags)
+	diags = diags.Append(moreDiags)
+	return newState, diags
+}
+
+// checkApplyOpts checks that the given apply options are valid for applying
+// the given plan, before ApplyWithOpts does anything else, and returns the
+// scheduler that they select for the apply walk, if any.
+func checkApplyOpts(plan *plans.Plan, config *configs.Config, opts *ApplyOpts) (Scheduler, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	if plan.Errored {
+		diags = diags.Append(tfdiags.WithCause(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Cannot apply failed plan",
+			`The given plan is incomplete due to errors during planning, and so it cannot be applied.`,
+		), ErrPlanErrored))
+		return nil, diags
+	}
+
+	if opts.ForbidTarget && (len(plan.TargetAddrs) > 0 || len(plan.ExcludeAddrs) > 0) {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Targeted plan not allowed",
+			"The plan was created with the -target or the -exclude option in effect, but the apply options forbid applying such plans because they may not include all of the changes requested in the configuration.\n\nCreate a new plan without those options and apply that instead.",
+		))
+		return nil, diags
+	}
+
+	if opts.ProviderLocks != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Cannot check provider versions",
+			"The apply options include provider locks, but OpenTofu can only check those against the provider versions recorded in a saved plan file. Use ApplyPlanFileWithOpts to apply a saved plan file with provider locks, or leave ProviderLocks unset.",
+		))
+		return nil, diags
+	}
+
+	diags = diags.Append(checkDuplicateChanges(plan.Changes))
+	diags = diags.Append(checkOutputsDeclared(config, opts.RequireNonNullOutputs))
+	diags = diags.Append(checkModuleParallelism(config, opts.ModuleParallelism))
+	diags = diags.Append(checkErrorRateThreshold(opts.ErrorRateThreshold))
+	diags = diags.Append(checkFunctionOverrides(opts.FunctionOverrides))
+	diags = diags.Append(checkMaxProviderInstances(opts.MaxProviderInstances))
+	diags = diags.Append(checkOutputTypesDeclared(config, opts.OutputTypes))
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	scheduler := opts.Scheduler
+	if opts.ShuffleSeed != 0 {
+		if scheduler != nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Incompatible apply options",
+				"The ShuffleSeed and Scheduler apply options cannot be used together, because both choose the order of the apply operations.",
+			))
+			return nil, diags
+		}
+		scheduler = shuffleScheduler{seed: opts.ShuffleSeed}
+	}
+	if opts.ReverseOrder {
+		if scheduler != nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Incompatible apply options",
+				"The ReverseOrder apply option cannot be used with ShuffleSeed or Scheduler, because they all choose the order of the apply operations.",
+			))
+			return nil, diags
+		}
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Warning,
+			"Applying in reverse order",
+			"The ReverseOrder apply option is in effect, so OpenTofu is applying the changes that don't depend on each other in reverse dependency order. This is intended only for testing, and is not how OpenTofu normally applies a plan.",
+		))
+	}
+
+	return scheduler, diags
+}
+
+// prepareApplyPlan returns the plan that ApplyWithOpts should actually
+// apply, which differs from the given plan when the given apply options
+// adjust it before the apply walk begins.
+func (c *Context) prepareApplyPlan(ctx context.Context, plan *plans.Plan, config *configs.Config, opts *ApplyOpts) (*plans.Plan, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	if opts.TolerateCorruptChanges {
+		var moreDiags tfdiags.Diagnostics
+		plan, moreDiags = c.withoutUndecodableChanges(plan, config)
+		diags = diags.Append(moreDiags)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+	}
+	if opts.IgnorePriorState {
+		var moreDiags tfdiags.Diagnostics
+		plan, moreDiags = c.withEmptyPriorState(plan, config)
+		diags = diags.Append(moreDiags)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+	}
+	if opts.PlanConfigVerifier != nil {
+		diags = diags.Append(opts.PlanConfigVerifier.Verify(plan, config))
+		if diags.HasErrors() {
+			return nil, diags
+		}
+	}
+	if opts.AddressRenames.Len() > 0 {
+		var moreDiags tfdiags.Diagnostics
+		plan, moreDiags = withAddressRenames(plan, opts.AddressRenames)
+		diags = diags.Append(moreDiags)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+	}
+	if opts.RefreshUpdatesOnly {
+		var skipped []*plans.ResourceInstanceChangeSrc
+		plan, skipped = withoutManagedChanges(plan)
+		if len(skipped) > 0 {
+			diags = diags.Append(skippedChangesWarning(
+				skipped,
+				"Only the updates from refreshing were applied",
+				"Apply the plan again without this option to make these changes.",
+			))
+		}
+	}
+	if opts.RequireStateMatch || len(opts.RefreshOnly) > 0 {
+		var moreDiags tfdiags.Diagnostics
+		plan, moreDiags = c.refreshBeforeApply(ctx, plan, config, opts)
+		diags = diags.Append(moreDiags)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+	}
+	diags = diags.Append(checkTypeQuotas(plan, opts.TypeQuotas))
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	return plan, diags
+}
+
+// applyWalk describes an apply walk that ApplyWithOpts has prepared, including
+// the objects that observe the walk and whatever else ApplyWithOpts needs
+// again once the walk is complete.
+type applyWalk struct {
+	plan    *plans.Plan
+	config  *configs.Config
+	opts    *ApplyOpts
+	graph   *Graph
+	results *lastApplyResults
+
+	// inputState is the state that the walk begins from.
+	inputState *states.State
+
+	progress       *applyProgressHook
+	lockWatch      *lockWatcher
+	goroutineWatch *goroutineMonitor
+	errorRate      *errorRateBreaker
+	forgetAudit    *forgetAuditHook
+	hooks          []Hook
+	middleware     ProviderCallMiddleware
+	recorder       *applyCallRecorder
+	journal        *applyJournal
+	callCounter    *providerCallCounter
+	readSources    *readSourceRecorder
+	variableReads  *variableReads
+
+	forgets        []*plans.ResourceInstanceChangeSrc
+	plannedCreates []addrs.AbsResourceInstance
+	rollback       *rollbackPlan
+	priorHusks     []addrs.AbsResource
+	retryPlan      *plans.Plan
+}
+
+// prepareApplyWalk creates everything that the apply options call for before
+// walking the given apply graph.
+func (c *Context) prepareApplyWalk(plan *plans.Plan, config *configs.Config, opts *ApplyOpts, graph *Graph, perResourceHooks *perResourceHooks, results *lastApplyResults) (*applyWalk, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+	walk := &applyWalk{
+		plan:    plan,
+		config:  config,
+		opts:    opts,
+		graph:   graph,
+		results: results,
+	}
+
+	if opts.CaptureSchemas {
+		schemas, moreDiags := c.Schemas(config, plan.PriorState)
+		diags = diags.Append(moreDiags)
+		if moreDiags.HasErrors() {
+			return nil, diags
+		}
+		results.providerSchemas = make(map[addrs.Provider]providers.ProviderSchema, len(schemas.Providers))
+		for addr, schema := range schemas.Providers {
+			results.providerSchemas[addr] = schema
+		}
+	}
+
+	if opts.CleanupDependentsOnFailure {
+		walk.plannedCreates = plannedCreateAddrs(plan.Changes)
+	}
+	if opts.RollbackOnError && plan.UIMode != plans.DestroyMode {
+		walk.rollback = newRollbackPlan(plan.Changes)
+	}
+
+	diags = diags.Append(forgottenDependentsWarnings(plan))
+	if opts.BatchForgetHooks {
+		walk.forgets = plannedForgets(plan.Changes)
+		if len(walk.forgets) > 0 {
+			diags = diags.Append(c.preForgetBatchHook(walk.forgets))
+			if diags.HasErrors() {
+				return nil, diags
+			}
+		}
+	}
+
+	walk.middleware = opts.ProviderCallMiddleware
+	if opts.PlaybackApplyCalls != nil {
+		playback, err := readApplyCallPlayback(opts.PlaybackApplyCalls)
+		if err != nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Failed to read provider call recording",
+				fmt.Sprintf("Cannot play back the recorded provider calls: %s.", err),
+			))
+			return nil, diags
+		}
+		// Playback takes the place of the provider itself, so the caller's
+		// own middleware still sees each call.
+		walk.middleware = chainProviderCallMiddleware(walk.middleware, playback.Middleware)
+	}
+	if opts.RecordApplyCalls != nil {
+		walk.recorder = newApplyCallRecorder(opts.RecordApplyCalls)
+		walk.middleware = chainProviderCallMiddleware(walk.recorder.Middleware, walk.middleware)
+	}
+	if opts.ReadinessCheck != nil {
+		// The readiness check polls the remote object rather than calling
+		// the provider, so it wraps the recorder in order that any
+		// recording includes only the provider's own responses.
+		walk.middleware = chainProviderCallMiddleware(readinessMiddleware(opts.ReadinessCheck, opts.ReadinessCheckInterval, c.runContext), walk.middleware)
+	}
+
+	if opts.JournalPath != "" {
+		enc := c.encryption
+		if enc == nil {
+			enc = encryption.Disabled()
+		}
+		var moreDiags tfdiags.Diagnostics
+		walk.journal, moreDiags = openApplyJournal(opts.JournalPath, plan.Changes, enc.State())
+		diags = diags.Append(moreDiags)
+		if moreDiags.HasErrors() {
+			return nil, diags
+		}
+	}
+
+	if opts.CaptureProviderCalls || opts.ProviderCallWarningThreshold > 0 {
+		walk.callCounter = newProviderCallCounter()
+	}
+	if opts.CaptureReadSources {
+		walk.readSources = newReadSourceRecorder()
+	}
+	if opts.CaptureReferencedVariables {
+		walk.variableReads = newVariableReads()
+	}
+
+	walk.progress = newApplyProgressHook(plan.Changes, c.hooks)
+	results.progress = walk.progress
+	c.setApplyProgress(walk.progress)
+	walk.hooks = []Hook{walk.progress}
+	if opts.ForgetAuditWriter != nil {
+		walk.forgetAudit = newForgetAuditHook(opts.ForgetAuditWriter, plannedForgets(plan.Changes))
+		walk.hooks = append(walk.hooks, walk.forgetAudit)
+	}
+	if perResourceHooks != nil {
+		walk.hooks = append(walk.hooks, perResourceHooks)
+	}
+	walk.errorRate = newErrorRateBreaker(c, opts.ErrorRateThreshold, walk.progress)
+	if walk.errorRate != nil {
+		walk.hooks = append(walk.hooks, walk.errorRate)
+	}
+
+	walk.inputState = plan.PriorState.DeepCopy()
+	if opts.ReturnPriorState {
+		results.priorState = walk.inputState.DeepCopy()
+	}
+	if opts.CaptureCheckDiff {
+		results.checkDiff = true
+		results.plannedChecks = plan.Checks.DeepCopy()
+	}
+	if opts.CapturePrunedResourceHusks {
+		walk.priorHusks = plan.PriorState.PreviewPruneResourceHusks()
+	}
+	if opts.RecordFailures {
+		walk.retryPlan = copyPlanForRetry(plan, walk.inputState.DeepCopy())
+	}
+
+	return walk, diags
+}
+
+// finishApplyWalk returns the new state that ApplyWithOpts returns once the
+// given apply walk is complete, after recording the results of the walk and
+// doing whatever else the apply options call for.
+func (c *Context) finishApplyWalk(ctx context.Context, walk *applyWalk, walker *ContextGraphWalker, walkDiags tfdiags.Diagnostics) (*states.State, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+	plan, config, opts, results := walk.plan, walk.config, walk.opts, walk.results
+
+	diags = diags.Append(walk.lockWatch.Close())
+	diags = diags.Append(walk.goroutineWatch.Close())
+	diags = diags.Append(walk.errorRate.Close())
 	diags = diags.Append(walker.NonFatalDiagnostics)
 	diags = diags.Append(walkDiags)
+	results.changeCounts = walk.progress.finalCounts()
+	results.referencedVars = walk.variableReads.Addrs()
+	if walk.recorder != nil {
+		diags = diags.Append(walk.recorder.Diagnostics())
+	}
+	if walk.forgetAudit != nil {
+		diags = diags.Append(walk.forgetAudit.Diagnostics())
+	}
+	if walk.journal != nil {
+		diags = diags.Append(walk.journal.Close())
+	}
+	if opts.WarnIncompleteApply {
+		diags = diags.Append(incompleteApplyWarning(results.changeCounts))
+	}
 
 	// After the walk is finished, we capture a simplified snapshot of the
 	// check result data as part of the new state.
 	walker.State.RecordCheckResults(walker.Checks)
+	// The walk is over, so nothing else modifies the walker's checks, and
+	// LastApplyChecks copies them for each caller.
+	results.checks = walker.Checks
+	if opts.CaptureProviderCalls {
+		results.providerCalls = walk.callCounter.Counts()
+	}
+	if opts.CaptureReadSources {
+		results.readSources = walk.readSources.Sources()
+		for _, addr := range opts.RefreshOnly {
+			// These were refreshed before the walk, and so the objects the
+			// walk read from the prior state came from the provider.
+			if results.readSources.Has(addr) {
+				results.readSources.Put(addr, ReadSourceProvider)
+			}
+		}
+	}
+	if opts.ProviderCallWarningThreshold > 0 {
+		diags = diags.Append(excessiveProviderCallWarnings(walk.callCounter.Counts(), opts.ProviderCallWarningThreshold))
+	}
 
 	newState := walker.State.Close()
+	if opts.TaintOnWarning {
+		byResource, _ := groupResourceDiagnostics(walkDiags)
+		diags = diags.Append(taintWarnedResources(newState, byResource))
+	}
+	if len(walk.forgets) > 0 {
+		diags = diags.Append(c.postForgetBatchHook(walk.forgets, newState))
+	}
+	if opts.CleanupDependentsOnFailure && diags.HasErrors() && plan.UIMode != plans.DestroyMode {
+		var moreDiags tfdiags.Diagnostics
+		newState, moreDiags = c.destroyDependentsOfFailedCreates(ctx, walk.graph, plan, config, newState, walk.plannedCreates)
+		diags = diags.Append(moreDiags)
+	}
+	if walk.rollback != nil && diags.HasErrors() {
+		var moreDiags tfdiags.Diagnostics
+		newState, moreDiags = c.rollbackFailedApply(ctx, walk.rollback, plan, config, newState, walk.progress)
+		diags = diags.Append(moreDiags)
+	}
 	if plan.UIMode == plans.DestroyMode && !diags.HasErrors() {
 		// NOTE: This is a vestigial violation of the rule that we mustn't
 		// use plan.UIMode to affect apply-time behavior.
@@ -94,6 +1360,8 @@ func (c *Context) Apply(ctx context.Context, plan *plans.Plan, config *configs.C
 		newState.PruneResourceHusks()
 	}
 
+	results.prunedHusks = prunedResourceHusks(walk.priorHusks, newState)
+
 	if len(plan.TargetAddrs) > 0 || len(plan.ExcludeAddrs) > 0 {
 		diags = diags.Append(tfdiags.Sourceless(
 			tfdiags.Warning,
@@ -120,23 +1388,1421 @@ Note that the -target and -exclude options are not suitable for routine use, and
 		newState.CheckResults = plan.Checks.DeepCopy()
 	}
 
-	return newState, diags
-}
-
-//nolint:revive,unparam // TODO remove validate bool as it's not used
-func (c *Context) applyGraph(plan *plans.Plan, config *configs.Config, validate bool, providerFunctionTracker ProviderFunctionMapping) (*Graph, walkOperation, tfdiags.Diagnostics) {
+	if opts.RunMetadata != nil {
+		newState.RunMetadata = make(map[string]string, len(opts.RunMetadata))
+		for k, v := range opts.RunMetadata {
+			newState.RunMetadata[k] = v
+		}
+	}
+	if opts.StateTransform != nil {
+		var moreDiags tfdiags.Diagnostics
+		newState, moreDiags = transformState(opts.StateTransform, newState)
+		diags = diags.Append(moreDiags)
+	}
+	if opts.MaxStateBytes > 0 {
+		diags = diags.Append(checkStateSize(newState, opts.MaxStateBytes))
+	}
+	if len(opts.RequireNonNullOutputs) > 0 && plan.UIMode != plans.DestroyMode && !diags.HasErrors() {
+		diags = diags.Append(checkNonNullOutputs(newState, config, opts.RequireNonNullOutputs))
+	}
+	if walk.retryPlan != nil {
+		results.failures = newApplyFailures(walk.retryPlan, newState, walk.progress)
+	}
+	if opts.StatePartitioner != nil {
+		results.partitions = partitionState(newState, opts.StatePartitioner)
+	}
+
+	return newState, diags
+}
+
+// ApplyRefreshOnly applies a plan created in refresh-only mode, updating the
+// state to match the remote objects as they were observed during planning
+// without making any changes to the remote objects themselves.
+//
+// It is an error to pass a plan created in any other mode. Any managed
+// resource changes in the plan other than no-op changes are skipped, with a
+// warning listing them, so ApplyRefreshOnly never asks a provider to apply a
+// change.
+func (c *Context) ApplyRefreshOnly(ctx context.Context, plan *plans.Plan, config *configs.Config) (*states.State, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	if plan.UIMode != plans.RefreshOnlyMode {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Not a refresh-only plan",
+			fmt.Sprintf("ApplyRefreshOnly can only apply a plan created in refresh-only mode, but the given plan was created in %s.", plan.UIMode),
+		))
+		return nil, diags
+	}
+
+	plan, skipped := withoutManagedChanges(plan)
+	if len(skipped) > 0 {
+		diags = diags.Append(skippedChangesWarning(
+			skipped,
+			"A refresh-only plan can only update the state",
+			"Create a plan in the normal mode to make these changes.",
+		))
+	}
+
+	newState, moreDiags := c.Apply(ctx, plan, config)
+	diags = diags.Append(moreDiags)
+	return newState, diags
+}
+
+// withoutManagedChanges returns a copy of the given plan without any of its
+// managed resource changes other than no-op changes, along with the changes
+// that it left out. The given plan is returned as-is if there are none.
+//
+// Applying the result writes the objects from the plan's prior state, which
+// already include any updates from refreshing, without asking a provider to
+// apply anything. The root module output values keep their values from the
+// prior state too, because their planned values could refer to the objects
+// that the skipped changes would have created or updated.
+func withoutManagedChanges(plan *plans.Plan) (*plans.Plan, []*plans.ResourceInstanceChangeSrc) {
+	var skipped []*plans.ResourceInstanceChangeSrc
+	keep := make([]*plans.ResourceInstanceChangeSrc, 0, len(plan.Changes.Resources))
+	for _, rc := range plan.Changes.Resources {
+		if rc.Addr.Resource.Resource.Mode == addrs.ManagedResourceMode && rc.Action != plans.NoOp {
+			skipped = append(skipped, rc)
+			continue
+		}
+		keep = append(keep, rc)
+	}
+	outputsChanged := false
+	outputs := make([]*plans.OutputChangeSrc, len(plan.Changes.Outputs))
+	for i, oc := range plan.Changes.Outputs {
+		outputs[i] = oc
+		if !oc.Addr.Module.IsRoot() || oc.Action == plans.NoOp {
+			continue
+		}
+		// A no-op change whose new value is the value from the prior state
+		// makes the apply save that value again, or remove the output if
+		// it didn't exist yet, rather than evaluating the output.
+		kept := oc.DeepCopy()
+		kept.Action = plans.NoOp
+		kept.After = kept.Before
+		kept.AfterValMarks = kept.BeforeValMarks
+		outputs[i] = kept
+		outputsChanged = true
+	}
+	if len(skipped) == 0 && !outputsChanged {
+		return plan, nil
+	}
+
+	changes := *plan.Changes
+	changes.Resources = keep
+	changes.Outputs = outputs
+	copied := *plan
+	copied.Changes = &changes
+	return &copied, skipped
+}
+
+// skippedChangesWarning returns a warning listing the given resource changes,
+// which were left out of an apply by withoutManagedChanges. The reason is
+// the start of a sentence explaining why, and advice tells the user how to
+// make the changes instead.
+func skippedChangesWarning(skipped []*plans.ResourceInstanceChangeSrc, reason, advice string) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	lines := make([]string, 0, len(skipped))
+	for _, rc := range skipped {
+		line := fmt.Sprintf("%s (%s)", rc.Addr, rc.Action)
+		if rc.DeposedKey != states.NotDeposed {
+			line = fmt.Sprintf("%s deposed object %s (%s)", rc.Addr, rc.DeposedKey, rc.Action)
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Warning,
+		"Resource changes skipped",
+		fmt.Sprintf(
+			"%s, so the following planned changes were skipped:\n  - %s\n\n%s",
+			reason, strings.Join(lines, "\n  - "), advice,
+		),
+	))
+	return diags
+}
+
+// ConvergenceRound summarizes one round of planning and applying performed
+// by Context.ApplyUntilConverged.
+type ConvergenceRound struct {
+	// Targeted is true if the plan for this round was created with the
+	// targeting options from the original PlanOpts in effect.
+	Targeted bool
+
+	// Changes summarizes how many of the changes planned in this round
+	// were applied.
+	Changes ApplyChangeCounts
+}
+
+// ApplyUntilConverged repeatedly creates and applies plans for the given
+// configuration, starting from the given state, until a plan proposes no
+// changes or until it has applied maxRounds plans.
+//
+// The first plan uses the given options, which may be nil to use
+// DefaultPlanOpts. If those options include Targets or Excludes then each
+// later plan uses the same options with the targeting removed, so that the
+// staged changes are applied first and then the remaining changes follow.
+//
+// ApplyUntilConverged returns the state after the last apply it performed,
+// along with a summary of each round that it applied. It stops early if any
+// plan or apply returns errors, and returns a warning if the last plan it
+// created still proposed changes after maxRounds rounds.
+func (c *Context) ApplyUntilConverged(ctx context.Context, config *configs.Config, state *states.State, maxRounds int, opts *PlanOpts) (*states.State, []ConvergenceRound, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+	var rounds []ConvergenceRound
+
+	if opts == nil {
+		opts = DefaultPlanOpts
+	}
+	untargeted := opts
+	targeted := len(opts.Targets) > 0 || len(opts.Excludes) > 0
+	if targeted {
+		copied := *opts
+		copied.Targets = nil
+		copied.Excludes = nil
+		untargeted = &copied
+	}
+
+	for {
+		planOpts := untargeted
+		if targeted {
+			planOpts = opts
+		}
+		plan, moreDiags := c.Plan(ctx, config, state, planOpts)
+		diags = diags.Append(moreDiags)
+		if moreDiags.HasErrors() {
+			return state, rounds, diags
+		}
+
+		if plan.Changes.Empty() {
+			if !targeted {
+				return state, rounds, diags
+			}
+			// The targeted changes might already be applied, but there could
+			// still be other changes outside of the targets.
+			targeted = false
+			continue
+		}
+
+		if len(rounds) >= maxRounds {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Warning,
+				"Configuration did not converge",
+				fmt.Sprintf("OpenTofu applied %d rounds of changes, but the configuration still has changes pending. Run the following command to see them:\n    tofu plan", len(rounds)),
+			))
+			return state, rounds, diags
+		}
+
+		newState, moreDiags := c.Apply(ctx, plan, config)
+		diags = diags.Append(moreDiags)
+		rounds = append(rounds, ConvergenceRound{
+			Targeted: targeted,
+			Changes:  c.LastApplyChangeCounts(),
+		})
+		if newState != nil {
+			state = newState
+		}
+		if moreDiags.HasErrors() {
+			return state, rounds, diags
+		}
+		targeted = false
+	}
+}
+
+// ApplyPlanFile reads the saved plan file at the given path and applies it
+// using the given configuration, which must be the same configuration that
+// was used to create the plan.
+//
+// The plan file is decrypted using the Context's encryption settings.
+// Before applying, ApplyPlanFile checks that every provider recorded in the
+// plan file's dependency locks is available to this Context, returning
+// error diagnostics without applying anything if not.
+func (c *Context) ApplyPlanFile(ctx context.Context, path string, config *configs.Config) (*states.State, tfdiags.Diagnostics) {
+	return c.ApplyPlanFileWithOpts(ctx, path, config, nil)
+}
+
+// ApplyPlanFileWithOpts is a variant of ApplyPlanFile which additionally
+// accepts options that customize the apply process. Passing nil opts is
+// equivalent to calling ApplyPlanFile.
+//
+// The provider versions selected by opts.ProviderLocks, or else by
+// ContextOpts.ProviderLocks, must also match those recorded in the plan
+// file, unless opts.AllowProviderUpgrade is set.
+func (c *Context) ApplyPlanFileWithOpts(ctx context.Context, path string, config *configs.Config, opts *ApplyOpts) (*states.State, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	if opts == nil {
+		opts = &ApplyOpts{}
+	}
+
+	enc := c.encryption
+	if enc == nil {
+		enc = encryption.Disabled()
+	}
+	pr, err := planfile.Open(path, enc.Plan())
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to read plan file",
+			fmt.Sprintf("Couldn't read the saved plan file %q: %s.", path, tfdiags.FormatError(err)),
+		))
+		return nil, diags
+	}
+	plan, err := pr.ReadPlan()
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to read plan file",
+			fmt.Sprintf("Couldn't read the plan from the saved plan file %q: %s.", path, tfdiags.FormatError(err)),
+		))
+		return nil, diags
+	}
+
+	locks, moreDiags := pr.ReadDependencyLocks()
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+	var missing []string
+	for addr := range locks.AllProviders() {
+		if !c.plugins.HasProvider(addr) {
+			missing = append(missing, addr.String())
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Missing providers for saved plan",
+			fmt.Sprintf(
+				"The saved plan file %q requires the following providers, which are not available:\n  - %s",
+				path, strings.Join(missing, "\n  - "),
+			),
+		))
+		return nil, diags
+	}
+	if !opts.AllowProviderUpgrade {
+		currentLocks := opts.ProviderLocks
+		if currentLocks == nil {
+			currentLocks = c.providerLocks
+		}
+		diags = diags.Append(checkPlanProviderVersions(path, locks, currentLocks))
+		if diags.HasErrors() {
+			return nil, diags
+		}
+	}
+
+	// ApplyWithOpts rejects ProviderLocks, because it cannot check them
+	// itself, so we pass it a copy of the options without them.
+	applyOpts := *opts
+	applyOpts.ProviderLocks = nil
+	newState, moreDiags := c.ApplyWithOpts(ctx, plan, config, &applyOpts)
+	diags = diags.Append(moreDiags)
+	return newState, diags
+}
+
+// checkPlanProviderVersions returns an error diagnostic if any provider
+// recorded in the given plan file's dependency locks has a different
+// version, or no version at all, in the given current locks, or if there
+// are no current locks to check against.
+func checkPlanProviderVersions(path string, planLocks, currentLocks *depsfile.Locks) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	if currentLocks == nil {
+		if len(planLocks.AllProviders()) == 0 {
+			return diags
+		}
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Cannot check provider versions",
+			fmt.Sprintf(
+				"The saved plan file %q records the provider versions it was created with, but there are no current dependency locks to check them against.\n\nSet ProviderLocks in the context or apply options to the current dependency locks, or set AllowProviderUpgrade in the apply options to apply the plan without checking.",
+				path,
+			),
+		))
+		return diags
+	}
+
+	var changed []string
+	for addr, planLock := range planLocks.AllProviders() {
+		currentLock := currentLocks.Provider(addr)
+		switch {
+		case currentLock == nil:
+			changed = append(changed, fmt.Sprintf("%s: planned with v%s, but no version is now selected", addr, planLock.Version()))
+		case currentLock.Version() != planLock.Version():
+			changed = append(changed, fmt.Sprintf("%s: planned with v%s, but v%s is now selected", addr, planLock.Version(), currentLock.Version()))
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Provider versions changed since plan",
+			fmt.Sprintf(
+				"The saved plan file %q was created with different provider versions than are now selected:\n  - %s\n\nA different provider version might not produce the planned results. Create a new plan with the current provider versions.",
+				path, strings.Join(changed, "\n  - "),
+			),
+		))
+	}
+	return diags
+}
+
+// destroyDependentsOfFailedCreates destroys the objects that belong to any
+// resource instances that depend on one of the given resource instances
+// whose planned create failed during the apply walk for the given graph and
+// plan, returning the updated state.
+//
+// The creates must be collected using plannedCreateAddrs before the apply
+// walk begins, because the walk modifies the plan's changes as it goes.
+//
+// Only current objects that already existed in the plan's prior state are
+// destroyed, because the dependents of a failed create were skipped by the
+// apply walk and so their objects are exactly as they were before.
+func (c *Context) destroyDependentsOfFailedCreates(ctx context.Context, graph *Graph, plan *plans.Plan, config *configs.Config, state *states.State, creates []addrs.AbsResourceInstance) (*states.State, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	failed := addrs.MakeSet[addrs.AbsResourceInstance]()
+	for _, addr := range creates {
+		is := state.ResourceInstance(addr)
+		if is == nil || is.Current == nil || is.Current.Status == states.ObjectTainted {
+			failed.Add(addr)
+		}
+	}
+	if len(failed) == 0 {
+		return state, diags
+	}
+
+	dependents := addrs.MakeSet[addrs.AbsResourceInstance]()
+	for _, v := range graph.Vertices() {
+		n, ok := v.(*NodeApplyableResourceInstance)
+		if !ok || !failed.Has(n.Addr) {
+			continue
+		}
+		descendents, err := graph.Descendents(v)
+		if err != nil {
+			diags = diags.Append(err)
+			return state, diags
+		}
+		for d := range descendents {
+			dn, ok := d.(GraphNodeResourceInstance)
+			if !ok {
+				continue
+			}
+			addr := dn.ResourceInstanceAddr()
+			if addr.Resource.Resource.Mode != addrs.ManagedResourceMode || failed.Has(addr) {
+				continue
+			}
+			if prior := plan.PriorState.ResourceInstance(addr); prior == nil || prior.Current == nil {
+				continue
+			}
+			if is := state.ResourceInstance(addr); is == nil || is.Current == nil {
+				continue
+			}
+			dependents.Add(addr)
+		}
+	}
+	if len(dependents) == 0 {
+		return state, diags
+	}
+
+	addrList := make([]addrs.AbsResourceInstance, 0, len(dependents))
+	for _, addr := range dependents {
+		addrList = append(addrList, addr)
+	}
+	sort.Slice(addrList, func(i, j int) bool {
+		return addrList[i].Less(addrList[j])
+	})
+
+	log.Printf("[WARN] Context.Apply: destroying dependents of failed creates: %v", addrList)
+	newState, moreDiags := c.destroyResourceInstances(ctx, plan, config, state, addrList)
+	diags = diags.Append(moreDiags)
+
+	var destroyed []string
+	for _, addr := range addrList {
+		if is := newState.ResourceInstance(addr); is == nil || is.Current == nil {
+			destroyed = append(destroyed, addr.String())
+		}
+	}
+	if len(destroyed) > 0 {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Warning,
+			"Destroyed dependents of failed resources",
+			fmt.Sprintf(
+				"Because some resource instances could not be created, OpenTofu destroyed the following resource instances that depend on them:\n  - %s",
+				strings.Join(destroyed, "\n  - "),
+			),
+		))
+	}
+
+	return newState, diags
+}
+
+// destroyResourceInstances destroys the current objects of the given managed
+// resource instances, which must all exist in the given state, after the
+// main apply walk for the given plan has completed, returning the resulting
+// state.
+//
+// The objects are destroyed by a walk of a graph built only from destroy
+// changes for them, targeted so that nothing else is changed, which still
+// orders the destroy operations by the dependencies between the objects.
+func (c *Context) destroyResourceInstances(ctx context.Context, plan *plans.Plan, config *configs.Config, state *states.State, addrList []addrs.AbsResourceInstance) (*states.State, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	schemas, moreDiags := c.Schemas(config, state)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return state, diags
+	}
+
+	changes := plans.NewChanges()
+	targets := make([]addrs.Targetable, 0, len(addrList))
+	for _, addr := range addrList {
+		rs := state.Resource(addr.ContainingResource())
+		is := rs.Instance(addr.Resource.Key)
+		schema, _ := schemas.ResourceTypeConfig(rs.ProviderConfig.Provider, addr.Resource.Resource.Mode, addr.Resource.Resource.Type)
+		if schema == nil {
+			diags = diags.Append(fmt.Errorf("no schema available for %s; this is a bug in OpenTofu that should be reported", addr))
+			return state, diags
+		}
+		obj, err := is.Current.Decode(schema.ImpliedType())
+		if err != nil {
+			diags = diags.Append(fmt.Errorf("failed to decode %s from state: %w", addr, err))
+			return state, diags
+		}
+		change := &plans.ResourceInstanceChange{
+			Addr:        addr,
+			PrevRunAddr: addr,
+			Change: plans.Change{
+				Action: plans.Delete,
+				Before: obj.Value,
+				After:  cty.NullVal(schema.ImpliedType()),
+			},
+			Private:      obj.Private,
+			ProviderAddr: rs.ProviderConfig,
+		}
+		src, err := change.Encode(schema.ImpliedType())
+		if err != nil {
+			diags = diags.Append(fmt.Errorf("failed to encode destroy change for %s: %w", addr, err))
+			return state, diags
+		}
+		changes.Resources = append(changes.Resources, src)
+		targets = append(targets, addr)
+	}
+
+	cleanupPlan := *plan
+	cleanupPlan.Changes = changes
+	cleanupPlan.PriorState = state
+	cleanupPlan.TargetAddrs = targets
+	cleanupPlan.ExcludeAddrs = nil
+	cleanupPlan.ForceReplaceAddrs = nil
+	cleanupPlan.ExternalReferences = nil
+
+	providerFunctionTracker := make(ProviderFunctionMapping)
+	cleanupGraph, operation, moreDiags := c.applyGraph(&cleanupPlan, config, nil, true, false, providerFunctionTracker)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return state, diags
+	}
+	walker, walkDiags := c.walk(ctx, cleanupGraph, operation, &graphWalkOpts{
+		Config:                  config,
+		InputState:              state,
+		Changes:                 changes,
+		PlanTimeTimestamp:       plan.Timestamp,
+		ProviderFunctionTracker: providerFunctionTracker,
+	})
+	diags = diags.Append(walker.NonFatalDiagnostics)
+	diags = diags.Append(walkDiags)
+
+	// The check results recorded in the state belong to the main apply
+	// walk, so we retain them rather than the (empty) results from this one.
+	newState := walker.State.Close()
+	newState.CheckResults = state.CheckResults
+	return newState, diags
+}
+
+// plannedCreateAddrs returns the addresses of the managed resource instances
+// whose current objects are planned to be created by the given changes,
+// including as part of a replace action.
+func plannedCreateAddrs(changes *plans.Changes) []addrs.AbsResourceInstance {
+	var ret []addrs.AbsResourceInstance
+	for _, rc := range changes.Resources {
+		if rc.DeposedKey != states.NotDeposed || rc.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+			continue
+		}
+		switch rc.Action {
+		case plans.Create, plans.CreateThenDelete, plans.DeleteThenCreate:
+			ret = append(ret, rc.Addr)
+		}
+	}
+	return ret
+}
+
+// outputOnlyChanges returns true if the given changes propose no actions for
+// any resource instances, and none of the no-op changes belong to resources
+// with preconditions or postconditions that must be re-checked during apply.
+func outputOnlyChanges(changes *plans.Changes, config *configs.Config) bool {
+	for _, rc := range changes.Resources {
+		if rc.Action != plans.NoOp {
+			return false
+		}
+		if config == nil {
+			continue
+		}
+		cfg := config.DescendentForInstance(rc.Addr.Module)
+		if cfg == nil {
+			continue
+		}
+		res := cfg.Module.ResourceByAddr(rc.Addr.Resource.Resource)
+		if res != nil && (len(res.Preconditions) > 0 || len(res.Postconditions) > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// plannedForgets returns the resource instance changes that forget objects,
+// which we must collect before the apply walk begins because the walk
+// modifies the plan's changes as it goes.
+func plannedForgets(changes *plans.Changes) []*plans.ResourceInstanceChangeSrc {
+	var ret []*plans.ResourceInstanceChangeSrc
+	for _, rc := range changes.Resources {
+		if rc.Action == plans.Forget {
+			ret = append(ret, rc)
+		}
+	}
+	return ret
+}
+
+// graphBuiltHook calls OnGraphBuilt on any GraphBuiltListener hooks with the
+// given apply graph.
+func (c *Context) graphBuiltHook(graph *Graph) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	for _, h := range c.hooks {
+		l, ok := h.(GraphBuiltListener)
+		if !ok {
+			continue
+		}
+		_, err := l.OnGraphBuilt(graph)
+		if err != nil {
+			diags = diags.Append(err)
+			return tfdiags.Categorize(diags, tfdiags.CategoryHook)
+		}
+	}
+	return diags
+}
+
+// preForgetBatchHook calls PreForgetBatch on any ForgetBatchListener hooks
+// with the addresses of all of the resource instances that the given changes
+// will forget.
+func (c *Context) preForgetBatchHook(forgets []*plans.ResourceInstanceChangeSrc) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	forgotten := forgetBatchAddrs(forgets, func(*plans.ResourceInstanceChangeSrc) bool { return true })
+	for _, h := range c.hooks {
+		l, ok := h.(ForgetBatchListener)
+		if !ok {
+			continue
+		}
+		_, err := l.PreForgetBatch(forgotten)
+		if err != nil {
+			diags = diags.Append(err)
+			return tfdiags.Categorize(diags, tfdiags.CategoryHook)
+		}
+	}
+	return diags
+}
+
+// postForgetBatchHook calls PostForgetBatch on any ForgetBatchListener hooks
+// with the addresses of the resource instances whose objects the given
+// changes removed from the given new state.
+func (c *Context) postForgetBatchHook(forgets []*plans.ResourceInstanceChangeSrc, newState *states.State) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	forgotten := forgetBatchAddrs(forgets, func(rc *plans.ResourceInstanceChangeSrc) bool {
+		is := newState.ResourceInstance(rc.Addr)
+		if is == nil {
+			return true
+		}
+		if rc.DeposedKey != states.NotDeposed {
+			return is.Deposed[rc.DeposedKey] == nil
+		}
+		return is.Current == nil
+	})
+	if len(forgotten) == 0 {
+		return diags
+	}
+	for _, h := range c.hooks {
+		l, ok := h.(ForgetBatchListener)
+		if !ok {
+			continue
+		}
+		_, err := l.PostForgetBatch(forgotten)
+		if err != nil {
+			diags = diags.Append(err)
+		}
+	}
+	return tfdiags.Categorize(diags, tfdiags.CategoryHook)
+}
+
+// forgetBatchAddrs returns the sorted addresses of the resource instances of
+// the given forget changes that match the given filter, without duplicates.
+func forgetBatchAddrs(forgets []*plans.ResourceInstanceChangeSrc, include func(*plans.ResourceInstanceChangeSrc) bool) []addrs.AbsResourceInstance {
+	seen := addrs.MakeSet[addrs.AbsResourceInstance]()
+	var ret []addrs.AbsResourceInstance
+	for _, rc := range forgets {
+		if seen.Has(rc.Addr) || !include(rc) {
+			continue
+		}
+		seen.Add(rc.Addr)
+		ret = append(ret, rc.Addr)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Less(ret[j])
+	})
+	return ret
+}
+
+// withoutUndecodableChanges returns a shallow copy of the given plan which
+// excludes any resource instance changes that cannot be decoded using the
+// current provider schemas, along with a warning for each change that was
+// excluded.
+//
+// The given plan is not modified.
+func (c *Context) withoutUndecodableChanges(plan *plans.Plan, config *configs.Config) (*plans.Plan, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	schemas, moreDiags := c.Schemas(config, plan.PriorState)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return plan, diags
+	}
+
+	keep := make([]*plans.ResourceInstanceChangeSrc, 0, len(plan.Changes.Resources))
+	for _, rc := range plan.Changes.Resources {
+		schema, _ := schemas.ResourceTypeConfig(
+			rc.ProviderAddr.Provider,
+			rc.Addr.Resource.Resource.Mode,
+			rc.Addr.Resource.Resource.Type,
+		)
+		if schema == nil {
+			// We'll let the apply walk report the missing schema in the
+			// usual way, since that isn't a problem with the plan itself.
+			keep = append(keep, rc)
+			continue
+		}
+		if _, err := rc.Decode(schema.ImpliedType()); err != nil {
+			addr := rc.Addr.String()
+			if rc.DeposedKey != states.NotDeposed {
+				addr = fmt.Sprintf("%s (deposed object %s)", addr, rc.DeposedKey)
+			}
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Warning,
+				"Skipping corrupt planned change",
+				fmt.Sprintf("The planned change for %s could not be decoded, so OpenTofu will not apply it: %s.", addr, err),
+			))
+			continue
+		}
+		keep = append(keep, rc)
+	}
+	if len(keep) == len(plan.Changes.Resources) {
+		return plan, diags
+	}
+
+	changes := *plan.Changes
+	changes.Resources = keep
+	ret := *plan
+	ret.Changes = &changes
+	return &ret, diags
+}
+
+// withEmptyPriorState returns a shallow copy of the given plan whose prior
+// state contains no managed resource objects, and whose changes create new
+// objects for all of the managed resource instances that the given plan
+// would retain, along with a warning about the consequences.
+//
+// The given plan is not modified.
+func (c *Context) withEmptyPriorState(plan *plans.Plan, config *configs.Config) (*plans.Plan, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	schemas, moreDiags := c.Schemas(config, plan.PriorState)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return plan, diags
+	}
+
+	// We retain only the data resource objects from the prior state, since
+	// those are just cached results that don't need to be created.
+	priorState := plan.PriorState.DeepCopy()
+	for _, ms := range priorState.Modules {
+		for _, rs := range ms.Resources {
+			if rs.Addr.Resource.Mode == addrs.ManagedResourceMode {
+				ms.RemoveResource(rs.Addr.Resource)
+			}
+		}
+	}
+
+	keep := make([]*plans.ResourceInstanceChangeSrc, 0, len(plan.Changes.Resources))
+	for _, rc := range plan.Changes.Resources {
+		if rc.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode || rc.Action == plans.Create {
+			keep = append(keep, rc)
+			continue
+		}
+		if rc.DeposedKey != states.NotDeposed {
+			continue
+		}
+		switch rc.Action {
+		case plans.Delete, plans.Forget:
+			continue
+		}
+
+		schema, _ := schemas.ResourceTypeConfig(
+			rc.ProviderAddr.Provider,
+			rc.Addr.Resource.Resource.Mode,
+			rc.Addr.Resource.Resource.Type,
+		)
+		if schema == nil {
+			diags = diags.Append(fmt.Errorf("no schema available for %s; this is a bug in OpenTofu that should be reported", rc.Addr))
+			return plan, diags
+		}
+		ty := schema.ImpliedType()
+		before, err := plans.NewDynamicValue(cty.NullVal(ty), ty)
+		if err != nil {
+			diags = diags.Append(fmt.Errorf("failed to encode create change for %s: %w", rc.Addr, err))
+			return plan, diags
+		}
+
+		// The planned new object was based on the prior object, so we can't
+		// promise any of its values for an object created from scratch. The
+		// final plan made during the apply walk decides them instead.
+		after, err := plans.NewDynamicValue(cty.UnknownVal(ty), ty)
+		if err != nil {
+			diags = diags.Append(fmt.Errorf("failed to encode create change for %s: %w", rc.Addr, err))
+			return plan, diags
+		}
+
+		create := *rc
+		create.Action = plans.Create
+		create.ActionReason = plans.ResourceInstanceChangeNoReason
+		create.Before = before
+		create.After = after
+		create.BeforeValMarks = nil
+		create.AfterValMarks = nil
+		create.RequiredReplace = cty.NewPathSet()
+		create.Private = nil
+		create.Importing = nil
+		keep = append(keep, &create)
+	}
+
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Warning,
+		"Ignoring prior state",
+		"OpenTofu is applying this plan as if the prior state were empty, and so it will create new objects for all of the managed resource instances in the configuration, even if they already exist. This may create duplicate remote objects, or fail if the remote system rejects duplicates. Any existing remote objects will not be tracked in the new state.",
+	))
+
+	changes := *plan.Changes
+	changes.Resources = keep
+	ret := *plan
+	ret.Changes = &changes
+	ret.PriorState = priorState
+	return &ret, diags
+}
+
+// checkStateSize returns an error diagnostic if the serialized form of the
+// given state is larger than the given number of bytes.
+func checkStateSize(state *states.State, maxBytes int64) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	var w countingWriter
+	err := statefile.Write(statefile.New(state, "", 0), &w, encryption.StateEncryptionDisabled())
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to measure state size",
+			fmt.Sprintf("Could not serialize the new state to check its size: %s.", err),
+		))
+		return diags
+	}
+	if w.n > maxBytes {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"State size limit exceeded",
+			fmt.Sprintf("The new state is %d bytes, which exceeds the configured limit of %d bytes. The changes have already been applied, so the resulting state still describes the real remote objects.", w.n, maxBytes),
+		))
+	}
+	return diags
+}
+
+// transformState calls the given ApplyOpts.StateTransform function with the
+// given state, returning the given state unchanged along with an error
+// diagnostic if the function fails.
+func transformState(transform func(*states.State) (*states.State, error), state *states.State) (*states.State, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	// The transform may modify the state it's given, so we give it a copy
+	// to make sure we can still return the original if it fails.
+	ret, err := transform(state.DeepCopy())
+	if err == nil && ret == nil {
+		err = errors.New("the transform returned no state")
+	}
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to transform state",
+			fmt.Sprintf("The state transform could not process the new state: %s. The changes have already been applied, so the untransformed state is returned instead.", tfdiags.FormatError(err)),
+		))
+		return state, diags
+	}
+	return ret, diags
+}
+
+// writeGraphDump writes the given graph to the given writer in dot format,
+// for ApplyOpts.GraphDumpOnError, returning a warning if it cannot.
+func writeGraphDump(w io.Writer, graph *Graph) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	dot, err := GraphDot(graph, &dag.DotOpts{Verbose: true})
+	if err == nil {
+		_, err = io.WriteString(w, dot)
+	}
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Warning,
+			"Failed to write apply graph",
+			fmt.Sprintf("Could not write the apply graph after the apply failed: %s.", err),
+		))
+	}
+	return diags
+}
+
+// checkDuplicateChanges returns an error diagnostic for each object that has
+// more than one resource instance change in the given changes, which could
+// only happen if the plan was corrupted or incorrectly merged, and would make
+// the result of applying it depend on which of the changes happened to win.
+//
+// Deposed objects are distinguished by their deposed key as well as their
+// resource instance address.
+func checkDuplicateChanges(changes *plans.Changes) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if changes == nil {
+		return diags
+	}
+
+	seen := addrs.MakeMap[addrs.AbsResourceInstance, map[states.DeposedKey]int]()
+	for _, rc := range changes.Resources {
+		counts := seen.Get(rc.Addr)
+		if counts == nil {
+			counts = make(map[states.DeposedKey]int)
+			seen.Put(rc.Addr, counts)
+		}
+		counts[rc.DeposedKey]++
+		if counts[rc.DeposedKey] != 2 {
+			// We report each object only once, however many changes
+			// it has.
+			continue
+		}
+
+		objName := rc.Addr.String()
+		if rc.DeposedKey != states.NotDeposed {
+			objName = fmt.Sprintf("%s deposed object %s", rc.Addr, rc.DeposedKey)
+		}
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Duplicate change in plan",
+			fmt.Sprintf("The plan includes more than one change for %s, so it cannot be applied. The plan may be corrupted or may have been incorrectly combined with another plan.\n\nCreate a new plan and apply that instead.", objName),
+		))
+	}
+	return diags
+}
+
+// checkOutputsDeclared returns an error diagnostic for each of the given
+// names that is not the name of an output value declared in the root module
+// of the given configuration.
+func checkOutputsDeclared(config *configs.Config, names []string) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	for _, name := range names {
+		if _, ok := config.Module.Outputs[name]; !ok {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Undeclared output value",
+				fmt.Sprintf("Cannot require a non-null value for output %q, because the root module does not declare an output value with that name.", name),
+			))
+		}
+	}
+	return diags
+}
+
+// checkNonNullOutputs returns an error diagnostic for each of the given root
+// module output values that is null in the given state.
+//
+// Null root module output values are not saved in the state at all, and so
+// any that are missing from the state are also considered to be null.
+func checkNonNullOutputs(state *states.State, config *configs.Config, names []string) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	for _, name := range names {
+		if ov := state.RootModule().OutputValues[name]; ov != nil && !ov.Value.IsNull() {
+			continue
+		}
+		var subject *hcl.Range
+		if oc := config.Module.Outputs[name]; oc != nil {
+			subject = oc.DeclRange.Ptr()
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Required output value is null",
+			Detail:   fmt.Sprintf("The output value %q is null after the apply, but the apply options require it to have a value. The changes have already been applied, so the resulting state still describes the real remote objects.", name),
+			Subject:  subject,
+		})
+	}
+	return diags
+}
+
+// countingWriter is an io.Writer that discards everything written to it,
+// but records the total number of bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// lastApplyResults is a collection of supplemental results from an apply
+// operation, beyond the new state and diagnostics returned from Apply itself.
+type lastApplyResults struct {
+	checks          *checks.State
+	providerCalls   addrs.Map[addrs.AbsResourceInstance, ProviderCallCounts]
+	priorState      *states.State
+	changeCounts    ApplyChangeCounts
+	providerSchemas map[addrs.Provider]providers.ProviderSchema
+	graphNodes      int
+	graphEdges      int
+	diagsByResource addrs.Map[addrs.AbsResourceInstance, tfdiags.Diagnostics]
+	otherDiags      tfdiags.Diagnostics
+	plannedChecks   *states.CheckResults
+	checkDiff       bool
+	failures        *ApplyFailures
+	referencedVars  []string
+	consumedVars    []string
+	partitions      map[string]*states.State
+	readSources     addrs.Map[addrs.AbsResourceInstance, ReadSource]
+	providerWarns   tfdiags.Diagnostics
+	prunedHusks     []addrs.AbsResource
+	runID           string
+	progress        *applyProgressHook
+}
+
+func (c *Context) setLastApplyResults(results *lastApplyResults) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.lastApply = results
+}
+
+func (c *Context) lastApplyResults() *lastApplyResults {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if c.lastApply == nil {
+		return &lastApplyResults{}
+	}
+	return c.lastApply
+}
+
+// LastApplyChecks returns the detailed check results from the most recent
+// call to Apply on this context, or nil if there has not yet been an apply
+// or if the most recent apply failed before the graph walk began.
+//
+// The new state returned from Apply includes only a simplified summary of
+// these results. The returned object is a copy owned by the caller, so
+// modifying it does not affect the context or any later apply operations.
+func (c *Context) LastApplyChecks() *checks.State {
+	return c.lastApplyResults().checks.DeepCopy()
+}
+
+// LastApplyCheckDiff returns the checkable objects whose check status at
+// the end of the most recent call to Apply on this context differs from
+// their status in the plan that it applied, sorted by object address.
+//
+// This includes checks that passed during planning but failed during the
+// apply, for example, and also checks whose results were unknown during
+// planning. The result is nil if there are no such objects, if there has
+// not yet been an apply, if the most recent apply did not set
+// ApplyOpts.CaptureCheckDiff, or if it failed before the graph walk began.
+func (c *Context) LastApplyCheckDiff() []CheckStatusChange {
+	results := c.lastApplyResults()
+	if results.checks == nil || !results.checkDiff {
+		return nil
+	}
+	return checkStatusChanges(results.plannedChecks, states.NewCheckResults(results.checks))
+}
+
+// LastApplyProviderCalls returns the number of provider calls of each kind
+// that OpenTofu made on behalf of each resource instance during the most
+// recent call to Apply on this context.
+//
+// Resource instances that required no provider calls at all are not
+// included, and the result is empty if that apply did not set
+// ApplyOpts.CaptureProviderCalls. The returned map is a copy owned by the
+// caller.
+func (c *Context) LastApplyProviderCalls() addrs.Map[addrs.AbsResourceInstance, ProviderCallCounts] {
+	ret := addrs.MakeMap[addrs.AbsResourceInstance, ProviderCallCounts]()
+	for _, elem := range c.lastApplyResults().providerCalls.Elems {
+		ret.PutElement(elem)
+	}
+	return ret
+}
+
+// LastApplyPriorState returns a copy of the prior state that the most recent
+// call to Apply on this context began from, or nil if that apply did not
+// set ApplyOpts.ReturnPriorState or failed before the graph walk began.
+//
+// The returned object is a copy owned by the caller.
+func (c *Context) LastApplyPriorState() *states.State {
+	return c.lastApplyResults().priorState.DeepCopy()
+}
+
+// LastApplyProviderSchemas returns the schemas of the providers that were
+// used during the most recent call to Apply on this context, keyed by
+// provider address, or nil if that apply did not set
+// ApplyOpts.CaptureSchemas or failed before the graph walk began.
+//
+// The results can be passed to providers.SchemaCache.Set to seed the schema
+// cache for a later operation, which then uses these schemas instead of
+// starting the providers to obtain them.
+func (c *Context) LastApplyProviderSchemas() map[addrs.Provider]providers.ProviderSchema {
+	schemas := c.lastApplyResults().providerSchemas
+	if schemas == nil {
+		return nil
+	}
+	ret := make(map[addrs.Provider]providers.ProviderSchema, len(schemas))
+	for addr, schema := range schemas {
+		ret[addr] = schema
+	}
+	return ret
+}
+
+// LastApplyChangeCounts returns a summary of how many of the planned
+// resource instance changes were applied, failed, or not reached during the
+// most recent call to Apply on this context.
+//
+// The result is the zero value if there has not yet been an apply or if the
+// most recent apply failed before the graph walk began.
+func (c *Context) LastApplyChangeCounts() ApplyChangeCounts {
+	return c.lastApplyResults().changeCounts
+}
+
+// LastApplyProviderWarnings returns the warnings from the most recent call
+// to Apply on this context that were returned by providers, as opposed to
+// those produced by OpenTofu itself. These warnings are also included in
+// the diagnostics that Apply returned.
+//
+// The result is empty if that apply did not set ApplyOpts.CaptureDiagnostics.
+// That option also classifies the diagnostics returned by providers as
+// tfdiags.CategoryProvider, so callers that need both errors and warnings
+// can instead filter the diagnostics from Apply using
+// tfdiags.DiagnosticCategory. The returned diagnostics are a copy owned by
+// the caller.
+func (c *Context) LastApplyProviderWarnings() tfdiags.Diagnostics {
+	return slices.Clone(c.lastApplyResults().providerWarns)
+}
+
+// LastApplyPrunedResourceHusks returns the addresses of the resources that
+// had no instances in the prior state of the most recent call to Apply on
+// this context, and which that apply removed from the state without any
+// planned change, sorted by address.
+//
+// The apply walk cleans up such "husks" as it finishes, so this can explain
+// resources that disappear from the state even though the plan didn't
+// mention them. Callers can use states.State.PreviewPruneResourceHusks to
+// see which resources a state would lose in this way before applying.
+//
+// The result is nil if the most recent apply did not set
+// ApplyOpts.CapturePrunedResourceHusks.
+func (c *Context) LastApplyPrunedResourceHusks() []addrs.AbsResource {
+	return slices.Clone(c.lastApplyResults().prunedHusks)
+}
+
+// prunedResourceHusks returns the resources from the given husks, as
+// returned by states.State.PreviewPruneResourceHusks for a prior state,
+// that are no longer in the given new state.
+func prunedResourceHusks(husks []addrs.AbsResource, newState *states.State) []addrs.AbsResource {
+	var ret []addrs.AbsResource
+	for _, addr := range husks {
+		if newState.Resource(addr) == nil {
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
+
+// LastApplyReferencedVariables returns the addresses of the input variables
+// that expressions evaluated during the most recent call to Apply on this
+// context referred to, such as "var.region" or "module.network.var.cidr",
+// in lexical order, so that callers can audit which variables an apply
+// actually used.
+//
+// The result is nil if there has not yet been an apply, if the most recent
+// apply did not set ApplyOpts.CaptureReferencedVariables, if it failed
+// before the graph walk began, or if it read no variables.
+func (c *Context) LastApplyReferencedVariables() []string {
+	return slices.Clone(c.lastApplyResults().referencedVars)
+}
+
+// LastApplyConsumedVariables returns the names of the root module input
+// variables, as used for the keys of plans.Plan.VariableValues, that the
+// graph for the most recent call to Apply on this context referred to, in
+// lexical order.
+//
+// Unlike LastApplyReferencedVariables, this is based on the references in
+// the configuration of the graph's nodes rather than on the expressions
+// that were actually evaluated, and so it includes variables used only by a
+// node that was then skipped, but excludes the variables used only by the
+// parts of the configuration that a targeted apply left out of its graph.
+// The result is nil if there has not yet been an apply, if the most recent
+// apply did not set ApplyOpts.CaptureConsumedVariables, if it failed before
+// building its graph, or if nothing referred to any root module variable.
+func (c *Context) LastApplyConsumedVariables() []string {
+	return slices.Clone(c.lastApplyResults().consumedVars)
+}
+
+// LastApplyReadSources returns where the most recent call to Apply on this
+// context got the current object of each resource instance that it applied,
+// keyed by resource instance address.
+//
+// The objects of managed resource instances come from the state, unless
+// ApplyOpts.RefreshOnly refreshed them from the provider before the apply.
+// Those of data resource instances come from the provider if the plan left
+// reading them until the apply, or from the state if planning already read
+// them. Instances with no current object, such as those being created, and
+// data resource instances whose results came from ApplyOpts.DataSourceResults
+// are left out.
+//
+// The result is empty if there has not yet been an apply, if the most
+// recent apply did not set ApplyOpts.CaptureReadSources, or if it failed
+// before the graph walk began.
+func (c *Context) LastApplyReadSources() addrs.Map[addrs.AbsResourceInstance, ReadSource] {
+	ret := addrs.MakeMap[addrs.AbsResourceInstance, ReadSource]()
+	for _, elem := range c.lastApplyResults().readSources.Elems {
+		ret.PutElement(elem)
+	}
+	return ret
+}
+
+// LastApplyPartitionedStates returns the new state from the most recent call
+// to Apply on this context split into partitions by ApplyOpts.StatePartitioner,
+// keyed by partition. The empty key is always present and holds the resource
+// instances assigned to it along with everything that isn't a resource
+// instance.
+//
+// The result is nil if there has not yet been an apply, if the most recent
+// apply had no StatePartitioner, or if it failed before making a new state.
+func (c *Context) LastApplyPartitionedStates() map[string]*states.State {
+	partitions := c.lastApplyResults().partitions
+	if partitions == nil {
+		return nil
+	}
+	ret := make(map[string]*states.State, len(partitions))
+	for k, s := range partitions {
+		ret[k] = s.DeepCopy()
+	}
+	return ret
+}
+
+// consumedRootVariables returns the names of the root module input variables
+// declared in the given graph that at least one of its nodes refers to.
+func consumedRootVariables(g *Graph) []string {
+	declared := make(map[string]bool)
+	for _, v := range g.Vertices() {
+		if node, ok := v.(*NodeRootVariable); ok {
+			declared[node.Addr.Name] = true
+		}
+	}
+
+	var names []string
+	consume := func(ref *addrs.Reference) {
+		addr, ok := ref.Subject.(addrs.InputVariable)
+		if ok && declared[addr.Name] && !slices.Contains(names, addr.Name) {
+			names = append(names, addr.Name)
+		}
+	}
+	for _, v := range g.Vertices() {
+		rn, ok := v.(GraphNodeReferencer)
+		if !ok {
+			continue
+		}
+		if rrn, ok := rn.(GraphNodeRootReferencer); ok {
+			for _, ref := range rrn.RootReferences() {
+				consume(ref)
+			}
+		}
+		if !vertexReferencePath(v).IsRoot() {
+			continue
+		}
+		for _, ref := range rn.References() {
+			consume(ref)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyStatus returns a snapshot of the progress of the apply operation that
+// is currently running on this context, or nil if no apply is currently
+// walking its graph.
+//
+// ApplyStatus is safe to call concurrently with Apply, such as from a
+// goroutine serving a status endpoint. Each call returns a new snapshot
+// owned by the caller.
+func (c *Context) ApplyStatus() *ApplyStatusSnapshot {
+	c.l.Lock()
+	status := c.applyStatus
+	c.l.Unlock()
+	if status == nil {
+		return nil
+	}
+	return status.Status()
+}
+
+func (c *Context) setApplyStatus(status *applyProgressHook) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.applyStatus = status
+}
+
+// LastApplyGraphSize returns the number of nodes and edges in the graph that
+// OpenTofu walked during the most recent call to Apply on this context.
+//
+// This is intended for callers that run many applies and want to estimate
+// the resources each one needs. The counts include all of OpenTofu's
+// internal nodes, not just resource instances, and so are only meaningful
+// relative to each other. Both are zero if there has not yet been an apply
+// or if the most recent apply failed before building its graph.
+func (c *Context) LastApplyGraphSize() (nodes, edges int) {
+	results := c.lastApplyResults()
+	return results.graphNodes, results.graphEdges
+}
+
+// LastApplyDiagnosticsByResource returns the diagnostics from the most
+// recent call to Apply on this context, grouped by the resource instance
+// whose operation produced them.
+//
+// A Go map can't use resource instance addresses as keys, and so the
+// diagnostics that don't belong to any single resource instance, such as
+// those about providers or output values, or from the checks made before
+// and after the graph walk, are returned separately as others. Together the two results
+// contain exactly the diagnostics that Apply returned, or are both empty if
+// that apply did not set ApplyOpts.CaptureDiagnostics. The returned map and
+// diagnostics are copies owned by the caller.
+func (c *Context) LastApplyDiagnosticsByResource() (byResource addrs.Map[addrs.AbsResourceInstance, tfdiags.Diagnostics], others tfdiags.Diagnostics) {
+	results := c.lastApplyResults()
+	byResource = addrs.MakeMap[addrs.AbsResourceInstance, tfdiags.Diagnostics]()
+	for _, elem := range results.diagsByResource.Elems {
+		byResource.Put(elem.Key, slices.Clone(elem.Value))
+	}
+	return byResource, slices.Clone(results.otherDiags)
+}
+
+// excessiveProviderCallWarnings returns a warning for each resource instance
+// that has more than the given threshold number of provider calls, sorted
+// by resource instance address.
+func excessiveProviderCallWarnings(calls addrs.Map[addrs.AbsResourceInstance, ProviderCallCounts], threshold int) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	elems := calls.Elements()
+	sort.Slice(elems, func(i, j int) bool {
+		return elems[i].Key.Less(elems[j].Key)
+	})
+	for _, elem := range elems {
+		counts := elem.Value
+		if counts.Total() <= threshold {
+			continue
+		}
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Warning,
+			"Excessive provider calls during apply",
+			fmt.Sprintf(
+				"OpenTofu made %d provider calls on behalf of %s during this apply (%d plan, %d read, %d apply), which exceeds the configured threshold of %d. This may indicate a misbehaving provider.",
+				counts.Total(), elem.Key, counts.Plan, counts.Read, counts.Apply, threshold,
+			),
+		))
+	}
+	return diags
+}
+
+// propagateHookContext delivers the given request-scoped values to each of
+// the given hooks that is interested in them. Each receiving hook gets its
+// own shallow copy of the map, so hooks cannot interfere with one another.
+//
+// We always call this, even when values is nil, so that hooks reused across
+// multiple operations don't retain values from an earlier one.
+func propagateHookContext(hooks []Hook, values map[string]any) {
+	for _, h := range hooks {
+		receiver, ok := h.(HookContextReceiver)
+		if !ok {
+			continue
+		}
+		var hookValues map[string]any
+		if values != nil {
+			hookValues = make(map[string]any, len(values))
+			for k, v := range values {
+				hookValues[k] = v
+			}
+		}
+		receiver.SetHookContext(hookValues)
+	}
+}
+
+// propagatePlannedActions delivers the changes in the plan about to be
+// applied to each of the context's hooks that needs their planned actions.
+func (c *Context) propagatePlannedActions(changes *plans.Changes) {
+	for _, h := range c.hooks {
+		if l, ok := h.(plannedActionsListener); ok {
+			l.plannedActions(changes)
+		}
+	}
+}
+
+// planInputValues returns the root module variable values recorded in the
+// given plan, in the form expected by a graph walk.
+func planInputValues(plan *plans.Plan, config *configs.Config, fallbacks map[string]cty.Value) (InputValues, tfdiags.Diagnostics) {
 	var diags tfdiags.Diagnostics
 
 	variables := InputValues{}
 	for name, dyVal := range plan.VariableValues {
 		val, err := dyVal.Decode(cty.DynamicPseudoType)
 		if err != nil {
+			fallback, ok := fallbacks[name]
+			if !ok {
+				diags = diags.Append(tfdiags.Sourceless(
+					tfdiags.Error,
+					"Invalid variable value in plan",
+					fmt.Sprintf("Invalid value for variable %q recorded in plan file: %s.", name, err),
+				))
+				continue
+			}
+
+			ty := cty.DynamicPseudoType
+			if vc, ok := config.Module.Variables[name]; ok && vc.ConstraintType != cty.NilType {
+				ty = vc.ConstraintType
+			}
+			var convErr error
+			val, convErr = convert.Convert(fallback, ty)
+			if convErr != nil {
+				diags = diags.Append(tfdiags.Sourceless(
+					tfdiags.Error,
+					"Invalid variable fallback",
+					fmt.Sprintf("The value recorded in the plan file for variable %q is invalid, and its fallback value is not suitable: %s.", name, tfdiags.FormatError(convErr)),
+				))
+				continue
+			}
 			diags = diags.Append(tfdiags.Sourceless(
-				tfdiags.Error,
-				"Invalid variable value in plan",
-				fmt.Sprintf("Invalid value for variable %q recorded in plan file: %s.", name, err),
+				tfdiags.Warning,
+				"Using fallback variable value",
+				fmt.Sprintf("The value recorded in the plan file for variable %q is invalid (%s), so OpenTofu is using its fallback value instead.", name, err),
 			))
-			continue
 		}
 
 		variables[name] = &InputValue{
@@ -145,7 +2811,7 @@ func (c *Context) applyGraph(plan *plans.Plan, config *configs.Config, validate
 		}
 	}
 	if diags.HasErrors() {
-		return nil, walkApply, diags
+		return nil, diags
 	}
 
 	// The plan.VariableValues field only records variables that were actually
@@ -162,6 +2828,26 @@ func (c *Context) applyGraph(plan *plans.Plan, config *configs.Config, validate
 			SourceType: ValueFromPlan,
 		}
 	}
+	return variables, diags
+}
+
+// skipUnchangedResources should be set only when building the graph for a
+// real apply, because it allows leaving out the resource nodes entirely when
+// the plan changes only output values.
+//
+//nolint:revive,unparam // TODO remove validate bool as it's not used
+func (c *Context) applyGraph(plan *plans.Plan, config *configs.Config, opts *ApplyOpts, validate, skipUnchangedResources bool, providerFunctionTracker ProviderFunctionMapping) (*Graph, walkOperation, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	if opts == nil {
+		opts = &ApplyOpts{}
+	}
+
+	variables, varDiags := planInputValues(plan, config, opts.VariableFallbacks)
+	diags = diags.Append(varDiags)
+	if diags.HasErrors() {
+		return nil, walkApply, diags
+	}
 
 	operation := walkApply
 	if plan.UIMode == plans.DestroyMode {
@@ -174,6 +2860,36 @@ func (c *Context) applyGraph(plan *plans.Plan, config *configs.Config, validate
 		// TODO: Audit that and remove walkDestroy as an operation mode.
 		operation = walkDestroy
 	}
+	if opts.operation != walkInvalid {
+		if !opts.operation.known() {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid walk operation",
+				fmt.Sprintf("Cannot apply using unsupported graph walk operation %s. This is a bug in OpenTofu.", opts.operation),
+			))
+			return nil, walkApply, diags
+		}
+		log.Printf("[WARN] Context.applyGraph: using %s instead of %s, as requested", opts.operation, operation)
+		operation = opts.operation
+	}
+
+	externalReferences := plan.ExternalReferences
+	if len(opts.AdditionalExternalReferences) > 0 {
+		externalReferences = make([]*addrs.Reference, 0, len(plan.ExternalReferences)+len(opts.AdditionalExternalReferences))
+		externalReferences = append(externalReferences, plan.ExternalReferences...)
+		for _, subject := range opts.AdditionalExternalReferences {
+			externalReferences = append(externalReferences, &addrs.Reference{
+				Subject: subject,
+			})
+		}
+	}
+
+	// If the plan changes only output values then there's no need to
+	// visit any resources, and so we can skip configuring the providers.
+	skipResources := skipUnchangedResources && operation == walkApply && outputOnlyChanges(plan.Changes, config)
+	if skipResources {
+		log.Printf("[TRACE] Context.applyGraph: plan has no resource changes, so skipping resource nodes")
+	}
 
 	graph, moreDiags := (&ApplyGraphBuilder{
 		Config:                  config,
@@ -184,11 +2900,16 @@ func (c *Context) applyGraph(plan *plans.Plan, config *configs.Config, validate
 		Targets:                 plan.TargetAddrs,
 		Excludes:                plan.ExcludeAddrs,
 		ForceReplace:            plan.ForceReplaceAddrs,
+		TraceOrder:              opts.traceOrder,
 		Operation:               operation,
-		ExternalReferences:      plan.ExternalReferences,
+		ExternalReferences:      externalReferences,
 		ProviderFunctionTracker: providerFunctionTracker,
+		SkipResources:           skipResources,
+		ForgetLast:              opts.ForgetLast,
+		ExtraEdges:              opts.ExtraEdges,
+		MaxProviderInstances:    opts.MaxProviderInstances,
 	}).Build(addrs.RootModuleInstance)
-	diags = diags.Append(moreDiags)
+	diags = diags.Append(tfdiags.Categorize(moreDiags, tfdiags.CategoryCore))
 	if moreDiags.HasErrors() {
 		return nil, walkApply, diags
 	}
@@ -211,7 +2932,7 @@ func (c *Context) ApplyGraphForUI(plan *plans.Plan, config *configs.Config) (*Gr
 
 	var diags tfdiags.Diagnostics
 
-	graph, _, moreDiags := c.applyGraph(plan, config, false, make(ProviderFunctionMapping))
+	graph, _, moreDiags := c.applyGraph(plan, config, nil, false, false, make(ProviderFunctionMapping))
 	diags = diags.Append(moreDiags)
 	return graph, diags
 }
@@ -10,6 +10,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -23,9 +25,12 @@ import (
 	"github.com/opentofu/opentofu/internal/checks"
 	"github.com/opentofu/opentofu/internal/configs"
 	"github.com/opentofu/opentofu/internal/configs/configschema"
+	"github.com/opentofu/opentofu/internal/depsfile"
 	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/getproviders"
 	"github.com/opentofu/opentofu/internal/lang/marks"
 	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/plans/planfile"
 	"github.com/opentofu/opentofu/internal/providers"
 	"github.com/opentofu/opentofu/internal/states"
 	"github.com/opentofu/opentofu/internal/states/statefile"
@@ -2234,6 +2239,98 @@ import {
 	}
 }
 
+func TestContext2Apply_importIdentity(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_resource" "a" {
+  id = "region-a/composite"
+}
+
+import {
+  to = test_resource.a
+  id = "region-a/composite"
+}
+`,
+	})
+
+	p := testProvider("test")
+	p.GetProviderSchemaResponse = getProviderSchemaResponseFromProviderSchema(&ProviderSchema{
+		ResourceTypes: map[string]*configschema.Block{
+			"test_resource": {
+				Attributes: map[string]*configschema.Attribute{
+					"id": {
+						Type:     cty.String,
+						Required: true,
+					},
+				},
+			},
+		},
+	})
+	p.PlanResourceChangeFn = func(req providers.PlanResourceChangeRequest) providers.PlanResourceChangeResponse {
+		return providers.PlanResourceChangeResponse{
+			PlannedState: req.ProposedNewState,
+		}
+	}
+	p.ImportResourceStateFn = func(req providers.ImportResourceStateRequest) providers.ImportResourceStateResponse {
+		return providers.ImportResourceStateResponse{
+			ImportedResources: []providers.ImportedResource{
+				{
+					TypeName: "test_resource",
+					State: cty.ObjectVal(map[string]cty.Value{
+						"id": cty.StringVal(req.ID),
+					}),
+				},
+			},
+		}
+	}
+	hook := new(MockHook)
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{hook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	// OpenTofu itself only plans imports by ID, so we add the composite
+	// identity that a richer import process would have recorded.
+	addr := mustResourceInstanceAddr("test_resource.a")
+	change := plan.Changes.ResourceInstance(addr)
+	if change == nil || change.Importing == nil {
+		t.Fatalf("no planned import for %s", addr)
+	}
+	identity := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("region-a"),
+		"name":   cty.StringVal("composite"),
+	})
+	dv, err := plans.NewDynamicValue(identity, cty.DynamicPseudoType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change.Importing.Identity = dv
+
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	for name, importing := range map[string]plans.ImportingSrc{
+		"PreApplyImport":  hook.PreApplyImportImporting,
+		"PostApplyImport": hook.PostApplyImportImporting,
+	} {
+		if got, want := importing.ID, "region-a/composite"; got != want {
+			t.Errorf("%s got ID %q; want %q", name, got, want)
+		}
+		got, err := importing.Identity.Decode(cty.DynamicPseudoType)
+		if err != nil {
+			t.Errorf("%s got invalid identity: %s", name, err)
+			continue
+		}
+		if !got.RawEquals(identity) {
+			t.Errorf("%s got wrong identity\ngot:  %#v\nwant: %#v", name, got, identity)
+		}
+	}
+}
+
 func TestContext2Apply_noExternalReferences(t *testing.T) {
 	m := testModuleInline(t, map[string]string{
 		"main.tf": `
@@ -4850,3 +4947,965 @@ variable "res_data" {
 		t.Fatalf("Expected: %q, got %q", want, got)
 	}
 }
+
+func TestContext2Apply_providerChangedSincePlan(t *testing.T) {
+	planConfig := testModuleInline(t, map[string]string{
+		"main.tf": `
+provider "test" {
+  alias = "a"
+}
+
+provider "test" {
+  alias = "b"
+}
+
+resource "test_object" "a" {
+  provider    = test.a
+  test_string = "foo"
+}
+`,
+	})
+	applyConfig := testModuleInline(t, map[string]string{
+		"main.tf": `
+provider "test" {
+  alias = "a"
+}
+
+provider "test" {
+  alias = "b"
+}
+
+resource "test_object" "a" {
+  provider    = test.b
+  test_string = "foo"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), planConfig, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	_, diags = ctx.Apply(context.Background(), plan, applyConfig)
+	assertNoErrors(t, diags)
+
+	var found bool
+	for _, diag := range diags {
+		desc := diag.Description()
+		if diag.Severity() != tfdiags.Warning || desc.Summary != "Resource provider configuration changed since plan" {
+			continue
+		}
+		found = true
+		if !strings.Contains(desc.Detail, `provider["registry.opentofu.org/hashicorp/test"].a`) {
+			t.Errorf("warning does not mention the planned provider\n%s", desc.Detail)
+		}
+		if !strings.Contains(desc.Detail, `provider["registry.opentofu.org/hashicorp/test"].b`) {
+			t.Errorf("warning does not mention the current provider\n%s", desc.Detail)
+		}
+	}
+	if !found {
+		t.Fatalf("missing provider change warning; got diagnostics: %s", diags.ErrWithWarnings())
+	}
+
+	// Applying with the same configuration that was planned must not
+	// produce the warning.
+	plan, diags = ctx.Plan(context.Background(), planConfig, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.Apply(context.Background(), plan, planConfig)
+	assertNoDiagnostics(t, diags)
+}
+
+func TestContext2Apply_resourceAppliedHook(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_instance" "a" {
+  value  = "foo"
+  secret = sensitive("hunter2")
+}
+`,
+	})
+
+	p := new(MockProvider)
+	p.GetProviderSchemaResponse = getProviderSchemaResponseFromProviderSchema(&ProviderSchema{
+		ResourceTypes: map[string]*configschema.Block{
+			"test_instance": {
+				Attributes: map[string]*configschema.Attribute{
+					"id":     {Type: cty.String, Computed: true},
+					"value":  {Type: cty.String, Optional: true},
+					"secret": {Type: cty.String, Optional: true},
+				},
+			},
+		},
+	})
+	p.PlanResourceChangeFn = testDiffFn
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		vals := req.PlannedState.AsValueMap()
+		vals["id"] = cty.StringVal("i-abc123")
+		resp.NewState = cty.ObjectVal(vals)
+		return resp
+	}
+
+	hook := new(MockHook)
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{hook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	if !hook.ResourceAppliedCalled {
+		t.Fatal("ResourceApplied hook was not called")
+	}
+	if got, want := hook.ResourceAppliedAddr, mustResourceInstanceAddr("test_instance.a"); !got.Equal(want) {
+		t.Errorf("wrong address\ngot:  %s\nwant: %s", got, want)
+	}
+
+	got := hook.ResourceAppliedNewValue
+	if id := got.GetAttr("id"); !id.RawEquals(cty.StringVal("i-abc123")) {
+		t.Errorf("computed attribute not delivered: id = %#v", id)
+	}
+	if value := got.GetAttr("value"); !value.RawEquals(cty.StringVal("foo")) {
+		t.Errorf("wrong value attribute: %#v", value)
+	}
+	if secret := got.GetAttr("secret"); !secret.HasMark(marks.Sensitive) {
+		t.Errorf("sensitive attribute is not marked as sensitive: %#v", secret)
+	}
+}
+
+func TestContext2ApplyRefreshOnly(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "configured"
+}
+`,
+	})
+	addr := mustResourceInstanceAddr("test_object.a")
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			addr,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"before"}`),
+			},
+			mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			addrs.NoKey,
+		)
+	})
+
+	newProvider := func() *MockProvider {
+		p := simpleMockProvider()
+		p.ReadResourceFn = func(req providers.ReadResourceRequest) (resp providers.ReadResourceResponse) {
+			vals := req.PriorState.AsValueMap()
+			vals["test_string"] = cty.StringVal("drifted")
+			resp.NewState = cty.ObjectVal(vals)
+			return resp
+		}
+		return p
+	}
+
+	t.Run("refresh-only plan", func(t *testing.T) {
+		p := newProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, state, SimplePlanOpts(plans.RefreshOnlyMode, nil))
+		assertNoErrors(t, diags)
+
+		// A refresh-only plan should never include changes to managed
+		// resources, but we add one here to make sure that it's skipped.
+		plan.Changes.Resources = append(plan.Changes.Resources, &plans.ResourceInstanceChangeSrc{
+			Addr:         addr,
+			PrevRunAddr:  addr,
+			ProviderAddr: mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			ChangeSrc: plans.ChangeSrc{
+				Action: plans.Update,
+			},
+		})
+
+		newState, diags := ctx.ApplyRefreshOnly(context.Background(), plan, m)
+		assertNoErrors(t, diags)
+		if len(diags) != 1 {
+			t.Fatalf("wrong number of diagnostics %d; want 1 warning\n%s", len(diags), diags.ErrWithWarnings())
+		}
+		if got, want := diags[0].Severity(), tfdiags.Warning; got != want {
+			t.Errorf("wrong severity %s; want %s", got, want)
+		}
+		if got, want := diags[0].Description().Detail, "test_object.a (Update)"; !strings.Contains(got, want) {
+			t.Errorf("skipped changes warning doesn't mention %q:\n%s", want, got)
+		}
+
+		if p.ApplyResourceChangeCalled {
+			t.Error("provider ApplyResourceChange was called during a refresh-only apply")
+		}
+		obj := newState.ResourceInstance(addr)
+		if obj == nil || obj.Current == nil {
+			t.Fatalf("%s is missing from the new state", addr)
+		}
+		if got, want := string(obj.Current.AttrsJSON), `"test_string":"drifted"`; !strings.Contains(got, want) {
+			t.Errorf("new state does not reflect the refreshed value\ngot:  %s\nwant attribute: %s", got, want)
+		}
+	})
+
+	t.Run("normal plan", func(t *testing.T) {
+		p := newProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		_, diags = ctx.ApplyRefreshOnly(context.Background(), plan, m)
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error for a plan not created in refresh-only mode")
+		}
+		if got, want := diags.Err().Error(), "Not a refresh-only plan"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("provider ApplyResourceChange was called")
+		}
+	})
+}
+
+func TestContext2Apply_stateMutationHook(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "after"
+}
+
+resource "test_object" "c" {
+  test_string = "new"
+}
+`,
+	})
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	state := states.BuildState(func(s *states.SyncState) {
+		for _, addr := range []string{"test_object.a", "test_object.b"} {
+			s.SetResourceInstanceCurrent(
+				mustResourceInstanceAddr(addr),
+				&states.ResourceInstanceObjectSrc{
+					Status:    states.ObjectReady,
+					AttrsJSON: []byte(`{"test_string":"before"}`),
+				},
+				providerAddr,
+				addrs.NoKey,
+			)
+		}
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	// We use the hook only for the apply phase, so that the mutations made
+	// to the plan walk's own working state don't confuse the result.
+	hook := &testStateMutationHook{}
+	ctx = testContext2(t, &ContextOpts{
+		Hooks: []Hook{hook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+	newState, diags := ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	schema := simpleTestSchema()
+	objValue := func(s *states.State, addr string) cty.Value {
+		src := s.ResourceInstance(mustResourceInstanceAddr(addr))
+		if src == nil || src.Current == nil {
+			return cty.NilVal
+		}
+		obj, err := src.Current.Decode(schema.ImpliedType())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj.Value
+	}
+
+	for _, addr := range []string{"test_object.a", "test_object.b", "test_object.c"} {
+		t.Run(addr, func(t *testing.T) {
+			mutations := hook.mutations[addr]
+			if len(mutations) == 0 {
+				t.Fatal("no mutations reported")
+			}
+
+			// Each mutation must start from the object that the previous
+			// one left behind, beginning with the prior state, and the last
+			// one must produce the object in the final state.
+			prev := objValue(state, addr)
+			for i, mut := range mutations {
+				if !mut.old.RawEquals(prev) {
+					t.Errorf("mutation %d has wrong old object\ngot:  %#v\nwant: %#v", i, mut.old, prev)
+				}
+				prev = mut.new
+			}
+			if want := objValue(newState, addr); !prev.RawEquals(want) {
+				t.Errorf("final mutation has wrong new object\ngot:  %#v\nwant: %#v", prev, want)
+			}
+		})
+	}
+}
+
+type testStateMutation struct {
+	old, new cty.Value
+}
+
+// testStateMutationHook records all of the current object mutations it's
+// notified about, in order, for each resource instance. Removed objects are
+// recorded as cty.NilVal.
+type testStateMutationHook struct {
+	NilHook
+
+	mu        sync.Mutex
+	mutations map[string][]testStateMutation
+}
+
+func (h *testStateMutationHook) StateMutation(addr addrs.AbsResourceInstance, gen states.Generation, old, new *states.ResourceInstanceObject) (HookAction, error) {
+	if gen != states.CurrentGen {
+		return HookActionContinue, nil
+	}
+	value := func(obj *states.ResourceInstanceObject) cty.Value {
+		if obj == nil {
+			return cty.NilVal
+		}
+		return obj.Value
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.mutations == nil {
+		h.mutations = make(map[string][]testStateMutation)
+	}
+	h.mutations[addr.String()] = append(h.mutations[addr.String()], testStateMutation{
+		old: value(old),
+		new: value(new),
+	})
+	return HookActionContinue, nil
+}
+
+func TestContext2Apply_ignoreChangesChangedSincePlan(t *testing.T) {
+	addr := mustResourceInstanceAddr("test_object.a")
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			addr,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"old"}`),
+			},
+			mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			addrs.NoKey,
+		)
+	})
+	module := func(ignoreChanges string) *configs.Config {
+		return testModuleInline(t, map[string]string{
+			"main.tf": fmt.Sprintf(`
+resource "test_object" "a" {
+  test_string = "new"
+
+  lifecycle {
+    ignore_changes = %s
+  }
+}
+`, ignoreChanges),
+		})
+	}
+
+	tests := map[string]struct {
+		planIgnore, applyIgnore string
+		wantWarning             bool
+		wantError               bool
+	}{
+		"unchanged": {
+			planIgnore:  "[test_number]",
+			applyIgnore: "[test_number]",
+		},
+		"reordered": {
+			planIgnore:  "[test_number, test_bool]",
+			applyIgnore: "[test_bool, test_number]",
+		},
+		"changed": {
+			planIgnore:  "[test_number]",
+			applyIgnore: "[test_bool]",
+			wantWarning: true,
+		},
+		"changed to all": {
+			// Ignoring all changes now discards the planned update, so
+			// the final plan is inconsistent, but we still warn about
+			// the cause.
+			planIgnore:  "[test_number]",
+			applyIgnore: "all",
+			wantWarning: true,
+			wantError:   true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := simpleMockProvider()
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), module(test.planIgnore), state, DefaultPlanOpts)
+			assertNoErrors(t, diags)
+
+			_, diags = ctx.Apply(context.Background(), plan, module(test.applyIgnore))
+			if got := diags.HasErrors(); got != test.wantError {
+				t.Errorf("wrong error result %t; want %t\n%s", got, test.wantError, diags.ErrWithWarnings())
+			}
+
+			var gotWarning bool
+			for _, diag := range diags {
+				if diag.Severity() == tfdiags.Warning && diag.Description().Summary == "Resource ignore_changes changed since plan" {
+					gotWarning = true
+				}
+			}
+			if gotWarning != test.wantWarning {
+				t.Errorf("wrong warning result %t; want %t\n%s", gotWarning, test.wantWarning, diags.ErrWithWarnings())
+			}
+		})
+	}
+}
+
+func TestContext2Apply_diagnosticCategories(t *testing.T) {
+	t.Run("hook error", func(t *testing.T) {
+		m := testModuleInline(t, map[string]string{
+			"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+`,
+		})
+
+		p := simpleMockProvider()
+		hook := &MockHook{
+			PreApplyError: errors.New("hook failed"),
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Hooks: []Hook{hook},
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.Apply(context.Background(), plan, m)
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want hook error")
+		}
+		for _, diag := range diags {
+			if diag.Severity() != tfdiags.Error {
+				continue
+			}
+			if got, want := diag.Description().Summary, "hook failed"; got != want {
+				t.Errorf("wrong error summary %q; want %q", got, want)
+			}
+			if got, want := tfdiags.DiagnosticCategory(diag), tfdiags.CategoryHook; got != want {
+				t.Errorf("wrong category %q; want %q", got, want)
+			}
+		}
+	})
+
+	t.Run("graph build error", func(t *testing.T) {
+		m := testModuleInline(t, map[string]string{
+			"main.tf": `
+resource "test_object" "a" {
+}
+resource "test_object" "b" {
+	depends_on = [test_object.a]
+}
+`,
+		})
+
+		// The dependencies recorded in the state contradict the
+		// configuration, which causes a cycle in the apply graph.
+		state := states.BuildState(func(s *states.SyncState) {
+			s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.a"), &states.ResourceInstanceObjectSrc{
+				Status:       states.ObjectTainted,
+				AttrsJSON:    []byte(`{"test_string":"a"}`),
+				Dependencies: []addrs.ConfigResource{mustConfigResourceAddr("test_object.b")},
+			}, mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`), addrs.NoKey)
+			s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.b"), &states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectTainted,
+				AttrsJSON: []byte(`{"test_string":"b"}`),
+			}, mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`), addrs.NoKey)
+		})
+
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		// Planning builds the apply graph too, to detect this problem as
+		// early as possible.
+		_, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+		if !diags.HasErrors() {
+			t.Fatal("plan succeeded; want cycle error")
+		}
+		for _, diag := range diags {
+			if diag.Severity() != tfdiags.Error {
+				continue
+			}
+			if got, want := tfdiags.DiagnosticCategory(diag), tfdiags.CategoryCore; got != want {
+				t.Errorf("wrong category %q for %q; want %q", got, diag.Description().Summary, want)
+			}
+		}
+	})
+}
+
+func TestContext2Apply_plannedValueMutatorHook(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+`,
+	})
+
+	tests := map[string]struct {
+		mutate  func(cty.Value) cty.Value
+		want    string
+		wantErr string
+	}{
+		"valid": {
+			mutate: func(v cty.Value) cty.Value {
+				attrs := v.AsValueMap()
+				attrs["test_string"] = cty.StringVal(attrs["test_string"].AsString() + "-tagged")
+				return cty.ObjectVal(attrs)
+			},
+			want: "a-tagged",
+		},
+		"missing attributes": {
+			mutate: func(v cty.Value) cty.Value {
+				return cty.ObjectVal(map[string]cty.Value{
+					"test_string": cty.StringVal("a-tagged"),
+				})
+			},
+			wantErr: "does not conform to the schema for test_object",
+		},
+		"null": {
+			mutate: func(v cty.Value) cty.Value {
+				return cty.NullVal(v.Type())
+			},
+			wantErr: "the new value is null",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := simpleMockProvider()
+			var applied []string
+			p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+				applied = append(applied, req.PlannedState.GetAttr("test_string").AsString())
+				resp.NewState = req.PlannedState
+				return resp
+			}
+			ctx := testContext2(t, &ContextOpts{
+				Hooks: []Hook{&testPlannedValueMutatorHook{mutate: test.mutate}},
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+			assertNoErrors(t, diags)
+			state, diags := ctx.Apply(context.Background(), plan, m)
+
+			if test.wantErr != "" {
+				if !diags.HasErrors() {
+					t.Fatal("apply succeeded; want error")
+				}
+				if got := diags.Err().Error(); !strings.Contains(got, test.wantErr) {
+					t.Errorf("wrong error\ngot:  %s\nwant: message containing %q", got, test.wantErr)
+				}
+				if len(applied) != 0 {
+					t.Errorf("provider was asked to apply %q; want no apply", applied)
+				}
+				return
+			}
+
+			assertNoErrors(t, diags)
+			if diff := cmp.Diff([]string{test.want}, applied); diff != "" {
+				t.Errorf("wrong values sent to provider\n%s", diff)
+			}
+			got := state.ResourceInstance(mustResourceInstanceAddr("test_object.a")).Current.AttrsJSON
+			if want := fmt.Sprintf(`"test_string":%q`, test.want); !strings.Contains(string(got), want) {
+				t.Errorf("wrong new attributes %s; want %s", got, want)
+			}
+		})
+	}
+}
+
+// testPlannedValueMutatorHook is a Hook that replaces each planned value
+// with the result of a function.
+type testPlannedValueMutatorHook struct {
+	NilHook
+
+	mutate func(cty.Value) cty.Value
+}
+
+var _ PlannedValueMutator = (*testPlannedValueMutatorHook)(nil)
+
+func (h *testPlannedValueMutatorHook) MutatePlannedValue(addr addrs.AbsResourceInstance, planned cty.Value) (cty.Value, error) {
+	return h.mutate(planned), nil
+}
+
+func TestContext2Apply_applyProgressHook(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "${test_object.a.test_string}b"
+}
+
+resource "test_object" "c" {
+  count       = 3
+  test_string = "${test_object.b.test_string}c${count.index}"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	hook := &MockHook{}
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{hook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	estimates := hook.ApplyProgressEstimates
+	if got, want := len(estimates), 5; got != want {
+		t.Fatalf("wrong number of progress events %d; want %d", got, want)
+	}
+	prev := 0.0
+	for i, estimate := range estimates {
+		if got, want := estimate.Total, 5; got != want {
+			t.Errorf("event %d has wrong total %d; want %d", i, got, want)
+		}
+		if got, want := estimate.Completed, i+1; got != want {
+			t.Errorf("event %d has wrong completed count %d; want %d", i, got, want)
+		}
+		if got := estimate.Fraction(); got <= prev {
+			t.Errorf("event %d has fraction %f, which is not greater than previous %f", i, got, prev)
+		}
+		prev = estimate.Fraction()
+	}
+	last := estimates[len(estimates)-1]
+	if got := last.Fraction(); got != 1.0 {
+		t.Errorf("final fraction is %f; want 1.0", got)
+	}
+	if last.Remaining != 0 {
+		t.Errorf("final estimate has %s remaining; want zero", last.Remaining)
+	}
+
+	t.Run("estimated time remaining", func(t *testing.T) {
+		changes := plans.NewChanges()
+		for _, name := range []string{"a", "b", "c", "d"} {
+			changes.Resources = append(changes.Resources, &plans.ResourceInstanceChangeSrc{
+				Addr: mustResourceInstanceAddr("test_object." + name),
+				ChangeSrc: plans.ChangeSrc{
+					Action: plans.Create,
+				},
+			})
+		}
+		listener := &MockHook{}
+		progress := newApplyProgressHook(changes, []Hook{listener})
+		start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		now := start
+		progress.start = start
+		progress.now = func() time.Time { return now }
+
+		now = start.Add(10 * time.Second)
+		progress.PostApply(mustResourceInstanceAddr("test_object.a"), states.CurrentGen, cty.NilVal, nil)
+		progress.PostApply(mustResourceInstanceAddr("test_object.a"), states.CurrentGen, cty.NilVal, nil) // a second operation for the same change is not counted again
+		now = start.Add(20 * time.Second)
+		progress.PostApply(mustResourceInstanceAddr("test_object.b"), states.CurrentGen, cty.NilVal, errors.New("failed"))
+
+		want := []ApplyProgressEstimate{
+			{Completed: 1, Total: 4, Elapsed: 10 * time.Second, Remaining: 30 * time.Second},
+			{Completed: 2, Total: 4, Elapsed: 20 * time.Second, Remaining: 20 * time.Second},
+		}
+		if diff := cmp.Diff(want, listener.ApplyProgressEstimates); diff != "" {
+			t.Errorf("wrong estimates\n%s", diff)
+		}
+	})
+}
+
+func TestContext2Apply_planFile(t *testing.T) {
+	m, snap := testModuleWithSnapshot(t, "apply-good")
+	p := testProvider("aws")
+	p.PlanResourceChangeFn = testDiffFn
+	p.ApplyResourceChangeFn = testApplyFn
+	currentLocks := func(version string) *depsfile.Locks {
+		locks := depsfile.NewLocks()
+		locks.SetProvider(addrs.NewDefaultProvider("aws"), getproviders.MustParseVersion(version), nil, nil)
+		return locks
+	}
+	newContext := func(t *testing.T, locks *depsfile.Locks) *Context {
+		return testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("aws"): testProviderFuncFixed(p),
+			},
+			ProviderLocks: locks,
+		})
+	}
+	ctx := newContext(t, currentLocks("1.0.0"))
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	writePlanFile := func(t *testing.T, locked ...addrs.Provider) string {
+		t.Helper()
+		locks := depsfile.NewLocks()
+		for _, addr := range locked {
+			locks.SetProvider(addr, getproviders.MustParseVersion("1.0.0"), nil, nil)
+		}
+		backendConfig, err := plans.NewDynamicValue(cty.EmptyObjectVal, cty.EmptyObject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// We write a copy so that each plan file starts from the same plan.
+		toWrite := *plan
+		toWrite.Backend = plans.Backend{
+			Type:      "local",
+			Config:    backendConfig,
+			Workspace: "default",
+		}
+		filename := filepath.Join(t.TempDir(), "tfplan")
+		err = planfile.Create(filename, planfile.CreateArgs{
+			ConfigSnapshot:       snap,
+			PreviousRunStateFile: &statefile.File{State: plan.PrevRunState},
+			StateFile:            &statefile.File{State: plan.PriorState},
+			Plan:                 &toWrite,
+			DependencyLocks:      locks,
+		}, encryption.PlanEncryptionDisabled())
+		if err != nil {
+			t.Fatalf("failed to create plan file: %s", err)
+		}
+		return filename
+	}
+
+	t.Run("success", func(t *testing.T) {
+		filename := writePlanFile(t, addrs.NewDefaultProvider("aws"))
+		state, diags := ctx.ApplyPlanFile(context.Background(), filename, m)
+		assertNoErrors(t, diags)
+		checkStateString(t, state, `
+aws_instance.bar:
+  ID = foo
+  provider = provider["registry.opentofu.org/hashicorp/aws"]
+  foo = bar
+  type = aws_instance
+aws_instance.foo:
+  ID = foo
+  provider = provider["registry.opentofu.org/hashicorp/aws"]
+  num = 2
+  type = aws_instance
+`)
+	})
+
+	t.Run("missing provider", func(t *testing.T) {
+		p.ApplyResourceChangeCalled = false
+		filename := writePlanFile(t, addrs.NewDefaultProvider("aws"), addrs.NewDefaultProvider("null"))
+		_, diags := ctx.ApplyPlanFile(context.Background(), filename, m)
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want missing provider error")
+		}
+		if got, want := diags.Err().Error(), "registry.opentofu.org/hashicorp/null"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("plan was applied despite the missing provider")
+		}
+	})
+
+	t.Run("matching provider versions", func(t *testing.T) {
+		filename := writePlanFile(t, addrs.NewDefaultProvider("aws"))
+		_, diags := ctx.ApplyPlanFileWithOpts(context.Background(), filename, m, &ApplyOpts{
+			ProviderLocks: currentLocks("1.0.0"),
+		})
+		assertNoErrors(t, diags)
+	})
+
+	t.Run("upgraded provider", func(t *testing.T) {
+		p.ApplyResourceChangeCalled = false
+		filename := writePlanFile(t, addrs.NewDefaultProvider("aws"))
+		_, diags := ctx.ApplyPlanFileWithOpts(context.Background(), filename, m, &ApplyOpts{
+			ProviderLocks: currentLocks("1.1.0"),
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want provider version error")
+		}
+		if got, want := diags.Err().Error(), "registry.opentofu.org/hashicorp/aws: planned with v1.0.0, but v1.1.0 is now selected"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("plan was applied despite the provider version change")
+		}
+	})
+
+	t.Run("upgraded provider in context", func(t *testing.T) {
+		p.ApplyResourceChangeCalled = false
+		filename := writePlanFile(t, addrs.NewDefaultProvider("aws"))
+		_, diags := newContext(t, currentLocks("1.1.0")).ApplyPlanFile(context.Background(), filename, m)
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want provider version error")
+		}
+		if got, want := diags.Err().Error(), "registry.opentofu.org/hashicorp/aws: planned with v1.0.0, but v1.1.0 is now selected"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("plan was applied despite the provider version change")
+		}
+	})
+
+	t.Run("no current locks", func(t *testing.T) {
+		p.ApplyResourceChangeCalled = false
+		filename := writePlanFile(t, addrs.NewDefaultProvider("aws"))
+		_, diags := newContext(t, nil).ApplyPlanFile(context.Background(), filename, m)
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Cannot check provider versions"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("plan was applied without checking the provider versions")
+		}
+	})
+
+	t.Run("upgraded provider allowed", func(t *testing.T) {
+		filename := writePlanFile(t, addrs.NewDefaultProvider("aws"))
+		_, diags := ctx.ApplyPlanFileWithOpts(context.Background(), filename, m, &ApplyOpts{
+			ProviderLocks:        currentLocks("1.1.0"),
+			AllowProviderUpgrade: true,
+		})
+		assertNoErrors(t, diags)
+	})
+
+	t.Run("provider locks without plan file", func(t *testing.T) {
+		p.ApplyResourceChangeCalled = false
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ProviderLocks: currentLocks("1.0.0"),
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Use ApplyPlanFileWithOpts"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("plan was applied despite the unsupported option")
+		}
+	})
+
+	t.Run("not a plan file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "tfplan")
+		if err := os.WriteFile(filename, []byte("not a plan"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, diags := ctx.ApplyPlanFile(context.Background(), filename, m)
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Failed to read plan file"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+	})
+}
+
+func TestContext2Apply_prunedResourceHusks(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+`,
+	})
+
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	priorState := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			mustResourceInstanceAddr("test_object.a"),
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"a"}`),
+			},
+			providerAddr,
+			addrs.NoKey,
+		)
+		// test_object.gone has no instances left, and so there is no
+		// planned change for it.
+		s.SetResourceProvider(mustAbsResourceAddr("test_object.gone"), providerAddr)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, priorState, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	want := []addrs.AbsResource{
+		mustAbsResourceAddr("test_object.gone"),
+	}
+	if diff := cmp.Diff(want, plan.PriorState.PreviewPruneResourceHusks()); diff != "" {
+		t.Errorf("wrong preview of resource husks\n%s", diff)
+	}
+
+	state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{CapturePrunedResourceHusks: true})
+	assertNoErrors(t, diags)
+
+	if diff := cmp.Diff(want, ctx.LastApplyPrunedResourceHusks()); diff != "" {
+		t.Errorf("wrong pruned resource husks\n%s", diff)
+	}
+	if state.Resource(mustAbsResourceAddr("test_object.gone")) != nil {
+		t.Error("test_object.gone is still in the state")
+	}
+	if state.Resource(mustAbsResourceAddr("test_object.a")) == nil {
+		t.Error("test_object.a was removed from the state")
+	}
+}
@@ -820,3 +820,66 @@ func validateCheckResults(t *testing.T, stage string, expected map[string]checks
 
 	}
 }
+
+func TestContext2Apply_lastApplyChecks(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+
+  lifecycle {
+    postcondition {
+      condition     = self.test_string == "foo"
+      error_message = "wrong string"
+    }
+  }
+}
+
+check "failing" {
+  assert {
+    condition     = test_object.a.test_string == "bar"
+    error_message = "not bar"
+  }
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	if got := ctx.LastApplyChecks(); got != nil {
+		t.Fatalf("unexpected checks before any apply: %#v", got)
+	}
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	resourceAddr := mustResourceInstanceAddr("test_object.a")
+	checkAddr := addrs.Check{Name: "failing"}.Absolute(addrs.RootModuleInstance)
+
+	got := ctx.LastApplyChecks()
+	if got == nil {
+		t.Fatal("no checks recorded for the apply")
+	}
+	if status := got.ObjectCheckStatus(resourceAddr); status != checks.StatusPass {
+		t.Errorf("wrong status for %s: %s", resourceAddr, status)
+	}
+	if status := got.ObjectCheckStatus(checkAddr); status != checks.StatusFail {
+		t.Errorf("wrong status for %s: %s", checkAddr, status)
+	}
+	if msgs := got.ObjectFailureMessages(checkAddr); len(msgs) != 1 || msgs[0] != "not bar" {
+		t.Errorf("wrong failure messages for %s: %#v", checkAddr, msgs)
+	}
+
+	// The result must be a defensive copy, so each call must return a
+	// distinct object.
+	if again := ctx.LastApplyChecks(); again == got {
+		t.Errorf("LastApplyChecks returned the same object twice")
+	}
+}
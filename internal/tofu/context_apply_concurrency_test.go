@@ -0,0 +1,851 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// lazyProvidersTestFixture returns a configuration with the given number of
+// additional provider configurations whose resources all depend on
+// test_object.fails, along with a provider factory whose provider instances
+// fail to create test_object.fails and count the number of times that any
+// of them are configured.
+//
+// Because test_object.fails cannot be created, none of the other resources
+// are ever applied, and so their provider configurations are not needed.
+func lazyProvidersTestFixture(unused int) (src string, factory providers.Factory, configured *atomic.Int64) {
+	var buf strings.Builder
+	buf.WriteString(`
+resource "test_object" "fails" {
+  test_string = "fails"
+}
+`)
+	for i := 0; i < unused; i++ {
+		fmt.Fprintf(&buf, `
+provider "test" {
+  alias = "p%[1]d"
+}
+
+resource "test_object" "r%[1]d" {
+  provider    = test.p%[1]d
+  test_string = "r%[1]d"
+
+  depends_on = [test_object.fails]
+}
+`, i)
+	}
+
+	configured = &atomic.Int64{}
+	factory = func() (providers.Interface, error) {
+		p := simpleMockProvider()
+		p.ConfigureProviderFn = func(providers.ConfigureProviderRequest) providers.ConfigureProviderResponse {
+			configured.Add(1)
+			return providers.ConfigureProviderResponse{}
+		}
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			resp.NewState = req.PlannedState
+			if req.Config.GetAttr("test_string").RawEquals(cty.StringVal("fails")) {
+				resp.NewState = req.PriorState
+				resp.Diagnostics = resp.Diagnostics.Append(fmt.Errorf("intentional failure"))
+			}
+			return resp
+		}
+		return p, nil
+	}
+	return buf.String(), factory, configured
+}
+
+func TestContext2Apply_lazyProviders(t *testing.T) {
+	const unused = 5
+	src, factory, configured := lazyProvidersTestFixture(unused)
+	m := testModuleInline(t, map[string]string{
+		"main.tf": src,
+	})
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): factory,
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	configured.Store(0)
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want intentional failure")
+	}
+	if got, want := configured.Load(), int64(unused+1); got != want {
+		t.Fatalf("wrong number of Configure calls without lazy providers: got %d, want %d", got, want)
+	}
+
+	plan, diags = ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	configured.Store(0)
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		LazyProviders: true,
+	})
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want intentional failure")
+	}
+	if got, want := diags.Err().Error(), "intentional failure"; !strings.Contains(got, want) {
+		t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+	}
+	if got, want := configured.Load(), int64(1); got != want {
+		t.Errorf("wrong number of Configure calls with lazy providers: got %d, want %d", got, want)
+	}
+}
+
+func TestContext2Apply_lazyProvidersConfigureError(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	p.ConfigureProviderResponse = &providers.ConfigureProviderResponse{
+		Diagnostics: tfdiags.Diagnostics{
+			tfdiags.Sourceless(tfdiags.Error, "Invalid credentials", "The provider credentials are not valid."),
+		},
+	}
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		LazyProviders: true,
+	})
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want provider configuration error")
+	}
+	if got, want := diags.Err().Error(), "Invalid credentials"; !strings.Contains(got, want) {
+		t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+	}
+	if p.ApplyResourceChangeCalled {
+		t.Error("provider ApplyResourceChange was called despite the configuration failing")
+	}
+}
+
+func BenchmarkContext2Apply_lazyProviders(b *testing.B) {
+	const unused = 50
+	src, factory, configured := lazyProvidersTestFixture(unused)
+
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "main.tf", []byte(src), 0644); err != nil {
+		b.Fatal(err)
+	}
+	mod, hclDiags := configs.NewParser(fs).LoadConfigDir(".", configs.RootModuleCallForTesting())
+	if hclDiags.HasErrors() {
+		b.Fatal(hclDiags.Error())
+	}
+	m, hclDiags := configs.BuildConfig(mod, configs.DisabledModuleWalker)
+	if hclDiags.HasErrors() {
+		b.Fatal(hclDiags.Error())
+	}
+
+	ctx, diags := NewContext(&ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): factory,
+		},
+	})
+	if diags.HasErrors() {
+		b.Fatal(diags.Err())
+	}
+	ctx.encryption = encryption.Disabled()
+
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lazy=%t", lazy), func(b *testing.B) {
+			var applyConfigured int64
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+				if diags.HasErrors() {
+					b.Fatal(diags.Err())
+				}
+				before := configured.Load()
+				b.StartTimer()
+
+				// The apply always fails, by design of the fixture.
+				ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+					LazyProviders: lazy,
+				})
+
+				applyConfigured += configured.Load() - before
+			}
+			b.ReportMetric(float64(applyConfigured)/float64(b.N), "configures/op")
+		})
+	}
+}
+
+func TestContext2Apply_scheduler(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+
+resource "test_object" "c" {
+  test_string = "c"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Parallelism: 1,
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	scheduler := &testLastNameScheduler{}
+	state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		Scheduler: scheduler,
+	})
+	assertNoErrors(t, diags)
+	for _, addr := range []string{"test_object.a", "test_object.b", "test_object.c"} {
+		if state.ResourceInstance(mustResourceInstanceAddr(addr)) == nil {
+			t.Errorf("%s was not created", addr)
+		}
+	}
+
+	// The scheduler can't tell us the order of the resources themselves,
+	// because it depends on when each one became ready, but every
+	// resource instance node must have been scheduled exactly once.
+	var got []string
+	for _, name := range scheduler.chosen {
+		switch name {
+		case "test_object.a", "test_object.b", "test_object.c":
+			got = append(got, name)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"test_object.a", "test_object.b", "test_object.c"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong scheduled resource instances\n%s", diff)
+	}
+}
+
+func TestContext2Apply_shuffleSeed(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+
+resource "test_object" "c" {
+  test_string = "c"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Parallelism: 1,
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	t.Run("shuffled", func(t *testing.T) {
+		state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ShuffleSeed: 42,
+		})
+		assertNoErrors(t, diags)
+		for _, addr := range []string{"test_object.a", "test_object.b", "test_object.c"} {
+			if state.ResourceInstance(mustResourceInstanceAddr(addr)) == nil {
+				t.Errorf("%s was not created", addr)
+			}
+		}
+	})
+
+	t.Run("with scheduler", func(t *testing.T) {
+		_, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ShuffleSeed: 42,
+			Scheduler:   &testLastNameScheduler{},
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want incompatible options error")
+		}
+		if got, want := diags.Err().Error(), "Incompatible apply options"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+	})
+}
+
+func TestContext2Apply_transferPriorState(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "new"
+}
+`,
+	})
+
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.a"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"old"}`),
+		}, mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`), addrs.NoKey)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	priorState := plan.PriorState.DeepCopy()
+
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		TransferPriorState: true,
+	})
+	assertNoErrors(t, diags)
+
+	if got, want := string(newState.ResourceInstance(mustResourceInstanceAddr("test_object.a")).Current.AttrsJSON), `"test_string":"new"`; !strings.Contains(got, want) {
+		t.Errorf("wrong new attributes %s; want %s", got, want)
+	}
+	if !plan.PriorState.Equal(priorState) {
+		t.Error("apply modified the plan's prior state")
+	}
+}
+
+func BenchmarkContext2Apply_transferPriorState(b *testing.B) {
+	const instances = 1000
+
+	state := states.BuildState(func(s *states.SyncState) {
+		providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+		for i := 0; i < instances; i++ {
+			s.SetResourceInstanceCurrent(mustResourceInstanceAddr(fmt.Sprintf("test_object.a[%d]", i)), &states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"a","test_list":["x","y","z"],"test_map":{"k":"v"}}`),
+			}, providerAddr, addrs.NoKey)
+		}
+	})
+
+	// Only test_object.b has a planned change, and so the cost of the apply
+	// is dominated by handling the large prior state.
+	src := fmt.Sprintf(`
+resource "test_object" "a" {
+  count = %d
+
+  test_string = "a"
+  test_list   = ["x", "y", "z"]
+  test_map    = { k = "v" }
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+}
+`, instances)
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "main.tf", []byte(src), 0644); err != nil {
+		b.Fatal(err)
+	}
+	mod, hclDiags := configs.NewParser(fs).LoadConfigDir(".", configs.RootModuleCallForTesting())
+	if hclDiags.HasErrors() {
+		b.Fatal(hclDiags.Error())
+	}
+	m, hclDiags := configs.BuildConfig(mod, configs.DisabledModuleWalker)
+	if hclDiags.HasErrors() {
+		b.Fatal(hclDiags.Error())
+	}
+
+	p := simpleMockProvider()
+	ctx, diags := NewContext(&ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+	if diags.HasErrors() {
+		b.Fatal(diags.Err())
+	}
+	ctx.encryption = encryption.Disabled()
+
+	for _, transfer := range []bool{false, true} {
+		b.Run(fmt.Sprintf("transfer=%t", transfer), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				plan, diags := ctx.Plan(context.Background(), m, state, &PlanOpts{
+					Mode:        plans.NormalMode,
+					SkipRefresh: true,
+				})
+				if diags.HasErrors() {
+					b.Fatal(diags.Err())
+				}
+				b.StartTimer()
+
+				_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+					TransferPriorState: transfer,
+				})
+				if diags.HasErrors() {
+					b.Fatal(diags.Err())
+				}
+			}
+		})
+	}
+}
+
+func TestContext2Apply_moduleParallelism(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "fast" {
+  count = 3
+
+  test_string = "fast"
+}
+
+module "slow" {
+  source = "./slow"
+}
+`,
+		"slow/main.tf": `
+resource "test_object" "slow" {
+  count = 3
+
+  test_string = "slow"
+}
+`,
+	})
+
+	hook := &testConcurrencyHook{
+		wait: map[string]int{"fast": 3},
+	}
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{hook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		ModuleParallelism: map[string]int{"module.slow": 1},
+	})
+	assertNoErrors(t, diags)
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if got, want := hook.max["slow"], 1; got != want {
+		t.Errorf("ran %d module.slow resources at once; want %d", got, want)
+	}
+	if got, want := hook.max["fast"], 3; got != want {
+		t.Errorf("ran %d root module resources at once; want %d", got, want)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ModuleParallelism: map[string]int{"module.missing": 1, "module.slow": 0},
+		})
+		if got, want := len(diags), 2; got != want {
+			t.Fatalf("got %d diagnostics; want %d\n%s", got, want, diags.ErrWithWarnings())
+		}
+		for _, diag := range diags {
+			if got, want := diag.Description().Summary, "Invalid module parallelism"; got != want {
+				t.Errorf("wrong error summary %q; want %q", got, want)
+			}
+		}
+	})
+}
+
+// testConcurrencyHook records the highest number of resource instances
+// with each test_string value that were being applied at once.
+//
+// For each value in wait, each PreApply call waits until that many
+// instances with that value are being applied, so that the test can assert
+// that they were allowed to run concurrently. Other PreApply calls pause
+// briefly, to give any concurrent calls a chance to overlap.
+type testConcurrencyHook struct {
+	NilHook
+
+	wait map[string]int
+
+	mu       sync.Mutex
+	inFlight map[string]int
+	max      map[string]int
+}
+
+func (h *testConcurrencyHook) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
+	name := plannedNewState.GetAttr("test_string").AsString()
+
+	h.mu.Lock()
+	if h.inFlight == nil {
+		h.inFlight = make(map[string]int)
+		h.max = make(map[string]int)
+	}
+	h.inFlight[name]++
+	h.max[name] = max(h.max[name], h.inFlight[name])
+	h.mu.Unlock()
+
+	want, ok := h.wait[name]
+	if !ok {
+		time.Sleep(20 * time.Millisecond)
+		return HookActionContinue, nil
+	}
+
+	// If the instances can't all run at once, we give up waiting after a
+	// while so that the test fails instead of hanging.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		h.mu.Lock()
+		done := h.max[name] >= want
+		h.mu.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return HookActionContinue, nil
+}
+
+func (h *testConcurrencyHook) PostApply(addr addrs.AbsResourceInstance, gen states.Generation, newState cty.Value, err error) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.inFlight[newState.GetAttr("test_string").AsString()]--
+	return HookActionContinue, nil
+}
+
+func TestContext2Apply_reverseOrder(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+
+resource "test_object" "c" {
+  test_string = "c"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Parallelism: 1,
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	t.Run("reversed", func(t *testing.T) {
+		state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ReverseOrder: true,
+		})
+		assertNoErrors(t, diags)
+		for _, addr := range []string{"test_object.a", "test_object.b", "test_object.c"} {
+			if state.ResourceInstance(mustResourceInstanceAddr(addr)) == nil {
+				t.Errorf("%s was not created", addr)
+			}
+		}
+
+		var warnings []string
+		for _, diag := range diags {
+			if diag.Severity() == tfdiags.Warning {
+				warnings = append(warnings, diag.Description().Summary)
+			}
+		}
+		if diff := cmp.Diff([]string{"Applying in reverse order"}, warnings); diff != "" {
+			t.Errorf("wrong warnings\n%s", diff)
+		}
+	})
+
+	t.Run("with shuffle seed", func(t *testing.T) {
+		_, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ReverseOrder: true,
+			ShuffleSeed:  42,
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want incompatible options error")
+		}
+		if got, want := diags.Err().Error(), "Incompatible apply options"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+	})
+}
+
+func TestContext2Apply_extraEdges(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.c.test_string
+}
+
+resource "test_object" "c" {
+  test_string = "c"
+}
+`,
+	})
+
+	t.Run("honored", func(t *testing.T) {
+		var mu sync.Mutex
+		var order []string
+		p := simpleMockProvider()
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			name := req.PlannedState.GetAttr("test_string").AsString()
+			if name == "c" {
+				// Without the extra edge, test_object.a would be applied
+				// while this is still running.
+				time.Sleep(50 * time.Millisecond)
+			}
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ExtraEdges: []ApplyEdge{
+				{From: mustResourceInstanceAddr("test_object.c"), To: mustResourceInstanceAddr("test_object.a")},
+				// Neither of these has a change, so there's nothing to order.
+				{From: mustResourceInstanceAddr("test_object.x"), To: mustResourceInstanceAddr("test_object.y")},
+			},
+		})
+		assertNoErrors(t, diags)
+
+		if len(order) != 3 || order[0] != "c" {
+			t.Errorf("wrong apply order %v; want test_object.c first", order)
+		}
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ExtraEdges: []ApplyEdge{
+				{From: mustResourceInstanceAddr("test_object.b"), To: mustResourceInstanceAddr("test_object.c")},
+			},
+		})
+		if !diags.HasErrors() {
+			t.Fatal("succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Invalid extra apply edge"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant: message containing %q", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("provider was asked to apply a change")
+		}
+	})
+}
+
+// closeTrackingProvider is a MockProvider that reports when it is closed,
+// for TestContext2Apply_maxProviderInstances.
+type closeTrackingProvider struct {
+	*MockProvider
+	onClose func()
+}
+
+func (p closeTrackingProvider) Close() error {
+	p.onClose()
+	return p.MockProvider.Close()
+}
+
+func TestContext2Apply_maxProviderInstances(t *testing.T) {
+	providersConfig := `
+provider "test" {
+  alias = "a"
+}
+
+provider "test" {
+  alias = "b"
+}
+
+provider "test" {
+  alias = "c"
+}
+`
+
+	apply := func(t *testing.T, m *configs.Config, limit int) (int, tfdiags.Diagnostics) {
+		t.Helper()
+
+		// We count only the configured instances, because OpenTofu also
+		// starts short-lived instances to fetch the schema when it isn't
+		// cached, as is the case for the mock provider.
+		var mu sync.Mutex
+		var open, maxOpen int
+		factory := func() (providers.Interface, error) {
+			var configured bool
+			p := simpleMockProvider()
+			p.ConfigureProviderFn = func(req providers.ConfigureProviderRequest) (resp providers.ConfigureProviderResponse) {
+				mu.Lock()
+				defer mu.Unlock()
+				configured = true
+				open++
+				maxOpen = max(maxOpen, open)
+				return resp
+			}
+			p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+				// Give the other providers a chance to start, if they can.
+				time.Sleep(20 * time.Millisecond)
+				resp.NewState = req.PlannedState
+				return resp
+			}
+			return closeTrackingProvider{
+				MockProvider: p,
+				onClose: func() {
+					mu.Lock()
+					defer mu.Unlock()
+					if configured {
+						open--
+					}
+				},
+			}, nil
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): factory,
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		mu.Lock()
+		maxOpen = 0
+		mu.Unlock()
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			MaxProviderInstances: limit,
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		return maxOpen, diags
+	}
+
+	t.Run("limited", func(t *testing.T) {
+		m := testModuleInline(t, map[string]string{
+			"main.tf": providersConfig + `
+resource "test_object" "a" {
+  provider    = test.a
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  provider    = test.b
+  test_string = test_object.c.test_string
+}
+
+resource "test_object" "c" {
+  provider    = test.c
+  test_string = "c"
+}
+`,
+		})
+		for _, limit := range []int{1, 2} {
+			maxOpen, diags := apply(t, m, limit)
+			assertNoErrors(t, diags)
+			if maxOpen > limit {
+				t.Errorf("%d providers were open at once; want at most %d", maxOpen, limit)
+			}
+		}
+	})
+
+	t.Run("impossible", func(t *testing.T) {
+		// Each of these providers has a resource that depends on a resource
+		// of the other, and so both must be open at once.
+		m := testModuleInline(t, map[string]string{
+			"main.tf": providersConfig + `
+resource "test_object" "a1" {
+  provider    = test.a
+  test_string = test_object.b1.test_string
+}
+
+resource "test_object" "a2" {
+  provider    = test.a
+  test_string = "a2"
+}
+
+resource "test_object" "b1" {
+  provider    = test.b
+  test_string = "b1"
+}
+
+resource "test_object" "b2" {
+  provider    = test.b
+  test_string = test_object.a2.test_string
+}
+`,
+		})
+		_, diags := apply(t, m, 1)
+		if !diags.HasErrors() {
+			t.Fatal("succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Too many provider instances"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant: message containing %q", got, want)
+		}
+	})
+}
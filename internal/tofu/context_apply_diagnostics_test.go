@@ -0,0 +1,521 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+func TestContext2Apply_lastApplyDiagnosticsByResource(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "warn"
+}
+
+resource "test_object" "b" {
+  test_string = "fail"
+}
+
+resource "test_object" "c" {
+  test_string = "ok"
+}
+
+resource "test_object" "d" {
+  test_string = "warn"
+}
+
+check "ok" {
+  assert {
+    condition     = test_object.a.test_string == "ok"
+    error_message = "Not ok."
+  }
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		resp.NewState = req.PlannedState
+		switch req.PlannedState.GetAttr("test_string").AsString() {
+		case "warn":
+			resp.Diagnostics = resp.Diagnostics.Append(tfdiags.SimpleWarning("a warning"))
+		case "fail":
+			resp.Diagnostics = resp.Diagnostics.Append(errors.New("a failure"))
+		}
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, applyDiags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		CaptureDiagnostics: true,
+	})
+
+	byResource, others := ctx.LastApplyDiagnosticsByResource()
+	summaries := func(diags tfdiags.Diagnostics) []string {
+		var ret []string
+		for _, diag := range diags {
+			ret = append(ret, diag.Description().Summary)
+		}
+		return ret
+	}
+
+	got := map[string][]string{}
+	for _, elem := range byResource.Elems {
+		got[elem.Key.String()] = summaries(elem.Value)
+	}
+	// test_object.a and test_object.d report identical warnings, but each
+	// is still attributed to the resource instance that returned it.
+	want := map[string][]string{
+		"test_object.a": {"a warning"},
+		"test_object.b": {"a failure"},
+		"test_object.d": {"a warning"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong diagnostics by resource\n%s", diff)
+	}
+
+	if got, want := summaries(others), []string{"Check block assertion failed"}; !cmp.Equal(want, got) {
+		t.Errorf("wrong other diagnostics\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	if got, want := len(others)+len(want["test_object.a"])+len(want["test_object.b"])+len(want["test_object.d"]), len(applyDiags); got != want {
+		t.Errorf("grouped %d diagnostics, but Apply returned %d", got, want)
+	}
+
+	// The results are copies, so changing them must not affect later calls.
+	byResource.Get(mustResourceInstanceAddr("test_object.a"))[0] = nil
+	others[0] = nil
+	byResource, others = ctx.LastApplyDiagnosticsByResource()
+	if byResource.Get(mustResourceInstanceAddr("test_object.a"))[0] == nil || others[0] == nil {
+		t.Errorf("modifying the results changed the stored diagnostics")
+	}
+}
+
+func TestContext2Apply_explainSkippedChanges(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "fail"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+
+resource "test_object" "c" {
+  test_string = test_object.b.test_string
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		resp.Diagnostics = resp.Diagnostics.Append(errors.New("a failure"))
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		ExplainSkippedChanges: true,
+	})
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want error")
+	}
+
+	var got []string
+	for _, diag := range diags {
+		if diag.Severity() == tfdiags.Warning {
+			got = append(got, diag.Description().Detail)
+		}
+	}
+	sort.Strings(got)
+	// test_object.c is skipped because test_object.b was skipped, but the
+	// warning names the failure that caused both skips.
+	want := []string{
+		"The planned change for test_object.b was skipped because dependency test_object.a failed.",
+		"The planned change for test_object.c was skipped because dependency test_object.a failed.",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong skip warnings\n%s", diff)
+	}
+}
+
+func TestContext2Apply_onDiagnostic(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "warn"
+}
+
+resource "test_object" "b" {
+  test_string = "fail"
+
+  depends_on = [test_object.a]
+}
+
+check "ok" {
+  assert {
+    condition     = test_object.a.test_string == "ok"
+    error_message = "Not ok."
+  }
+}
+`,
+	})
+
+	var mu sync.Mutex
+	var streamed []string
+	summariesSoFar := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), streamed...)
+	}
+
+	var seenBeforeB []string
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		resp.NewState = req.PlannedState
+		switch req.PlannedState.GetAttr("test_string").AsString() {
+		case "warn":
+			resp.Diagnostics = resp.Diagnostics.Append(tfdiags.SimpleWarning("a warning"))
+		case "fail":
+			seenBeforeB = summariesSoFar()
+			resp.Diagnostics = resp.Diagnostics.Append(errors.New("a failure"))
+		}
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		OnDiagnostic: func(diag tfdiags.Diagnostic) {
+			mu.Lock()
+			defer mu.Unlock()
+			streamed = append(streamed, diag.Description().Summary)
+		},
+	})
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want error")
+	}
+
+	// The warning from test_object.a must be streamed as soon as it occurs,
+	// and so before test_object.b is applied.
+	if !slices.Contains(seenBeforeB, "a warning") {
+		t.Errorf("warning for test_object.a not streamed before applying test_object.b; got %q", seenBeforeB)
+	}
+
+	var want []string
+	for _, diag := range diags {
+		want = append(want, diag.Description().Summary)
+	}
+	got := summariesSoFar()
+	sort.Strings(got)
+	sort.Strings(want)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("streamed diagnostics don't match returned diagnostics\n%s", diff)
+	}
+}
+
+func TestContext2Apply_graphDumpOnError(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+`,
+	})
+
+	for name, fail := range map[string]bool{"success": false, "failure": true} {
+		t.Run(name, func(t *testing.T) {
+			p := simpleMockProvider()
+			if fail {
+				p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+					resp.Diagnostics = resp.Diagnostics.Append(errors.New("a failure"))
+					return resp
+				}
+			}
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+			assertNoErrors(t, diags)
+
+			var buf bytes.Buffer
+			_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				GraphDumpOnError: &buf,
+			})
+			if got := diags.HasErrors(); got != fail {
+				t.Fatalf("apply returned errors %t; want %t\n%s", got, fail, diags.ErrWithWarnings())
+			}
+
+			dot := buf.String()
+			if !fail {
+				if dot != "" {
+					t.Errorf("graph written after successful apply:\n%s", dot)
+				}
+				return
+			}
+			if !strings.HasPrefix(dot, "digraph {") {
+				t.Errorf("graph is not in dot format:\n%s", dot)
+			}
+			if !strings.Contains(dot, `"test_object.a"`) {
+				t.Errorf("graph does not include test_object.a:\n%s", dot)
+			}
+		})
+	}
+}
+
+func TestContext2Apply_providerWarnings(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		resp.NewState = req.PlannedState
+		resp.Diagnostics = resp.Diagnostics.Append(tfdiags.AttributeValue(
+			tfdiags.Warning,
+			"Deprecated attribute",
+			"The provider warns about this attribute.",
+			cty.GetAttrPath("test_string"),
+		))
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	// A targeted plan makes Apply return a warning of its own too.
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), &PlanOpts{
+		Mode: plans.NormalMode,
+		Targets: []addrs.Targetable{
+			mustResourceInstanceAddr("test_object.a"),
+		},
+	})
+	assertNoErrors(t, diags)
+
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		CaptureDiagnostics: true,
+	})
+	assertNoErrors(t, diags)
+
+	var coreWarnings []string
+	for _, diag := range diags {
+		if diag.Severity() == tfdiags.Warning && tfdiags.DiagnosticCategory(diag) != tfdiags.CategoryProvider {
+			coreWarnings = append(coreWarnings, diag.Description().Summary)
+		}
+	}
+	if diff := cmp.Diff([]string{"Applied changes may be incomplete"}, coreWarnings); diff != "" {
+		t.Errorf("wrong warnings from OpenTofu itself\n%s", diff)
+	}
+
+	got := ctx.LastApplyProviderWarnings()
+	if len(got) != 1 {
+		t.Fatalf("wrong number of provider warnings %d; want 1\n%s", len(got), got.ErrWithWarnings())
+	}
+	if got, want := got[0].Description().Summary, "Deprecated attribute"; got != want {
+		t.Errorf("wrong provider warning %q; want %q", got, want)
+	}
+	// The provider's warning must still be placed in the configuration,
+	// even though it has been classified.
+	if subject := got[0].Source().Subject; subject == nil || subject.Start.Line != 3 {
+		t.Errorf("provider warning has wrong source location %#v; want line 3", subject)
+	}
+
+	// The result is a copy, so changing it must not affect later calls.
+	got[0] = nil
+	if again := ctx.LastApplyProviderWarnings(); len(again) != 1 || again[0] == nil {
+		t.Errorf("modifying the result changed the stored provider warnings")
+	}
+}
+
+func TestContext2Apply_taintOnWarning(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "warned" {
+  test_string = "warn"
+}
+
+resource "test_object" "quiet" {
+  test_string = "quiet"
+}
+`,
+	})
+	warned := mustResourceInstanceAddr("test_object.warned")
+	quiet := mustResourceInstanceAddr("test_object.quiet")
+
+	for _, taint := range []bool{true, false} {
+		t.Run(fmt.Sprintf("TaintOnWarning=%t", taint), func(t *testing.T) {
+			p := simpleMockProvider()
+			p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+				if req.PlannedState.GetAttr("test_string").AsString() == "warn" {
+					resp.Diagnostics = resp.Diagnostics.Append(tfdiags.SimpleWarning("Something looks wrong"))
+				}
+				resp.NewState = req.PlannedState
+				return resp
+			}
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+			assertNoErrors(t, diags)
+			state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				TaintOnWarning: taint,
+			})
+			assertNoErrors(t, diags)
+
+			wantStatus := states.ObjectReady
+			if taint {
+				wantStatus = states.ObjectTainted
+			}
+			if got := state.ResourceInstance(warned).Current.Status; got != wantStatus {
+				t.Errorf("wrong status for %s %s; want %s", warned, got, wantStatus)
+			}
+			if got := state.ResourceInstance(quiet).Current.Status; got != states.ObjectReady {
+				t.Errorf("wrong status for %s %s; want %s", quiet, got, states.ObjectReady)
+			}
+
+			var tainted bool
+			for _, diag := range diags {
+				if diag.Description().Summary == "Resource instances tainted because of warnings" {
+					tainted = true
+					if !strings.Contains(diag.Description().Detail, warned.String()) {
+						t.Errorf("warning doesn't mention %s:\n%s", warned, diag.Description().Detail)
+					}
+				}
+			}
+			if tainted != taint {
+				t.Errorf("wrong taint warning: got %t, want %t", tainted, taint)
+			}
+		})
+	}
+}
+
+func TestContext2Apply_forgottenResourceDependents(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+removed {
+  from = test_object.old
+}
+
+resource "test_object" "user" {
+  test_string = "user"
+}
+
+resource "test_object" "unrelated" {
+  test_string = "unrelated"
+}
+`,
+	})
+	old := mustResourceInstanceAddr("test_object.old")
+	state := states.BuildState(func(s *states.SyncState) {
+		for name, deps := range map[string][]addrs.ConfigResource{
+			"old":       nil,
+			"user":      {old.ConfigResource()},
+			"gone":      {old.ConfigResource()},
+			"unrelated": nil,
+		} {
+			s.SetResourceInstanceCurrent(
+				mustResourceInstanceAddr("test_object."+name),
+				&states.ResourceInstanceObjectSrc{
+					Status:       states.ObjectReady,
+					AttrsJSON:    []byte(fmt.Sprintf(`{"test_string":%q}`, name)),
+					Dependencies: deps,
+				},
+				mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+				addrs.NoKey,
+			)
+		}
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	newState, diags := ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	var details []string
+	for _, diag := range diags {
+		if desc := diag.Description(); desc.Summary == "Forgotten resource has dependents" {
+			details = append(details, desc.Detail)
+		}
+	}
+	if len(details) != 1 {
+		t.Fatalf("want one warning about dependents, got %d:\n%s", len(details), strings.Join(details, "\n\n"))
+	}
+	// The destroyed dependent doesn't need a warning, because it's going
+	// away too.
+	if !strings.Contains(details[0], "test_object.old") || !strings.Contains(details[0], "test_object.user") {
+		t.Errorf("warning doesn't mention the forgotten resource and its dependent:\n%s", details[0])
+	}
+	for _, name := range []string{"test_object.gone", "test_object.unrelated"} {
+		if strings.Contains(details[0], name) {
+			t.Errorf("warning mentions %s, which is not a live dependent:\n%s", name, details[0])
+		}
+	}
+
+	// The warning doesn't stop the forget.
+	if is := newState.ResourceInstance(old); is != nil {
+		t.Errorf("%s was not forgotten", old)
+	}
+}
@@ -0,0 +1,827 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+func TestContext2Apply_cleanupDependentsOnFailure(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "new"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+
+resource "test_object" "c" {
+  test_string = "unrelated"
+}
+`,
+	})
+	addrA := mustResourceInstanceAddr("test_object.a")
+	addrB := mustResourceInstanceAddr("test_object.b")
+	addrC := mustResourceInstanceAddr("test_object.c")
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			addrB,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"old"}`),
+			},
+			mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			addrs.NoKey,
+		)
+		s.SetResourceInstanceCurrent(
+			addrC,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"unrelated"}`),
+			},
+			mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			addrs.NoKey,
+		)
+	})
+
+	for _, cleanup := range []bool{false, true} {
+		t.Run(fmt.Sprintf("cleanup=%t", cleanup), func(t *testing.T) {
+			p := simpleMockProvider()
+			var destroyed []string
+			var mu sync.Mutex
+			p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+				if req.PlannedState.IsNull() {
+					mu.Lock()
+					destroyed = append(destroyed, req.PriorState.GetAttr("test_string").AsString())
+					mu.Unlock()
+					resp.NewState = req.PlannedState
+					return resp
+				}
+				if req.PriorState.IsNull() && req.PlannedState.GetAttr("test_string").RawEquals(cty.StringVal("new")) {
+					resp.NewState = req.PriorState
+					resp.Diagnostics = resp.Diagnostics.Append(errors.New("create failed"))
+					return resp
+				}
+				resp.NewState = req.PlannedState
+				return resp
+			}
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+			assertNoErrors(t, diags)
+
+			newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				CleanupDependentsOnFailure: cleanup,
+			})
+			if !diags.HasErrors() {
+				t.Fatal("apply succeeded; want create error")
+			}
+			if got, want := diags.Err().Error(), "create failed"; !strings.Contains(got, want) {
+				t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+			}
+
+			if got := newState.ResourceInstance(addrA); got != nil && got.Current != nil {
+				t.Errorf("%s was created, but its create should have failed", addrA)
+			}
+			if got := newState.ResourceInstance(addrC); got == nil || got.Current == nil {
+				t.Errorf("%s was destroyed, but it does not depend on %s", addrC, addrA)
+			}
+
+			gotB := newState.ResourceInstance(addrB)
+			if cleanup {
+				if gotB != nil && gotB.Current != nil {
+					t.Errorf("%s was not destroyed", addrB)
+				}
+				if diff := cmp.Diff([]string{"old"}, destroyed); diff != "" {
+					t.Errorf("wrong objects destroyed\n%s", diff)
+				}
+				found := false
+				for _, diag := range diags {
+					desc := diag.Description()
+					if diag.Severity() == tfdiags.Warning && desc.Summary == "Destroyed dependents of failed resources" {
+						found = true
+						if !strings.Contains(desc.Detail, addrB.String()) {
+							t.Errorf("warning does not mention %s\n%s", addrB, desc.Detail)
+						}
+					}
+				}
+				if !found {
+					t.Errorf("no warning about destroyed dependents\n%s", diags.ErrWithWarnings())
+				}
+			} else {
+				if gotB == nil || gotB.Current == nil {
+					t.Errorf("%s was destroyed without CleanupDependentsOnFailure", addrB)
+				}
+				if len(destroyed) != 0 {
+					t.Errorf("unexpected objects destroyed: %v", destroyed)
+				}
+			}
+		})
+	}
+}
+
+func TestContext2Apply_untilConverged(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+}
+`,
+	})
+
+	t.Run("targeted", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		// The first round applies only the targeted resource, and then the
+		// second round applies everything else.
+		state, rounds, diags := ctx.ApplyUntilConverged(context.Background(), m, states.NewState(), 5, &PlanOpts{
+			Mode:    plans.NormalMode,
+			Targets: []addrs.Targetable{mustResourceInstanceAddr("test_object.a")},
+		})
+		assertNoErrors(t, diags)
+		want := []ConvergenceRound{
+			{Targeted: true, Changes: ApplyChangeCounts{Planned: 1, Applied: 1}},
+			{Targeted: false, Changes: ApplyChangeCounts{Planned: 1, Applied: 1}},
+		}
+		if diff := cmp.Diff(want, rounds); diff != "" {
+			t.Errorf("wrong rounds\n%s", diff)
+		}
+		for _, addr := range []string{"test_object.a", "test_object.b"} {
+			if state.ResourceInstance(mustResourceInstanceAddr(addr)) == nil {
+				t.Errorf("final state does not include %s", addr)
+			}
+		}
+	})
+
+	t.Run("not converged", func(t *testing.T) {
+		// This provider reports drift on every refresh, so every plan
+		// proposes an update and the apply never converges.
+		p := simpleMockProvider()
+		p.ReadResourceFn = func(req providers.ReadResourceRequest) (resp providers.ReadResourceResponse) {
+			resp.NewState = cty.ObjectVal(map[string]cty.Value{
+				"test_string": cty.StringVal("drifted"),
+				"test_number": cty.NullVal(cty.Number),
+				"test_bool":   cty.NullVal(cty.Bool),
+				"test_list":   cty.NullVal(cty.List(cty.String)),
+				"test_map":    cty.NullVal(cty.Map(cty.String)),
+			})
+			return resp
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		_, rounds, diags := ctx.ApplyUntilConverged(context.Background(), m, states.NewState(), 2, nil)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Err())
+		}
+		if got, want := len(rounds), 2; got != want {
+			t.Errorf("wrong number of rounds %d; want %d", got, want)
+		}
+		if len(diags) != 1 || diags[0].Description().Summary != "Configuration did not converge" {
+			t.Errorf("wrong diagnostics; want only the convergence warning\n%s", diags.ErrWithWarnings())
+		}
+	})
+}
+
+func TestContext2Apply_lockChecker(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+`,
+	})
+
+	errLockLost := errors.New("lease expired")
+	var lost atomic.Bool
+	stopped := make(chan struct{})
+
+	p := simpleMockProvider()
+	p.StopFn = func() error {
+		close(stopped)
+		return nil
+	}
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		if req.PlannedState.GetAttr("test_string").AsString() == "a" {
+			// We lose the lock while creating test_object.a, and so the
+			// apply must stop before it creates test_object.b.
+			lost.Store(true)
+			select {
+			case <-stopped:
+			case <-time.After(10 * time.Second):
+				t.Error("apply was not stopped after the lock was lost")
+			}
+		}
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		LockChecker: func() error {
+			if lost.Load() {
+				return errLockLost
+			}
+			return nil
+		},
+		LockCheckInterval: time.Millisecond,
+	})
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want lock lost error")
+	}
+	if !errors.Is(diags.Err(), errLockLost) {
+		t.Errorf("wrong error\ngot:  %s\nwant: error caused by %q", diags.Err(), errLockLost)
+	}
+	if got, want := diags.Err().Error(), "State lock lost during apply"; !strings.Contains(got, want) {
+		t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+	}
+
+	if state.ResourceInstance(mustResourceInstanceAddr("test_object.a")) == nil {
+		t.Error("test_object.a is missing from the partial state")
+	}
+	if state.ResourceInstance(mustResourceInstanceAddr("test_object.b")) != nil {
+		t.Error("test_object.b was created after the lock was lost")
+	}
+}
+
+func TestContext2Apply_lockCheckerHeld(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		LockChecker:       func() error { return nil },
+		LockCheckInterval: time.Millisecond,
+	})
+	assertNoErrors(t, diags)
+}
+
+func TestContext2Apply_goroutineLimit(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+`,
+	})
+
+	for _, abort := range []bool{false, true} {
+		t.Run(fmt.Sprintf("abort=%t", abort), func(t *testing.T) {
+			var leaking atomic.Bool
+			sampled := make(chan struct{})
+			var sampledOnce sync.Once
+			countGoroutines := func() int {
+				if !leaking.Load() {
+					return 10
+				}
+				sampledOnce.Do(func() { close(sampled) })
+				return 5000
+			}
+
+			stopped := make(chan struct{})
+			p := simpleMockProvider()
+			p.StopFn = func() error {
+				close(stopped)
+				return nil
+			}
+			p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+				if req.PlannedState.GetAttr("test_string").AsString() == "a" {
+					// The provider starts "leaking" while creating
+					// test_object.a, and we wait until the monitor has
+					// noticed before continuing.
+					leaking.Store(true)
+					wait := sampled
+					if abort {
+						wait = stopped
+					}
+					select {
+					case <-wait:
+					case <-time.After(10 * time.Second):
+						t.Error("goroutine monitor did not react to the high goroutine count")
+					}
+				}
+				resp.NewState = req.PlannedState
+				return resp
+			}
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+			assertNoErrors(t, diags)
+
+			state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				GoroutineLimit:         1000,
+				GoroutineCheckInterval: time.Millisecond,
+				AbortOnGoroutineLimit:  abort,
+				countGoroutines:        countGoroutines,
+			})
+
+			var found tfdiags.Diagnostic
+			for _, diag := range diags {
+				if diag.Description().Summary == "Goroutine limit exceeded during apply" {
+					found = diag
+				}
+			}
+			if found == nil {
+				t.Fatalf("no goroutine limit diagnostic\ngot: %s", diags.ErrWithWarnings())
+			}
+			if got, want := found.Description().Detail, "5000 goroutines"; !strings.Contains(got, want) {
+				t.Errorf("wrong detail\ngot:  %s\nwant detail containing: %s", got, want)
+			}
+
+			createdB := state.ResourceInstance(mustResourceInstanceAddr("test_object.b")) != nil
+			if abort {
+				if found.Severity() != tfdiags.Error {
+					t.Errorf("diagnostic is %s; want error", found.Severity())
+				}
+				if createdB {
+					t.Error("test_object.b was created after the goroutine limit was exceeded")
+				}
+			} else {
+				assertNoErrors(t, diags)
+				if found.Severity() != tfdiags.Warning {
+					t.Errorf("diagnostic is %s; want warning", found.Severity())
+				}
+				if !createdB {
+					t.Error("test_object.b was not created")
+				}
+			}
+			if state.ResourceInstance(mustResourceInstanceAddr("test_object.a")) == nil {
+				t.Error("test_object.a is missing from the state")
+			}
+		})
+	}
+}
+
+func TestContext2Apply_retryFailed(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b-${test_object.a.test_string}"
+}
+
+resource "test_object" "c" {
+  test_string = "c-${test_object.b.test_string}"
+}
+`,
+	})
+
+	for name, partial := range map[string]bool{"clean failure": false, "partial object": true} {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			applied := make(map[string]int)
+			failB := true
+			p := simpleMockProvider()
+			p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+				mu.Lock()
+				defer mu.Unlock()
+				v := req.PlannedState.GetAttr("test_string").AsString()
+				applied[v]++
+				if v == "b-a" && failB {
+					resp.Diagnostics = resp.Diagnostics.Append(errors.New("temporary failure"))
+					if partial {
+						resp.NewState = req.PlannedState
+					} else {
+						resp.NewState = cty.NullVal(req.PlannedState.Type())
+					}
+					return resp
+				}
+				resp.NewState = req.PlannedState
+				return resp
+			}
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+			assertNoErrors(t, diags)
+
+			_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				RecordFailures: true,
+			})
+			if !diags.HasErrors() {
+				t.Fatal("first apply succeeded; want failure")
+			}
+
+			failures := ctx.LastApplyFailures()
+			if failures == nil {
+				t.Fatal("no failures recorded")
+			}
+			wantFailed := []addrs.AbsResourceInstance{mustResourceInstanceAddr("test_object.b")}
+			wantNotReached := []addrs.AbsResourceInstance{mustResourceInstanceAddr("test_object.c")}
+			if diff := cmp.Diff(wantFailed, failures.Failed); diff != "" {
+				t.Errorf("wrong failed resource instances\n%s", diff)
+			}
+			if diff := cmp.Diff(wantNotReached, failures.NotReached); diff != "" {
+				t.Errorf("wrong unreached resource instances\n%s", diff)
+			}
+
+			failB = false
+			state, diags := ctx.RetryFailed(context.Background(), failures, m)
+			if partial {
+				if !diags.HasErrors() {
+					t.Fatal("retry succeeded; want error for the partially-created object")
+				}
+				if got, want := diags.Err().Error(), "The planned change for test_object.b cannot be retried"; !strings.Contains(got, want) {
+					t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+				}
+				if got := applied["c-b-a"]; got != 0 {
+					t.Errorf("test_object.c was applied %d times; want 0", got)
+				}
+				return
+			}
+			assertNoErrors(t, diags)
+
+			wantApplied := map[string]int{
+				"a":     1, // not repeated, because it succeeded the first time
+				"b-a":   2,
+				"c-b-a": 1,
+			}
+			if diff := cmp.Diff(wantApplied, applied); diff != "" {
+				t.Errorf("wrong apply calls\n%s", diff)
+			}
+			for _, addr := range []string{"test_object.a", "test_object.b", "test_object.c"} {
+				if state.ResourceInstance(mustResourceInstanceAddr(addr)) == nil {
+					t.Errorf("%s is missing from the state after the retry", addr)
+				}
+			}
+			if got := ctx.LastApplyFailures(); got != nil {
+				t.Errorf("retry recorded failures: %#v", got)
+			}
+		})
+	}
+
+	t.Run("nothing to retry", func(t *testing.T) {
+		ctx := testContext2(t, &ContextOpts{})
+		_, diags := ctx.RetryFailed(context.Background(), nil, m)
+		if got, want := diags.Err().Error(), "Nothing to retry"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+	})
+}
+
+func TestContext2Apply_rollbackOnError(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "existing" {
+  test_string = "updated"
+}
+
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b-${test_object.a.test_string}"
+}
+
+resource "test_object" "c" {
+  test_string = "c-${test_object.b.test_string}"
+}
+`,
+	})
+
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	priorState := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			mustResourceInstanceAddr("test_object.existing"),
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"original"}`),
+			},
+			providerAddr,
+			addrs.NoKey,
+		)
+	})
+
+	var mu sync.Mutex
+	var destroyed []string
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		if req.PlannedState.IsNull() {
+			mu.Lock()
+			destroyed = append(destroyed, req.PriorState.GetAttr("test_string").AsString())
+			mu.Unlock()
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		resp.NewState = req.PlannedState
+		if req.PlannedState.GetAttr("test_string").AsString() == "b-a" {
+			// The object is partially created before the failure, and so
+			// remains in the state as tainted.
+			resp.Diagnostics = resp.Diagnostics.Append(errors.New("creation failed"))
+		}
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, priorState, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		RollbackOnError: true,
+	})
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want failure")
+	}
+
+	summaries := make(map[string]string)
+	for _, diag := range diags {
+		desc := diag.Description()
+		summaries[desc.Summary] = desc.Detail
+	}
+	if _, ok := summaries["creation failed"]; !ok {
+		t.Errorf("original error is missing\n%s", diags.ErrWithWarnings())
+	}
+	if got, want := summaries["Rolled back created resources"], "\n  - test_object.a\n  - test_object.b"; !strings.HasSuffix(got, want) {
+		t.Errorf("wrong rollback warning\ngot:  %s\nwant detail ending: %s", got, want)
+	}
+	if got, want := summaries["Changes not rolled back"], "\n  - test_object.existing"; !strings.HasSuffix(got, want) {
+		t.Errorf("wrong kept changes warning\ngot:  %s\nwant detail ending: %s", got, want)
+	}
+	if _, ok := summaries["Rollback incomplete"]; ok {
+		t.Errorf("unexpected incomplete rollback\n%s", diags.ErrWithWarnings())
+	}
+
+	// The objects are destroyed in reverse dependency order.
+	if diff := cmp.Diff([]string{"b-a", "a"}, destroyed); diff != "" {
+		t.Errorf("wrong destroyed objects\n%s", diff)
+	}
+	for _, addr := range []string{"test_object.a", "test_object.b", "test_object.c"} {
+		if is := state.ResourceInstance(mustResourceInstanceAddr(addr)); is != nil && is.Current != nil {
+			t.Errorf("%s remains in the state after the rollback", addr)
+		}
+	}
+	existing := state.ResourceInstance(mustResourceInstanceAddr("test_object.existing"))
+	if existing == nil || !strings.Contains(string(existing.Current.AttrsJSON), `"updated"`) {
+		t.Errorf("test_object.existing does not keep its update")
+	}
+}
+
+func TestContext2Apply_cancelRun(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+`,
+	})
+
+	// The first apply of test_object.a blocks until the test has cancelled
+	// the apply.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var block sync.Once
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		if req.Config.GetAttr("test_string").AsString() == "a" {
+			block.Do(func() {
+				close(started)
+				<-release
+			})
+		}
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	if ctx.CancelRun("tenant-a") {
+		t.Fatal("CancelRun succeeded before the apply started")
+	}
+
+	type applyResult struct {
+		state *states.State
+		diags tfdiags.Diagnostics
+	}
+	resultCh := make(chan applyResult)
+	go func() {
+		state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			RunID: "tenant-a",
+		})
+		resultCh <- applyResult{state, diags}
+	}()
+
+	<-started
+	if ctx.CancelRun("tenant-b") {
+		t.Error("CancelRun succeeded for a run ID that isn't running")
+	}
+	if !ctx.CancelRun("tenant-a") {
+		t.Error("CancelRun failed for the running apply")
+	}
+	close(release)
+	result := <-resultCh
+
+	if !result.diags.HasErrors() {
+		t.Fatal("expected the cancelled apply to fail")
+	}
+	for _, diag := range result.diags {
+		if got := diag.Description().Summary; got != "execution halted" {
+			t.Errorf("unexpected diagnostic: %s", got)
+		}
+	}
+	if is := result.state.ResourceInstance(mustResourceInstanceAddr("test_object.a")); is == nil || is.Current == nil {
+		t.Error("test_object.a should have been applied before the apply stopped")
+	}
+	if is := result.state.ResourceInstance(mustResourceInstanceAddr("test_object.b")); is != nil {
+		t.Error("test_object.b should not have been applied after the apply was cancelled")
+	}
+	if !p.StopCalled {
+		t.Error("provider should have been stopped")
+	}
+
+	if got, want := ctx.LastApplyRunID(), "tenant-a"; got != want {
+		t.Errorf("wrong run ID %q; want %q", got, want)
+	}
+	if ctx.CancelRun("tenant-a") {
+		t.Error("CancelRun succeeded after the apply finished")
+	}
+
+	// An apply without a chosen run ID still gets one.
+	plan, diags = ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+	if id := ctx.LastApplyRunID(); id == "" || id == "tenant-a" {
+		t.Errorf("wrong generated run ID %q", id)
+	}
+}
+
+func TestContext2Apply_errorRateThreshold(t *testing.T) {
+	// Half of the ten independent resource instances fail.
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  count       = 10
+  test_string = count.index % 2 == 0 ? "fail" : "ok"
+}
+`,
+	})
+
+	apply := func(t *testing.T, threshold float64) (ApplyChangeCounts, int, tfdiags.Diagnostics) {
+		t.Helper()
+
+		var mu sync.Mutex
+		failures := 0
+		p := simpleMockProvider()
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			if req.Config.GetAttr("test_string").AsString() == "fail" {
+				mu.Lock()
+				failures++
+				mu.Unlock()
+				resp.Diagnostics = resp.Diagnostics.Append(errors.New("injected failure"))
+				return resp
+			}
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+			// With only one change running at a time, the breaker trips
+			// before any other change starts.
+			Parallelism: 1,
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ErrorRateThreshold: threshold,
+		})
+		return ctx.LastApplyChangeCounts(), failures, diags
+	}
+
+	hasTripped := func(diags tfdiags.Diagnostics) bool {
+		for _, diag := range diags {
+			if diag.Description().Summary == "Error rate threshold exceeded" {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("exceeded", func(t *testing.T) {
+		counts, failures, diags := apply(t, 0.2)
+		if !hasTripped(diags) {
+			t.Fatalf("apply was not stopped\n%s", diags.ErrWithWarnings())
+		}
+		// The third of the ten changes to fail exceeds the threshold.
+		if failures != 3 {
+			t.Errorf("wrong number of failed changes %d; want 3", failures)
+		}
+		if counts.Failed != 3 || counts.NotReached() < 2 {
+			t.Errorf("wrong change counts %#v", counts)
+		}
+	})
+	t.Run("not exceeded", func(t *testing.T) {
+		counts, failures, diags := apply(t, 0.5)
+		if hasTripped(diags) {
+			t.Fatalf("apply was stopped\n%s", diags.ErrWithWarnings())
+		}
+		if failures != 5 {
+			t.Errorf("wrong number of failed changes %d; want 5", failures)
+		}
+		if want := (ApplyChangeCounts{Planned: 10, Applied: 5, Failed: 5}); counts != want {
+			t.Errorf("wrong change counts %#v; want %#v", counts, want)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, failures, diags := apply(t, 1)
+		if got, want := diags.Err().Error(), "Invalid error rate threshold"; !strings.Contains(got, want) {
+			t.Errorf("wrong error %q; want %q", got, want)
+		}
+		if failures != 0 {
+			t.Errorf("changes were applied despite the invalid threshold")
+		}
+	})
+}
@@ -0,0 +1,1401 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/lang/marks"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// hookContextTestHook is a hook which records the request-scoped values
+// it receives, along with the values visible at the time of each PreApply.
+type hookContextTestHook struct {
+	NilHook
+
+	mu       sync.Mutex
+	values   map[string]any
+	preApply []map[string]any
+}
+
+var _ HookContextReceiver = (*hookContextTestHook)(nil)
+
+func (h *hookContextTestHook) SetHookContext(values map[string]any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.values = values
+}
+
+func (h *hookContextTestHook) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.preApply = append(h.preApply, h.values)
+	return HookActionContinue, nil
+}
+
+func TestContext2Apply_hookContext(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	hook := &hookContextTestHook{}
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{hook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	hookContext := map[string]any{
+		"run_id": "run-abc123",
+		"user":   "jane@example.com",
+	}
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		HookContext: hookContext,
+	})
+	assertNoErrors(t, diags)
+
+	// Mutating the caller's map after the fact must not affect what the
+	// hook received.
+	hookContext["run_id"] = "modified"
+
+	want := []map[string]any{
+		{
+			"run_id": "run-abc123",
+			"user":   "jane@example.com",
+		},
+	}
+	if diff := cmp.Diff(want, hook.preApply); diff != "" {
+		t.Errorf("wrong hook context values\n%s", diff)
+	}
+
+	// A subsequent apply without any hook context must not leak the values
+	// from the previous run.
+	plan, diags = ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+	if hook.values != nil {
+		t.Errorf("hook context values retained from previous apply: %#v", hook.values)
+	}
+}
+
+// runListenerTestHook is a hook which records the run lifecycle events it
+// is notified about, in order.
+type runListenerTestHook struct {
+	NilHook
+
+	mu     sync.Mutex
+	events []string
+}
+
+var _ RunListener = (*runListenerTestHook)(nil)
+
+func (h *runListenerTestHook) OnRunAcquired(phase string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, "acquired "+phase)
+}
+
+func (h *runListenerTestHook) OnRunReleased(phase string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, "released "+phase)
+}
+
+func TestContext2Apply_runListener(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	hook := &runListenerTestHook{}
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{hook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	// The listener must be notified of the release even when the operation
+	// fails, which we force here by applying a plan marked as errored.
+	plan.Errored = true
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want error for errored plan")
+	}
+
+	want := []string{
+		"acquired plan",
+		"released plan",
+		"acquired apply",
+		"released apply",
+	}
+	if diff := cmp.Diff(want, hook.events); diff != "" {
+		t.Errorf("wrong run lifecycle events\n%s", diff)
+	}
+}
+
+func TestContext2Apply_tracer(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+resource "test_object" "b" {
+  test_string = "fails"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		resp.NewState = req.PlannedState
+		if req.Config.GetAttr("test_string").RawEquals(cty.StringVal("fails")) {
+			resp.NewState = req.PriorState
+			resp.Diagnostics = resp.Diagnostics.Append(fmt.Errorf("intentional failure"))
+		}
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		Tracer: tp.Tracer("test"),
+	})
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want intentional failure")
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	if got, want := len(spans), 3; got != want {
+		t.Fatalf("wrong number of spans: got %d, want %d", got, want)
+	}
+
+	root, ok := spans["apply"]
+	if !ok {
+		t.Fatal("no span for the overall apply")
+	}
+	if root.Parent().IsValid() {
+		t.Errorf("apply span has unexpected parent %s", root.Parent().SpanID())
+	}
+	if got, want := root.Status().Code, codes.Error; got != want {
+		t.Errorf("wrong status for apply span: got %s, want %s", got, want)
+	}
+
+	tests := map[string]struct {
+		wantStatus codes.Code
+	}{
+		"test_object.a": {wantStatus: codes.Unset},
+		"test_object.b": {wantStatus: codes.Error},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			span, ok := spans[name]
+			if !ok {
+				t.Fatalf("no span for %s", name)
+			}
+			if got, want := span.Parent().SpanID(), root.SpanContext().SpanID(); got != want {
+				t.Errorf("wrong parent span: got %s, want %s", got, want)
+			}
+			if got, want := span.Status().Code, test.wantStatus; got != want {
+				t.Errorf("wrong status: got %s, want %s", got, want)
+			}
+
+			attrs := make(map[attribute.Key]string)
+			for _, attr := range span.Attributes() {
+				attrs[attr.Key] = attr.Value.Emit()
+			}
+			want := map[attribute.Key]string{
+				"opentofu.resource.address": name,
+				"opentofu.resource.action":  "Create",
+			}
+			if diff := cmp.Diff(want, attrs); diff != "" {
+				t.Errorf("wrong attributes\n%s", diff)
+			}
+			if !span.EndTime().After(span.StartTime()) {
+				t.Errorf("span has no duration")
+			}
+		})
+	}
+}
+
+func TestContext2Apply_operationOverride(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+`,
+	})
+	addr := mustResourceInstanceAddr("test_object.a")
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			addr,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"foo"}`),
+			},
+			mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			addrs.NoKey,
+		)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, &PlanOpts{
+		Mode: plans.DestroyMode,
+	})
+	assertNoErrors(t, diags)
+
+	t.Run("derived from plan mode", func(t *testing.T) {
+		_, op, diags := ctx.applyGraph(plan, m, &ApplyOpts{}, true, true, make(ProviderFunctionMapping))
+		assertNoErrors(t, diags)
+		if got, want := op, walkDestroy; got != want {
+			t.Errorf("wrong operation %s; want %s", got, want)
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		_, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			operation: walkEval + 1,
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Invalid walk operation"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("provider was asked to apply a change")
+		}
+	})
+	t.Run("overridden", func(t *testing.T) {
+		_, op, diags := ctx.applyGraph(plan, m, &ApplyOpts{operation: walkApply}, true, true, make(ProviderFunctionMapping))
+		assertNoErrors(t, diags)
+		if got, want := op, walkApply; got != want {
+			t.Errorf("wrong operation %s; want %s", got, want)
+		}
+
+		// The destroy plan's changes can also be applied using an ordinary
+		// apply walk.
+		newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			operation: walkApply,
+		})
+		assertNoErrors(t, diags)
+		if got := newState.ResourceInstance(addr); got != nil && got.Current != nil {
+			t.Errorf("%s was not destroyed", addr)
+		}
+	})
+}
+
+func TestContext2Apply_batchForgetHooks(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+removed {
+  from = test_object.a
+}
+
+removed {
+  from = test_object.b
+}
+
+resource "test_object" "c" {
+  test_string = "kept"
+}
+`,
+	})
+
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	state := states.BuildState(func(s *states.SyncState) {
+		for _, addr := range []string{"test_object.a[0]", "test_object.a[1]", "test_object.b"} {
+			s.SetResourceInstanceCurrent(mustResourceInstanceAddr(addr), &states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"forgotten"}`),
+			}, providerAddr, addrs.NoKey)
+		}
+		s.SetResourceInstanceDeposed(mustResourceInstanceAddr("test_object.a[0]"), states.DeposedKey("deposed"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectTainted,
+			AttrsJSON: []byte(`{"test_string":"deposed"}`),
+		}, providerAddr, addrs.NoKey)
+	})
+	wantAddrs := []addrs.AbsResourceInstance{
+		mustResourceInstanceAddr("test_object.a[0]"),
+		mustResourceInstanceAddr("test_object.a[1]"),
+		mustResourceInstanceAddr("test_object.b"),
+	}
+
+	for _, batch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("batch=%t", batch), func(t *testing.T) {
+			p := simpleMockProvider()
+			hook := &MockHook{}
+			ctx := testContext2(t, &ContextOpts{
+				Hooks: []Hook{hook},
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+			assertNoErrors(t, diags)
+			newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				BatchForgetHooks: batch,
+			})
+			assertNoErrors(t, diags)
+
+			for _, addr := range wantAddrs {
+				if newState.ResourceInstance(addr) != nil {
+					t.Errorf("%s was not forgotten", addr)
+				}
+			}
+
+			if !batch {
+				if hook.PreForgetBatchCalled || hook.PostForgetBatchCalled {
+					t.Error("batch forget hooks were called without BatchForgetHooks")
+				}
+				return
+			}
+			if diff := cmp.Diff(wantAddrs, hook.PreForgetBatchAddrs); diff != "" {
+				t.Errorf("wrong PreForgetBatch addresses\n%s", diff)
+			}
+			if diff := cmp.Diff(wantAddrs, hook.PostForgetBatchAddrs); diff != "" {
+				t.Errorf("wrong PostForgetBatch addresses\n%s", diff)
+			}
+			// The per-object events are still reported too.
+			if !hook.StateMutationCalled {
+				t.Error("StateMutation was not called for the forgotten objects")
+			}
+		})
+	}
+
+	t.Run("PreForgetBatch error", func(t *testing.T) {
+		p := simpleMockProvider()
+		hook := &MockHook{
+			PreForgetBatchError: errors.New("not allowed"),
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Hooks: []Hook{hook},
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			BatchForgetHooks: true,
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want hook error")
+		}
+		if got, want := diags.Err().Error(), "not allowed"; got != want {
+			t.Errorf("wrong error %q; want %q", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("apply started despite the PreForgetBatch error")
+		}
+		if hook.PostForgetBatchCalled {
+			t.Error("PostForgetBatch was called despite the PreForgetBatch error")
+		}
+	})
+}
+
+func TestContext2Apply_breakpoints(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "${test_object.a.test_string}b"
+}
+
+resource "test_object" "c" {
+  test_string = "${test_object.b.test_string}c"
+}
+`,
+	})
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.old"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"old"}`),
+		}, providerAddr, addrs.NoKey)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	var mu sync.Mutex
+	snapshots := map[string]*states.State{}
+	breakpoint := func(addr string) func(*states.State) {
+		return func(s *states.State) {
+			mu.Lock()
+			defer mu.Unlock()
+			snapshots[addr] = s.DeepCopy()
+			// The snapshot belongs to the callback, so modifying it must
+			// not affect the apply.
+			s.SyncWrapper().RemoveResource(mustResourceInstanceAddr("test_object.a").ContainingResource())
+		}
+	}
+	breakpoints := addrs.MakeMap[addrs.AbsResourceInstance, func(*states.State)]()
+	for _, addr := range []string{"test_object.b", "test_object.c", "test_object.old"} {
+		breakpoints.Put(mustResourceInstanceAddr(addr), breakpoint(addr))
+	}
+
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		Breakpoints: breakpoints,
+	})
+	assertNoErrors(t, diags)
+
+	// Each snapshot should include only the objects that had already been
+	// applied when OpenTofu reached the breakpoint's resource instance.
+	tests := map[string]struct {
+		present, absent []string
+	}{
+		"test_object.b": {
+			present: []string{"test_object.a"},
+			absent:  []string{"test_object.b", "test_object.c"},
+		},
+		"test_object.c": {
+			present: []string{"test_object.a", "test_object.b"},
+			absent:  []string{"test_object.c"},
+		},
+		"test_object.old": {
+			present: []string{"test_object.old"},
+		},
+	}
+	for bp, test := range tests {
+		snap := snapshots[bp]
+		if snap == nil {
+			t.Errorf("breakpoint for %s was not called", bp)
+			continue
+		}
+		for _, addr := range test.present {
+			if snap.ResourceInstance(mustResourceInstanceAddr(addr)) == nil {
+				t.Errorf("snapshot at %s does not include %s", bp, addr)
+			}
+		}
+		for _, addr := range test.absent {
+			if snap.ResourceInstance(mustResourceInstanceAddr(addr)) != nil {
+				t.Errorf("snapshot at %s includes %s, which should not yet be applied", bp, addr)
+			}
+		}
+	}
+
+	for _, addr := range []string{"test_object.a", "test_object.b", "test_object.c"} {
+		if newState.ResourceInstance(mustResourceInstanceAddr(addr)) == nil {
+			t.Errorf("final state does not include %s", addr)
+		}
+	}
+	if newState.ResourceInstance(mustResourceInstanceAddr("test_object.old")) != nil {
+		t.Errorf("final state still includes test_object.old")
+	}
+}
+
+// testApplyGateHook is a hook which declines to create the resource
+// instances in skip, and fails for those in fail.
+type testApplyGateHook struct {
+	NilHook
+
+	skip, fail map[string]bool
+}
+
+var _ ApplyGate = (*testApplyGateHook)(nil)
+
+func (h *testApplyGateHook) ShouldApply(addr addrs.AbsResourceInstance, plannedValue cty.Value) (bool, error) {
+	if h.fail[addr.String()] {
+		return false, errors.New("gate unavailable")
+	}
+	return !h.skip[addr.String()], nil
+}
+
+func TestContext2Apply_applyGate(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+}
+`,
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		p := simpleMockProvider()
+		var mu sync.Mutex
+		var applied []string
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			mu.Lock()
+			defer mu.Unlock()
+			applied = append(applied, req.PlannedState.GetAttr("test_string").AsString())
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		hook := &testApplyGateHook{skip: map[string]bool{"test_object.b": true}}
+		ctx := testContext2(t, &ContextOpts{
+			Hooks: []Hook{hook},
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		state, diags := ctx.Apply(context.Background(), plan, m)
+		assertNoErrors(t, diags)
+
+		if diff := cmp.Diff([]string{"a"}, applied); diff != "" {
+			t.Errorf("wrong applied objects\n%s", diff)
+		}
+		if state.ResourceInstance(mustResourceInstanceAddr("test_object.a")) == nil {
+			t.Error("test_object.a was not created")
+		}
+		if state.ResourceInstance(mustResourceInstanceAddr("test_object.b")) != nil {
+			t.Error("test_object.b was created, but the hook skipped it")
+		}
+		if len(diags) != 1 || diags[0].Description().Summary != "Resource creation skipped" {
+			t.Errorf("wrong diagnostics; want only the skip warning\n%s", diags.ErrWithWarnings())
+		}
+		want := ApplyChangeCounts{Planned: 2, Applied: 1, Skipped: 1}
+		if diff := cmp.Diff(want, ctx.LastApplyChangeCounts()); diff != "" {
+			t.Errorf("wrong change counts\n%s", diff)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		p := simpleMockProvider()
+		hook := &testApplyGateHook{fail: map[string]bool{"test_object.b": true}}
+		ctx := testContext2(t, &ContextOpts{
+			Hooks: []Hook{hook},
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		state, diags := ctx.Apply(context.Background(), plan, m)
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error from the gate")
+		}
+		if got, want := diags.Err().Error(), "gate unavailable"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if got := tfdiags.DiagnosticCategory(diags[0]); got != tfdiags.CategoryHook {
+			t.Errorf("wrong category %q; want %q", got, tfdiags.CategoryHook)
+		}
+		if state.ResourceInstance(mustResourceInstanceAddr("test_object.b")) != nil {
+			t.Error("test_object.b was created despite the gate error")
+		}
+	})
+}
+
+func TestContext2Apply_forgetAuditWriter(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+removed {
+  from = test_object.a
+}
+
+removed {
+  from = test_object.b
+}
+
+resource "test_object" "c" {
+  test_string = "kept"
+}
+`,
+	})
+
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.a"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"secret","test_number":1.5,"test_bool":true}`),
+			AttrSensitivePaths: []cty.PathValueMarks{
+				{
+					Path:  cty.GetAttrPath("test_string"),
+					Marks: cty.NewValueMarks(marks.Sensitive),
+				},
+			},
+		}, providerAddr, addrs.NoKey)
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.b"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_list":["x","y"],"test_map":{"k":"v"}}`),
+		}, providerAddr, addrs.NoKey)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	var audit bytes.Buffer
+	before := time.Now()
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		ForgetAuditWriter: &audit,
+	})
+	assertNoErrors(t, diags)
+
+	lines := strings.Split(strings.TrimSpace(audit.String()), "\n")
+	sort.Strings(lines)
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("wrong number of audit records %d; want %d\n%s", got, want, audit.String())
+	}
+
+	want := []map[string]any{
+		{
+			"address": "test_object.a",
+			"type":    "test_object",
+			"attributes": map[string]any{
+				"test_string": "(sensitive value)",
+				"test_number": 1.5,
+				"test_bool":   true,
+				"test_list":   nil,
+				"test_map":    nil,
+			},
+		},
+		{
+			"address": "test_object.b",
+			"type":    "test_object",
+			"attributes": map[string]any{
+				"test_string": nil,
+				"test_number": nil,
+				"test_bool":   nil,
+				"test_list":   []any{"x", "y"},
+				"test_map":    map[string]any{"k": "v"},
+			},
+		},
+	}
+	for i, line := range lines {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("invalid audit record %q: %s", line, err)
+		}
+
+		ts, err := time.Parse(time.RFC3339Nano, got["timestamp"].(string))
+		if err != nil {
+			t.Errorf("invalid timestamp in %q: %s", line, err)
+		} else if ts.Before(before.Truncate(time.Second)) || ts.After(time.Now()) {
+			t.Errorf("timestamp %s is not during the apply", ts)
+		}
+		delete(got, "timestamp")
+
+		if diff := cmp.Diff(want[i], got); diff != "" {
+			t.Errorf("wrong audit record %d\n%s", i, diff)
+		}
+	}
+}
+
+func TestContext2Apply_perResourceHooks(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	hook := &testHook{}
+	hooks := addrs.MakeMap[addrs.AbsResourceInstance, Hook]()
+	hooks.Put(mustResourceInstanceAddr("test_object.a"), hook)
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		PerResourceHooks: hooks,
+	})
+	assertNoErrors(t, diags)
+
+	var gotActions []string
+	for _, call := range hook.Calls {
+		if call.InstanceID != "test_object.a" {
+			t.Errorf("hook for test_object.a received %s for %q", call.Action, call.InstanceID)
+		}
+		gotActions = append(gotActions, call.Action)
+	}
+	for _, want := range []string{"PreApply", "PostApply"} {
+		if !slices.Contains(gotActions, want) {
+			t.Errorf("hook for test_object.a did not receive %s; got %v", want, gotActions)
+		}
+	}
+}
+
+func TestContext2Apply_perResourceHooksContext(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	hook := &hookContextTestHook{}
+	hooks := addrs.MakeMap[addrs.AbsResourceInstance, Hook]()
+	hooks.Put(mustResourceInstanceAddr("test_object.a"), hook)
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		PerResourceHooks: hooks,
+		HookContext:      map[string]any{"run_id": "run-abc123"},
+	})
+	assertNoErrors(t, diags)
+
+	want := []map[string]any{
+		{"run_id": "run-abc123"},
+	}
+	if diff := cmp.Diff(want, hook.preApply); diff != "" {
+		t.Errorf("wrong hook context values\n%s", diff)
+	}
+}
+
+func TestContext2Apply_perResourceHooksStopping(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+}
+`,
+	})
+
+	// The apply of test_object.a blocks until the test has cancelled the
+	// apply.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		if req.Config.GetAttr("test_string").AsString() == "a" {
+			close(started)
+			<-release
+		}
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	// Every registered hook is told, even one registered only for an
+	// instance that has no planned change.
+	shared := &MockHook{}
+	other := &MockHook{}
+	hooks := addrs.MakeMap[addrs.AbsResourceInstance, Hook]()
+	hooks.Put(mustResourceInstanceAddr("test_object.a"), shared)
+	hooks.Put(mustResourceInstanceAddr("test_object.b"), shared)
+	hooks.Put(mustResourceInstanceAddr("test_object.c"), other)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			RunID:            "run",
+			PerResourceHooks: hooks,
+		})
+	}()
+	<-started
+	if !ctx.CancelRun("run") {
+		t.Error("CancelRun failed for the running apply")
+	}
+	close(release)
+	<-done
+
+	for name, hook := range map[string]*MockHook{"shared": shared, "other": other} {
+		if !hook.StoppingCalled {
+			t.Errorf("%s hook was not told that the apply is stopping", name)
+		}
+	}
+}
+
+// denyPolicyEvaluator is a PolicyEvaluator that denies every change to the
+// resource instances in deny, and records the planned values it evaluates.
+type denyPolicyEvaluator struct {
+	deny map[string]bool
+
+	mu        sync.Mutex
+	evaluated map[string]cty.Value
+}
+
+func (e *denyPolicyEvaluator) Evaluate(addr addrs.AbsResourceInstance, plannedValue cty.Value) tfdiags.Diagnostics {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.evaluated[addr.String()] = plannedValue
+
+	var diags tfdiags.Diagnostics
+	if e.deny[addr.String()] {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Change denied by policy",
+			fmt.Sprintf("The policy does not allow changes to %s.", addr),
+		))
+	}
+	return diags
+}
+
+func TestContext2Apply_policyEvaluator(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "allowed" {
+  test_string = "allowed"
+}
+
+resource "test_object" "denied" {
+  test_string = "denied"
+}
+
+resource "test_object" "dependent" {
+  test_string = test_object.denied.test_string
+}
+`,
+	})
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			mustResourceInstanceAddr("test_object.protected"),
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"protected"}`),
+			},
+			mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			addrs.NoKey,
+		)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	policy := &denyPolicyEvaluator{
+		deny: map[string]bool{
+			"test_object.denied":    true,
+			"test_object.protected": true,
+		},
+		evaluated: make(map[string]cty.Value),
+	}
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		PolicyEvaluator: policy,
+	})
+	if !diags.HasErrors() {
+		t.Fatal("expected the denied changes to fail")
+	}
+	var denied []string
+	for _, diag := range diags {
+		if diag.Severity() == tfdiags.Error {
+			denied = append(denied, diag.Description().Detail)
+		}
+	}
+	sort.Strings(denied)
+	wantDenied := []string{
+		"The policy does not allow changes to test_object.denied.",
+		"The policy does not allow changes to test_object.protected.",
+	}
+	if diff := cmp.Diff(wantDenied, denied); diff != "" {
+		t.Errorf("wrong errors\n%s", diff)
+	}
+
+	wantEvaluated := map[string]cty.Value{
+		"test_object.allowed": cty.StringVal("allowed"),
+		"test_object.denied":  cty.StringVal("denied"),
+	}
+	for addr, want := range wantEvaluated {
+		got, ok := policy.evaluated[addr]
+		if !ok {
+			t.Errorf("policy did not evaluate %s", addr)
+			continue
+		}
+		if got := got.GetAttr("test_string"); !got.RawEquals(want) {
+			t.Errorf("wrong planned test_string for %s: %#v", addr, got)
+		}
+	}
+	if got, ok := policy.evaluated["test_object.protected"]; !ok || !got.IsNull() {
+		t.Errorf("wrong planned value for the delete of test_object.protected: %#v", got)
+	}
+	if _, ok := policy.evaluated["test_object.dependent"]; ok {
+		t.Error("policy evaluated test_object.dependent, which depends on a denied change")
+	}
+
+	gotAddrs := make(map[string]bool)
+	for _, addr := range []string{"test_object.allowed", "test_object.denied", "test_object.dependent", "test_object.protected"} {
+		if is := newState.ResourceInstance(mustResourceInstanceAddr(addr)); is != nil && is.Current != nil {
+			gotAddrs[addr] = true
+		}
+	}
+	wantAddrs := map[string]bool{
+		"test_object.allowed":   true,
+		"test_object.protected": true,
+	}
+	if diff := cmp.Diff(wantAddrs, gotAddrs); diff != "" {
+		t.Errorf("wrong resource instances in the new state\n%s", diff)
+	}
+}
+
+func TestContext2Apply_preApplyValidateHook(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "good" {
+  test_string = "acme-good"
+}
+
+resource "test_object" "bad" {
+  test_string = "bad"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	var mu sync.Mutex
+	var applied []string
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		mu.Lock()
+		defer mu.Unlock()
+		applied = append(applied, req.PlannedState.GetAttr("test_string").AsString())
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	hook := &testNamingPolicyHook{prefix: "acme-"}
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{hook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	state, diags := ctx.Apply(context.Background(), plan, m)
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want policy error")
+	}
+
+	var errs []tfdiags.Diagnostic
+	for _, diag := range diags {
+		if diag.Severity() == tfdiags.Error {
+			errs = append(errs, diag)
+		}
+	}
+	if len(errs) != 1 {
+		t.Fatalf("wrong number of errors %d; want 1\n%s", len(errs), diags.Err())
+	}
+	desc := errs[0].Description()
+	if got, want := desc.Summary, "Planned change rejected"; got != want {
+		t.Errorf("wrong error summary %q; want %q", got, want)
+	}
+	if got, want := desc.Detail, `The planned Create for test_object.bad was rejected before it was applied: test_string "bad" does not start with "acme-".`; got != want {
+		t.Errorf("wrong error detail\ngot:  %s\nwant: %s", got, want)
+	}
+	if errs[0].Source().Subject == nil {
+		t.Error("error has no source location")
+	}
+	if got, want := tfdiags.DiagnosticCategory(errs[0]), tfdiags.CategoryHook; got != want {
+		t.Errorf("wrong category %q; want %q", got, want)
+	}
+
+	if diff := cmp.Diff([]string{"acme-good"}, applied); diff != "" {
+		t.Errorf("wrong applied objects\n%s", diff)
+	}
+	if state.ResourceInstance(mustResourceInstanceAddr("test_object.good")) == nil {
+		t.Error("test_object.good is missing from the new state")
+	}
+	if state.ResourceInstance(mustResourceInstanceAddr("test_object.bad")) != nil {
+		t.Error("test_object.bad was created despite being rejected")
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if got, want := hook.actions, map[string]plans.Action{
+		"test_object.good": plans.Create,
+		"test_object.bad":  plans.Create,
+	}; !cmp.Equal(got, want) {
+		t.Errorf("wrong validated actions\n%s", cmp.Diff(want, got))
+	}
+}
+
+// testNamingPolicyHook is a Hook that rejects any planned object whose
+// test_string attribute does not begin with a particular prefix.
+type testNamingPolicyHook struct {
+	NilHook
+
+	prefix string
+
+	mu      sync.Mutex
+	actions map[string]plans.Action
+}
+
+func (h *testNamingPolicyHook) PreApplyValidate(addr addrs.AbsResourceInstance, action plans.Action, plannedNewState cty.Value) (HookAction, error) {
+	h.mu.Lock()
+	if h.actions == nil {
+		h.actions = make(map[string]plans.Action)
+	}
+	h.actions[addr.String()] = action
+	h.mu.Unlock()
+
+	name := plannedNewState.GetAttr("test_string")
+	if !name.IsKnown() || name.IsNull() {
+		return HookActionContinue, nil
+	}
+	if !strings.HasPrefix(name.AsString(), h.prefix) {
+		return HookActionContinue, fmt.Errorf("test_string %q does not start with %q", name.AsString(), h.prefix)
+	}
+	return HookActionContinue, nil
+}
+
+func TestContext2Apply_preApplyReplaceHook(t *testing.T) {
+	addr := mustResourceInstanceAddr("test_object.a")
+	tests := map[string]struct {
+		config          string
+		status          states.ObjectStatus
+		forceReplace    bool
+		wantAction      plans.Action
+		wantReason      plans.ResourceInstanceChangeActionReason
+		wantReplacePath bool
+	}{
+		"requires replace": {
+			config:          `test_string = "new"`,
+			status:          states.ObjectReady,
+			wantAction:      plans.DeleteThenCreate,
+			wantReason:      plans.ResourceInstanceReplaceBecauseCannotUpdate,
+			wantReplacePath: true,
+		},
+		"requires replace with create_before_destroy": {
+			config: `test_string = "new"
+  lifecycle {
+    create_before_destroy = true
+  }`,
+			status:          states.ObjectReady,
+			wantAction:      plans.CreateThenDelete,
+			wantReason:      plans.ResourceInstanceReplaceBecauseCannotUpdate,
+			wantReplacePath: true,
+		},
+		"tainted": {
+			config:     `test_string = "old"`,
+			status:     states.ObjectTainted,
+			wantAction: plans.DeleteThenCreate,
+			wantReason: plans.ResourceInstanceReplaceBecauseTainted,
+		},
+		"force replace": {
+			config:       `test_string = "old"`,
+			status:       states.ObjectReady,
+			forceReplace: true,
+			wantAction:   plans.DeleteThenCreate,
+			wantReason:   plans.ResourceInstanceReplaceByRequest,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := testModuleInline(t, map[string]string{
+				"main.tf": fmt.Sprintf(`
+resource "test_object" "a" {
+  %s
+}
+`, test.config),
+			})
+			state := states.BuildState(func(s *states.SyncState) {
+				s.SetResourceInstanceCurrent(addr, &states.ResourceInstanceObjectSrc{
+					Status:    test.status,
+					AttrsJSON: []byte(`{"test_string":"old"}`),
+				}, mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`), addrs.NoKey)
+			})
+
+			p := simpleMockProvider()
+			p.PlanResourceChangeFn = func(req providers.PlanResourceChangeRequest) (resp providers.PlanResourceChangeResponse) {
+				resp.PlannedState = req.ProposedNewState
+				if req.PriorState.IsNull() || req.ProposedNewState.IsNull() {
+					return resp
+				}
+				if !req.PriorState.GetAttr("test_string").RawEquals(req.ProposedNewState.GetAttr("test_string")) {
+					resp.RequiresReplace = []cty.Path{cty.GetAttrPath("test_string")}
+				}
+				return resp
+			}
+			hook := &testHook{}
+			mock := &MockHook{}
+			ctx := testContext2(t, &ContextOpts{
+				Hooks: []Hook{hook, mock},
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			opts := &PlanOpts{Mode: plans.NormalMode}
+			if test.forceReplace {
+				opts.ForceReplace = []addrs.AbsResourceInstance{addr}
+			}
+			plan, diags := ctx.Plan(context.Background(), m, state, opts)
+			assertNoErrors(t, diags)
+			hook.Calls = nil
+			_, diags = ctx.Apply(context.Background(), plan, m)
+			assertNoErrors(t, diags)
+
+			if got, want := mock.PreApplyReplaceAddr, addr; !got.Equal(want) {
+				t.Errorf("wrong address %s; want %s", got, want)
+			}
+			if got, want := mock.PreApplyReplaceAction, test.wantAction; got != want {
+				t.Errorf("wrong action %s; want %s", got, want)
+			}
+			if got, want := mock.PreApplyReplaceReason, test.wantReason; got != want {
+				t.Errorf("wrong reason %s; want %s", got, want)
+			}
+			if got, want := mock.PreApplyReplaceRequiredReplace.Has(cty.GetAttrPath("test_string")), test.wantReplacePath; got != want {
+				t.Errorf("wrong required replace paths %#v", mock.PreApplyReplaceRequiredReplace.List())
+			}
+
+			// The reason must be reported exactly once, before the first
+			// PreApply for the replace.
+			replaceCalls := 0
+			firstPreApply := -1
+			for i, call := range hook.Calls {
+				switch call.Action {
+				case "PreApplyReplace":
+					replaceCalls++
+					if firstPreApply != -1 {
+						t.Errorf("PreApplyReplace was called after PreApply")
+					}
+				case "PreApply":
+					if firstPreApply == -1 {
+						firstPreApply = i
+					}
+				}
+			}
+			if replaceCalls != 1 {
+				t.Errorf("PreApplyReplace was called %d times; want 1", replaceCalls)
+			}
+		})
+	}
+}
+
+func TestContext2Apply_destroyWithDeposedHook(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "x" {
+  test_string = "ok"
+  lifecycle {
+    create_before_destroy = true
+  }
+}`,
+	})
+
+	p := simpleMockProvider()
+
+	addr := mustResourceInstanceAddr("test_object.x")
+	deposedKeys := []states.DeposedKey{"00000001", "00000002"}
+	state := states.BuildState(func(s *states.SyncState) {
+		providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+		s.SetResourceInstanceCurrent(addr, &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"ok"}`),
+		}, providerAddr, addrs.NoKey)
+		for _, key := range deposedKeys {
+			s.SetResourceInstanceDeposed(addr, key, &states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectTainted,
+				AttrsJSON: []byte(`{"test_string":"deposed"}`),
+			}, providerAddr, addrs.NoKey)
+		}
+	})
+
+	hook := &deposedDestroyHook{}
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{hook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	state, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	if got := state.ResourceInstance(addr); got == nil || len(got.Deposed) != 0 {
+		t.Fatalf("deposed objects were not destroyed:\n%s", state)
+	}
+
+	sort.Strings(hook.destroyed)
+	want := []string{"test_object.x deposed 00000001", "test_object.x deposed 00000002"}
+	if diff := cmp.Diff(want, hook.destroyed); diff != "" {
+		t.Errorf("wrong deposed objects reported\n%s", diff)
+	}
+}
+
+// deposedDestroyHook records each deposed object reported by the
+// PostDestroyDeposed hook.
+type deposedDestroyHook struct {
+	NilHook
+
+	mu        sync.Mutex
+	destroyed []string
+}
+
+func (h *deposedDestroyHook) PostDestroyDeposed(addr addrs.AbsResourceInstance, key states.DeposedKey) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.destroyed = append(h.destroyed, fmt.Sprintf("%s deposed %s", addr, key))
+	return HookActionContinue, nil
+}
+
+func TestContext2Apply_onGraphBuiltHook(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+`,
+	})
+
+	t.Run("receives graph", func(t *testing.T) {
+		h := &MockHook{}
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Hooks: []Hook{h},
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.Apply(context.Background(), plan, m)
+		assertNoErrors(t, diags)
+
+		if !h.OnGraphBuiltCalled {
+			t.Fatal("OnGraphBuilt was not called")
+		}
+		nodes, _ := ctx.LastApplyGraphSize()
+		if got := len(h.OnGraphBuiltGraph.Vertices()); got != nodes {
+			t.Errorf("hook received a graph with %d nodes; want the apply graph with %d", got, nodes)
+		}
+		var found bool
+		for _, v := range h.OnGraphBuiltGraph.Vertices() {
+			if n, ok := v.(*NodeApplyableResourceInstance); ok && n.Addr.String() == "test_object.a" {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("graph doesn't include test_object.a")
+		}
+	})
+	t.Run("rejects graph", func(t *testing.T) {
+		h := &MockHook{OnGraphBuiltError: errors.New("graph too large")}
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Hooks: []Hook{h},
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.Apply(context.Background(), plan, m)
+		if !diags.HasErrors() || !strings.Contains(diags.Err().Error(), "graph too large") {
+			t.Fatalf("expected the hook's error, got: %v", diags.ErrWithWarnings())
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("provider was asked to apply a change despite the hook's error")
+		}
+	})
+}
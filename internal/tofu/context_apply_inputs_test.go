@@ -0,0 +1,1400 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+	"github.com/zclconf/go-cty-debug/ctydebug"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+func TestContext2Apply_additionalExternalReferences(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+locals {
+  local_value = test_object.a.test_string
+  other_value = "bar"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	// The plan doesn't record any external references, so without the
+	// additional references given at apply time both local values would be
+	// pruned from the apply graph.
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		AdditionalExternalReferences: []addrs.Referenceable{
+			addrs.LocalValue{Name: "local_value"},
+		},
+	})
+	assertNoErrors(t, diags)
+
+	module := state.RootModule()
+	if got, ok := module.LocalValues["local_value"]; !ok {
+		t.Errorf("local.local_value was pruned despite being an additional external reference")
+	} else if want := cty.StringVal("foo"); !got.RawEquals(want) {
+		t.Errorf("wrong value for local.local_value\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if _, ok := module.LocalValues["other_value"]; ok {
+		t.Errorf("local.other_value was retained, but it isn't referenced by anything")
+	}
+}
+
+// planConfigTestVerifier is a PlanConfigVerifier which records whether it
+// was called and returns a fixed set of diagnostics.
+type planConfigTestVerifier struct {
+	called bool
+	diags  tfdiags.Diagnostics
+}
+
+var _ PlanConfigVerifier = (*planConfigTestVerifier)(nil)
+
+func (v *planConfigTestVerifier) Verify(plan *plans.Plan, config *configs.Config) tfdiags.Diagnostics {
+	v.called = true
+	return v.diags
+}
+
+func TestContext2Apply_planConfigVerifier(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+`,
+	})
+
+	t.Run("rejects", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		verifier := &planConfigTestVerifier{
+			diags: tfdiags.Diagnostics{
+				tfdiags.Sourceless(tfdiags.Error, "Incompatible configuration", "The configuration doesn't match the plan."),
+			},
+		}
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			PlanConfigVerifier: verifier,
+		})
+		if !verifier.called {
+			t.Fatal("verifier was not called")
+		}
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error from verifier")
+		}
+		if got, want := diags.Err().Error(), "Incompatible configuration"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("provider ApplyResourceChange was called despite the verifier rejecting the configuration")
+		}
+	})
+
+	t.Run("accepts", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		verifier := &planConfigTestVerifier{
+			diags: tfdiags.Diagnostics{
+				tfdiags.Sourceless(tfdiags.Warning, "Configuration differs from plan", "Some non-essential details have changed."),
+			},
+		}
+		state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			PlanConfigVerifier: verifier,
+		})
+		assertNoErrors(t, diags)
+		if !verifier.called {
+			t.Fatal("verifier was not called")
+		}
+		if len(diags) != 1 || diags[0].Description().Summary != "Configuration differs from plan" {
+			t.Errorf("verifier warning was not returned: %s", diags.ErrWithWarnings())
+		}
+		if !p.ApplyResourceChangeCalled {
+			t.Error("provider ApplyResourceChange was not called")
+		}
+		if got := state.ResourceInstance(mustResourceInstanceAddr("test_object.a")); got == nil {
+			t.Error("test_object.a was not created")
+		}
+	})
+}
+
+func TestContext2Apply_tolerateCorruptChanges(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+resource "test_object" "b" {
+  test_string = "bar"
+}
+`,
+	})
+	addrA := mustResourceInstanceAddr("test_object.a")
+	addrB := mustResourceInstanceAddr("test_object.b")
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	// makePlan returns a plan in which the change for test_object.b has
+	// been corrupted, as if it had been damaged in a saved plan file.
+	makePlan := func(t *testing.T) *plans.Plan {
+		t.Helper()
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		rc := plan.Changes.ResourceInstance(addrB)
+		if rc == nil {
+			t.Fatalf("no planned change for %s", addrB)
+		}
+		rc.After = plans.DynamicValue("not a valid msgpack value")
+		return plan
+	}
+
+	t.Run("default", func(t *testing.T) {
+		_, diags := ctx.Apply(context.Background(), makePlan(t), m)
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error decoding the corrupt change")
+		}
+	})
+
+	t.Run("tolerated", func(t *testing.T) {
+		plan := makePlan(t)
+		state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			TolerateCorruptChanges: true,
+		})
+		assertNoErrors(t, diags)
+
+		if len(diags) != 1 || diags[0].Description().Summary != "Skipping corrupt planned change" {
+			t.Fatalf("expected exactly one warning about the corrupt change, got: %s", diags.ErrWithWarnings())
+		}
+		if got := diags[0].Description().Detail; !strings.Contains(got, addrB.String()) {
+			t.Errorf("warning does not mention %s: %s", addrB, got)
+		}
+
+		if got := state.ResourceInstance(addrA); got == nil {
+			t.Errorf("%s was not created", addrA)
+		}
+		if got := state.ResourceInstance(addrB); got != nil {
+			t.Errorf("%s was created despite its planned change being corrupt", addrB)
+		}
+
+		// The caller's plan must be left intact.
+		if got := plan.Changes.ResourceInstance(addrB); got == nil {
+			t.Errorf("corrupt change for %s was removed from the original plan", addrB)
+		}
+	})
+}
+
+func TestContext2Apply_ignorePriorState(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "unchanged" {
+  test_string = "foo"
+}
+
+resource "test_object" "updated" {
+  test_string = "new"
+}
+
+resource "test_object" "created" {
+  test_string = "${test_object.updated.test_string}-copy"
+}
+`,
+	})
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.unchanged"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"foo"}`),
+		}, providerAddr, addrs.NoKey)
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.updated"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"old"}`),
+		}, providerAddr, addrs.NoKey)
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.orphan"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"orphan"}`),
+		}, providerAddr, addrs.NoKey)
+	})
+
+	p := simpleMockProvider()
+	var mu sync.Mutex
+	applied := map[string]cty.Value{}
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		mu.Lock()
+		defer mu.Unlock()
+		applied[req.PlannedState.GetAttr("test_string").AsString()] = req.PriorState
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		IgnorePriorState: true,
+	})
+	assertNoErrors(t, diags)
+
+	warned := false
+	for _, diag := range diags {
+		if diag.Severity() == tfdiags.Warning && diag.Description().Summary == "Ignoring prior state" {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Errorf("missing warning about ignoring the prior state\ngot: %s", diags.ErrWithWarnings())
+	}
+
+	// Every object must have been created from scratch, including the one
+	// that was planned as a no-op, and nothing must have been destroyed.
+	if got, want := len(applied), 3; got != want {
+		t.Errorf("wrong number of applied changes %d; want %d\n%#v", got, want, applied)
+	}
+	for _, value := range []string{"foo", "new", "new-copy"} {
+		prior, ok := applied[value]
+		if !ok {
+			t.Errorf("no object created with test_string %q", value)
+			continue
+		}
+		if !prior.IsNull() {
+			t.Errorf("object with test_string %q was not created: prior state %#v", value, prior)
+		}
+	}
+	if _, ok := applied["orphan"]; ok {
+		t.Error("orphaned object was destroyed")
+	}
+
+	for _, addr := range []string{"test_object.unchanged", "test_object.updated", "test_object.created"} {
+		if newState.ResourceInstance(mustResourceInstanceAddr(addr)) == nil {
+			t.Errorf("%s is missing from the new state", addr)
+		}
+	}
+	if newState.ResourceInstance(mustResourceInstanceAddr("test_object.orphan")) != nil {
+		t.Error("test_object.orphan is still tracked in the new state")
+	}
+
+	// The plan itself must be unchanged.
+	if plan.PriorState.ResourceInstance(mustResourceInstanceAddr("test_object.orphan")) == nil {
+		t.Error("test_object.orphan was removed from the plan's prior state")
+	}
+}
+
+func TestContext2Apply_erroredPlan(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	plan.Errored = true
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want error for errored plan")
+	}
+	if err := diags.Err(); !errors.Is(err, ErrPlanErrored) {
+		t.Errorf("error does not match ErrPlanErrored: %s", err)
+	}
+	if got, want := diags[0].Description().Summary, "Cannot apply failed plan"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+	if p.ApplyResourceChangeCalled {
+		t.Error("provider called for errored plan")
+	}
+}
+
+func TestContext2Apply_dataSourceResults(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+data "test_object" "d" {
+  test_string = "d"
+
+  # This defers reading the data source until test_object.a is created
+  # during the apply.
+  depends_on = [test_object.a]
+}
+`,
+	})
+	dataAddr := mustResourceInstanceAddr("data.test_object.d")
+
+	apply := func(t *testing.T, result cty.Value) (*MockProvider, *states.State, tfdiags.Diagnostics) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		if change := plan.Changes.ResourceInstance(dataAddr); change == nil || change.Action != plans.Read {
+			t.Fatalf("data source read was not deferred to apply")
+		}
+
+		results := addrs.MakeMap[addrs.AbsResourceInstance, cty.Value]()
+		results.Put(dataAddr, result)
+		state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			DataSourceResults: results,
+		})
+		return p, state, diags
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		// Attributes that are not given are null, as they would be in
+		// a provider's response.
+		p, state, diags := apply(t, cty.ObjectVal(map[string]cty.Value{
+			"test_string": cty.StringVal("d"),
+			"test_number": cty.NumberIntVal(42),
+		}))
+		assertNoErrors(t, diags)
+
+		if p.ReadDataSourceCalled {
+			t.Error("provider was asked to read the data source")
+		}
+		rs := state.ResourceInstance(dataAddr)
+		if rs == nil || rs.Current == nil {
+			t.Fatal("data source result is missing from the state")
+		}
+		if got, want := string(rs.Current.AttrsJSON), `"test_number":42`; !strings.Contains(got, want) {
+			t.Errorf("wrong data source result %s; want it to contain %s", got, want)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		p, _, diags := apply(t, cty.ObjectVal(map[string]cty.Value{
+			"test_number": cty.StringVal("not a number"),
+		}))
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Invalid data source result"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ReadDataSourceCalled {
+			t.Error("provider was asked to read the data source")
+		}
+	})
+}
+
+func TestContext2Apply_requireStateMatch(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "new"
+}
+`,
+	})
+
+	state := states.BuildState(func(s *states.SyncState) {
+		providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.a"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"a"}`),
+		}, providerAddr, addrs.NoKey)
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.b"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"old"}`),
+		}, providerAddr, addrs.NoKey)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			RequireStateMatch: true,
+		})
+		assertNoErrors(t, diags)
+
+		if got, want := string(newState.ResourceInstance(mustResourceInstanceAddr("test_object.b")).Current.AttrsJSON), `"test_string":"new"`; !strings.Contains(got, want) {
+			t.Errorf("wrong new attributes %s; want %s", got, want)
+		}
+	})
+
+	t.Run("drifted", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		// After planning, test_object.a changes outside of OpenTofu.
+		p.ReadResourceFn = func(req providers.ReadResourceRequest) providers.ReadResourceResponse {
+			newState := req.PriorState
+			if newState.GetAttr("test_string").RawEquals(cty.StringVal("a")) {
+				attrs := newState.AsValueMap()
+				attrs["test_string"] = cty.StringVal("changed")
+				newState = cty.ObjectVal(attrs)
+			}
+			return providers.ReadResourceResponse{NewState: newState}
+		}
+
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			RequireStateMatch: true,
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "State changed since plan"; !strings.Contains(got, want) {
+			t.Fatalf("wrong error\ngot:  %s\nwant: message containing %q", got, want)
+		}
+		if got := diags.ErrWithWarnings().Error(); !strings.Contains(got, "test_object.a") || strings.Contains(got, "test_object.b") {
+			t.Errorf("error should name only test_object.a\ngot: %s", got)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("ApplyResourceChange was called; want no changes applied")
+		}
+	})
+}
+
+func TestContext2Apply_refreshOnly(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+}
+`,
+	})
+
+	state := states.BuildState(func(s *states.SyncState) {
+		providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+		for _, name := range []string{"a", "b"} {
+			s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object."+name), &states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"old","test_number":1}`),
+			}, providerAddr, addrs.NoKey)
+		}
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	// After planning, both objects change outside of OpenTofu, but only
+	// test_object.a is refreshed before applying.
+	var mu sync.Mutex
+	var refreshed []cty.Value
+	p.ReadResourceFn = func(req providers.ReadResourceRequest) providers.ReadResourceResponse {
+		mu.Lock()
+		refreshed = append(refreshed, req.PriorState)
+		mu.Unlock()
+		attrs := req.PriorState.AsValueMap()
+		attrs["test_number"] = cty.NumberIntVal(2)
+		return providers.ReadResourceResponse{NewState: cty.ObjectVal(attrs)}
+	}
+	priorNumbers := map[string]cty.Value{}
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+		mu.Lock()
+		priorNumbers[req.PlannedState.GetAttr("test_string").AsString()] = req.PriorState.GetAttr("test_number")
+		mu.Unlock()
+		return providers.ApplyResourceChangeResponse{NewState: req.PlannedState}
+	}
+
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		RefreshOnly: []addrs.AbsResourceInstance{mustResourceInstanceAddr("test_object.a")},
+	})
+	assertNoErrors(t, diags)
+
+	if got, want := len(refreshed), 1; got != want {
+		t.Fatalf("refreshed %d resource instances; want %d", got, want)
+	}
+
+	// The change for test_object.a was applied to its refreshed object,
+	// while test_object.b still had the object from the prior state.
+	want := map[string]cty.Value{
+		"a": cty.NumberIntVal(2),
+		"b": cty.NumberIntVal(1),
+	}
+	if diff := cmp.Diff(want, priorNumbers, ctydebug.CmpOptions); diff != "" {
+		t.Errorf("wrong prior test_number values\n%s", diff)
+	}
+}
+
+func TestContext2Apply_addressRenames(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+}
+
+resource "test_object" "c" {
+  test_string = "c"
+}
+`,
+	})
+
+	// test_object.a was previously called test_object.old, and was renamed
+	// in the configuration without a moved block.
+	state := states.BuildState(func(s *states.SyncState) {
+		providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.old"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"a"}`),
+		}, providerAddr, addrs.NoKey)
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.b"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"b"}`),
+		}, providerAddr, addrs.NoKey)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	// Applying a plan consumes its changes, so each test makes a new one.
+	makePlan := func(t *testing.T) *plans.Plan {
+		plan, diags := ctx.Plan(context.Background(), m, state, &PlanOpts{
+			Mode: plans.NormalMode,
+			Targets: []addrs.Targetable{
+				mustResourceInstanceAddr("test_object.c"),
+			},
+		})
+		assertNoErrors(t, diags)
+		return plan
+	}
+
+	renames := func(pairs ...string) addrs.Map[addrs.AbsResourceInstance, addrs.AbsResourceInstance] {
+		ret := addrs.MakeMap[addrs.AbsResourceInstance, addrs.AbsResourceInstance]()
+		for i := 0; i < len(pairs); i += 2 {
+			ret.Put(mustResourceInstanceAddr(pairs[i]), mustResourceInstanceAddr(pairs[i+1]))
+		}
+		return ret
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		newState, diags := ctx.ApplyWithOpts(context.Background(), makePlan(t), m, &ApplyOpts{
+			AddressRenames: renames("test_object.old", "test_object.a"),
+		})
+		assertNoErrors(t, diags)
+
+		if newState.ResourceInstance(mustResourceInstanceAddr("test_object.old")) != nil {
+			t.Error("test_object.old is still in the new state")
+		}
+		for _, name := range []string{"a", "b", "c"} {
+			is := newState.ResourceInstance(mustResourceInstanceAddr("test_object." + name))
+			if is == nil || is.Current == nil {
+				t.Errorf("test_object.%s is missing from the new state", name)
+				continue
+			}
+			if got, want := string(is.Current.AttrsJSON), fmt.Sprintf(`"test_string":%q`, name); !strings.Contains(got, want) {
+				t.Errorf("wrong attributes for test_object.%s %s; want %s", name, got, want)
+			}
+		}
+	})
+
+	for name, tc := range map[string]struct {
+		renames addrs.Map[addrs.AbsResourceInstance, addrs.AbsResourceInstance]
+		want    string
+	}{
+		"existing": {
+			renames("test_object.old", "test_object.b"),
+			"there is already an object for test_object.b",
+		},
+		"duplicate": {
+			renames("test_object.old", "test_object.a", "test_object.b", "test_object.a"),
+			"another resource instance is also renamed to test_object.a",
+		},
+		"planned": {
+			renames("test_object.old", "test_object.c"),
+			"the plan includes changes",
+		},
+		"missing": {
+			renames("test_object.missing", "test_object.a"),
+			"there is no object for test_object.missing",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, diags := ctx.ApplyWithOpts(context.Background(), makePlan(t), m, &ApplyOpts{
+				AddressRenames: tc.renames,
+			})
+			if !diags.HasErrors() {
+				t.Fatal("apply succeeded; want error")
+			}
+			if got := diags.Err().Error(); !strings.Contains(got, tc.want) {
+				t.Errorf("wrong error\ngot:  %s\nwant: message containing %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestContext2Apply_forbidTarget(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+}
+`,
+	})
+
+	tests := map[string]struct {
+		planOpts  *PlanOpts
+		wantError bool
+	}{
+		"untargeted": {
+			planOpts:  DefaultPlanOpts,
+			wantError: false,
+		},
+		"targeted": {
+			planOpts: &PlanOpts{
+				Mode:    plans.NormalMode,
+				Targets: []addrs.Targetable{mustResourceInstanceAddr("test_object.a")},
+			},
+			wantError: true,
+		},
+		"excluded": {
+			planOpts: &PlanOpts{
+				Mode:     plans.NormalMode,
+				Excludes: []addrs.Targetable{mustResourceInstanceAddr("test_object.a")},
+			},
+			wantError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := simpleMockProvider()
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), test.planOpts)
+			assertNoErrors(t, diags)
+
+			_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				ForbidTarget: true,
+			})
+			if !test.wantError {
+				assertNoDiagnostics(t, diags)
+				return
+			}
+			if !diags.HasErrors() {
+				t.Fatal("apply succeeded; want targeted plan error")
+			}
+			if got, want := diags.Err().Error(), "Targeted plan not allowed"; !strings.Contains(got, want) {
+				t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+			}
+			if p.ApplyResourceChangeCalled {
+				t.Error("targeted plan was applied")
+			}
+		})
+	}
+}
+
+func TestContext2Apply_typeQuotas(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  count = 3
+
+  test_string = "a"
+}
+`,
+	})
+
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	priorState := states.BuildState(func(s *states.SyncState) {
+		// test_object.a[0] already exists and test_object.old will be
+		// destroyed, so the apply nets one new object overall.
+		for _, addr := range []string{"test_object.a[0]", "test_object.old"} {
+			s.SetResourceInstanceCurrent(
+				mustResourceInstanceAddr(addr),
+				&states.ResourceInstanceObjectSrc{
+					Status:    states.ObjectReady,
+					AttrsJSON: []byte(`{"test_string":"a"}`),
+				},
+				providerAddr,
+				addrs.NoKey,
+			)
+		}
+	})
+
+	tests := map[string]struct {
+		quotas    map[string]int
+		wantError string
+	}{
+		"not tripped": {
+			quotas: map[string]int{"test_object": 3},
+		},
+		"other type": {
+			quotas: map[string]int{"test_other": 0},
+		},
+		"tripped": {
+			quotas:    map[string]int{"test_object": 2},
+			wantError: `Applying this plan would leave 3 instances of resource type "test_object", which exceeds its quota of 2. There are 2 in the prior state, and the plan creates 2 and destroys or forgets 1.`,
+		},
+		"negative": {
+			quotas:    map[string]int{"test_object": -1},
+			wantError: `The quota for resource type "test_object" is -1, but quotas must not be negative.`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := simpleMockProvider()
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, priorState, DefaultPlanOpts)
+			assertNoErrors(t, diags)
+
+			state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				TypeQuotas: test.quotas,
+			})
+			if test.wantError == "" {
+				assertNoErrors(t, diags)
+				if got, want := len(state.Resource(mustAbsResourceAddr("test_object.a")).Instances), 3; got != want {
+					t.Errorf("got %d instances of test_object.a; want %d", got, want)
+				}
+				return
+			}
+			if !diags.HasErrors() {
+				t.Fatal("apply succeeded; want quota error")
+			}
+			if got := diags.Err().Error(); !strings.Contains(got, test.wantError) {
+				t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, test.wantError)
+			}
+			if p.ApplyResourceChangeCalled {
+				t.Error("plan was applied despite the quota")
+			}
+		})
+	}
+}
+
+func TestContext2Apply_variableFallbacks(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+variable "name" {
+  type = string
+}
+
+resource "test_object" "a" {
+  test_string = var.name
+}
+`,
+	})
+
+	tests := map[string]struct {
+		fallbacks   map[string]cty.Value
+		wantError   string
+		wantWarning string
+	}{
+		"no fallback": {
+			wantError: `Invalid value for variable "name" recorded in plan file`,
+		},
+		"fallback": {
+			fallbacks:   map[string]cty.Value{"name": cty.StringVal("hello")},
+			wantWarning: `The value recorded in the plan file for variable "name" is invalid`,
+		},
+		"unsuitable fallback": {
+			fallbacks: map[string]cty.Value{"name": cty.ListValEmpty(cty.String)},
+			wantError: `The value recorded in the plan file for variable "name" is invalid, and its fallback value is not suitable: string required.`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := simpleMockProvider()
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), &PlanOpts{
+				Mode: plans.NormalMode,
+				SetVariables: InputValues{
+					"name": &InputValue{
+						Value:      cty.StringVal("hello"),
+						SourceType: ValueFromCLIArg,
+					},
+				},
+			})
+			assertNoErrors(t, diags)
+			// Simulate a plan file whose recorded value can't be decoded.
+			plan.VariableValues["name"] = plans.DynamicValue("not msgpack")
+
+			state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				VariableFallbacks: test.fallbacks,
+			})
+			if test.wantError != "" {
+				if !diags.HasErrors() {
+					t.Fatal("apply succeeded; want error")
+				}
+				if got := diags.Err().Error(); !strings.Contains(got, test.wantError) {
+					t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, test.wantError)
+				}
+				return
+			}
+			assertNoErrors(t, diags)
+
+			var warnings []string
+			for _, diag := range diags {
+				if diag.Severity() == tfdiags.Warning {
+					warnings = append(warnings, diag.Description().Detail)
+				}
+			}
+			if len(warnings) != 1 || !strings.Contains(warnings[0], test.wantWarning) {
+				t.Errorf("wrong warnings %q\nwant one containing: %s", warnings, test.wantWarning)
+			}
+			got := state.ResourceInstance(mustResourceInstanceAddr("test_object.a")).Current.AttrsJSON
+			if want := `"test_string":"hello"`; !strings.Contains(string(got), want) {
+				t.Errorf("wrong attributes\ngot:  %s\nwant attributes containing: %s", got, want)
+			}
+		})
+	}
+}
+
+func TestContext2Apply_refreshUpdatesOnly(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "drifted" {
+  test_string = "refreshed"
+}
+
+resource "test_object" "changed" {
+  test_string = "new"
+}
+
+resource "test_object" "created" {
+  test_string = "new"
+}
+`,
+	})
+	state := states.BuildState(func(s *states.SyncState) {
+		for name, value := range map[string]string{"drifted": "stale", "changed": "old", "gone": "old"} {
+			s.SetResourceInstanceCurrent(
+				mustResourceInstanceAddr("test_object."+name),
+				&states.ResourceInstanceObjectSrc{
+					Status:    states.ObjectReady,
+					AttrsJSON: []byte(fmt.Sprintf(`{"test_string":%q}`, value)),
+				},
+				mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+				addrs.NoKey,
+			)
+		}
+	})
+
+	p := simpleMockProvider()
+	p.ReadResourceFn = func(req providers.ReadResourceRequest) (resp providers.ReadResourceResponse) {
+		resp.NewState = req.PriorState
+		if req.PriorState.GetAttr("test_string").AsString() == "stale" {
+			resp.NewState = cty.ObjectVal(map[string]cty.Value{
+				"test_string": cty.StringVal("refreshed"),
+				"test_number": cty.NullVal(cty.Number),
+				"test_bool":   cty.NullVal(cty.Bool),
+				"test_list":   cty.NullVal(cty.List(cty.String)),
+				"test_map":    cty.NullVal(cty.Map(cty.String)),
+			})
+		}
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		RefreshUpdatesOnly: true,
+	})
+	assertNoErrors(t, diags)
+
+	if p.ApplyResourceChangeCalled {
+		t.Error("provider was asked to apply a change")
+	}
+
+	got := make(map[string]string)
+	for _, rs := range newState.RootModule().Resources {
+		for _, is := range rs.Instances {
+			got[rs.Addr.String()] = string(is.Current.AttrsJSON)
+		}
+	}
+	for name, want := range map[string]string{"drifted": "refreshed", "changed": "old", "gone": "old"} {
+		addr := "test_object." + name
+		if !strings.Contains(got[addr], fmt.Sprintf(`"test_string":%q`, want)) {
+			t.Errorf("wrong object for %s: %s", addr, got[addr])
+		}
+	}
+	if _, ok := got["test_object.created"]; ok {
+		t.Error("test_object.created was created")
+	}
+
+	var detail string
+	for _, diag := range diags {
+		if desc := diag.Description(); desc.Summary == "Resource changes skipped" {
+			detail = desc.Detail
+		}
+	}
+	for _, want := range []string{"test_object.changed (Update)", "test_object.created (Create)", "test_object.gone (Delete)"} {
+		if !strings.Contains(detail, want) {
+			t.Errorf("skipped changes warning doesn't mention %q:\n%s", want, detail)
+		}
+	}
+	if strings.Contains(detail, "test_object.drifted") {
+		t.Errorf("skipped changes warning mentions the no-op change:\n%s", detail)
+	}
+}
+
+func TestContext2Apply_refreshUpdatesOnlyOutputs(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+output "o" {
+  value = test_object.a.test_string
+}
+
+output "kept" {
+  value = "new"
+}
+`,
+	})
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetOutputValue(addrs.OutputValue{Name: "kept"}.Absolute(addrs.RootModuleInstance), cty.StringVal("old"), false)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		RefreshUpdatesOnly: true,
+	})
+	assertNoErrors(t, diags)
+
+	if got := newState.ResourceInstance(mustResourceInstanceAddr("test_object.a")); got != nil {
+		t.Errorf("test_object.a was created")
+	}
+	// The output for the object that wasn't created must not be saved,
+	// and the other output keeps its value from the prior state.
+	outputs := newState.RootModule().OutputValues
+	if got, ok := outputs["o"]; ok {
+		t.Errorf("output o was saved for a skipped create: %#v", got.Value)
+	}
+	if got, want := outputs["kept"], cty.StringVal("old"); got == nil || !got.Value.RawEquals(want) {
+		t.Errorf("wrong value for output kept: %#v; want %#v", got, want)
+	}
+}
+
+func TestContext2Apply_outputOnlyChanges(t *testing.T) {
+	before := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+output "out" {
+  value = test_object.a.test_string
+}
+`,
+	})
+	after := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+output "out" {
+  value = "${test_object.a.test_string}-new"
+}
+`,
+	})
+	withCondition := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+
+  lifecycle {
+    postcondition {
+      condition     = self.test_string == "foo"
+      error_message = "Wrong test_string."
+    }
+  }
+}
+
+output "out" {
+  value = "${test_object.a.test_string}-new"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+	plan, diags := ctx.Plan(context.Background(), before, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	state, diags := ctx.Apply(context.Background(), plan, before)
+	assertNoErrors(t, diags)
+
+	tests := map[string]struct {
+		config        *configs.Config
+		wantFastPath  bool
+		wantConfigure bool
+	}{
+		"output only": {
+			config:        after,
+			wantFastPath:  true,
+			wantConfigure: false,
+		},
+		"resource with postcondition": {
+			config:        withCondition,
+			wantFastPath:  false,
+			wantConfigure: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			plan, diags := ctx.Plan(context.Background(), test.config, state, DefaultPlanOpts)
+			assertNoErrors(t, diags)
+			for _, rc := range plan.Changes.Resources {
+				if rc.Action != plans.NoOp {
+					t.Fatalf("unexpected %s change for %s", rc.Action, rc.Addr)
+				}
+			}
+
+			if got := outputOnlyChanges(plan.Changes, test.config); got != test.wantFastPath {
+				t.Errorf("wrong outputOnlyChanges result: got %t, want %t", got, test.wantFastPath)
+			}
+			graph, _, diags := ctx.applyGraph(plan, test.config, nil, true, true, make(ProviderFunctionMapping))
+			assertNoErrors(t, diags)
+			hasResourceNodes := false
+			for _, v := range graph.Vertices() {
+				if _, ok := v.(*nodeExpandApplyableResource); ok {
+					hasResourceNodes = true
+				}
+			}
+			if hasResourceNodes == test.wantFastPath {
+				t.Errorf("wrong graph: has resource nodes %t, but fast path %t", hasResourceNodes, test.wantFastPath)
+			}
+
+			// We apply using a fresh provider so we can see whether the
+			// apply step needed to configure it.
+			applyP := simpleMockProvider()
+			applyCtx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(applyP),
+				},
+			})
+			newState, diags := applyCtx.Apply(context.Background(), plan, test.config)
+			assertNoErrors(t, diags)
+
+			if got := applyP.ConfigureProviderCalled; got != test.wantConfigure {
+				t.Errorf("wrong ConfigureProviderCalled: got %t, want %t", got, test.wantConfigure)
+			}
+			if applyP.ApplyResourceChangeCalled {
+				t.Error("provider ApplyResourceChange was called for a plan with no resource changes")
+			}
+			if got, want := newState.RootModule().OutputValues["out"].Value, cty.StringVal("foo-new"); !got.RawEquals(want) {
+				t.Errorf("wrong output value\ngot:  %#v\nwant: %#v", got, want)
+			}
+			if newState.ResourceInstance(mustResourceInstanceAddr("test_object.a")) == nil {
+				t.Error("test_object.a is missing from the new state")
+			}
+		})
+	}
+}
+
+func TestContext2Apply_outputOnlyChangesGraphForUI(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+module "child" {
+  source = "./child"
+}
+
+output "out" {
+  value = module.child.out
+}
+`,
+		"child/main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+output "out" {
+  value = test_object.a.test_string
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	state, diags := ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+	plan, diags = ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	if !outputOnlyChanges(plan.Changes, m) {
+		t.Fatal("plan unexpectedly has resource changes")
+	}
+
+	hasResourceNode := func(graph *Graph) bool {
+		for _, v := range graph.Vertices() {
+			if _, ok := v.(*nodeExpandApplyableResource); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	applyGraph, _, diags := ctx.applyGraph(plan, m, nil, true, true, make(ProviderFunctionMapping))
+	assertNoErrors(t, diags)
+	if hasResourceNode(applyGraph) {
+		t.Errorf("apply graph has resource nodes\n%s", applyGraph.String())
+	}
+
+	// The graph shown to the user is not a real apply, and so it must still
+	// include the resource nodes.
+	uiGraph, diags := ctx.ApplyGraphForUI(plan, m)
+	assertNoErrors(t, diags)
+	if !hasResourceNode(uiGraph) {
+		t.Errorf("graph for UI has no resource nodes\n%s", uiGraph.String())
+	}
+	fullGraph, _, diags := ctx.applyGraph(plan, m, nil, true, false, make(ProviderFunctionMapping))
+	assertNoErrors(t, diags)
+	if got, want := uiGraph.String(), fullGraph.String(); got != want {
+		t.Errorf("wrong graph for UI\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func BenchmarkContext2Apply_outputOnlyChanges(b *testing.B) {
+	const resources = 50
+
+	var src strings.Builder
+	for i := 0; i < resources; i++ {
+		fmt.Fprintf(&src, "resource \"test_object\" \"r%d\" {\n  test_string = \"foo\"\n}\n\n", i)
+	}
+	src.WriteString(`
+variable "suffix" {
+  type = string
+}
+
+output "out" {
+  value = "${test_object.r0.test_string}-${var.suffix}"
+}
+`)
+
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "main.tf", []byte(src.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	mod, hclDiags := configs.NewParser(fs).LoadConfigDir(".", configs.RootModuleCallForTesting())
+	if hclDiags.HasErrors() {
+		b.Fatal(hclDiags.Error())
+	}
+	m, hclDiags := configs.BuildConfig(mod, configs.DisabledModuleWalker)
+	if hclDiags.HasErrors() {
+		b.Fatal(hclDiags.Error())
+	}
+
+	p := simpleMockProvider()
+	ctx, diags := NewContext(&ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+	if diags.HasErrors() {
+		b.Fatal(diags.Err())
+	}
+	ctx.encryption = encryption.Disabled()
+
+	planOpts := func(suffix string) *PlanOpts {
+		return &PlanOpts{
+			Mode: plans.NormalMode,
+			SetVariables: InputValues{
+				"suffix": &InputValue{
+					Value:      cty.StringVal(suffix),
+					SourceType: ValueFromCaller,
+				},
+			},
+		}
+	}
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), planOpts("a"))
+	if diags.HasErrors() {
+		b.Fatal(diags.Err())
+	}
+	state, diags := ctx.Apply(context.Background(), plan, m)
+	if diags.HasErrors() {
+		b.Fatal(diags.Err())
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		plan, diags := ctx.Plan(context.Background(), m, state, planOpts(fmt.Sprintf("b%d", i)))
+		if diags.HasErrors() {
+			b.Fatal(diags.Err())
+		}
+		b.StartTimer()
+
+		if _, diags := ctx.Apply(context.Background(), plan, m); diags.HasErrors() {
+			b.Fatal(diags.Err())
+		}
+	}
+}
+
+func TestContext2Apply_duplicateChanges(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	// Simulate a plan that was incorrectly merged with another plan for
+	// the same configuration.
+	addr := mustResourceInstanceAddr("test_object.a")
+	change := plan.Changes.ResourceInstance(addr)
+	if change == nil {
+		t.Fatalf("no planned change for %s", addr)
+	}
+	plan.Changes.Resources = append(plan.Changes.Resources, change, change)
+
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want duplicate change error")
+	}
+	if got, want := len(diags), 1; got != want {
+		t.Errorf("got %d diagnostics; want %d\n%s", got, want, diags.Err())
+	}
+	if got, want := diags.Err().Error(), "The plan includes more than one change for test_object.a"; !strings.Contains(got, want) {
+		t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+	}
+	if p.ApplyResourceChangeCalled {
+		t.Error("plan was applied despite the duplicate change")
+	}
+}
@@ -0,0 +1,637 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty-debug/ctydebug"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/configs/configschema"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/states/statefile"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+func TestContext2Apply_providerCallMiddleware(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+resource "test_object" "b" {
+  test_string = "bar"
+}
+`,
+	})
+
+	t.Run("counts", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		var mu sync.Mutex
+		var called []string
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ProviderCallMiddleware: func(next ProviderCall) ProviderCall {
+				return func(addr addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+					mu.Lock()
+					called = append(called, addr.String())
+					mu.Unlock()
+					return next(addr, req)
+				}
+			},
+		})
+		assertNoErrors(t, diags)
+
+		sort.Strings(called)
+		if diff := cmp.Diff([]string{"test_object.a", "test_object.b"}, called); diff != "" {
+			t.Errorf("wrong middleware calls\n%s", diff)
+		}
+		if !p.ApplyResourceChangeCalled {
+			t.Error("provider ApplyResourceChange was not called")
+		}
+	})
+
+	t.Run("short-circuits", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ProviderCallMiddleware: func(next ProviderCall) ProviderCall {
+				return func(addr addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+					var resp providers.ApplyResourceChangeResponse
+					resp.NewState = req.PriorState
+					resp.Diagnostics = resp.Diagnostics.Append(tfdiags.Sourceless(
+						tfdiags.Error,
+						"Circuit open",
+						fmt.Sprintf("Refusing to apply changes to %s.", addr),
+					))
+					return resp
+				}
+			},
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want errors from middleware")
+		}
+		if got, want := diags.Err().Error(), "Circuit open"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("provider ApplyResourceChange was called despite the middleware short-circuiting")
+		}
+	})
+}
+
+func TestContext2Apply_recordAndPlaybackApplyCalls(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "created" {
+  test_string = "new"
+}
+
+resource "test_object" "updated" {
+  test_string = "${test_object.created.test_string}-updated"
+  test_number = 2
+}
+`,
+	})
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.updated"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"old","test_number":1}`),
+		}, providerAddr, addrs.NoKey)
+		s.SetResourceInstanceCurrent(mustResourceInstanceAddr("test_object.deleted"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"gone"}`),
+		}, providerAddr, addrs.NoKey)
+	})
+
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		resp.NewState = req.PlannedState
+		if !req.PlannedState.IsNull() {
+			resp.Private = []byte(req.PlannedState.GetAttr("test_string").AsString())
+		}
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	var recording bytes.Buffer
+	recordedState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		RecordApplyCalls: &recording,
+	})
+	assertNoErrors(t, diags)
+	if got, want := strings.Count(recording.String(), "\n"), 3; got != want {
+		t.Fatalf("wrong number of recorded calls %d; want %d\n%s", got, want, recording.String())
+	}
+
+	t.Run("playback", func(t *testing.T) {
+		p := simpleMockProvider()
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			t.Errorf("provider called for %s during playback", req.TypeName)
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+		plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		playedState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			PlaybackApplyCalls: bytes.NewReader(recording.Bytes()),
+		})
+		assertNoErrors(t, diags)
+		if !statefile.StatesMarshalEqual(playedState, recordedState) {
+			t.Errorf("played back state differs from recorded state\ngot:\n%s\nwant:\n%s", playedState, recordedState)
+		}
+		obj := playedState.ResourceInstance(mustResourceInstanceAddr("test_object.created")).Current
+		if got, want := string(obj.Private), "new"; got != want {
+			t.Errorf("wrong private data %q; want %q", got, want)
+		}
+	})
+
+	t.Run("missing response", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+		plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			PlaybackApplyCalls: strings.NewReader(""),
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "No recorded provider response"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if p.ApplyResourceChangeCalled {
+			t.Error("provider called during playback")
+		}
+	})
+}
+
+func TestContext2Apply_readinessCheck(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+`,
+	})
+
+	t.Run("ready after polls", func(t *testing.T) {
+		var mu sync.Mutex
+		var events []string
+		polls := map[string]int{}
+
+		p := simpleMockProvider()
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			mu.Lock()
+			events = append(events, "apply")
+			mu.Unlock()
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ReadinessCheck: func(addr addrs.AbsResourceInstance, value cty.Value) (bool, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				polls[addr.String()]++
+				// test_object.a becomes ready on the third poll.
+				ready := addr.String() != "test_object.a" || polls[addr.String()] == 3
+				events = append(events, fmt.Sprintf("poll %s ready=%t", addr, ready))
+				return ready, nil
+			},
+			ReadinessCheckInterval: time.Millisecond,
+		})
+		assertNoErrors(t, diags)
+
+		want := []string{
+			"apply",
+			"poll test_object.a ready=false",
+			"poll test_object.a ready=false",
+			"poll test_object.a ready=true",
+			"apply",
+			"poll test_object.b ready=true",
+		}
+		if diff := cmp.Diff(want, events); diff != "" {
+			t.Errorf("wrong events\n%s", diff)
+		}
+	})
+
+	t.Run("check fails", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+
+		state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ReadinessCheck: func(addr addrs.AbsResourceInstance, value cty.Value) (bool, error) {
+				return false, errors.New("instance entered a failed state")
+			},
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want readiness error")
+		}
+		if got, want := diags.Err().Error(), "The provider applied the change for test_object.a, but the readiness check failed: instance entered a failed state."; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+		}
+		if state.ResourceInstance(mustResourceInstanceAddr("test_object.b")) != nil {
+			t.Error("test_object.b was created even though test_object.a never became ready")
+		}
+	})
+}
+
+func TestContext2Apply_randomSeed(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+terraform {
+  provider_meta "test" {
+    label = "root"
+  }
+}
+
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+module "child" {
+  source = "./child"
+}
+`,
+		"child/main.tf": `
+resource "test_object" "b" {
+  test_string = "b"
+}
+`,
+	})
+
+	// apply applies the configuration from scratch, using a provider whose
+	// provider meta schema is the given one, and returns the provider meta
+	// and the seeded random number that the provider saw for each resource
+	// instance.
+	apply := func(t *testing.T, metaSchema *configschema.Block, seed *int64) (map[string]cty.Value, map[string]int64) {
+		t.Helper()
+
+		p := simpleMockProvider()
+		p.GetProviderSchemaResponse.ProviderMeta = providers.Schema{Block: metaSchema}
+
+		var mu sync.Mutex
+		metas := make(map[string]cty.Value)
+		randoms := make(map[string]int64)
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			mu.Lock()
+			defer mu.Unlock()
+			name := req.Config.GetAttr("test_string").AsString()
+			metas[name] = req.ProviderMeta
+			if !req.ProviderMeta.IsNull() && req.ProviderMeta.Type().HasAttribute("random_seed") {
+				if s := req.ProviderMeta.GetAttr("random_seed"); !s.IsNull() {
+					seed, _ := s.AsBigFloat().Int64()
+					randoms[name] = rand.New(rand.NewSource(seed)).Int63()
+				}
+			}
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			RandomSeed: seed,
+		})
+		assertNoErrors(t, diags)
+		return metas, randoms
+	}
+
+	seedSchema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"label":       {Type: cty.String, Optional: true},
+			"random_seed": {Type: cty.Number, Optional: true},
+		},
+	}
+	seed := int64(42)
+
+	t.Run("supported", func(t *testing.T) {
+		metas, randoms := apply(t, seedSchema, &seed)
+		want := map[string]cty.Value{
+			// The configured provider_meta is kept alongside the seed.
+			"a": cty.ObjectVal(map[string]cty.Value{
+				"label":       cty.StringVal("root"),
+				"random_seed": cty.NumberIntVal(42),
+			}),
+			// The child module has no provider_meta block of its own.
+			"b": cty.ObjectVal(map[string]cty.Value{
+				"label":       cty.NullVal(cty.String),
+				"random_seed": cty.NumberIntVal(42),
+			}),
+		}
+		if diff := cmp.Diff(want, metas, ctydebug.CmpOptions); diff != "" {
+			t.Errorf("wrong provider metas\n%s", diff)
+		}
+
+		// Applying again with the same seed must let the provider produce
+		// the same values.
+		_, again := apply(t, seedSchema, &seed)
+		if len(randoms) != 2 {
+			t.Fatalf("provider did not see the seed for every resource: %v", randoms)
+		}
+		if diff := cmp.Diff(randoms, again); diff != "" {
+			t.Errorf("provider produced different values with the same seed\n%s", diff)
+		}
+	})
+	t.Run("unset", func(t *testing.T) {
+		metas, _ := apply(t, seedSchema, nil)
+		if got := metas["b"]; !got.IsNull() {
+			t.Errorf("wrong provider meta for child module without seed: %#v", got)
+		}
+		if got := metas["a"].GetAttr("random_seed"); !got.IsNull() {
+			t.Errorf("wrong random_seed without seed: %#v", got)
+		}
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		metas, _ := apply(t, &configschema.Block{
+			Attributes: map[string]*configschema.Attribute{
+				"label": {Type: cty.String, Optional: true},
+			},
+		}, &seed)
+		want := map[string]cty.Value{
+			"a": cty.ObjectVal(map[string]cty.Value{
+				"label": cty.StringVal("root"),
+			}),
+			"b": cty.NullVal(cty.DynamicPseudoType),
+		}
+		if diff := cmp.Diff(want, metas, ctydebug.CmpOptions); diff != "" {
+			t.Errorf("wrong provider metas\n%s", diff)
+		}
+	})
+}
+
+func TestContext2Apply_resourceTimeouts(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "slow" {
+  test_string = "slow"
+
+  timeouts {
+    create = "10ms"
+  }
+}
+
+resource "test_object" "fast" {
+  test_string = "fast"
+}
+`,
+	})
+	slow := mustResourceInstanceAddr("test_object.slow")
+	fast := mustResourceInstanceAddr("test_object.fast")
+
+	apply := func(t *testing.T, opts *ApplyOpts) (*states.State, tfdiags.Diagnostics, bool) {
+		t.Helper()
+
+		p := simpleMockProvider()
+		schema := p.GetProviderSchemaResponse.ResourceTypes["test_object"]
+		schema.Block.BlockTypes = map[string]*configschema.NestedBlock{
+			"timeouts": {
+				Nesting: configschema.NestingSingle,
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"create": {Type: cty.String, Optional: true},
+					},
+				},
+			},
+		}
+		p.GetProviderSchemaResponse.ResourceTypes["test_object"] = schema
+
+		// The slow object takes until the provider is stopped, or else a
+		// while longer than its configured timeout, and then reports that
+		// it was created anyway.
+		stopped := make(chan struct{})
+		var stopOnce sync.Once
+		p.StopFn = func() error {
+			stopOnce.Do(func() { close(stopped) })
+			return nil
+		}
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			if req.PlannedState.GetAttr("test_string").AsString() == "slow" {
+				select {
+				case <-stopped:
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, opts)
+		return newState, diags, p.StopCalled
+	}
+	timedOut := func(diags tfdiags.Diagnostics) []string {
+		var ret []string
+		for _, diag := range diags {
+			if desc := diag.Description(); desc.Summary == "Resource apply timed out" {
+				ret = append(ret, desc.Detail)
+			}
+		}
+		return ret
+	}
+
+	t.Run("only in config", func(t *testing.T) {
+		newState, diags, stopCalled := apply(t, &ApplyOpts{})
+		if !stopCalled {
+			t.Error("provider was not stopped")
+		}
+		details := timedOut(diags)
+		if len(details) != 1 || !strings.Contains(details[0], slow.String()) {
+			t.Fatalf("want a timeout for %s only, got: %s", slow, diags.Err())
+		}
+		for _, addr := range []addrs.AbsResourceInstance{slow, fast} {
+			if is := newState.ResourceInstance(addr); is == nil || is.Current == nil {
+				t.Errorf("%s was not recorded", addr)
+			}
+		}
+	})
+	t.Run("from options", func(t *testing.T) {
+		timeouts := addrs.MakeMap[addrs.AbsResourceInstance, time.Duration]()
+		timeouts.Put(slow, time.Hour)
+		newState, diags, stopCalled := apply(t, &ApplyOpts{ResourceTimeouts: timeouts})
+		// The options override the slow object's configured timeout.
+		assertNoErrors(t, diags)
+		if stopCalled {
+			t.Error("provider was stopped despite the longer timeout from the options")
+		}
+		for _, addr := range []addrs.AbsResourceInstance{slow, fast} {
+			if is := newState.ResourceInstance(addr); is == nil || is.Current == nil {
+				t.Errorf("%s was not created", addr)
+			}
+		}
+	})
+}
+
+func TestContext2Apply_functionOverrides(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = uuid()
+}
+
+resource "test_object" "b" {
+  test_string = core::uuid()
+}
+`,
+	})
+	fixedUUID := function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.StringVal("00000000-0000-0000-0000-000000000000"), nil
+		},
+	})
+
+	apply := func(t *testing.T, overrides map[string]function.Function) (*states.State, tfdiags.Diagnostics) {
+		t.Helper()
+
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		return ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			FunctionOverrides: overrides,
+		})
+	}
+
+	t.Run("uuid", func(t *testing.T) {
+		state, diags := apply(t, map[string]function.Function{"uuid": fixedUUID})
+		assertNoErrors(t, diags)
+
+		for _, name := range []string{"test_object.a", "test_object.b"} {
+			is := state.ResourceInstance(mustResourceInstanceAddr(name))
+			if is == nil || is.Current == nil {
+				t.Fatalf("%s was not created", name)
+			}
+			if got, want := string(is.Current.AttrsJSON), `"test_string":"00000000-0000-0000-0000-000000000000"`; !strings.Contains(got, want) {
+				t.Errorf("wrong object for %s: %s", name, got)
+			}
+		}
+	})
+
+	tests := map[string]struct {
+		overrides map[string]function.Function
+		want      string
+	}{
+		"unknown function": {
+			overrides: map[string]function.Function{"not_a_function": fixedUUID},
+			want:      `Cannot override function "not_a_function"`,
+		},
+		"core alias": {
+			overrides: map[string]function.Function{"core::uuid": fixedUUID},
+			want:      `Cannot override function "core::uuid"`,
+		},
+		"wrong parameters": {
+			overrides: map[string]function.Function{"timestamp": function.New(&function.Spec{
+				Params: []function.Parameter{{Name: "format", Type: cty.String}},
+				Type:   function.StaticReturnType(cty.String),
+				Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+					return cty.StringVal("2024-01-01T00:00:00Z"), nil
+				},
+			})},
+			want: `it must take 0 parameters, not 1`,
+		},
+		"variadic parameter": {
+			overrides: map[string]function.Function{"concat": fixedUUID},
+			want:      `it must take a variable number of arguments`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, diags := apply(t, test.overrides)
+			if !diags.HasErrors() {
+				t.Fatal("apply succeeded; want an invalid override error")
+			}
+			if got := diags.Err().Error(); !strings.Contains(got, test.want) {
+				t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1092 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/checks"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+func TestContext2Apply_providerCallCounts(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+resource "test_object" "b" {
+  test_string = "bar"
+}
+`,
+	})
+
+	addrA := mustResourceInstanceAddr("test_object.a")
+	addrB := mustResourceInstanceAddr("test_object.b")
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			addrB,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"bar"}`),
+			},
+			providerAddr,
+			addrs.NoKey,
+		)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	// test_object.a is created, and so requires both a final plan and an
+	// apply call. test_object.b has no changes, so the apply walk makes no
+	// provider calls on its behalf at all.
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		CaptureProviderCalls:         true,
+		ProviderCallWarningThreshold: 1,
+	})
+	assertNoErrors(t, diags)
+
+	calls := ctx.LastApplyProviderCalls()
+	if got, want := calls.Get(addrA), (ProviderCallCounts{Plan: 1, Apply: 1}); got != want {
+		t.Errorf("wrong call counts for %s\ngot:  %#v\nwant: %#v", addrA, got, want)
+	}
+	if calls.Has(addrB) {
+		t.Errorf("unexpected call counts for %s: %#v", addrB, calls.Get(addrB))
+	}
+
+	if len(diags) != 1 || diags[0].Severity() != tfdiags.Warning {
+		t.Fatalf("expected exactly one warning, got: %s", diags.ErrWithWarnings())
+	}
+	if got, want := diags[0].Description().Summary, "Excessive provider calls during apply"; got != want {
+		t.Errorf("wrong warning summary\ngot:  %s\nwant: %s", got, want)
+	}
+	if got := diags[0].Description().Detail; !strings.Contains(got, addrA.String()) {
+		t.Errorf("warning does not mention %s: %s", addrA, got)
+	}
+
+	// Without a threshold there is no warning, but the counts are still
+	// available.
+	plan, diags = ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		CaptureProviderCalls: true,
+	})
+	assertNoDiagnostics(t, diags)
+	if got := ctx.LastApplyProviderCalls().Len(); got != 1 {
+		t.Errorf("wrong number of resource instances with provider calls: %d", got)
+	}
+
+	// Without either option, OpenTofu doesn't count the calls at all.
+	plan, diags = ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoDiagnostics(t, diags)
+	if got := ctx.LastApplyProviderCalls().Len(); got != 0 {
+		t.Errorf("provider calls counted without CaptureProviderCalls: %d", got)
+	}
+}
+
+func TestContext2Apply_returnPriorState(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+resource "test_object" "b" {
+  test_string = "bar"
+}
+`,
+	})
+	addrA := mustResourceInstanceAddr("test_object.a")
+	addrB := mustResourceInstanceAddr("test_object.b")
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			addrB,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"bar"}`),
+			},
+			mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			addrs.NoKey,
+		)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		ReturnPriorState: true,
+	})
+	assertNoErrors(t, diags)
+
+	priorState := ctx.LastApplyPriorState()
+	if priorState == nil {
+		t.Fatal("no prior state returned")
+	}
+	if !priorState.Equal(plan.PriorState) {
+		t.Errorf("returned prior state does not match the plan's prior state\ngot:\n%s\nwant:\n%s", priorState, plan.PriorState)
+	}
+	if got := priorState.ResourceInstance(addrA); got != nil {
+		t.Errorf("returned prior state includes %s, which was only created during the apply", addrA)
+	}
+	if got := newState.ResourceInstance(addrA); got == nil {
+		t.Errorf("%s was not created", addrA)
+	}
+
+	// A later apply that doesn't ask for the prior state must not return
+	// the snapshot from the previous run.
+	plan, diags = ctx.Plan(context.Background(), m, newState, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+	if got := ctx.LastApplyPriorState(); got != nil {
+		t.Errorf("prior state retained from previous apply:\n%s", got)
+	}
+}
+
+func TestContext2Apply_changeCounts(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "fails"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+
+resource "test_object" "c" {
+  test_string = "ok"
+}
+`,
+	})
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			mustResourceInstanceAddr("test_object.d"),
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"orphan"}`),
+			},
+			mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			addrs.NoKey,
+		)
+	})
+
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		if !req.PlannedState.IsNull() && req.PlannedState.GetAttr("test_string").RawEquals(cty.StringVal("fails")) {
+			resp.NewState = req.PriorState
+			resp.Diagnostics = resp.Diagnostics.Append(errors.New("create failed"))
+			return resp
+		}
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		WarnIncompleteApply: true,
+	})
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want create error")
+	}
+
+	// test_object.c is created and test_object.d is destroyed, while
+	// test_object.b is never reached because test_object.a failed.
+	got := ctx.LastApplyChangeCounts()
+	want := ApplyChangeCounts{
+		Planned: 4,
+		Applied: 2,
+		Failed:  1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong change counts\n%s", diff)
+	}
+	if got, want := got.NotReached(), 1; got != want {
+		t.Errorf("wrong number of changes not reached %d; want %d", got, want)
+	}
+
+	var gotWarning string
+	for _, diag := range diags {
+		if desc := diag.Description(); diag.Severity() == tfdiags.Warning && desc.Summary == "Apply incomplete" {
+			gotWarning = desc.Detail
+		}
+	}
+	if want := "OpenTofu applied 2 of the 4 planned changes. 1 failed and 1 were not reached."; !strings.Contains(gotWarning, want) {
+		t.Errorf("wrong summary warning\ngot:  %s\nwant: %s", gotWarning, want)
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+		plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			WarnIncompleteApply: true,
+		})
+		assertNoDiagnostics(t, diags)
+
+		want := ApplyChangeCounts{
+			Planned: 4,
+			Applied: 4,
+		}
+		if diff := cmp.Diff(want, ctx.LastApplyChangeCounts()); diff != "" {
+			t.Errorf("wrong change counts\n%s", diff)
+		}
+	})
+}
+
+// applyStatusRecorder is a Hook that records the context's apply status
+// each time the state is updated, which is after the progress of each
+// operation has been recorded.
+type applyStatusRecorder struct {
+	NilHook
+
+	ctx *Context
+
+	mu   sync.Mutex
+	last *ApplyStatusSnapshot
+}
+
+func (h *applyStatusRecorder) PostStateUpdate(new *states.State) (HookAction, error) {
+	status := h.ctx.ApplyStatus()
+	h.mu.Lock()
+	h.last = status
+	h.mu.Unlock()
+	return HookActionContinue, nil
+}
+
+func TestContext2Apply_progressDeposedDestroy(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+`,
+	})
+	addr := mustResourceInstanceAddr("test_object.a")
+	priorState := states.BuildState(func(s *states.SyncState) {
+		provider := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+		s.SetResourceInstanceCurrent(addr, &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"a"}`),
+		}, provider, addrs.NoKey)
+		s.SetResourceInstanceDeposed(addr, states.DeposedKey("deposed"), &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"old"}`),
+		}, provider, addrs.NoKey)
+	})
+
+	p := simpleMockProvider()
+	statusHook := &applyStatusRecorder{}
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{statusHook},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+	statusHook.ctx = ctx
+
+	plan, diags := ctx.Plan(context.Background(), m, priorState, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	var planned []string
+	for _, rc := range plan.Changes.Resources {
+		if rc.Action != plans.NoOp {
+			planned = append(planned, fmt.Sprintf("%s %s %s", rc.Addr, rc.DeposedKey, rc.Action))
+		}
+	}
+	if diff := cmp.Diff([]string{"test_object.a deposed Delete"}, planned); diff != "" {
+		t.Fatalf("wrong planned changes\n%s", diff)
+	}
+
+	state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		RecordFailures: true,
+	})
+	assertNoErrors(t, diags)
+	if is := state.ResourceInstance(addr); is == nil || len(is.Deposed) != 0 {
+		t.Fatalf("deposed object was not destroyed: %#v", is)
+	}
+
+	if got, want := ctx.LastApplyChangeCounts(), (ApplyChangeCounts{Planned: 1, Applied: 1}); got != want {
+		t.Errorf("wrong change counts %#v; want %#v", got, want)
+	}
+	if got := ctx.LastApplyFailures(); got != nil {
+		t.Errorf("unexpected failures: %#v", got)
+	}
+	statusHook.mu.Lock()
+	defer statusHook.mu.Unlock()
+	if statusHook.last == nil {
+		t.Fatal("no status recorded during the apply")
+	}
+	if got := statusHook.last.Running; len(got) != 0 {
+		t.Errorf("instances still running after destroying the deposed object: %v", got)
+	}
+	if got, want := statusHook.last.Completed, 1; got != want {
+		t.Errorf("wrong completed count %d; want %d", got, want)
+	}
+}
+
+func TestContext2Apply_captureSchemas(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+`,
+	})
+	providerAddr := addrs.NewDefaultProvider("test")
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			providerAddr: testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		CaptureSchemas: true,
+	})
+	assertNoErrors(t, diags)
+
+	captured := ctx.LastApplyProviderSchemas()
+	schema, ok := captured[providerAddr]
+	if !ok {
+		t.Fatalf("no schema captured for %s", providerAddr)
+	}
+	if len(schema.ResourceTypes) == 0 {
+		t.Fatalf("captured schema for %s has no resource types", providerAddr)
+	}
+	if _, ok := schema.ResourceTypes["test_object"]; !ok {
+		t.Errorf("captured schema for %s does not include test_object", providerAddr)
+	}
+
+	// The captured schemas can seed the schema cache for a later operation
+	// that doesn't have the provider available at all.
+	providers.SchemaCache.Set(providerAddr, schema)
+	defer providers.SchemaCache.Remove(providerAddr)
+	offline := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			providerAddr: func() (providers.Interface, error) {
+				return nil, errors.New("provider is not installed")
+			},
+		},
+	})
+	schemas, diags := offline.Schemas(m, states.NewState())
+	assertNoErrors(t, diags)
+	if got, _ := schemas.ResourceTypeConfig(providerAddr, addrs.ManagedResourceMode, "test_object"); got == nil {
+		t.Error("seeded schema cache does not provide the test_object schema")
+	}
+
+	// A later apply that doesn't ask for the schemas must not return the
+	// schemas from the previous run.
+	plan, diags = ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+	if got := ctx.LastApplyProviderSchemas(); got != nil {
+		t.Errorf("schemas retained from an earlier apply: %#v", got)
+	}
+}
+
+func TestContext2Apply_lastApplyGraphSize(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a.test_string
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	if nodes, edges := ctx.LastApplyGraphSize(); nodes != 0 || edges != 0 {
+		t.Fatalf("non-zero graph size %d, %d before any apply", nodes, edges)
+	}
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	// We build the same graph that Apply will build, before the apply walk
+	// modifies the plan, so that we know what counts to expect.
+	graph, _, diags := ctx.applyGraph(plan, m, &ApplyOpts{}, true, true, make(ProviderFunctionMapping))
+	assertNoErrors(t, diags)
+	wantNodes, wantEdges := len(graph.Vertices()), len(graph.Edges())
+
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	nodes, edges := ctx.LastApplyGraphSize()
+	if nodes != wantNodes || edges != wantEdges {
+		t.Errorf("wrong graph size %d nodes, %d edges; want %d nodes, %d edges", nodes, edges, wantNodes, wantEdges)
+	}
+	// The graph must include at least the two resource instances and the
+	// provider, with edges connecting them.
+	if nodes < 3 || edges < 2 {
+		t.Errorf("implausibly small graph: %d nodes, %d edges", nodes, edges)
+	}
+}
+
+func TestContext2Apply_applyStatus(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "slow" {
+  test_string = "slow"
+}
+
+resource "other_object" "broken" {
+  test_string = "broken"
+}
+`,
+	})
+
+	// MockProvider serializes its calls, so we use a separate provider for
+	// each resource in order that they can be applied concurrently.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := simpleMockProvider()
+	slow.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		close(started)
+		<-release
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	broken := &MockProvider{
+		GetProviderSchemaResponse: &providers.GetProviderSchemaResponse{
+			Provider: providers.Schema{Block: simpleTestSchema()},
+			ResourceTypes: map[string]providers.Schema{
+				"other_object": {Block: simpleTestSchema()},
+			},
+		},
+	}
+	broken.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		resp.Diagnostics = resp.Diagnostics.Append(errors.New("broken"))
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"):  testProviderFuncFixed(slow),
+			addrs.NewDefaultProvider("other"): testProviderFuncFixed(broken),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	if status := ctx.ApplyStatus(); status != nil {
+		t.Fatalf("unexpected status before apply: %#v", status)
+	}
+
+	done := make(chan tfdiags.Diagnostics)
+	go func() {
+		_, diags := ctx.Apply(context.Background(), plan, m)
+		done <- diags
+	}()
+
+	// While the slow resource is blocked we wait for the broken one to
+	// finish, and then check that the status reports both.
+	<-started
+	var status *ApplyStatusSnapshot
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status = ctx.ApplyStatus()
+		if status != nil && status.Completed == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("timed out waiting for status; last status %#v", status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if got, want := status.Total, 2; got != want {
+		t.Errorf("wrong total %d; want %d", got, want)
+	}
+	if got, want := len(status.Running), 1; got != want {
+		t.Errorf("wrong number of running instances %d; want %d", got, want)
+	} else if got, want := status.Running[0].String(), "test_object.slow"; got != want {
+		t.Errorf("wrong running instance %s; want %s", got, want)
+	}
+	if got, want := len(status.Errors), 1; got != want {
+		t.Errorf("wrong number of errors %d; want %d", got, want)
+	} else if got, want := status.Errors[0].Error(), "broken"; !strings.Contains(got, want) {
+		t.Errorf("wrong error %q; want message containing %q", got, want)
+	}
+
+	diags = <-done
+	if !diags.HasErrors() {
+		t.Fatal("apply succeeded; want error from the broken resource")
+	}
+	if status := ctx.ApplyStatus(); status != nil {
+		t.Errorf("unexpected status after apply: %#v", status)
+	}
+}
+
+func TestContext2Apply_lastApplyCheckDiff(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+check "flips" {
+  data "test_object" "d" {
+  }
+
+  assert {
+    condition     = data.test_object.d.test_string == "ok"
+    error_message = "Not ok."
+  }
+}
+
+resource "test_object" "a" {
+  test_string = "steady"
+}
+
+check "steady" {
+  assert {
+    condition     = test_object.a.test_string == "steady"
+    error_message = "Not steady."
+  }
+}
+`,
+	})
+
+	var applying atomic.Bool
+	p := simpleMockProvider()
+	p.ReadDataSourceFn = func(req providers.ReadDataSourceRequest) (resp providers.ReadDataSourceResponse) {
+		// The check passes during planning, but the remote object has
+		// changed by the time its data source is read again during apply.
+		value := "ok"
+		if applying.Load() {
+			value = "changed"
+		}
+		resp.State = cty.ObjectVal(map[string]cty.Value{
+			"test_string": cty.StringVal(value),
+			"test_number": cty.NullVal(cty.Number),
+			"test_bool":   cty.NullVal(cty.Bool),
+			"test_list":   cty.NullVal(cty.List(cty.String)),
+			"test_map":    cty.NullVal(cty.Map(cty.String)),
+		})
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	if got := ctx.LastApplyCheckDiff(); got != nil {
+		t.Errorf("unexpected check diff before apply: %#v", got)
+	}
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	applying.Store(true)
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{CaptureCheckDiff: true})
+	assertNoErrors(t, diags)
+
+	got := ctx.LastApplyCheckDiff()
+	want := []CheckStatusChange{
+		{
+			Addr:    addrs.Check{Name: "flips"}.Absolute(addrs.RootModuleInstance),
+			Planned: checks.StatusPass,
+			Applied: checks.StatusFail,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong check diff\n%s", diff)
+	}
+}
+
+func TestContext2Apply_progressSnapshot(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b"
+  depends_on  = [test_object.a]
+}
+
+resource "test_object" "c" {
+  test_string = "c"
+  depends_on  = [test_object.b]
+}
+`,
+	})
+
+	var ctx *Context
+	var mu sync.Mutex
+	during := make(map[string]*ApplyProgressSnapshot)
+	p := simpleMockProvider()
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		name := req.Config.GetAttr("test_string").AsString()
+		mu.Lock()
+		during[name] = ctx.ApplyProgressSnapshot("tenant-a")
+		mu.Unlock()
+		if name == "b" {
+			resp.Diagnostics = resp.Diagnostics.Append(errors.New("b failed"))
+			return resp
+		}
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	ctx = testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	if got := ctx.ApplyProgressSnapshot("tenant-a"); got != nil {
+		t.Fatalf("unexpected snapshot before the apply started: %#v", got)
+	}
+
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		RunID: "tenant-a",
+	})
+	if !diags.HasErrors() {
+		t.Fatal("expected the apply to fail")
+	}
+
+	a := mustResourceInstanceAddr("test_object.a")
+	b := mustResourceInstanceAddr("test_object.b")
+	c := mustResourceInstanceAddr("test_object.c")
+	want := map[string]*ApplyProgressSnapshot{
+		"a": {
+			RunID:     "tenant-a",
+			Remaining: []addrs.AbsResourceInstance{a, b, c},
+		},
+		"b": {
+			RunID:     "tenant-a",
+			Completed: []addrs.AbsResourceInstance{a},
+			Remaining: []addrs.AbsResourceInstance{b, c},
+		},
+	}
+	if diff := cmp.Diff(want, during); diff != "" {
+		t.Errorf("wrong snapshots during the apply\n%s", diff)
+	}
+
+	wantFinal := &ApplyProgressSnapshot{
+		RunID:     "tenant-a",
+		Finished:  true,
+		Completed: []addrs.AbsResourceInstance{a},
+		Errored:   []addrs.AbsResourceInstance{b},
+		Remaining: []addrs.AbsResourceInstance{c},
+	}
+	if diff := cmp.Diff(wantFinal, ctx.ApplyProgressSnapshot("tenant-a")); diff != "" {
+		t.Errorf("wrong snapshot after the apply\n%s", diff)
+	}
+	if got := ctx.ApplyProgressSnapshot("tenant-b"); got != nil {
+		t.Errorf("unexpected snapshot for unknown run: %#v", got)
+	}
+
+	// Only the most recent apply's snapshot is kept.
+	plan, diags = ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, _ = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		RunID: "tenant-b",
+	})
+	if got := ctx.ApplyProgressSnapshot("tenant-a"); got != nil {
+		t.Errorf("unexpected snapshot for earlier run: %#v", got)
+	}
+	if got := ctx.ApplyProgressSnapshot("tenant-b"); got == nil || !got.Finished {
+		t.Errorf("wrong snapshot for latest run: %#v", got)
+	}
+}
+
+func TestContext2Apply_statePartitioner(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "shared" {
+  count       = 2
+  test_string = "shared"
+}
+
+resource "test_object" "default" {
+  test_string = "default"
+}
+
+module "child" {
+  source = "./child"
+}
+
+output "out" {
+  value = test_object.default.test_string
+}
+`,
+		"child/main.tf": `
+resource "test_object" "a" {
+  test_string = "child"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		StatePartitioner: func(addr addrs.AbsResourceInstance) string {
+			switch {
+			case !addr.Module.IsRoot():
+				return "modules"
+			case addr.Resource.Key == addrs.IntKey(1):
+				return "second"
+			case addr.Resource.Resource.Name == "shared":
+				return "shared"
+			}
+			return ""
+		},
+	})
+	assertNoErrors(t, diags)
+
+	// Apply still returns the whole state.
+	if got, want := len(newState.AllResourceInstanceObjectAddrs()), 4; got != want {
+		t.Errorf("wrong number of objects in the new state %d; want %d", got, want)
+	}
+
+	partitions := ctx.LastApplyPartitionedStates()
+	got := make(map[string][]string)
+	for name, s := range partitions {
+		got[name] = []string{}
+		for _, obj := range s.AllResourceInstanceObjectAddrs() {
+			got[name] = append(got[name], obj.Instance.String())
+		}
+		sort.Strings(got[name])
+	}
+	want := map[string][]string{
+		"":        {"test_object.default"},
+		"modules": {"module.child.test_object.a"},
+		"second":  {"test_object.shared[1]"},
+		"shared":  {"test_object.shared[0]"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong partitions\n%s", diff)
+	}
+
+	if out := partitions[""].OutputValue(addrs.RootModuleInstance.OutputValue("out")); out == nil {
+		t.Error("default partition has no output value")
+	}
+	if partitions[""].Module(addrs.RootModuleInstance.Child("child", addrs.NoKey)) != nil {
+		t.Error("default partition still has the emptied child module")
+	}
+	rs := partitions["shared"].Resource(mustResourceInstanceAddr("test_object.shared[0]").ContainingResource())
+	if rs == nil || rs.ProviderConfig.String() != `provider["registry.opentofu.org/hashicorp/test"]` {
+		t.Errorf("wrong resource in the shared partition: %#v", rs)
+	}
+
+	// Changing the returned states doesn't change the recorded partitions.
+	partitions[""].RootModule().RemoveResource(mustResourceInstanceAddr("test_object.default").ContainingResource().Resource)
+	if ctx.LastApplyPartitionedStates()[""].ResourceInstance(mustResourceInstanceAddr("test_object.default")) == nil {
+		t.Error("modifying the returned state modified the recorded partition")
+	}
+}
+
+func TestContext2Apply_readSources(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "cached" {
+  test_string = "new"
+}
+
+resource "test_object" "refreshed" {
+  test_string = "new"
+}
+
+resource "test_object" "created" {
+  test_string = "new"
+}
+
+data "test_object" "deferred" {
+  test_string = "deferred"
+
+  depends_on = [test_object.created]
+}
+
+data "test_object" "planned" {
+  test_string = "planned"
+}
+`,
+	})
+	state := states.BuildState(func(s *states.SyncState) {
+		for _, name := range []string{"cached", "refreshed", "gone"} {
+			s.SetResourceInstanceCurrent(
+				mustResourceInstanceAddr("test_object."+name),
+				&states.ResourceInstanceObjectSrc{
+					Status:    states.ObjectReady,
+					AttrsJSON: []byte(`{"test_string":"old"}`),
+				},
+				mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+				addrs.NoKey,
+			)
+		}
+	})
+
+	p := simpleMockProvider()
+	p.ReadDataSourceFn = func(req providers.ReadDataSourceRequest) (resp providers.ReadDataSourceResponse) {
+		resp.State = req.Config
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		RefreshOnly:        []addrs.AbsResourceInstance{mustResourceInstanceAddr("test_object.refreshed")},
+		CaptureReadSources: true,
+	})
+	assertNoErrors(t, diags)
+
+	got := make(map[string]ReadSource)
+	for _, elem := range ctx.LastApplyReadSources().Elems {
+		got[elem.Key.String()] = elem.Value
+	}
+	// The plan already read data.test_object.planned, and test_object.created
+	// had no object to read.
+	want := map[string]ReadSource{
+		"test_object.cached":        ReadSourceState,
+		"test_object.gone":          ReadSourceState,
+		"test_object.refreshed":     ReadSourceProvider,
+		"data.test_object.deferred": ReadSourceProvider,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong read sources\n%s", diff)
+	}
+}
+
+func TestContext2Apply_referencedVariables(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+variable "used" {
+  type = string
+}
+
+variable "unused" {
+  type = string
+}
+
+resource "test_object" "a" {
+  test_string = var.used
+}
+
+module "child" {
+  source = "./child"
+
+  input = "child"
+}
+`,
+		"child/main.tf": `
+variable "input" {
+  type = string
+}
+
+resource "test_object" "b" {
+  test_string = var.input
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	if got := ctx.LastApplyReferencedVariables(); got != nil {
+		t.Fatalf("unexpected referenced variables before apply: %#v", got)
+	}
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), &PlanOpts{
+		Mode: plans.NormalMode,
+		SetVariables: InputValues{
+			"used": &InputValue{
+				Value:      cty.StringVal("used"),
+				SourceType: ValueFromCLIArg,
+			},
+			"unused": &InputValue{
+				Value:      cty.StringVal("unused"),
+				SourceType: ValueFromCLIArg,
+			},
+		},
+	})
+	assertNoErrors(t, diags)
+
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{CaptureReferencedVariables: true})
+	assertNoErrors(t, diags)
+
+	got := ctx.LastApplyReferencedVariables()
+	want := []string{"module.child.var.input", "var.used"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong referenced variables\n%s", diff)
+	}
+}
+
+func TestContext2Apply_consumedVariables(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+variable "a" {
+  type = string
+}
+
+variable "b" {
+  type = string
+}
+
+variable "child" {
+  type = string
+}
+
+variable "unused" {
+  type = string
+}
+
+resource "test_object" "a" {
+  test_string = var.a
+}
+
+resource "test_object" "b" {
+  test_string = var.b
+}
+
+module "child" {
+  source = "./child"
+
+  input = var.child
+}
+`,
+		"child/main.tf": `
+variable "input" {
+  type = string
+}
+
+resource "test_object" "c" {
+  test_string = var.input
+}
+`,
+	})
+	vars := InputValues{}
+	for _, name := range []string{"a", "b", "child", "unused"} {
+		vars[name] = &InputValue{
+			Value:      cty.StringVal(name),
+			SourceType: ValueFromCLIArg,
+		}
+	}
+
+	tests := map[string]struct {
+		targets []addrs.Targetable
+		want    []string
+	}{
+		"untargeted": {
+			want: []string{"a", "b", "child"},
+		},
+		"targeted": {
+			targets: []addrs.Targetable{mustResourceInstanceAddr("test_object.a")},
+			want:    []string{"a"},
+		},
+		"targeted module": {
+			targets: []addrs.Targetable{addrs.RootModuleInstance.Child("child", addrs.NoKey)},
+			want:    []string{"child"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := simpleMockProvider()
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			if got := ctx.LastApplyConsumedVariables(); got != nil {
+				t.Fatalf("unexpected consumed variables before apply: %#v", got)
+			}
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), &PlanOpts{
+				Mode:         plans.NormalMode,
+				SetVariables: vars,
+				Targets:      test.targets,
+			})
+			assertNoErrors(t, diags)
+
+			_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{CaptureConsumedVariables: true})
+			assertNoErrors(t, diags)
+
+			if diff := cmp.Diff(test.want, ctx.LastApplyConsumedVariables()); diff != "" {
+				t.Errorf("wrong consumed variables\n%s", diff)
+			}
+		})
+	}
+}
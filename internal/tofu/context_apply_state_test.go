@@ -0,0 +1,881 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/states/statefile"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+func TestContext2Apply_forgetArchive(t *testing.T) {
+	deposedKey := states.DeposedKey("deposed")
+	addr := mustResourceInstanceAddr("test_object.a")
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+removed {
+  from = test_object.a
+}
+
+resource "test_object" "b" {
+  test_string = "kept"
+}
+`,
+	})
+
+	providerAddr := mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`)
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			addr,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"current"}`),
+			},
+			providerAddr,
+			addrs.NoKey,
+		)
+		s.SetResourceInstanceDeposed(
+			addr,
+			deposedKey,
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectTainted,
+				AttrsJSON: []byte(`{"test_string":"deposed"}`),
+			},
+			providerAddr,
+			addrs.NoKey,
+		)
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+
+	archive := states.NewState()
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		ForgetArchive: archive,
+	})
+	assertNoErrors(t, diags)
+
+	if got := newState.ResourceInstance(addr); got != nil {
+		t.Fatalf("%s is still in the main state after being forgotten", addr)
+	}
+
+	archived := archive.ResourceInstance(addr)
+	if archived == nil {
+		t.Fatalf("%s was not archived", addr)
+	}
+	if archived.Current == nil {
+		t.Fatalf("current object for %s was not archived", addr)
+	}
+	if got, want := string(archived.Current.AttrsJSON), `"test_string":"current"`; !strings.Contains(got, want) {
+		t.Errorf("wrong archived current object\ngot:  %s\nwant attribute: %s", got, want)
+	}
+	deposed := archived.Deposed[deposedKey]
+	if deposed == nil {
+		t.Fatalf("deposed object %s for %s was not archived", deposedKey, addr)
+	}
+	if got, want := string(deposed.AttrsJSON), `"test_string":"deposed"`; !strings.Contains(got, want) {
+		t.Errorf("wrong archived deposed object\ngot:  %s\nwant attribute: %s", got, want)
+	}
+	if got, want := archive.Resource(addr.ContainingResource()).ProviderConfig.String(), providerAddr.String(); got != want {
+		t.Errorf("wrong archived provider config\ngot:  %s\nwant: %s", got, want)
+	}
+
+	// Only forgotten resources belong in the archive.
+	if got := archive.ResourceInstance(mustResourceInstanceAddr("test_object.b")); got != nil {
+		t.Errorf("test_object.b was archived even though it wasn't forgotten")
+	}
+}
+
+func TestContext2Apply_maxStateBytes(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+`,
+	})
+	addr := mustResourceInstanceAddr("test_object.a")
+
+	tests := map[string]struct {
+		maxBytes  int64
+		wantError bool
+	}{
+		"not tripped": {
+			maxBytes:  1 << 20,
+			wantError: false,
+		},
+		"tripped": {
+			maxBytes:  10,
+			wantError: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := simpleMockProvider()
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+			assertNoErrors(t, diags)
+
+			state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				MaxStateBytes: test.maxBytes,
+			})
+			if test.wantError {
+				if !diags.HasErrors() {
+					t.Fatal("apply succeeded; want state size error")
+				}
+				if got, want := diags.Err().Error(), "State size limit exceeded"; !strings.Contains(got, want) {
+					t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+				}
+			} else {
+				assertNoDiagnostics(t, diags)
+			}
+
+			// The new state is returned either way, so that the caller can
+			// decide whether to persist it.
+			if state == nil {
+				t.Fatal("no state returned")
+			}
+			if got := state.ResourceInstance(addr); got == nil {
+				t.Errorf("%s is missing from the returned state", addr)
+			}
+		})
+	}
+}
+
+func TestContext2Apply_suppressAttributes(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "new"
+  test_number = 2
+  test_bool   = true
+}
+`,
+	})
+	addr := mustResourceInstanceAddr("test_object.a")
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(addr, &states.ResourceInstanceObjectSrc{
+			Status:    states.ObjectReady,
+			AttrsJSON: []byte(`{"test_string":"old","test_number":1,"test_bool":false}`),
+		}, mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`), addrs.NoKey)
+	})
+
+	// We make test_bool required so that we can check that it can't be
+	// suppressed.
+	schema := simpleTestSchema()
+	schema.Attributes["test_bool"].Optional = false
+	schema.Attributes["test_bool"].Required = true
+	p := simpleMockProvider()
+	p.GetProviderSchemaResponse.ResourceTypes["test_object"] = providers.Schema{Block: schema}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		SuppressAttributes: map[addrs.Resource][]cty.Path{
+			addr.Resource.Resource: {
+				cty.GetAttrPath("test_number"),
+				cty.GetAttrPath("test_bool"),
+				cty.GetAttrPath("nonexistent"),
+			},
+		},
+	})
+	assertNoErrors(t, diags)
+
+	var warnings []string
+	for _, diag := range diags {
+		warnings = append(warnings, diag.Description().Summary)
+	}
+	sort.Strings(warnings)
+	wantWarnings := []string{"Cannot suppress required attribute", "Invalid suppressed attribute"}
+	if diff := cmp.Diff(wantWarnings, warnings); diff != "" {
+		t.Errorf("wrong warnings\n%s", diff)
+	}
+
+	if !p.ApplyResourceChangeCalled {
+		t.Fatal("provider was not asked to apply the update")
+	}
+	got := p.ApplyResourceChangeRequest.PlannedState.GetAttr("test_number")
+	if want := cty.NumberIntVal(2); !got.RawEquals(want) {
+		t.Errorf("provider was asked to apply test_number %#v; want %#v", got, want)
+	}
+
+	obj, err := newState.ResourceInstance(addr).Current.Decode(schema.ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cty.ObjectVal(map[string]cty.Value{
+		"test_string": cty.StringVal("new"),
+		"test_number": cty.NumberIntVal(1), // suppressed, so retains prior value
+		"test_bool":   cty.True,            // required, so can't be suppressed
+		"test_list":   cty.NullVal(cty.List(cty.String)),
+		"test_map":    cty.NullVal(cty.Map(cty.String)),
+	})
+	if !obj.Value.RawEquals(want) {
+		t.Errorf("wrong new state\ngot:  %#v\nwant: %#v", obj.Value, want)
+	}
+}
+
+func TestContext2Apply_requireNonNullOutputs(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+variable "name" {
+  type    = string
+  default = null
+}
+
+output "name" {
+  value = var.name
+}
+
+output "ok" {
+  value = "ok"
+}
+`,
+	})
+
+	// The output previously had a value, which the apply replaces with
+	// null.
+	state := states.BuildState(func(s *states.SyncState) {
+		s.SetOutputValue(addrs.OutputValue{Name: "name"}.Absolute(addrs.RootModuleInstance), cty.StringVal("old"), false)
+	})
+
+	ctx := testContext2(t, &ContextOpts{})
+	plan, diags := ctx.Plan(context.Background(), m, state, &PlanOpts{
+		Mode: plans.NormalMode,
+		SetVariables: InputValues{
+			"name": &InputValue{Value: cty.NilVal, SourceType: ValueFromCaller},
+		},
+	})
+	assertNoErrors(t, diags)
+
+	t.Run("null", func(t *testing.T) {
+		newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			RequireNonNullOutputs: []string{"name", "ok"},
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error")
+		}
+		if got, want := len(diags), 1; got != want {
+			t.Fatalf("got %d diagnostics; want %d\n%s", got, want, diags.ErrWithWarnings())
+		}
+		if got, want := diags[0].Description().Detail, `"name" is null`; !strings.Contains(got, want) {
+			t.Errorf("wrong error detail\ngot:  %s\nwant: message containing %s", got, want)
+		}
+		if newState == nil {
+			t.Fatal("no new state returned")
+		}
+		if _, ok := newState.RootModule().OutputValues["ok"]; !ok {
+			t.Error("new state is missing output ok")
+		}
+	})
+
+	t.Run("undeclared", func(t *testing.T) {
+		_, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			RequireNonNullOutputs: []string{"missing"},
+		})
+		if !diags.HasErrors() {
+			t.Fatal("apply succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Undeclared output value"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant: message containing %q", got, want)
+		}
+	})
+}
+
+func TestContext2Apply_stateTransform(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "foo"
+}
+
+output "out" {
+  value = test_object.a.test_string
+}
+`,
+	})
+	addr := mustResourceInstanceAddr("test_object.a")
+
+	tests := map[string]struct {
+		transform func(*states.State) (*states.State, error)
+		wantError string
+		wantOut   bool
+	}{
+		"transformed": {
+			transform: func(s *states.State) (*states.State, error) {
+				s.RootModule().RemoveOutputValue("out")
+				return s, nil
+			},
+			wantOut: false,
+		},
+		"failed": {
+			transform: func(s *states.State) (*states.State, error) {
+				s.RootModule().RemoveOutputValue("out")
+				return nil, errors.New("cannot annotate")
+			},
+			wantError: "cannot annotate",
+			wantOut:   true,
+		},
+		"no state": {
+			transform: func(s *states.State) (*states.State, error) {
+				return nil, nil
+			},
+			wantError: "the transform returned no state",
+			wantOut:   true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := simpleMockProvider()
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+			assertNoErrors(t, diags)
+
+			calls := 0
+			state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				StateTransform: func(s *states.State) (*states.State, error) {
+					calls++
+					if s.ResourceInstance(addr) == nil {
+						t.Errorf("%s is missing from the state given to the transform", addr)
+					}
+					return test.transform(s)
+				},
+			})
+			if calls != 1 {
+				t.Errorf("transform called %d times; want 1", calls)
+			}
+			if test.wantError != "" {
+				if !diags.HasErrors() {
+					t.Fatal("apply succeeded; want transform error")
+				}
+				if got := diags.Err().Error(); !strings.Contains(got, "Failed to transform state") || !strings.Contains(got, test.wantError) {
+					t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, test.wantError)
+				}
+			} else {
+				assertNoDiagnostics(t, diags)
+			}
+
+			if state.ResourceInstance(addr) == nil {
+				t.Errorf("%s is missing from the returned state", addr)
+			}
+			_, gotOut := state.RootModule().OutputValues["out"]
+			if gotOut != test.wantOut {
+				t.Errorf("output in returned state is %t; want %t", gotOut, test.wantOut)
+			}
+		})
+	}
+}
+
+func TestContext2Apply_runMetadata(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	priorState := states.NewState()
+	priorState.RunMetadata = map[string]string{"run_id": "run-1", "stale": "yes"}
+
+	apply := func(t *testing.T, metadata map[string]string) *states.State {
+		t.Helper()
+		plan, diags := ctx.Plan(context.Background(), m, priorState, DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		state, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			RunMetadata: metadata,
+		})
+		assertNoErrors(t, diags)
+		return state
+	}
+
+	t.Run("recorded", func(t *testing.T) {
+		metadata := map[string]string{
+			"run_id":  "run-2",
+			"actor":   "ci-bot",
+			"job_url": "https://ci.example.com/jobs/2",
+		}
+		state := apply(t, metadata)
+		if diff := cmp.Diff(metadata, state.RunMetadata); diff != "" {
+			t.Fatalf("wrong run metadata\n%s", diff)
+		}
+		if _, ok := state.RunMetadata["stale"]; ok {
+			t.Errorf("state keeps run metadata from an earlier apply: %#v", state.RunMetadata)
+		}
+
+		var buf bytes.Buffer
+		err := statefile.Write(statefile.New(state, "lineage", 1), &buf, encryption.StateEncryptionDisabled())
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := statefile.Read(&buf, encryption.StateEncryptionDisabled())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(metadata, f.State.RunMetadata); diff != "" {
+			t.Errorf("wrong run metadata after round-trip\n%s", diff)
+		}
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		state := apply(t, nil)
+		if diff := cmp.Diff(priorState.RunMetadata, state.RunMetadata); diff != "" {
+			t.Errorf("apply without run metadata changed the earlier metadata\n%s", diff)
+		}
+	})
+}
+
+// forgetOrderHook records the order in which resource instances are
+// changed and forgotten during an apply.
+type forgetOrderHook struct {
+	NilHook
+
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *forgetOrderHook) PostApply(addr addrs.AbsResourceInstance, gen states.Generation, newState cty.Value, err error) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, "apply "+addr.String())
+	return HookActionContinue, nil
+}
+
+func (h *forgetOrderHook) StateMutation(addr addrs.AbsResourceInstance, gen states.Generation, old, new *states.ResourceInstanceObject) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if new == nil && old != nil && old.Value.GetAttr("test_string").AsString() == "forgotten" {
+		h.events = append(h.events, "forget "+addr.String())
+	}
+	return HookActionContinue, nil
+}
+
+func TestContext2Apply_forgetLast(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+removed {
+  from = test_object.forgotten
+}
+
+resource "test_object" "created" {
+  count       = 3
+  test_string = "created"
+}
+`,
+	})
+	state := states.BuildState(func(s *states.SyncState) {
+		for name, value := range map[string]string{"forgotten": "forgotten", "deleted": "deleted"} {
+			s.SetResourceInstanceCurrent(
+				mustResourceInstanceAddr("test_object."+name),
+				&states.ResourceInstanceObjectSrc{
+					Status:    states.ObjectReady,
+					AttrsJSON: []byte(fmt.Sprintf(`{"test_string":%q}`, value)),
+				},
+				mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+				addrs.NoKey,
+			)
+		}
+	})
+
+	apply := func(t *testing.T, fail bool) (*states.State, []string, tfdiags.Diagnostics) {
+		t.Helper()
+
+		hook := &forgetOrderHook{}
+		p := simpleMockProvider()
+		p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+			if fail && req.PlannedState.IsNull() {
+				resp.Diagnostics = resp.Diagnostics.Append(errors.New("cannot delete"))
+				return resp
+			}
+			resp.NewState = req.PlannedState
+			return resp
+		}
+		ctx := testContext2(t, &ContextOpts{
+			Hooks: []Hook{hook},
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+
+		plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		// Planning also removes the forgotten object from its working state,
+		// so only the apply's events count.
+		hook.events = nil
+		newState, diags := ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			ForgetLast: true,
+		})
+		return newState, hook.events, diags
+	}
+
+	forgotten := mustResourceInstanceAddr("test_object.forgotten")
+
+	t.Run("success", func(t *testing.T) {
+		newState, events, diags := apply(t, false)
+		assertNoErrors(t, diags)
+		// The three creates and the delete all come before the forget.
+		if len(events) != 5 || events[4] != "forget test_object.forgotten" {
+			t.Errorf("forget did not happen after the other changes: %v", events)
+		}
+		if is := newState.ResourceInstance(forgotten); is != nil {
+			t.Errorf("%s was not forgotten", forgotten)
+		}
+	})
+	t.Run("failure", func(t *testing.T) {
+		newState, events, diags := apply(t, true)
+		if !diags.HasErrors() {
+			t.Fatal("expected the delete to fail")
+		}
+		for _, event := range events {
+			if strings.HasPrefix(event, "forget ") {
+				t.Errorf("forget happened despite the failed delete: %v", events)
+			}
+		}
+		if is := newState.ResourceInstance(forgotten); is == nil || is.Current == nil {
+			t.Errorf("%s was forgotten despite the failed delete", forgotten)
+		}
+	})
+}
+
+func TestContext2Apply_outputTypes(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "not a number"
+}
+
+output "string" {
+  value = test_object.a.test_string
+}
+
+output "number" {
+  value = 5
+}
+`,
+	})
+
+	apply := func(t *testing.T, types map[string]cty.Type) (*states.State, tfdiags.Diagnostics) {
+		t.Helper()
+		p := simpleMockProvider()
+		ctx := testContext2(t, &ContextOpts{
+			Providers: map[addrs.Provider]providers.Factory{
+				addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+			},
+		})
+		plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+		assertNoErrors(t, diags)
+		return ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+			OutputTypes: types,
+		})
+	}
+
+	t.Run("conforming", func(t *testing.T) {
+		state, diags := apply(t, map[string]cty.Type{
+			"string": cty.String,
+			"number": cty.String,
+		})
+		assertNoErrors(t, diags)
+
+		// The number converts to the declared type.
+		got := state.RootModule().OutputValues["number"]
+		if got == nil || !got.Value.RawEquals(cty.StringVal("5")) {
+			t.Errorf("wrong value for output \"number\": %#v", got)
+		}
+	})
+
+	t.Run("mismatched", func(t *testing.T) {
+		state, diags := apply(t, map[string]cty.Type{
+			"string": cty.Number,
+		})
+		if !diags.HasErrors() {
+			t.Fatal("succeeded; want error")
+		}
+		want := `Invalid output value type: The value of output "string" must be number: a number is required.`
+		if got := diags.Err().Error(); got != want {
+			t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+		}
+		if got := state.RootModule().OutputValues["string"]; got != nil {
+			t.Errorf("mismatched value was saved: %#v", got.Value)
+		}
+	})
+
+	t.Run("undeclared", func(t *testing.T) {
+		_, diags := apply(t, map[string]cty.Type{
+			"missing": cty.String,
+		})
+		if !diags.HasErrors() {
+			t.Fatal("succeeded; want error")
+		}
+		if got, want := diags.Err().Error(), "Undeclared output value"; !strings.Contains(got, want) {
+			t.Errorf("wrong error\ngot:  %s\nwant: message containing %q", got, want)
+		}
+	})
+}
+
+func TestContext2Apply_journal(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+resource "test_object" "b" {
+  test_string = "b-${test_object.a.test_string}"
+}
+
+resource "test_object" "c" {
+  test_string = "c-${test_object.b.test_string}"
+}
+`,
+	})
+	priorState := states.BuildState(func(s *states.SyncState) {
+		s.SetResourceInstanceCurrent(
+			mustResourceInstanceAddr("test_object.old"),
+			&states.ResourceInstanceObjectSrc{
+				Status:    states.ObjectReady,
+				AttrsJSON: []byte(`{"test_string":"old"}`),
+			},
+			mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+			addrs.NoKey,
+		)
+	})
+
+	for name, partial := range map[string]bool{"clean failure": false, "partial object": true} {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			applied := make(map[string]int)
+			failB := true
+			p := simpleMockProvider()
+			p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+				mu.Lock()
+				defer mu.Unlock()
+				resp.NewState = req.PlannedState
+				if req.PlannedState.IsNull() {
+					applied["destroy "+req.PriorState.GetAttr("test_string").AsString()]++
+					return resp
+				}
+				v := req.PlannedState.GetAttr("test_string").AsString()
+				applied[v]++
+				if v == "b-a" && failB {
+					resp.Diagnostics = resp.Diagnostics.Append(errors.New("temporary failure"))
+					if !partial {
+						resp.NewState = cty.NullVal(req.PlannedState.Type())
+					}
+				}
+				return resp
+			}
+			ctx := testContext2(t, &ContextOpts{
+				Providers: map[addrs.Provider]providers.Factory{
+					addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+				},
+			})
+
+			plan, diags := ctx.Plan(context.Background(), m, priorState, DefaultPlanOpts)
+			assertNoErrors(t, diags)
+			// Applying a plan consumes its changes, so we keep our own copy
+			// to resume from, as a caller would by reading the plan file
+			// again.
+			original := copyPlanForRetry(plan, plan.PriorState.DeepCopy())
+
+			journal := filepath.Join(t.TempDir(), "apply.journal")
+			_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+				JournalPath: journal,
+			})
+			if !diags.HasErrors() {
+				t.Fatal("first apply succeeded; want failure")
+			}
+
+			// An entry cut short by OpenTofu being terminated mid-write is
+			// ignored when resuming, and discarded by the resumed apply.
+			f, err := os.OpenFile(journal, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteString(`{"address":"test_obj`); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			resumed, diags := ctx.ResumeFromJournal(original, journal)
+			if partial {
+				if got, want := diags.Err().Error(), "The planned change for test_object.b cannot be resumed"; !strings.Contains(got, want) {
+					t.Errorf("wrong error\ngot:  %s\nwant message containing: %s", got, want)
+				}
+				return
+			}
+			assertNoErrors(t, diags)
+
+			var remaining []string
+			for _, rc := range resumed.Changes.Resources {
+				remaining = append(remaining, rc.Addr.String())
+			}
+			sort.Strings(remaining)
+			if diff := cmp.Diff([]string{"test_object.b", "test_object.c"}, remaining); diff != "" {
+				t.Errorf("wrong remaining changes\n%s", diff)
+			}
+			if resumed.PriorState.ResourceInstance(mustResourceInstanceAddr("test_object.a")) == nil {
+				t.Error("test_object.a is missing from the resumed prior state")
+			}
+			if resumed.PriorState.ResourceInstance(mustResourceInstanceAddr("test_object.old")) != nil {
+				t.Error("test_object.old is still in the resumed prior state")
+			}
+
+			failB = false
+			state, diags := ctx.ApplyWithOpts(context.Background(), resumed, m, &ApplyOpts{
+				JournalPath: journal,
+			})
+			assertNoErrors(t, diags)
+
+			wantApplied := map[string]int{
+				"a":           1, // not repeated, because it succeeded the first time
+				"destroy old": 1,
+				"b-a":         2,
+				"c-b-a":       1,
+			}
+			if diff := cmp.Diff(wantApplied, applied); diff != "" {
+				t.Errorf("wrong apply calls\n%s", diff)
+			}
+			for _, addr := range []string{"test_object.a", "test_object.b", "test_object.c"} {
+				if state.ResourceInstance(mustResourceInstanceAddr(addr)) == nil {
+					t.Errorf("%s is missing from the state after resuming", addr)
+				}
+			}
+
+			// The journal now covers both applies, so resuming the original
+			// plan again leaves nothing to do.
+			resumed, diags = ctx.ResumeFromJournal(original, journal)
+			assertNoErrors(t, diags)
+			if got := len(resumed.Changes.Resources); got != 0 {
+				t.Errorf("%d changes remain after the journal completed them all", got)
+			}
+		})
+	}
+}
+
+func TestContext2Apply_journalDataSource(t *testing.T) {
+	// The data source depends on a managed resource and so is read during
+	// the apply, which must not leave an entry that prevents resuming.
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "a" {
+  test_string = "a"
+}
+
+data "test_object" "d" {
+  test_string = "d-${test_object.a.test_string}"
+}
+
+resource "test_object" "b" {
+  test_string = "b-${data.test_object.d.test_string}"
+}
+`,
+	})
+
+	failB := true
+	p := simpleMockProvider()
+	p.ReadDataSourceFn = func(req providers.ReadDataSourceRequest) (resp providers.ReadDataSourceResponse) {
+		resp.State = req.Config
+		return resp
+	}
+	p.ApplyResourceChangeFn = func(req providers.ApplyResourceChangeRequest) (resp providers.ApplyResourceChangeResponse) {
+		if req.PlannedState.GetAttr("test_string").AsString() == "b-d-a" && failB {
+			resp.NewState = cty.NullVal(req.PlannedState.Type())
+			resp.Diagnostics = resp.Diagnostics.Append(errors.New("temporary failure"))
+			return resp
+		}
+		resp.NewState = req.PlannedState
+		return resp
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	original := copyPlanForRetry(plan, plan.PriorState.DeepCopy())
+
+	journal := filepath.Join(t.TempDir(), "apply.journal")
+	_, diags = ctx.ApplyWithOpts(context.Background(), plan, m, &ApplyOpts{
+		JournalPath: journal,
+	})
+	if !diags.HasErrors() {
+		t.Fatal("first apply succeeded; want failure")
+	}
+	if !p.ReadDataSourceCalled {
+		t.Fatal("data source was not read during the apply")
+	}
+
+	resumed, diags := ctx.ResumeFromJournal(original, journal)
+	assertNoErrors(t, diags)
+	var remaining []string
+	for _, rc := range resumed.Changes.Resources {
+		remaining = append(remaining, rc.Addr.String())
+	}
+	sort.Strings(remaining)
+	if diff := cmp.Diff([]string{"data.test_object.d", "test_object.b"}, remaining); diff != "" {
+		t.Errorf("wrong remaining changes\n%s", diff)
+	}
+
+	failB = false
+	state, diags := ctx.ApplyWithOpts(context.Background(), resumed, m, &ApplyOpts{
+		JournalPath: journal,
+	})
+	assertNoErrors(t, diags)
+	if state.ResourceInstance(mustResourceInstanceAddr("test_object.b")) == nil {
+		t.Error("test_object.b is missing from the state after resuming")
+	}
+}
@@ -195,9 +195,13 @@ func TestContext2Apply_stop(t *testing.T) {
 	// resource. We don't visit indefinite.bar at all.
 	gotEvents := hook.Calls
 	wantEvents := []*testHookCall{
+		{"OnGraphBuilt", ""},
 		{"PreDiff", "indefinite.foo"},
 		{"PostDiff", "indefinite.foo"},
+		{"PreApplyValidate", "indefinite.foo"},
 		{"PreApply", "indefinite.foo"},
+		{"StateMutation", "indefinite.foo"}, // The apply result is recorded as soon as it is available...
+		{"StateMutation", "indefinite.foo"}, // ...and again after provisioning.
 		{"PostApply", "indefinite.foo"},
 		{"PostStateUpdate", ""}, // State gets updated one more time to include the apply result.
 	}
@@ -1721,6 +1725,8 @@ func TestContext2Apply_destroyData(t *testing.T) {
 	wantHookCalls := []*testHookCall{
 		{"PreApply", "data.null_data_source.testing"},
 		{"PostApply", "data.null_data_source.testing"},
+		{"StateMutation", "data.null_data_source.testing"},
+		{"OnGraphBuilt", ""},
 		{"PostStateUpdate", ""},
 	}
 	if !reflect.DeepEqual(hook.Calls, wantHookCalls) {
@@ -107,6 +107,19 @@ type PlanOpts struct {
 	//
 	// If empty, then no config will be generated.
 	GenerateConfigPath string
+
+	// MoveResolver, if set, is called for each module instance, resource or
+	// resource instance in the previous run state that a moved block, or an
+	// implied move, is about to move, and can return a different address
+	// to move it to together with true, or false to keep the move as
+	// written. This allows the caller to resolve a move that would otherwise
+	// be ambiguous, such as one whose destination already has an object.
+	//
+	// The returned address must be the same kind of address as the given
+	// one, or it is ignored. Returning the given address leaves the object
+	// where it is. Moves happen only while planning, and so the plan records
+	// the resolved moves for the apply.
+	MoveResolver func(from addrs.AbsMoveable) (addrs.AbsMoveable, bool)
 }
 
 // Plan generates an execution plan by comparing the given configuration
@@ -291,7 +304,7 @@ func (c *Context) checkApplyGraph(plan *plans.Plan, config *configs.Config) tfdi
 		return nil
 	}
 	log.Println("[DEBUG] building apply graph to check for errors")
-	_, _, diags := c.applyGraph(plan, config, true, make(ProviderFunctionMapping))
+	_, _, diags := c.applyGraph(plan, config, nil, true, false, make(ProviderFunctionMapping))
 	return diags
 }
 
@@ -318,6 +331,41 @@ func SimplePlanOpts(mode plans.Mode, setVariables InputValues) *PlanOpts {
 	}
 }
 
+// DeriveDestroyPlan returns a plan that would destroy all of the managed
+// resource instances in the given state, such as the state returned from an
+// apply, so that tooling can keep a ready-to-use plan for cleaning up
+// everything it just created.
+//
+// This is the same as calling Plan in destroy mode, except that it skips
+// refreshing the state, because the state is assumed to be current. The
+// given variable values are used as for Plan, except that any variables
+// that aren't set take their default values from the configuration.
+func (c *Context) DeriveDestroyPlan(ctx context.Context, state *states.State, config *configs.Config, setVariables InputValues) (*plans.Plan, tfdiags.Diagnostics) {
+	if config == nil {
+		config = configs.NewEmptyConfig()
+	}
+
+	variables := make(InputValues, len(config.Module.Variables))
+	for name, iv := range setVariables {
+		variables[name] = iv
+	}
+	for name := range config.Module.Variables {
+		if _, ok := variables[name]; ok {
+			continue
+		}
+		variables[name] = &InputValue{
+			Value:      cty.NilVal,
+			SourceType: ValueFromCaller,
+		}
+	}
+
+	return c.Plan(ctx, config, state, &PlanOpts{
+		Mode:         plans.DestroyMode,
+		SkipRefresh:  true,
+		SetVariables: variables,
+	})
+}
+
 func (c *Context) plan(ctx context.Context, config *configs.Config, prevRunState *states.State, opts *PlanOpts) (*plans.Plan, tfdiags.Diagnostics) {
 	var diags tfdiags.Diagnostics
 
@@ -474,7 +522,7 @@ func (c *Context) destroyPlan(ctx context.Context, config *configs.Config, prevR
 	return destroyPlan, diags
 }
 
-func (c *Context) prePlanFindAndApplyMoves(config *configs.Config, prevRunState *states.State) ([]refactoring.MoveStatement, refactoring.MoveResults) {
+func (c *Context) prePlanFindAndApplyMoves(config *configs.Config, prevRunState *states.State, resolver refactoring.MoveResolver) ([]refactoring.MoveStatement, refactoring.MoveResults) {
 	explicitMoveStmts := refactoring.FindMoveStatements(config)
 	implicitMoveStmts := refactoring.ImpliedMoveStatements(config, prevRunState, explicitMoveStmts)
 	var moveStmts []refactoring.MoveStatement
@@ -483,7 +531,7 @@ func (c *Context) prePlanFindAndApplyMoves(config *configs.Config, prevRunState
 		moveStmts = append(moveStmts, explicitMoveStmts...)
 		moveStmts = append(moveStmts, implicitMoveStmts...)
 	}
-	moveResults := refactoring.ApplyMoves(moveStmts, prevRunState)
+	moveResults := refactoring.ApplyMovesWithResolver(moveStmts, prevRunState, resolver)
 	return moveStmts, moveResults
 }
 
@@ -746,7 +794,7 @@ func (c *Context) planWalk(ctx context.Context, config *configs.Config, prevRunS
 	log.Printf("[DEBUG] Building and walking plan graph for %s", opts.Mode)
 
 	prevRunState = prevRunState.DeepCopy() // don't modify the caller's object when we process the moves
-	moveStmts, moveResults := c.prePlanFindAndApplyMoves(config, prevRunState)
+	moveStmts, moveResults := c.prePlanFindAndApplyMoves(config, prevRunState, opts.MoveResolver)
 
 	// If resource targeting is in effect then it might conflict with the
 	// move result.
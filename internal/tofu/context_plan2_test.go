@@ -10,6 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -1487,6 +1488,92 @@ OpenTofu has planned to destroy these objects. If OpenTofu's proposed changes ar
 	})
 }
 
+func TestContext2Plan_movedResourceResolver(t *testing.T) {
+	addrOld := mustResourceInstanceAddr("test_object.old")
+	addrNew := mustResourceInstanceAddr("test_object.new")
+	addrOther := mustResourceInstanceAddr("test_object.other")
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+			resource "test_object" "new" {
+			}
+
+			resource "test_object" "other" {
+			}
+
+			moved {
+				# Blocked by the existing object at test_object.new unless
+				# the resolver chooses somewhere else.
+				from = test_object.old
+				to   = test_object.new
+			}
+		`,
+	})
+
+	state := states.BuildState(func(s *states.SyncState) {
+		for _, addr := range []addrs.AbsResourceInstance{addrOld, addrNew} {
+			s.SetResourceInstanceCurrent(addr, &states.ResourceInstanceObjectSrc{
+				AttrsJSON: []byte(`{}`),
+				Status:    states.ObjectReady,
+			}, mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`), addrs.NoKey)
+		}
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	var resolved []string
+	plan, diags := ctx.Plan(context.Background(), m, state, &PlanOpts{
+		Mode: plans.NormalMode,
+		MoveResolver: func(from addrs.AbsMoveable) (addrs.AbsMoveable, bool) {
+			resolved = append(resolved, from.String())
+			// A moved block between whole resources moves whole resources.
+			if addr, ok := from.(addrs.AbsResource); ok && addr.Equal(addrOld.ContainingResource()) {
+				return addrOther.ContainingResource(), true
+			}
+			return nil, false
+		},
+	})
+	// The move isn't blocked, so there's no warning about it either.
+	assertNoDiagnostics(t, diags)
+
+	if diff := cmp.Diff([]string{"test_object.old"}, resolved); diff != "" {
+		t.Errorf("wrong resolver calls\n%s", diff)
+	}
+	for _, test := range []struct {
+		addr, prevRunAddr addrs.AbsResourceInstance
+	}{
+		{addrNew, addrNew},
+		{addrOther, addrOld},
+	} {
+		instPlan := plan.Changes.ResourceInstance(test.addr)
+		if instPlan == nil {
+			t.Fatalf("no plan for %s at all", test.addr)
+		}
+		if got, want := instPlan.PrevRunAddr, test.prevRunAddr; !got.Equal(want) {
+			t.Errorf("wrong previous run address for %s\ngot:  %s\nwant: %s", test.addr, got, want)
+		}
+		if got, want := instPlan.Action, plans.NoOp; got != want {
+			t.Errorf("wrong planned action for %s\ngot:  %s\nwant: %s", test.addr, got, want)
+		}
+	}
+	if instPlan := plan.Changes.ResourceInstance(addrOld); instPlan != nil {
+		t.Errorf("unexpected plan for %s: %s", addrOld, instPlan.Action)
+	}
+
+	newState, diags := ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+	if newState.ResourceInstance(addrOther) == nil {
+		t.Errorf("no object for %s after apply", addrOther)
+	}
+	if newState.ResourceInstance(addrOld) != nil {
+		t.Errorf("object still at %s after apply", addrOld)
+	}
+}
+
 func TestContext2Plan_movedResourceCollisionDestroy(t *testing.T) {
 	// This is like TestContext2Plan_movedResourceCollision but intended to
 	// ensure we still produce the expected warning (and produce it only once)
@@ -8046,3 +8133,82 @@ func featuresBlockTestSchema() *configschema.Block {
 		},
 	}
 }
+
+func TestContext2Plan_deriveDestroyPlan(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+variable "name" {
+  type    = string
+  default = "a"
+}
+
+variable "instances" {
+  type = number
+}
+
+resource "test_object" "a" {
+  count = var.instances
+
+  test_string = var.name
+}
+
+resource "test_object" "b" {
+  test_string = test_object.a[0].test_string
+}
+`,
+	})
+
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	variables := InputValues{
+		"instances": &InputValue{
+			Value:      cty.NumberIntVal(2),
+			SourceType: ValueFromCaller,
+		},
+	}
+	plan, diags := ctx.Plan(context.Background(), m, states.NewState(), &PlanOpts{
+		Mode: plans.NormalMode,
+		SetVariables: InputValues{
+			"name":      &InputValue{Value: cty.NilVal, SourceType: ValueFromCaller},
+			"instances": variables["instances"],
+		},
+	})
+	assertNoErrors(t, diags)
+	state, diags := ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	p.ReadResourceCalled = false
+	destroyPlan, diags := ctx.DeriveDestroyPlan(context.Background(), state, m, variables)
+	assertNoErrors(t, diags)
+
+	if got, want := destroyPlan.UIMode, plans.DestroyMode; got != want {
+		t.Errorf("wrong plan mode %s; want %s", got, want)
+	}
+	if p.ReadResourceCalled {
+		t.Error("ReadResource was called; want no refresh")
+	}
+
+	var got []string
+	for _, rc := range destroyPlan.Changes.Resources {
+		if rc.Action != plans.Delete {
+			t.Errorf("wrong action %s for %s; want %s", rc.Action, rc.Addr, plans.Delete)
+		}
+		got = append(got, rc.Addr.String())
+	}
+	sort.Strings(got)
+	want := []string{"test_object.a[0]", "test_object.a[1]", "test_object.b"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong resource instances in destroy plan\n%s", diff)
+	}
+
+	state, diags = ctx.Apply(context.Background(), destroyPlan, m)
+	assertNoErrors(t, diags)
+	if !state.Empty() {
+		t.Errorf("state is not empty after applying destroy plan:\n%s", state)
+	}
+}
@@ -10,6 +10,10 @@ import (
 	"log"
 	"time"
 
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/opentofu/opentofu/internal/addrs"
 	"github.com/opentofu/opentofu/internal/checks"
 	"github.com/opentofu/opentofu/internal/configs"
 	"github.com/opentofu/opentofu/internal/instances"
@@ -31,6 +35,11 @@ type graphWalkOpts struct {
 	Changes    *plans.Changes
 	Config     *configs.Config
 
+	// InputStateOwned, if set, means that the caller has given up ownership
+	// of InputState, and so a non-plan walk can modify it directly instead
+	// of making its own deep copy first.
+	InputStateOwned bool
+
 	// PlanTimeCheckResults should be populated during the apply phase with
 	// the snapshot of check results that was generated during the plan step.
 	//
@@ -47,6 +56,102 @@ type graphWalkOpts struct {
 	MoveResults refactoring.MoveResults
 
 	ProviderFunctionTracker ProviderFunctionMapping
+
+	// ForgetArchive, if set, is a state that will receive the last-known
+	// objects for any resource instances that are forgotten during an
+	// apply walk. Unlike InputState, this object is modified in-place.
+	ForgetArchive *states.State
+
+	// ProviderCallCounter, if set, records the number of provider calls
+	// made on behalf of each resource instance during the walk.
+	ProviderCallCounter *providerCallCounter
+
+	// ReadSourceRecorder, if set, records where the current object of each
+	// resource instance came from during the walk.
+	ReadSourceRecorder *readSourceRecorder
+
+	// ProviderCallMiddleware, if set, wraps each call to a provider's
+	// ApplyResourceChange operation during the walk.
+	ProviderCallMiddleware ProviderCallMiddleware
+
+	// LazyProviders, if set, defers configuring each provider instance until
+	// it is first needed by a resource operation during the walk.
+	LazyProviders bool
+
+	// CategorizeProviderDiags, if set, classifies all of the diagnostics
+	// returned by providers during the walk as tfdiags.CategoryProvider.
+	CategorizeProviderDiags bool
+
+	// ApplyTracer, if set, produces a tracing span for each resource
+	// operation during the walk.
+	ApplyTracer *applyTracer
+
+	// AdditionalHooks, if set, are notified of events during the walk in
+	// addition to the context's own hooks.
+	AdditionalHooks []Hook
+
+	// Breakpoints, if set, are callbacks to call with a snapshot of the
+	// working state just before each operation on the corresponding
+	// resource instances during the walk.
+	Breakpoints addrs.Map[addrs.AbsResourceInstance, func(*states.State)]
+
+	// Journal, if set, receives an entry for each operation completed
+	// during the walk. See ApplyOpts.JournalPath.
+	Journal *applyJournal
+
+	// SuppressAttributes, if set, are attribute paths whose new values are
+	// not written to the state after each update of a matching resource.
+	SuppressAttributes map[addrs.Resource][]cty.Path
+
+	// DataSourceResults, if set, are used as the results of reading the
+	// corresponding data resource instances instead of their providers.
+	DataSourceResults addrs.Map[addrs.AbsResourceInstance, cty.Value]
+
+	// TagResourceDiagnostics, if set, causes each diagnostic returned by a
+	// resource instance node during the walk to record which resource
+	// instance it belongs to, for groupResourceDiagnostics.
+	TagResourceDiagnostics bool
+
+	// DiagnosticStream, if set, receives the diagnostics returned by each
+	// node as soon as it completes.
+	DiagnosticStream *diagnosticStream
+
+	// Scheduler, if set, chooses the order in which nodes that are ready
+	// to execute take the available parallel execution slots.
+	Scheduler Scheduler
+
+	// ModuleParallelism, if set, limits how many resource instance nodes
+	// in each module may execute at once.
+	ModuleParallelism map[string]int
+
+	// ExplainSkippedChanges, if set, makes the walk return a warning for
+	// each resource instance node that is skipped because one of its
+	// dependencies failed.
+	ExplainSkippedChanges bool
+
+	// VariableReads, if set, records each input variable that is read
+	// while evaluating expressions during the walk.
+	VariableReads *variableReads
+
+	// RandomSeed, if set, is passed to the providers that support it in
+	// their provider meta. See ApplyOpts.RandomSeed.
+	RandomSeed *int64
+
+	// PolicyEvaluator, if set, evaluates each change to a managed resource
+	// instance before it is applied. See ApplyOpts.PolicyEvaluator.
+	PolicyEvaluator PolicyEvaluator
+
+	// ResourceTimeouts, if set, override the timeouts from the configuration
+	// for the given resource instances. See ApplyOpts.ResourceTimeouts.
+	ResourceTimeouts addrs.Map[addrs.AbsResourceInstance, time.Duration]
+
+	// OutputTypes, if set, are the type constraints for the root module
+	// output values of the same names. See ApplyOpts.OutputTypes.
+	OutputTypes map[string]cty.Type
+
+	// FunctionOverrides, if set, replace the built-in functions of the same
+	// names when evaluating expressions. See ApplyOpts.FunctionOverrides.
+	FunctionOverrides map[string]function.Function
 }
 
 func (c *Context) walk(ctx context.Context, graph *Graph, operation walkOperation, opts *graphWalkOpts) (*ContextGraphWalker, tfdiags.Diagnostics) {
@@ -74,7 +179,8 @@ func (c *Context) graphWalker(operation walkOperation, opts *graphWalkOpts) *Con
 
 	// NOTE: None of the SyncState objects must directly wrap opts.InputState,
 	// because we use those to mutate the state object and opts.InputState
-	// belongs to our caller and thus we must treat it as immutable.
+	// belongs to our caller and thus we must treat it as immutable, unless
+	// the caller has set opts.InputStateOwned.
 	//
 	// To account for that, most of our SyncState values created below end up
 	// wrapping a _deep copy_ of opts.InputState instead.
@@ -107,7 +213,11 @@ func (c *Context) graphWalker(operation walkOperation, opts *graphWalkOpts) *Con
 		refreshState.DiscardCheckResults()
 
 	default:
-		state = inputState.DeepCopy().SyncWrapper()
+		if opts.InputStateOwned {
+			state = inputState.SyncWrapper()
+		} else {
+			state = inputState.DeepCopy().SyncWrapper()
+		}
 		// Only plan-like walks use refreshState and prevRunState
 
 		// Discard the input state's check results, because we should create
@@ -142,12 +252,40 @@ func (c *Context) graphWalker(operation walkOperation, opts *graphWalkOpts) *Con
 		}
 	}
 
+	var forgetArchive *states.SyncState
+	if opts.ForgetArchive != nil {
+		forgetArchive = opts.ForgetArchive.SyncWrapper()
+	}
+
 	return &ContextGraphWalker{
 		Context:                 c,
 		State:                   state,
 		Config:                  opts.Config,
 		RefreshState:            refreshState,
 		PrevRunState:            prevRunState,
+		ForgetArchive:           forgetArchive,
+		ProviderCallCounter:     opts.ProviderCallCounter,
+		ReadSourceRecorder:      opts.ReadSourceRecorder,
+		ProviderCallMiddleware:  opts.ProviderCallMiddleware,
+		LazyProviders:           opts.LazyProviders,
+		CategorizeProviderDiags: opts.CategorizeProviderDiags,
+		ApplyTracer:             opts.ApplyTracer,
+		AdditionalHooks:         opts.AdditionalHooks,
+		Breakpoints:             opts.Breakpoints,
+		Journal:                 opts.Journal,
+		SuppressAttributes:      opts.SuppressAttributes,
+		DataSourceResults:       opts.DataSourceResults,
+		TagResourceDiagnostics:  opts.TagResourceDiagnostics,
+		DiagnosticStream:        opts.DiagnosticStream,
+		Scheduler:               opts.Scheduler,
+		ModuleParallelism:       opts.ModuleParallelism,
+		ExplainSkippedChanges:   opts.ExplainSkippedChanges,
+		VariableReads:           opts.VariableReads,
+		RandomSeed:              opts.RandomSeed,
+		PolicyEvaluator:         opts.PolicyEvaluator,
+		ResourceTimeouts:        opts.ResourceTimeouts,
+		OutputTypes:             opts.OutputTypes,
+		FunctionOverrides:       opts.FunctionOverrides,
 		Changes:                 changes.SyncWrapper(),
 		Checks:                  checkState,
 		InstanceExpander:        instances.NewExpander(),
@@ -0,0 +1,104 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"sync"
+
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// diagnosticStream passes diagnostics to a callback as soon as they are
+// produced during a graph walk, for ApplyOpts.OnDiagnostic.
+//
+// A nil *diagnosticStream is valid and silently discards all diagnostics,
+// so that callers don't need to check whether the current walk has a
+// callback.
+type diagnosticStream struct {
+	fn func(tfdiags.Diagnostic)
+
+	mu sync.Mutex
+}
+
+func newDiagnosticStream(fn func(tfdiags.Diagnostic)) *diagnosticStream {
+	return &diagnosticStream{
+		fn: fn,
+	}
+}
+
+// Send passes each of the given diagnostics to the callback, and returns a
+// copy of them that records that they were sent, so that Flush won't send
+// them again.
+//
+// The callback is never called concurrently, so Send blocks while a
+// different goroutine is sending.
+func (s *diagnosticStream) Send(diags tfdiags.Diagnostics) tfdiags.Diagnostics {
+	if s == nil || len(diags) == 0 {
+		return diags
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret := make(tfdiags.Diagnostics, len(diags))
+	for i, diag := range diags {
+		s.fn(diag)
+		ret[i] = tfdiags.Override(diag, diag.Severity(), func() tfdiags.DiagnosticExtraWrapper {
+			return &streamedDiagExtra{stream: s}
+		})
+	}
+	return ret
+}
+
+// Flush passes to the callback each of the given diagnostics, which are
+// typically all of those returned from an operation, that Send did not
+// already send.
+func (s *diagnosticStream) Flush(all tfdiags.Diagnostics) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, diag := range all {
+		if s.sent(diag) {
+			continue
+		}
+		s.fn(diag)
+	}
+}
+
+// sent returns true if the given diagnostic is one that this stream's Send
+// returned.
+func (s *diagnosticStream) sent(diag tfdiags.Diagnostic) bool {
+	extra := tfdiags.ExtraInfo[*streamedDiagExtra](diag)
+	for extra != nil {
+		if extra.stream == s {
+			return true
+		}
+		extra = tfdiags.ExtraInfoNext[*streamedDiagExtra](extra)
+	}
+	return false
+}
+
+// streamedDiagExtra is the extra info used by diagnosticStream.Send, which
+// wraps any extra info the original diagnostic already had.
+type streamedDiagExtra struct {
+	stream  *diagnosticStream
+	wrapped interface{}
+}
+
+var _ tfdiags.DiagnosticExtraWrapper = (*streamedDiagExtra)(nil)
+var _ tfdiags.DiagnosticExtraUnwrapper = (*streamedDiagExtra)(nil)
+
+func (e *streamedDiagExtra) WrapDiagnosticExtra(inner interface{}) {
+	e.wrapped = inner
+}
+
+func (e *streamedDiagExtra) UnwrapDiagnosticExtra() interface{} {
+	return e.wrapped
+}
@@ -0,0 +1,115 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// errorRateBreaker is a Hook used internally during the apply walk to
+// implement ApplyOpts.ErrorRateThreshold, by interrupting the context's run
+// as soon as the proportion of the planned changes that have failed exceeds
+// the threshold.
+//
+// A nil *errorRateBreaker is valid and its Close method does nothing, so
+// that callers don't need to check whether the current walk has a
+// threshold.
+type errorRateBreaker struct {
+	NilHook
+
+	ctx       *Context
+	threshold float64
+	progress  *applyProgressHook
+
+	mu      sync.Mutex
+	tripped bool
+	counts  ApplyChangeCounts
+}
+
+var _ Hook = (*errorRateBreaker)(nil)
+
+// newErrorRateBreaker returns a breaker that uses the counts recorded by the
+// given progress hook, which must be called before the breaker for each
+// event, or nil if threshold is not positive.
+func newErrorRateBreaker(ctx *Context, threshold float64, progress *applyProgressHook) *errorRateBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &errorRateBreaker{
+		ctx:       ctx,
+		threshold: threshold,
+		progress:  progress,
+	}
+}
+
+// checkErrorRateThreshold returns an error if the given threshold is not a
+// valid value for ApplyOpts.ErrorRateThreshold.
+func checkErrorRateThreshold(threshold float64) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if threshold < 0 || threshold >= 1 {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Invalid error rate threshold",
+			fmt.Sprintf("The error rate threshold must be at least 0 and less than 1, not %g.", threshold),
+		))
+	}
+	return diags
+}
+
+func (b *errorRateBreaker) PostApply(addr addrs.AbsResourceInstance, gen states.Generation, newState cty.Value, err error) (HookAction, error) {
+	if err == nil {
+		return HookActionContinue, nil
+	}
+
+	counts := b.progress.Counts()
+	if counts.Planned == 0 || float64(counts.Failed)/float64(counts.Planned) <= b.threshold {
+		return HookActionContinue, nil
+	}
+
+	b.mu.Lock()
+	first := !b.tripped
+	b.tripped = true
+	b.counts = counts
+	b.mu.Unlock()
+	if first {
+		log.Printf("[ERROR] tofu: %d of %d planned changes failed, exceeding the error rate threshold, stopping", counts.Failed, counts.Planned)
+		b.ctx.l.Lock()
+		b.ctx.interruptRun()
+		b.ctx.l.Unlock()
+	}
+	return HookActionContinue, nil
+}
+
+// Close returns an error if the breaker stopped the walk.
+func (b *errorRateBreaker) Close() tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if b == nil {
+		return diags
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.tripped {
+		return diags
+	}
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Error,
+		"Error rate threshold exceeded",
+		fmt.Sprintf(
+			"OpenTofu stopped applying changes because %d of the %d planned changes failed, which is more than %g%% of them.\n\nThe returned state includes only the changes that completed before the apply was stopped.",
+			b.counts.Failed, b.counts.Planned, b.threshold*100,
+		),
+	))
+	return diags
+}
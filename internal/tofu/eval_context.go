@@ -6,6 +6,8 @@
 package tofu
 
 import (
+	"time"
+
 	"github.com/hashicorp/hcl/v2"
 	"github.com/opentofu/opentofu/internal/addrs"
 	"github.com/opentofu/opentofu/internal/checks"
@@ -186,6 +188,65 @@ type EvalContext interface {
 	// meaningful comparison with RefreshState.
 	PrevRunState() *states.SyncState
 
+	// ForgetArchive returns a wrapper object that provides safe concurrent
+	// access to the state that should receive the last-known objects of any
+	// resource instances that are forgotten during the apply walk, or nil if
+	// the caller did not request such an archive.
+	ForgetArchive() *states.SyncState
+
+	// ProviderCallCounter returns the object that records how many provider
+	// calls are made on behalf of each resource instance, or nil if the
+	// current operation isn't tracking provider calls. Recording into a nil
+	// counter is a no-op, so callers need not check.
+	ProviderCallCounter() *providerCallCounter
+
+	// ReadSourceRecorder returns the object that records where the current
+	// object of each resource instance came from, or nil if the current
+	// operation isn't tracking that. Recording into a nil recorder is a
+	// no-op, so callers need not check.
+	ReadSourceRecorder() *readSourceRecorder
+
+	// ProviderCallMiddleware returns the middleware that must wrap each
+	// call to a provider's ApplyResourceChange operation, or nil if calls
+	// should be made directly.
+	ProviderCallMiddleware() ProviderCallMiddleware
+
+	// SuppressAttributes returns the attribute paths, by resource address,
+	// whose new values must not be written to the state after an update,
+	// or nil if there are none. See ApplyOpts.SuppressAttributes.
+	SuppressAttributes() map[addrs.Resource][]cty.Path
+
+	// DataSourceResults returns the results to use for data resource
+	// instances instead of reading them from their providers. See
+	// ApplyOpts.DataSourceResults.
+	DataSourceResults() addrs.Map[addrs.AbsResourceInstance, cty.Value]
+
+	// ApplyTracer returns the object that produces tracing spans for each
+	// resource operation, or nil if the current operation isn't being
+	// traced. Starting spans with a nil tracer is a no-op, so callers need
+	// not check.
+	ApplyTracer() *applyTracer
+
+	// RandomSeed returns the seed to offer to providers that support one
+	// in their provider meta, or nil if providers should behave as normal.
+	// See ApplyOpts.RandomSeed.
+	RandomSeed() *int64
+
+	// PolicyEvaluator returns the evaluator that must approve each change
+	// to a managed resource instance before it is applied, or nil if
+	// changes need no approval. See ApplyOpts.PolicyEvaluator.
+	PolicyEvaluator() PolicyEvaluator
+
+	// ResourceTimeouts returns the timeouts to use for the given resource
+	// instances instead of those from their configuration. See
+	// ApplyOpts.ResourceTimeouts.
+	ResourceTimeouts() addrs.Map[addrs.AbsResourceInstance, time.Duration]
+
+	// OutputTypes returns the type constraints that the values of the root
+	// module output values of the same names must conform to. See
+	// ApplyOpts.OutputTypes.
+	OutputTypes() map[string]cty.Type
+
 	// InstanceExpander returns a helper object for tracking the expansion of
 	// graph nodes during the plan phase in response to "count" and "for_each"
 	// arguments.
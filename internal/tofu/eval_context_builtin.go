@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
@@ -73,11 +74,32 @@ type BuiltinEvalContext struct {
 	ProvisionerLock  *sync.Mutex
 	ProvisionerCache map[string]provisioners.Interface
 
-	ChangesValue            *plans.ChangesSync
-	StateValue              *states.SyncState
-	ChecksValue             *checks.State
-	RefreshStateValue       *states.SyncState
-	PrevRunStateValue       *states.SyncState
+	ChangesValue                *plans.ChangesSync
+	StateValue                  *states.SyncState
+	ChecksValue                 *checks.State
+	RefreshStateValue           *states.SyncState
+	PrevRunStateValue           *states.SyncState
+	ForgetArchiveValue          *states.SyncState
+	ProviderCallCounterValue    *providerCallCounter
+	ReadSourceRecorderValue     *readSourceRecorder
+	ProviderCallMiddlewareValue ProviderCallMiddleware
+	SuppressAttributesValue     map[addrs.Resource][]cty.Path
+	DataSourceResultsValue      addrs.Map[addrs.AbsResourceInstance, cty.Value]
+	ApplyTracerValue            *applyTracer
+	RandomSeedValue             *int64
+	PolicyEvaluatorValue        PolicyEvaluator
+	ResourceTimeoutsValue       addrs.Map[addrs.AbsResourceInstance, time.Duration]
+	OutputTypesValue            map[string]cty.Type
+
+	// LazyProviders, if set, causes providers initialized by InitProvider to
+	// defer their configuration until first used by a resource operation.
+	LazyProviders bool
+
+	// CategorizeProviderDiags, if set, causes providers initialized by
+	// InitProvider to classify all of their diagnostics as
+	// tfdiags.CategoryProvider.
+	CategorizeProviderDiags bool
+
 	InstanceExpanderValue   *instances.Expander
 	MoveResultsValue        refactoring.MoveResults
 	ImportResolverValue     *ImportResolver
@@ -108,7 +130,11 @@ func (ctx *BuiltinEvalContext) Hook(fn func(Hook) (HookAction, error)) error {
 	for _, h := range ctx.Hooks {
 		action, err := fn(h)
 		if err != nil {
-			return err
+			// We classify hook errors so that the UI can distinguish them
+			// from problems with the configuration or the providers.
+			var diags tfdiags.Diagnostics
+			diags = diags.Append(err)
+			return tfdiags.Categorize(diags, tfdiags.CategoryHook).Err()
 		}
 
 		switch action {
@@ -164,6 +190,18 @@ func (ctx *BuiltinEvalContext) InitProvider(addr addrs.AbsProviderConfig, provid
 		}
 	}
 
+	// Providers mocked for the testing framework never need to be
+	// configured, and other code relies on being able to recognize them,
+	// so we only wrap real providers.
+	if _, isTestProvider := p.(providerForTest); !isTestProvider {
+		if ctx.CategorizeProviderDiags {
+			p = newCategorizingProvider(p)
+		}
+		if ctx.LazyProviders {
+			p = newLazyConfiguredProvider(p, addr, providerKey)
+		}
+	}
+
 	log.Printf("[TRACE] BuiltinEvalContext: Initialized %q%s provider for %s", addr.String(), providerKey, addr)
 	ctx.ProviderCache[key][providerKey] = p
 
@@ -573,6 +611,50 @@ func (ctx *BuiltinEvalContext) PrevRunState() *states.SyncState {
 	return ctx.PrevRunStateValue
 }
 
+func (ctx *BuiltinEvalContext) ForgetArchive() *states.SyncState {
+	return ctx.ForgetArchiveValue
+}
+
+func (ctx *BuiltinEvalContext) ProviderCallCounter() *providerCallCounter {
+	return ctx.ProviderCallCounterValue
+}
+
+func (ctx *BuiltinEvalContext) ReadSourceRecorder() *readSourceRecorder {
+	return ctx.ReadSourceRecorderValue
+}
+
+func (ctx *BuiltinEvalContext) ProviderCallMiddleware() ProviderCallMiddleware {
+	return ctx.ProviderCallMiddlewareValue
+}
+
+func (ctx *BuiltinEvalContext) SuppressAttributes() map[addrs.Resource][]cty.Path {
+	return ctx.SuppressAttributesValue
+}
+
+func (ctx *BuiltinEvalContext) DataSourceResults() addrs.Map[addrs.AbsResourceInstance, cty.Value] {
+	return ctx.DataSourceResultsValue
+}
+
+func (ctx *BuiltinEvalContext) ApplyTracer() *applyTracer {
+	return ctx.ApplyTracerValue
+}
+
+func (ctx *BuiltinEvalContext) RandomSeed() *int64 {
+	return ctx.RandomSeedValue
+}
+
+func (ctx *BuiltinEvalContext) PolicyEvaluator() PolicyEvaluator {
+	return ctx.PolicyEvaluatorValue
+}
+
+func (ctx *BuiltinEvalContext) ResourceTimeouts() addrs.Map[addrs.AbsResourceInstance, time.Duration] {
+	return ctx.ResourceTimeoutsValue
+}
+
+func (ctx *BuiltinEvalContext) OutputTypes() map[string]cty.Type {
+	return ctx.OutputTypesValue
+}
+
 func (ctx *BuiltinEvalContext) InstanceExpander() *instances.Expander {
 	return ctx.InstanceExpanderValue
 }
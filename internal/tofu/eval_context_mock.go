@@ -6,6 +6,8 @@
 package tofu
 
 import (
+	"time"
+
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/opentofu/opentofu/internal/addrs"
@@ -149,6 +151,39 @@ type MockEvalContext struct {
 	PrevRunStateCalled bool
 	PrevRunStateState  *states.SyncState
 
+	ForgetArchiveCalled bool
+	ForgetArchiveState  *states.SyncState
+
+	ProviderCallCounterCalled  bool
+	ProviderCallCounterCounter *providerCallCounter
+
+	ReadSourceRecorderCalled   bool
+	ReadSourceRecorderRecorder *readSourceRecorder
+
+	ProviderCallMiddlewareCalled     bool
+	ProviderCallMiddlewareMiddleware ProviderCallMiddleware
+
+	SuppressAttributesCalled bool
+	SuppressAttributesPaths  map[addrs.Resource][]cty.Path
+
+	DataSourceResultsCalled  bool
+	DataSourceResultsResults addrs.Map[addrs.AbsResourceInstance, cty.Value]
+
+	ApplyTracerCalled bool
+	ApplyTracerTracer *applyTracer
+
+	RandomSeedCalled bool
+	RandomSeedSeed   *int64
+
+	PolicyEvaluatorCalled    bool
+	PolicyEvaluatorEvaluator PolicyEvaluator
+
+	ResourceTimeoutsCalled   bool
+	ResourceTimeoutsTimeouts addrs.Map[addrs.AbsResourceInstance, time.Duration]
+
+	OutputTypesCalled bool
+	OutputTypesTypes  map[string]cty.Type
+
 	MoveResultsCalled  bool
 	MoveResultsResults refactoring.MoveResults
 
@@ -398,6 +433,61 @@ func (c *MockEvalContext) PrevRunState() *states.SyncState {
 	return c.PrevRunStateState
 }
 
+func (c *MockEvalContext) ForgetArchive() *states.SyncState {
+	c.ForgetArchiveCalled = true
+	return c.ForgetArchiveState
+}
+
+func (c *MockEvalContext) ProviderCallCounter() *providerCallCounter {
+	c.ProviderCallCounterCalled = true
+	return c.ProviderCallCounterCounter
+}
+
+func (c *MockEvalContext) ReadSourceRecorder() *readSourceRecorder {
+	c.ReadSourceRecorderCalled = true
+	return c.ReadSourceRecorderRecorder
+}
+
+func (c *MockEvalContext) ProviderCallMiddleware() ProviderCallMiddleware {
+	c.ProviderCallMiddlewareCalled = true
+	return c.ProviderCallMiddlewareMiddleware
+}
+
+func (c *MockEvalContext) SuppressAttributes() map[addrs.Resource][]cty.Path {
+	c.SuppressAttributesCalled = true
+	return c.SuppressAttributesPaths
+}
+
+func (c *MockEvalContext) DataSourceResults() addrs.Map[addrs.AbsResourceInstance, cty.Value] {
+	c.DataSourceResultsCalled = true
+	return c.DataSourceResultsResults
+}
+
+func (c *MockEvalContext) ApplyTracer() *applyTracer {
+	c.ApplyTracerCalled = true
+	return c.ApplyTracerTracer
+}
+
+func (c *MockEvalContext) RandomSeed() *int64 {
+	c.RandomSeedCalled = true
+	return c.RandomSeedSeed
+}
+
+func (c *MockEvalContext) PolicyEvaluator() PolicyEvaluator {
+	c.PolicyEvaluatorCalled = true
+	return c.PolicyEvaluatorEvaluator
+}
+
+func (c *MockEvalContext) ResourceTimeouts() addrs.Map[addrs.AbsResourceInstance, time.Duration] {
+	c.ResourceTimeoutsCalled = true
+	return c.ResourceTimeoutsTimeouts
+}
+
+func (c *MockEvalContext) OutputTypes() map[string]cty.Type {
+	c.OutputTypesCalled = true
+	return c.OutputTypesTypes
+}
+
 func (c *MockEvalContext) MoveResults() refactoring.MoveResults {
 	c.MoveResultsCalled = true
 	return c.MoveResultsResults
@@ -15,6 +15,7 @@ import (
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
 
 	"github.com/opentofu/opentofu/internal/addrs"
 	"github.com/opentofu/opentofu/internal/configs"
@@ -68,6 +69,14 @@ type Evaluator struct {
 	Changes *plans.ChangesSync
 
 	PlanTimestamp time.Time
+
+	// VariableReads, if set, records each input variable that is read
+	// by an expression evaluated with this evaluator.
+	VariableReads *variableReads
+
+	// FunctionOverrides, if set, replace the built-in functions of the same
+	// names in each scope created by this evaluator.
+	FunctionOverrides map[string]function.Function
 }
 
 // Scope creates an evaluation scope for the given module path and optional
@@ -86,6 +95,7 @@ func (e *Evaluator) Scope(data lang.Data, self addrs.Referenceable, source addrs
 		BaseDir:           ".", // Always current working directory for now.
 		PlanTimestamp:     e.PlanTimestamp,
 		ProviderFunctions: functions,
+		FunctionOverrides: e.FunctionOverrides,
 	}
 }
 
@@ -248,6 +258,10 @@ func (d *evaluationStateData) GetInputVariable(addr addrs.InputVariable, rng tfd
 		})
 		return cty.DynamicVal, diags
 	}
+	if reads := d.Evaluator.VariableReads; reads != nil {
+		reads.Record(addr.Absolute(d.ModulePath))
+	}
+
 	d.Evaluator.VariableValuesLock.Lock()
 	defer d.Evaluator.VariableValuesLock.Unlock()
 
@@ -0,0 +1,150 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/lang/marks"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// forgetAuditRedacted is the value written to the forget audit log in place
+// of any sensitive value.
+const forgetAuditRedacted = "(sensitive value)"
+
+// forgetAuditRecord is the serialization of a single forgotten object, as
+// written to ApplyOpts.ForgetAuditWriter.
+type forgetAuditRecord struct {
+	Address    string `json:"address"`
+	Deposed    string `json:"deposed,omitempty"`
+	Type       string `json:"type"`
+	Attributes any    `json:"attributes"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// forgetAuditHook is a Hook used internally during the apply walk to
+// implement ApplyOpts.ForgetAuditWriter.
+//
+// It writes a record for each object that the plan proposed to forget as
+// soon as the object is removed from the working state.
+type forgetAuditHook struct {
+	NilHook
+
+	now func() time.Time
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	forgets map[applyProgressKey]bool
+	err     error
+}
+
+var _ Hook = (*forgetAuditHook)(nil)
+var _ forgetListener = (*forgetAuditHook)(nil)
+
+func newForgetAuditHook(w io.Writer, forgets []*plans.ResourceInstanceChangeSrc) *forgetAuditHook {
+	h := &forgetAuditHook{
+		now:     time.Now,
+		enc:     json.NewEncoder(w),
+		forgets: make(map[applyProgressKey]bool, len(forgets)),
+	}
+	for _, rc := range forgets {
+		h.forgets[applyProgressKey{rc.Addr.String(), rc.DeposedKey.Generation()}] = true
+	}
+	return h
+}
+
+func (h *forgetAuditHook) objectForgotten(addr addrs.AbsResourceInstance, gen states.Generation, old *states.ResourceInstanceObject) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.forgets[applyProgressKey{addr.String(), gen}] || h.err != nil {
+		return HookActionContinue, nil
+	}
+
+	rec := forgetAuditRecord{
+		Address:    addr.String(),
+		Type:       addr.Resource.Resource.Type,
+		Attributes: redactedForgetAuditValue(old.Value),
+		Timestamp:  h.now().UTC().Format(time.RFC3339Nano),
+	}
+	if dk, ok := gen.(states.DeposedKey); ok {
+		rec.Deposed = dk.String()
+	}
+	if err := h.enc.Encode(rec); err != nil {
+		// We don't fail the apply here, because the object has already been
+		// forgotten by the time we hear about it, but we'll report the
+		// problem once the apply is complete.
+		h.err = fmt.Errorf("failed to write audit record for %s: %w", addr, err)
+	}
+	return HookActionContinue, nil
+}
+
+// Diagnostics returns an error diagnostic if the hook was unable to write
+// any of its audit records.
+func (h *forgetAuditHook) Diagnostics() tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err != nil {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Failed to write forget audit log",
+			fmt.Sprintf("The apply completed, but the audit log of forgotten resources is incomplete: %s.", h.err),
+		))
+	}
+	return diags
+}
+
+// redactedForgetAuditValue converts the given value into a form suitable for
+// encoding as JSON, replacing any sensitive values with a placeholder.
+func redactedForgetAuditValue(v cty.Value) any {
+	if v.HasMark(marks.Sensitive) {
+		return forgetAuditRedacted
+	}
+	v, _ = v.Unmark()
+	if v.IsNull() || !v.IsKnown() {
+		// There should not be any unknown values in a state, but we'll
+		// tolerate them anyway.
+		return nil
+	}
+
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return v.AsString()
+	case ty == cty.Number:
+		return json.Number(v.AsBigFloat().Text('f', -1))
+	case ty == cty.Bool:
+		return v.True()
+	case ty.IsObjectType() || ty.IsMapType():
+		ret := make(map[string]any, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			ret[k.AsString()] = redactedForgetAuditValue(ev)
+		}
+		return ret
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		ret := make([]any, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			ret = append(ret, redactedForgetAuditValue(ev))
+		}
+		return ret
+	default:
+		// Should not get here, since the above covers all of the types
+		// that can appear in a resource instance object.
+		return nil
+	}
+}
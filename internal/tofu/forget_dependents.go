@@ -0,0 +1,102 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// forgottenDependentsWarnings returns a warning for each resource that the
+// given plan forgets all of the instances of, listing the other resource
+// instances that depended on it as of the previous run and that the plan
+// neither destroys nor forgets.
+//
+// The configuration can't refer to a resource that a removed block forgets,
+// and planning updates the dependencies in the prior state to match the
+// configuration, so the dependencies are instead those recorded in the
+// previous run state when each dependent was last applied. Forgetting only
+// a deposed object, or only some of a resource's instances, leaves the
+// resource in the state and so doesn't produce a warning.
+func forgottenDependentsWarnings(plan *plans.Plan) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if plan.PrevRunState == nil {
+		return diags
+	}
+
+	forgets := addrs.MakeSet[addrs.AbsResourceInstance]()
+	deletes := addrs.MakeSet[addrs.AbsResourceInstance]()
+	forgotten := addrs.MakeSet[addrs.ConfigResource]()
+	for _, rc := range plan.Changes.Resources {
+		if rc.DeposedKey != states.NotDeposed {
+			continue
+		}
+		switch rc.Action {
+		case plans.Forget:
+			forgets.Add(rc.Addr)
+			forgotten.Add(rc.Addr.ConfigResource())
+		case plans.Delete:
+			deletes.Add(rc.Addr)
+		}
+	}
+	if len(forgotten) == 0 {
+		return diags
+	}
+
+	// A resource with any instance that isn't forgotten stays in the
+	// state, so its dependents aren't affected.
+	for _, ms := range plan.PrevRunState.Modules {
+		for _, rs := range ms.Resources {
+			for key := range rs.Instances {
+				if !forgets.Has(rs.Addr.Instance(key)) {
+					forgotten.Remove(rs.Addr.Config())
+				}
+			}
+		}
+	}
+
+	dependents := make(map[string][]string)
+	for _, ms := range plan.PrevRunState.Modules {
+		for _, rs := range ms.Resources {
+			for key, is := range rs.Instances {
+				addr := rs.Addr.Instance(key)
+				if is.Current == nil || forgets.Has(addr) || deletes.Has(addr) {
+					continue
+				}
+				for _, dep := range is.Current.Dependencies {
+					if forgotten.Has(dep) {
+						dependents[dep.String()] = append(dependents[dep.String()], addr.String())
+					}
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(dependents))
+	for name := range dependents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		list := dependents[name]
+		sort.Strings(list)
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Warning,
+			"Forgotten resource has dependents",
+			fmt.Sprintf(
+				"OpenTofu is forgetting %s, but the following resource instances depended on it when they were last applied:\n  - %s\n\nThe forgotten objects will continue to exist, but OpenTofu will no longer manage them, so any changes to them will not be reflected in these dependents. Check that the dependents still have what they need.",
+				name, strings.Join(list, "\n  - "),
+			),
+		))
+	}
+	return diags
+}
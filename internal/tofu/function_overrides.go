@@ -0,0 +1,85 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty/function"
+
+	"github.com/opentofu/opentofu/internal/lang"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// checkFunctionOverrides returns an error diagnostic for each of the given
+// function overrides, as for ApplyOpts.FunctionOverrides, that isn't named
+// after a built-in function, not counting the "core::" aliases, or that
+// doesn't take the same parameters as the function it replaces.
+//
+// Only the parameters can be compared, because the result type of a
+// function is decided only once it's called.
+func checkFunctionOverrides(overrides map[string]function.Function) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if len(overrides) == 0 {
+		return diags
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	builtins := (&lang.Scope{}).Functions()
+	for _, name := range names {
+		// Overriding a function also overrides its alias in the core
+		// namespace, so the aliases can't be overridden separately.
+		builtin, ok := builtins[name]
+		if !ok || strings.HasPrefix(name, lang.CoreNamespace) {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid function override",
+				fmt.Sprintf("Cannot override function %q, because there is no built-in function of that name.", name),
+			))
+			continue
+		}
+		if err := sameFunctionParams(builtin, overrides[name]); err != nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid function override",
+				fmt.Sprintf("The override for function %q doesn't match the built-in function's signature: %s.", name, err),
+			))
+		}
+	}
+	return diags
+}
+
+// sameFunctionParams returns an error describing the first difference
+// between the parameters of the given functions, if any.
+func sameFunctionParams(want, got function.Function) error {
+	wantParams, gotParams := want.Params(), got.Params()
+	if len(wantParams) != len(gotParams) {
+		return fmt.Errorf("it must take %d parameters, not %d", len(wantParams), len(gotParams))
+	}
+	for i := range wantParams {
+		if !wantParams[i].Type.Equals(gotParams[i].Type) {
+			return fmt.Errorf("parameter %d must be of type %s, not %s", i+1, wantParams[i].Type.FriendlyName(), gotParams[i].Type.FriendlyName())
+		}
+	}
+
+	wantVar, gotVar := want.VarParam(), got.VarParam()
+	switch {
+	case wantVar == nil && gotVar != nil:
+		return fmt.Errorf("it must not take a variable number of arguments")
+	case wantVar != nil && gotVar == nil:
+		return fmt.Errorf("it must take a variable number of arguments")
+	case wantVar != nil && !wantVar.Type.Equals(gotVar.Type):
+		return fmt.Errorf("its variadic parameter must be of type %s, not %s", wantVar.Type.FriendlyName(), gotVar.Type.FriendlyName())
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/opentofu/opentofu/internal/logging"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// defaultGoroutineCheckInterval is how often the number of goroutines is
+// sampled if ApplyOpts.GoroutineCheckInterval is not set.
+const defaultGoroutineCheckInterval = time.Second
+
+// goroutineMonitor periodically samples the number of goroutines for the
+// duration of a graph walk, and records whether it ever exceeded a limit.
+// If configured to do so, it also interrupts the context's run as soon as
+// the limit is exceeded.
+//
+// A nil *goroutineMonitor is valid and does nothing, so that callers don't
+// need to check whether the current walk has a goroutine limit.
+type goroutineMonitor struct {
+	limit int
+	abort bool
+
+	stop chan struct{}
+	wait chan struct{}
+
+	mu   sync.Mutex
+	peak int
+}
+
+// monitorGoroutines starts sampling the number of goroutines using count at
+// the given interval, or at defaultGoroutineCheckInterval if the interval is
+// not positive, until Close is called. It returns nil if limit is not
+// positive. If count is nil then runtime.NumGoroutine is used.
+func (c *Context) monitorGoroutines(limit int, interval time.Duration, abort bool, count func() int) *goroutineMonitor {
+	if limit <= 0 {
+		return nil
+	}
+	if interval <= 0 {
+		interval = defaultGoroutineCheckInterval
+	}
+	if count == nil {
+		count = runtime.NumGoroutine
+	}
+
+	m := &goroutineMonitor{
+		limit: limit,
+		abort: abort,
+		stop:  make(chan struct{}),
+		wait:  make(chan struct{}),
+	}
+
+	panicHandler := logging.PanicHandlerWithTraceFn()
+	go func() {
+		defer panicHandler()
+		defer close(m.wait)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+			}
+
+			n := count()
+			if n <= limit {
+				continue
+			}
+
+			m.mu.Lock()
+			first := m.peak == 0
+			m.peak = max(m.peak, n)
+			m.mu.Unlock()
+
+			if first {
+				log.Printf("[WARN] tofu: %d goroutines running during apply, exceeding the limit of %d", n, limit)
+			}
+			if abort {
+				log.Printf("[ERROR] tofu: goroutine limit exceeded, stopping")
+				c.l.Lock()
+				c.interruptRun()
+				c.l.Unlock()
+				return
+			}
+		}
+	}()
+
+	return m
+}
+
+// Close stops sampling the number of goroutines and returns a diagnostic if
+// the limit was exceeded during the walk: an error if the monitor stopped
+// the walk, or a warning otherwise.
+//
+// Close must be called before the run that the monitor might interrupt is
+// released, so that it can't interrupt a later run.
+func (m *goroutineMonitor) Close() tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if m == nil {
+		return diags
+	}
+
+	close(m.stop)
+	<-m.wait
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.peak == 0 {
+		return diags
+	}
+
+	if m.abort {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Goroutine limit exceeded during apply",
+			fmt.Sprintf(
+				"OpenTofu stopped applying changes because %d goroutines were running, exceeding the limit of %d. This often means that a provider or other plugin is leaking resources.\n\nThe returned state includes only the changes that completed before the apply was stopped.",
+				m.peak, m.limit,
+			),
+		))
+	} else {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Warning,
+			"Goroutine limit exceeded during apply",
+			fmt.Sprintf(
+				"Up to %d goroutines were running during the apply, exceeding the limit of %d. This often means that a provider or other plugin is leaking resources.",
+				m.peak, m.limit,
+			),
+		))
+	}
+	return diags
+}
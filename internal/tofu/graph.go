@@ -141,5 +141,11 @@ func (g *Graph) walk(ctx context.Context, walker GraphWalker) tfdiags.Diagnostic
 		return
 	}
 
-	return g.AcyclicGraph.Walk(walkFn)
+	w := &dag.Walker{
+		Callback:     walkFn,
+		SkipCallback: walker.Skipped,
+		Reverse:      true,
+	}
+	w.Update(&g.AcyclicGraph)
+	return w.Wait()
 }
@@ -6,9 +6,16 @@
 package tofu
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
 
 	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/dag"
 	"github.com/opentofu/opentofu/internal/logging"
 	"github.com/opentofu/opentofu/internal/tfdiags"
 )
@@ -29,6 +36,11 @@ type BasicGraphBuilder struct {
 	Steps []GraphTransformer
 	// Optional name to add to the graph debug log
 	Name string
+
+	// If set, any dependency cycles in the completed graph are reported as
+	// diagnostics describing a full dependency path around each cycle,
+	// rather than just listing the nodes involved.
+	DescribeCycles bool
 }
 
 func (b *BasicGraphBuilder) Build(path addrs.ModuleInstance) (*Graph, tfdiags.Diagnostics) {
@@ -65,9 +77,113 @@ func (b *BasicGraphBuilder) Build(path addrs.ModuleInstance) (*Graph, tfdiags.Di
 
 	if err := g.Validate(); err != nil {
 		log.Printf("[ERROR] Graph validation failed. Graph:\n\n%s", g.String())
+		if b.DescribeCycles {
+			if cycleDiags := describeGraphCycles(g, err); cycleDiags.HasErrors() {
+				diags = diags.Append(cycleDiags)
+				return nil, diags
+			}
+		}
 		diags = diags.Append(err)
 		return nil, diags
 	}
 
 	return g, diags
 }
+
+// describeGraphCycles returns an error diagnostic for each dependency cycle
+// reported in the given error from validating the graph, describing a path of
+// dependencies that leads from one of the nodes in the cycle back to itself.
+// The result has no diagnostics if the error doesn't report any cycles.
+func describeGraphCycles(g *Graph, validateErr error) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	errs := []error{validateErr}
+	if multiErr, ok := validateErr.(*multierror.Error); ok {
+		errs = multiErr.WrappedErrors()
+	}
+	var paths []string
+	for _, err := range errs {
+		var cycleErr *dag.CycleError
+		if !errors.As(err, &cycleErr) {
+			continue
+		}
+		path := graphCyclePath(g, cycleErr.Vertices)
+		names := make([]string, len(path))
+		for i, v := range path {
+			names[i] = dag.VertexName(v)
+		}
+		paths = append(paths, strings.Join(names, " -> "))
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Dependency cycle",
+			fmt.Sprintf("The following objects depend on one another in a cycle, so there is no valid order in which to process them:\n  Cycle: %s\n\nEach object in the path depends on the one after it.", path),
+		))
+	}
+	return diags
+}
+
+// graphCyclePath returns a path of dependency edges through the given
+// strongly-connected component of the graph which starts and ends at the
+// same vertex.
+//
+// The path begins at the vertex in the component whose name sorts first,
+// and is the shortest such path from that vertex back to itself, so that
+// the result is stable for a given graph.
+func graphCyclePath(g *Graph, component []dag.Vertex) []dag.Vertex {
+	members := make(map[dag.Vertex]struct{}, len(component))
+	for _, v := range component {
+		members[v] = struct{}{}
+	}
+	sorted := func(vs []dag.Vertex) []dag.Vertex {
+		sort.Slice(vs, func(i, j int) bool {
+			return dag.VertexName(vs[i]) < dag.VertexName(vs[j])
+		})
+		return vs
+	}
+	start := sorted(append([]dag.Vertex(nil), component...))[0]
+
+	// We do a breadth-first search from the start vertex, following only
+	// edges that stay within the component, until we find our way back.
+	prev := make(map[dag.Vertex]dag.Vertex, len(component))
+	queue := []dag.Vertex{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		var nexts []dag.Vertex
+		for _, v := range g.DownEdges(current) {
+			nexts = append(nexts, v)
+		}
+		for _, next := range sorted(nexts) {
+			if _, ok := members[next]; !ok {
+				continue
+			}
+			if next == start {
+				path := []dag.Vertex{start}
+				for v := current; v != start; v = prev[v] {
+					path = append(path, v)
+				}
+				path = append(path, start)
+				// We built the path from its end, so we must reverse it,
+				// leaving the duplicated start vertex at both ends.
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			if _, seen := prev[next]; seen {
+				continue
+			}
+			prev[next] = current
+			queue = append(queue, next)
+		}
+	}
+
+	// Should never get here, because every vertex in a strongly-connected
+	// component can reach every other, but we'll return the component
+	// members as a fallback so that we still return something useful.
+	return sorted(append([]dag.Vertex(nil), component...))
+}
@@ -58,6 +58,10 @@ type ApplyGraphBuilder struct {
 	// actions remain consistent between plan and apply.
 	ForceReplace []addrs.AbsResourceInstance
 
+	// TraceOrder, if set, is the order in which to apply the changes to
+	// these resource instances. See Context.ReplayApplyTrace.
+	TraceOrder []addrs.AbsResourceInstance
+
 	// Plan Operation this graph will be used for.
 	Operation walkOperation
 
@@ -67,13 +71,35 @@ type ApplyGraphBuilder struct {
 	ExternalReferences []*addrs.Reference
 
 	ProviderFunctionTracker ProviderFunctionMapping
+
+	// SkipResources, if set, omits the whole-resource nodes that would
+	// otherwise be added for every resource in the configuration.
+	//
+	// This is appropriate only when Changes proposes no actions for any
+	// resource instances, in which case those nodes would only rewrite
+	// resource-level metadata that the planning phase already recorded in
+	// State, and yet would still require configuring every provider.
+	SkipResources bool
+
+	// ForgetLast, if set, orders every forget after all of the other
+	// resource instance changes. See ApplyOpts.ForgetLast.
+	ForgetLast bool
+
+	// ExtraEdges are additional orderings between resource instances to
+	// add to the graph. See ApplyOpts.ExtraEdges.
+	ExtraEdges []ApplyEdge
+
+	// MaxProviderInstances, if positive, limits how many providers are open
+	// at once. See ApplyOpts.MaxProviderInstances.
+	MaxProviderInstances int
 }
 
 // See GraphBuilder
 func (b *ApplyGraphBuilder) Build(path addrs.ModuleInstance) (*Graph, tfdiags.Diagnostics) {
 	return (&BasicGraphBuilder{
-		Steps: b.Steps(),
-		Name:  "ApplyGraphBuilder",
+		Steps:          b.Steps(),
+		Name:           "ApplyGraphBuilder",
+		DescribeCycles: true,
 	}).Build(path)
 }
 
@@ -107,6 +133,7 @@ func (b *ApplyGraphBuilder) Steps() []GraphTransformer {
 		&ConfigTransformer{
 			Concrete: concreteResource,
 			Config:   b.Config,
+			skip:     b.SkipResources,
 		},
 
 		// Add dynamic values
@@ -200,9 +227,20 @@ func (b *ApplyGraphBuilder) Steps() []GraphTransformer {
 		// Target
 		&TargetingTransformer{Targets: b.Targets, Excludes: b.Excludes},
 
+		// Follow a recorded apply order, add the caller's orderings and then
+		// postpone forgets, only after targeting so that the new edges can't
+		// pull more changes into a targeted apply.
+		&applyTraceOrderTransformer{Order: b.TraceOrder},
+		&extraEdgesTransformer{Edges: b.ExtraEdges},
+		&forgetLastTransformer{Enabled: b.ForgetLast},
+
 		// Close opened plugin connections
 		&CloseProviderTransformer{},
 
+		// Limit how many of those connections are open at once, which
+		// needs the close nodes.
+		&providerLimitTransformer{Max: b.MaxProviderInstances},
+
 		// close the root module
 		&CloseRootModuleTransformer{
 			RootConfig: b.Config,
@@ -67,3 +67,44 @@ func (t *testBasicGraphBuilderTransform) Transform(g *Graph) error {
 const testBasicGraphBuilderStr = `
 1
 `
+
+func TestBasicGraphBuilder_describeCycles(t *testing.T) {
+	b := &BasicGraphBuilder{
+		Steps: []GraphTransformer{
+			&testCycleGraphBuilderTransform{},
+		},
+		DescribeCycles: true,
+	}
+
+	_, diags := b.Build(addrs.RootModuleInstance)
+	if !diags.HasErrors() {
+		t.Fatal("should error")
+	}
+	if got, want := len(diags), 1; got != want {
+		t.Fatalf("wrong number of diagnostics %d; want %d\n%s", got, want, diags.Err())
+	}
+	desc := diags[0].Description()
+	if got, want := desc.Summary, "Dependency cycle"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+	if got, want := desc.Detail, "a -> b -> c -> a"; !strings.Contains(got, want) {
+		t.Errorf("detail does not include cycle path %q\n%s", want, got)
+	}
+}
+
+// testCycleGraphBuilderTransform builds a graph where "root" depends on "a",
+// and "a", "b", and "c" depend on one another in a cycle. "c" also depends on
+// "d", which is not part of the cycle.
+type testCycleGraphBuilderTransform struct{}
+
+func (t *testCycleGraphBuilderTransform) Transform(g *Graph) error {
+	for _, v := range []string{"root", "a", "b", "c", "d"} {
+		g.Add(v)
+	}
+	g.Connect(dag.BasicEdge("root", "a"))
+	g.Connect(dag.BasicEdge("a", "b"))
+	g.Connect(dag.BasicEdge("b", "c"))
+	g.Connect(dag.BasicEdge("c", "a"))
+	g.Connect(dag.BasicEdge("c", "d"))
+	return nil
+}
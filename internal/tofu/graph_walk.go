@@ -7,6 +7,7 @@ package tofu
 
 import (
 	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/dag"
 	"github.com/opentofu/opentofu/internal/tfdiags"
 )
 
@@ -17,6 +18,7 @@ type GraphWalker interface {
 	EnterPath(addrs.ModuleInstance) EvalContext
 	ExitPath(addrs.ModuleInstance)
 	Execute(EvalContext, GraphNodeExecutable) tfdiags.Diagnostics
+	Skipped(v dag.Vertex, cause dag.Vertex) tfdiags.Diagnostics
 }
 
 // NullGraphWalker is a GraphWalker implementation that does nothing.
@@ -28,3 +30,4 @@ func (NullGraphWalker) EvalContext() EvalContext
 func (NullGraphWalker) EnterPath(addrs.ModuleInstance) EvalContext                   { return new(MockEvalContext) }
 func (NullGraphWalker) ExitPath(addrs.ModuleInstance)                                {}
 func (NullGraphWalker) Execute(EvalContext, GraphNodeExecutable) tfdiags.Diagnostics { return nil }
+func (NullGraphWalker) Skipped(dag.Vertex, dag.Vertex) tfdiags.Diagnostics           { return nil }
@@ -7,14 +7,17 @@ package tofu
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
 
 	"github.com/opentofu/opentofu/internal/addrs"
 	"github.com/opentofu/opentofu/internal/checks"
 	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/dag"
 	"github.com/opentofu/opentofu/internal/encryption"
 	"github.com/opentofu/opentofu/internal/instances"
 	"github.com/opentofu/opentofu/internal/plans"
@@ -35,6 +38,14 @@ type ContextGraphWalker struct {
 	State                   *states.SyncState       // Used for safe concurrent access to state
 	RefreshState            *states.SyncState       // Used for safe concurrent access to state
 	PrevRunState            *states.SyncState       // Used for safe concurrent access to state
+	ForgetArchive           *states.SyncState       // Receives objects forgotten during apply, if non-nil
+	ProviderCallCounter     *providerCallCounter    // Records provider calls per resource instance, if non-nil
+	ReadSourceRecorder      *readSourceRecorder     // Records where each resource instance's object came from, if non-nil
+	ProviderCallMiddleware  ProviderCallMiddleware  // Wraps each ApplyResourceChange call, if non-nil
+	LazyProviders           bool                    // Defer provider configuration until first use
+	CategorizeProviderDiags bool                    // Classify provider diagnostics as tfdiags.CategoryProvider
+	ApplyTracer             *applyTracer            // Produces spans for resource operations, if non-nil
+	AdditionalHooks         []Hook                  // Called after the context's own hooks, for this walk only
 	Changes                 *plans.ChangesSync      // Used for safe concurrent writes to changes
 	Checks                  *checks.State           // Used for safe concurrent writes of checkable objects and their check results
 	InstanceExpander        *instances.Expander     // Tracks our gradual expansion of module and resource instances
@@ -48,6 +59,69 @@ type ContextGraphWalker struct {
 	Encryption              encryption.Encryption
 	ProviderFunctionTracker ProviderFunctionMapping
 
+	// Breakpoints, if set, are called with a snapshot of the state just
+	// before each operation on the corresponding resource instances.
+	Breakpoints addrs.Map[addrs.AbsResourceInstance, func(*states.State)]
+
+	// Journal, if set, receives an entry for each resource instance
+	// operation as it finishes, with a snapshot of the object from the
+	// working state.
+	Journal *applyJournal
+
+	// SuppressAttributes, if set, are attribute paths whose new values are
+	// not written to the state after each update of a matching resource.
+	SuppressAttributes map[addrs.Resource][]cty.Path
+
+	// DataSourceResults, if set, are used as the results of reading the
+	// corresponding data resource instances instead of their providers.
+	DataSourceResults addrs.Map[addrs.AbsResourceInstance, cty.Value]
+
+	// TagResourceDiagnostics, if set, causes each diagnostic returned by a
+	// resource instance node to record which resource instance it belongs
+	// to, for groupResourceDiagnostics.
+	TagResourceDiagnostics bool
+
+	// DiagnosticStream, if set, receives the diagnostics returned by each
+	// node as soon as it completes.
+	DiagnosticStream *diagnosticStream
+
+	// Scheduler, if set, chooses the order in which nodes that are ready
+	// to execute take the context's parallel execution slots.
+	Scheduler Scheduler
+
+	// ModuleParallelism, if set, limits how many resource instance nodes
+	// in each module may execute at once, keyed by module address.
+	ModuleParallelism map[string]int
+
+	// ExplainSkippedChanges, if set, makes the walk return a warning for
+	// each resource instance node that is skipped because one of its
+	// dependencies failed.
+	ExplainSkippedChanges bool
+
+	// VariableReads, if set, records each input variable that is read
+	// while evaluating expressions during the walk.
+	VariableReads *variableReads
+
+	// RandomSeed, if set, is passed to the providers that support it in
+	// their provider meta.
+	RandomSeed *int64
+
+	// PolicyEvaluator, if set, evaluates each change to a managed resource
+	// instance before it is applied.
+	PolicyEvaluator PolicyEvaluator
+
+	// ResourceTimeouts, if set, override the timeouts from the configuration
+	// for the given resource instances.
+	ResourceTimeouts addrs.Map[addrs.AbsResourceInstance, time.Duration]
+
+	// OutputTypes, if set, are the type constraints for the root module
+	// output values of the same names.
+	OutputTypes map[string]cty.Type
+
+	// FunctionOverrides, if set, replace the built-in functions of the same
+	// names when evaluating expressions.
+	FunctionOverrides map[string]function.Function
+
 	// This is an output. Do not set this, nor read it while a graph walk
 	// is in progress.
 	NonFatalDiagnostics tfdiags.Diagnostics
@@ -55,6 +129,9 @@ type ContextGraphWalker struct {
 	once        sync.Once
 	contextLock sync.Mutex
 	contexts    map[string]*BuiltinEvalContext
+	hooks       []Hook
+	scheduled   *scheduledSemaphore
+	moduleSems  moduleSemaphores
 
 	variableValuesLock sync.Mutex
 	variableValues     map[string]map[string]cty.Value
@@ -97,31 +174,46 @@ func (w *ContextGraphWalker) EvalContext() EvalContext {
 		VariableValues:     w.variableValues,
 		VariableValuesLock: &w.variableValuesLock,
 		PlanTimestamp:      w.PlanTimestamp,
+		VariableReads:      w.VariableReads,
+		FunctionOverrides:  w.FunctionOverrides,
 	}
 
 	ctx := &BuiltinEvalContext{
-		StopContext:             w.StopContext,
-		Hooks:                   w.Context.hooks,
-		InputValue:              w.Context.uiInput,
-		InstanceExpanderValue:   w.InstanceExpander,
-		Plugins:                 w.Context.plugins,
-		MoveResultsValue:        w.MoveResults,
-		ImportResolverValue:     w.ImportResolver,
-		ProviderCache:           w.providerCache,
-		ProviderInputConfig:     w.Context.providerInputConfig,
-		ProviderLock:            &w.providerLock,
-		ProvisionerCache:        w.provisionerCache,
-		ProvisionerLock:         &w.provisionerLock,
-		ChangesValue:            w.Changes,
-		ChecksValue:             w.Checks,
-		StateValue:              w.State,
-		RefreshStateValue:       w.RefreshState,
-		PrevRunStateValue:       w.PrevRunState,
-		Evaluator:               evaluator,
-		VariableValues:          w.variableValues,
-		VariableValuesLock:      &w.variableValuesLock,
-		Encryption:              w.Encryption,
-		ProviderFunctionTracker: w.ProviderFunctionTracker,
+		StopContext:                 w.StopContext,
+		Hooks:                       w.hooks,
+		InputValue:                  w.Context.uiInput,
+		InstanceExpanderValue:       w.InstanceExpander,
+		Plugins:                     w.Context.plugins,
+		MoveResultsValue:            w.MoveResults,
+		ImportResolverValue:         w.ImportResolver,
+		ProviderCache:               w.providerCache,
+		ProviderInputConfig:         w.Context.providerInputConfig,
+		ProviderLock:                &w.providerLock,
+		ProvisionerCache:            w.provisionerCache,
+		ProvisionerLock:             &w.provisionerLock,
+		ChangesValue:                w.Changes,
+		ChecksValue:                 w.Checks,
+		StateValue:                  w.State,
+		RefreshStateValue:           w.RefreshState,
+		PrevRunStateValue:           w.PrevRunState,
+		ForgetArchiveValue:          w.ForgetArchive,
+		ProviderCallCounterValue:    w.ProviderCallCounter,
+		ReadSourceRecorderValue:     w.ReadSourceRecorder,
+		ProviderCallMiddlewareValue: w.ProviderCallMiddleware,
+		SuppressAttributesValue:     w.SuppressAttributes,
+		DataSourceResultsValue:      w.DataSourceResults,
+		LazyProviders:               w.LazyProviders,
+		CategorizeProviderDiags:     w.CategorizeProviderDiags,
+		ApplyTracerValue:            w.ApplyTracer,
+		RandomSeedValue:             w.RandomSeed,
+		PolicyEvaluatorValue:        w.PolicyEvaluator,
+		ResourceTimeoutsValue:       w.ResourceTimeouts,
+		OutputTypesValue:            w.OutputTypes,
+		Evaluator:                   evaluator,
+		VariableValues:              w.variableValues,
+		VariableValuesLock:          &w.variableValuesLock,
+		Encryption:                  w.Encryption,
+		ProviderFunctionTracker:     w.ProviderFunctionTracker,
 	}
 
 	return ctx
@@ -133,6 +225,36 @@ func (w *ContextGraphWalker) init() {
 	w.provisionerCache = make(map[string]provisioners.Interface)
 	w.variableValues = make(map[string]map[string]cty.Value)
 
+	w.hooks = w.Context.hooks
+	if len(w.AdditionalHooks) > 0 {
+		w.hooks = make([]Hook, 0, len(w.Context.hooks)+len(w.AdditionalHooks))
+		w.hooks = append(w.hooks, w.Context.hooks...)
+		w.hooks = append(w.hooks, w.AdditionalHooks...)
+	}
+	if w.Breakpoints.Len() > 0 {
+		// The breakpoint hook goes last so that any UI hooks have already
+		// reported the operation that the breakpoint is pausing.
+		hooks := make([]Hook, 0, len(w.hooks)+1)
+		hooks = append(hooks, w.hooks...)
+		w.hooks = append(hooks, &breakpointHook{
+			breakpoints: w.Breakpoints,
+			state:       w.State,
+		})
+	}
+	if w.Journal != nil {
+		hooks := make([]Hook, 0, len(w.hooks)+1)
+		hooks = append(hooks, w.hooks...)
+		w.hooks = append(hooks, &applyJournalHook{
+			journal: w.Journal,
+			state:   w.State,
+		})
+	}
+
+	if w.Scheduler != nil {
+		w.scheduled = newScheduledSemaphore(w.Scheduler, w.Context.parallelSem)
+	}
+	w.moduleSems = newModuleSemaphores(w.ModuleParallelism)
+
 	// Populate root module variable values. Other modules will be populated
 	// during the graph walk.
 	w.variableValues[""] = make(map[string]cty.Value)
@@ -142,9 +264,47 @@ func (w *ContextGraphWalker) init() {
 }
 
 func (w *ContextGraphWalker) Execute(ctx EvalContext, n GraphNodeExecutable) tfdiags.Diagnostics {
+	// Any limit on the node's module is acquired first, so that nodes
+	// waiting for their module don't hold any of the overall slots.
+	if sem := w.moduleSems.For(n); sem != nil {
+		sem.Acquire()
+		defer sem.Release()
+	}
+
 	// Acquire a lock on the semaphore
-	w.Context.parallelSem.Acquire()
+	if w.scheduled != nil {
+		w.scheduled.Acquire(n)
+	} else {
+		w.Context.parallelSem.Acquire()
+	}
 	defer w.Context.parallelSem.Release()
 
-	return n.Execute(ctx, w.Operation)
+	diags := n.Execute(ctx, w.Operation)
+	if rn, ok := n.(GraphNodeResourceInstance); ok && w.TagResourceDiagnostics {
+		diags = withResourceInstance(rn.ResourceInstanceAddr(), diags)
+	}
+	return w.DiagnosticStream.Send(diags)
+}
+
+// Skipped returns a warning for a resource instance node that was skipped
+// because one of its dependencies failed, naming the dependency that caused
+// it, if ExplainSkippedChanges is set.
+func (w *ContextGraphWalker) Skipped(v dag.Vertex, cause dag.Vertex) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if !w.ExplainSkippedChanges {
+		return diags
+	}
+	if _, ok := v.(GraphNodeResourceInstance); !ok {
+		return diags
+	}
+
+	// We use the node names rather than the resource instance addresses,
+	// because the names also distinguish destroying an object from
+	// creating its replacement.
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Warning,
+		"Resource instance change skipped",
+		fmt.Sprintf("The planned change for %s was skipped because dependency %s failed.", dag.VertexName(v), dag.VertexName(cause)),
+	))
+	return w.DiagnosticStream.Send(diags)
 }
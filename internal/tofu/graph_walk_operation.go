@@ -20,3 +20,9 @@ const (
 	walkImport
 	walkEval // used just to prepare EvalContext for expression evaluation, with no other actions
 )
+
+// known returns true if the operation is one of the walk operations defined
+// above, other than walkInvalid.
+func (op walkOperation) known() bool {
+	return op > walkInvalid && op <= walkEval
+}
@@ -114,12 +114,201 @@ type Hook interface {
 	PostStateUpdate(new *states.State) (HookAction, error)
 }
 
+// HookContextReceiver is an optional interface that a Hook implementation
+// may also implement in order to receive the request-scoped values given in
+// ApplyOpts.HookContext.
+//
+// SetHookContext is called once at the start of each apply operation, before
+// any other hook methods are called for that operation. The values argument
+// is nil if the caller did not set any hook context values.
+type HookContextReceiver interface {
+	SetHookContext(values map[string]any)
+}
+
+// RunListener is an optional interface that a Hook implementation may also
+// implement in order to be notified when a Context begins and ends each
+// operation, such as "plan" or "apply". This is intended for callers that
+// need to coordinate OpenTofu's own run lock with some external lock.
+//
+// OnRunAcquired is called after the Context has acquired its run lock and
+// before the operation begins. OnRunReleased is called after the run lock
+// has been released, regardless of whether the operation succeeded. Both
+// are given the name of the operation.
+type RunListener interface {
+	OnRunAcquired(phase string)
+	OnRunReleased(phase string)
+}
+
+// ApplyGate is an optional interface that a Hook implementation may also
+// implement in order to decide at apply time whether each planned create
+// should go ahead.
+//
+// ShouldApply is called just before OpenTofu creates each new managed
+// resource instance object, with the planned new value of the object. If
+// any hook returns false then OpenTofu skips creating the object, leaving
+// the resource instance absent from the new state, and returns a warning
+// saying that it was skipped. Any other objects that depend on it are still
+// applied, and so may fail if they require it to exist.
+//
+// Returning an error causes the create to fail with that error.
+type ApplyGate interface {
+	ShouldApply(addr addrs.AbsResourceInstance, plannedValue cty.Value) (bool, error)
+}
+
+// PlannedValueMutator is an optional interface that a Hook implementation
+// may also implement in order to adjust the planned new value of each
+// managed resource instance just before it is applied, such as to add a
+// tag that is only known at apply time.
+//
+// MutatePlannedValue is called before each create or update, before the
+// ApplyValidator hooks, and its result replaces the planned new value
+// that OpenTofu sends to the provider and then validates the provider's
+// result against. The planned value includes any sensitive marks. If
+// several hooks implement this interface then each receives the previous
+// hook's result.
+//
+// The result must conform exactly to the resource type's schema and must
+// not be null, or the apply of that resource instance fails. Any sensitive
+// marks from the original planned value are kept on the result, so
+// clearing them has no effect. Returning an error also causes the apply of
+// that resource instance to fail.
+//
+// This bypasses the usual guarantee that OpenTofu applies exactly what was
+// shown in the plan, so implementations must take care to only change
+// values that the provider and the configuration's author expect.
+type PlannedValueMutator interface {
+	MutatePlannedValue(addr addrs.AbsResourceInstance, planned cty.Value) (cty.Value, error)
+}
+
+// StateMutationListener is an optional interface that a Hook implementation
+// may also implement in order to be told about each change to an individual
+// resource instance object in the working state, such as to replicate the
+// state incrementally.
+//
+// StateMutation is called each time an object is written to or removed from
+// the working state, with the object that was previously recorded (if any)
+// and the object that replaced it (or nil if it was removed). Unlike
+// PostStateUpdate, which receives a snapshot of the whole state, this
+// reports each change as it happens. Calls for different resource instances
+// may arrive concurrently, but the calls for any single resource instance
+// object arrive in the order the changes were made.
+//
+// OpenTofu decodes the previous object only if at least one hook implements
+// this interface, so implement it only when the events are needed.
+type StateMutationListener interface {
+	StateMutation(addr addrs.AbsResourceInstance, gen states.Generation, old, new *states.ResourceInstanceObject) (HookAction, error)
+}
+
+// ResourceApplyListener is an optional interface that a Hook implementation
+// may also implement in order to receive the full new object of each
+// managed resource instance that is created or updated.
+//
+// ResourceApplied is called after a create or update action has completed
+// successfully, with the new object as returned by the provider, including
+// any computed attributes.
+//
+// Any sensitive parts of newValue remain marked as sensitive, so
+// implementations that export the value elsewhere must check the marks to
+// avoid disclosing sensitive data.
+type ResourceApplyListener interface {
+	ResourceApplied(addr addrs.AbsResourceInstance, newValue cty.Value) (HookAction, error)
+}
+
+// ApplyValidator is an optional interface that a Hook implementation may
+// also implement in order to check each planned create or update of a
+// managed resource instance just before it is applied.
+//
+// PreApplyValidate is called with the final planned new value that OpenTofu
+// is about to send to the provider. Returning an error blocks the action,
+// which is then reported as an error for that resource instance.
+//
+// This allows enforcing policies, such as naming conventions, against the
+// concrete values that will be applied rather than against the
+// configuration. Any sensitive parts of plannedNewState remain marked as
+// sensitive.
+type ApplyValidator interface {
+	PreApplyValidate(addr addrs.AbsResourceInstance, action plans.Action, plannedNewState cty.Value) (HookAction, error)
+}
+
+// ApplyProgressListener is an optional interface that a Hook implementation
+// may also implement in order to follow the progress of an apply operation.
+//
+// ApplyProgress is called each time another of the planned resource
+// instance changes finishes, whether it succeeded or not, with an estimate
+// of how much of the apply remains. Calls are never concurrent with one
+// another, and the number of completed changes increases with each call.
+// Changes that are never attempted, for example because something they
+// depend on failed, are never counted as completed. This cannot control
+// whether the apply continues.
+type ApplyProgressListener interface {
+	ApplyProgress(estimate ApplyProgressEstimate)
+}
+
+// ForgetBatchListener is an optional interface that a Hook implementation
+// may also implement in order to handle all of the resource instances that
+// an apply forgets together, rather than one at a time.
+//
+// PreForgetBatch and PostForgetBatch are called once per apply, before and
+// after the apply walk, with the addresses of all of the resource instances
+// that the plan forgets, but only if the apply was requested with
+// ApplyOpts.BatchForgetHooks set. PostForgetBatch receives only the
+// addresses of the resource instances that were actually forgotten. Neither
+// is called if the plan forgets nothing.
+//
+// These complement, rather than replace, the StateMutationListener events
+// for each individual forgotten object. Returning an error from
+// PreForgetBatch prevents the apply from starting.
+type ForgetBatchListener interface {
+	PreForgetBatch(instances []addrs.AbsResourceInstance) (HookAction, error)
+	PostForgetBatch(instances []addrs.AbsResourceInstance) (HookAction, error)
+}
+
+// ReplaceReasonListener is an optional interface that a Hook implementation
+// may also implement in order to learn why each managed resource instance
+// is being replaced.
+//
+// PreApplyReplace is called once for each replace action, immediately
+// before the PreApply call for whichever of its delete and create
+// operations happens first. The reason and requiredReplace arguments are
+// the ActionReason and RequiredReplace recorded in the planned change.
+type ReplaceReasonListener interface {
+	PreApplyReplace(addr addrs.AbsResourceInstance, action plans.Action, reason plans.ResourceInstanceChangeActionReason, requiredReplace cty.PathSet) (HookAction, error)
+}
+
+// DeposedDestroyListener is an optional interface that a Hook implementation
+// may also implement in order to be told when deposed objects are cleaned
+// up, such as those left behind by an earlier create-before-destroy
+// replacement that failed.
+//
+// PostDestroyDeposed is called after a deposed object of a managed resource
+// instance has been successfully destroyed and removed from the state, with
+// the key of the deposed object. This is in addition to the PreApply and
+// PostApply calls for the destroy action, and confirms that the deposed
+// object no longer exists.
+type DeposedDestroyListener interface {
+	PostDestroyDeposed(addr addrs.AbsResourceInstance, key states.DeposedKey) (HookAction, error)
+}
+
+// GraphBuiltListener is an optional interface that a Hook implementation
+// may also implement in order to inspect the graph for each apply, such as
+// to log the size of the graph or to check it against some limit.
+//
+// OnGraphBuilt is called once per apply, after OpenTofu has built the graph
+// for the apply and before it begins walking it. The graph belongs to
+// OpenTofu and must not be modified. Returning an error prevents the walk
+// from starting, and so prevents the apply from making any changes.
+type GraphBuiltListener interface {
+	OnGraphBuilt(graph *Graph) (HookAction, error)
+}
+
 // NilHook is a Hook implementation that does nothing. It exists only to
 // simplify implementing hooks. You can embed this into your Hook implementation
 // and only implement the functions you are interested in.
 type NilHook struct{}
 
 var _ Hook = (*NilHook)(nil)
+var _ RunListener = (*NilHook)(nil)
+var _ ApplyGate = (*NilHook)(nil)
 
 func (*NilHook) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
 	return HookActionContinue, nil
@@ -195,3 +384,15 @@ func (*NilHook) Stopping() {
 func (*NilHook) PostStateUpdate(new *states.State) (HookAction, error) {
 	return HookActionContinue, nil
 }
+
+func (*NilHook) OnRunAcquired(phase string) {
+	// Does nothing at all by default
+}
+
+func (*NilHook) OnRunReleased(phase string) {
+	// Does nothing at all by default
+}
+
+func (*NilHook) ShouldApply(addr addrs.AbsResourceInstance, plannedValue cty.Value) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,400 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+)
+
+// FilteredHook returns a Hook that passes to the given hook only the events
+// for changes whose action is one of the given actions, such as only the
+// events for deletes.
+//
+// PreApply, PreApplyReplace, PreApplyValidate and PostDiff are passed on if
+// their action matches. PostApply and the provisioner events are passed on
+// if the action of the enclosing PreApply call for the same object matched.
+// PreDiff, PrePlanImport and PostPlanImport are held back until the PostDiff
+// call for the same object and then passed on only if its action matches.
+// During apply, the events that don't include an action, such as
+// MutatePlannedValue, ResourceApplied and the import events, are passed on if
+// the planned action for the object matches, counting a planned replace as
+// a create. StateMutation is passed on if the action of the change that
+// caused it matches. PostDestroyDeposed is passed on if the actions include
+// plans.Delete, the forget batch events if they include plans.Forget, and an
+// ApplyGate hook is consulted only if they include plans.Create.
+//
+// Events that don't belong to a single change, such as PostStateUpdate,
+// Stopping, PreRefresh and PostRefresh, are always passed on. The optional
+// hook interfaces are passed on only if the given hook implements them.
+//
+// OpenTofu applies a replacement as a separate delete and create, and so a
+// hook filtered to plans.Delete also receives the events for the delete
+// half of each replace, and likewise for plans.Create.
+func FilteredHook(actions []plans.Action, h Hook) Hook {
+	return &filteredHook{
+		actions:  slices.Clone(actions),
+		hook:     h,
+		pending:  make(map[applyProgressKey]plans.Action),
+		deferred: make(map[applyProgressKey][]func() (HookAction, error)),
+	}
+}
+
+// plannedActionsListener is implemented by internal hooks that need the
+// planned action for each object before an apply walk starts, because some
+// of the events during apply don't include it.
+type plannedActionsListener interface {
+	plannedActions(changes *plans.Changes)
+}
+
+type filteredHook struct {
+	NilHook
+
+	actions []plans.Action
+	hook    Hook
+
+	mu sync.Mutex
+
+	// pending records the action of each PreApply call until the
+	// corresponding PostApply call, which doesn't include the action.
+	pending map[applyProgressKey]plans.Action
+
+	// planned records the planned action for each object in the plan
+	// currently being applied.
+	planned map[applyProgressKey]plans.Action
+
+	// deferred holds the calls for each object that we can't filter until
+	// the PostDiff call for the same object reports its action.
+	deferred map[applyProgressKey][]func() (HookAction, error)
+}
+
+var _ Hook = (*filteredHook)(nil)
+var _ ApplyGate = (*filteredHook)(nil)
+var _ ApplyValidator = (*filteredHook)(nil)
+var _ ReplaceReasonListener = (*filteredHook)(nil)
+var _ DeposedDestroyListener = (*filteredHook)(nil)
+var _ RunListener = (*filteredHook)(nil)
+var _ StateMutationListener = (*filteredHook)(nil)
+var _ ResourceApplyListener = (*filteredHook)(nil)
+var _ PlannedValueMutator = (*filteredHook)(nil)
+var _ ApplyProgressListener = (*filteredHook)(nil)
+var _ ForgetBatchListener = (*filteredHook)(nil)
+var _ GraphBuiltListener = (*filteredHook)(nil)
+var _ HookContextReceiver = (*filteredHook)(nil)
+var _ plannedActionsListener = (*filteredHook)(nil)
+
+func (h *filteredHook) matches(action plans.Action) bool {
+	return slices.Contains(h.actions, action)
+}
+
+// applying returns the action of the PreApply call for the given object
+// that hasn't yet been followed by a PostApply call, if any.
+func (h *filteredHook) applying(addr addrs.AbsResourceInstance, gen states.Generation) (plans.Action, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	action, ok := h.pending[applyProgressKey{addr.String(), gen}]
+	return action, ok
+}
+
+// matchesPlanned returns true if the planned action for the given object in
+// the plan being applied matches, counting a planned replace as a create.
+func (h *filteredHook) matchesPlanned(addr addrs.AbsResourceInstance, gen states.Generation) bool {
+	h.mu.Lock()
+	action, ok := h.planned[applyProgressKey{addr.String(), gen}]
+	h.mu.Unlock()
+	if action.IsReplace() {
+		action = plans.Create
+	}
+	return ok && h.matches(action)
+}
+
+// matchesApplying returns true if the action of the PreApply call enclosing
+// an event for the given current object matches.
+func (h *filteredHook) matchesApplying(addr addrs.AbsResourceInstance) bool {
+	action, ok := h.applying(addr, states.CurrentGen)
+	return ok && h.matches(action)
+}
+
+// deferUntilDiff holds back the given call until the PostDiff call for the
+// given object.
+func (h *filteredHook) deferUntilDiff(addr addrs.AbsResourceInstance, gen states.Generation, call func() (HookAction, error)) (HookAction, error) {
+	key := applyProgressKey{addr.String(), gen}
+	h.mu.Lock()
+	h.deferred[key] = append(h.deferred[key], call)
+	h.mu.Unlock()
+	return HookActionContinue, nil
+}
+
+func (h *filteredHook) plannedActions(changes *plans.Changes) {
+	planned := make(map[applyProgressKey]plans.Action)
+	if changes != nil {
+		for _, rc := range changes.Resources {
+			planned[applyProgressKey{rc.Addr.String(), rc.DeposedKey.Generation()}] = rc.Action
+		}
+	}
+	h.mu.Lock()
+	h.planned = planned
+	h.mu.Unlock()
+}
+
+func (h *filteredHook) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
+	h.mu.Lock()
+	h.pending[applyProgressKey{addr.String(), gen}] = action
+	h.mu.Unlock()
+	if !h.matches(action) {
+		return HookActionContinue, nil
+	}
+	return h.hook.PreApply(addr, gen, action, priorState, plannedNewState)
+}
+
+func (h *filteredHook) PostApply(addr addrs.AbsResourceInstance, gen states.Generation, newState cty.Value, err error) (HookAction, error) {
+	key := applyProgressKey{addr.String(), gen}
+	h.mu.Lock()
+	action, ok := h.pending[key]
+	delete(h.pending, key)
+	h.mu.Unlock()
+	if !ok || !h.matches(action) {
+		return HookActionContinue, nil
+	}
+	return h.hook.PostApply(addr, gen, newState, err)
+}
+
+func (h *filteredHook) PreApplyReplace(addr addrs.AbsResourceInstance, action plans.Action, reason plans.ResourceInstanceChangeActionReason, requiredReplace cty.PathSet) (HookAction, error) {
+	l, ok := h.hook.(ReplaceReasonListener)
+	if !ok || !h.matches(action) {
+		return HookActionContinue, nil
+	}
+	return l.PreApplyReplace(addr, action, reason, requiredReplace)
+}
+
+func (h *filteredHook) PreApplyValidate(addr addrs.AbsResourceInstance, action plans.Action, plannedNewState cty.Value) (HookAction, error) {
+	v, ok := h.hook.(ApplyValidator)
+	if !ok || !h.matches(action) {
+		return HookActionContinue, nil
+	}
+	return v.PreApplyValidate(addr, action, plannedNewState)
+}
+
+func (h *filteredHook) PreDiff(addr addrs.AbsResourceInstance, gen states.Generation, priorState, proposedNewState cty.Value) (HookAction, error) {
+	return h.deferUntilDiff(addr, gen, func() (HookAction, error) {
+		return h.hook.PreDiff(addr, gen, priorState, proposedNewState)
+	})
+}
+
+func (h *filteredHook) PostDiff(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
+	key := applyProgressKey{addr.String(), gen}
+	h.mu.Lock()
+	deferred := h.deferred[key]
+	delete(h.deferred, key)
+	h.mu.Unlock()
+	if !h.matches(action) {
+		return HookActionContinue, nil
+	}
+	for _, call := range deferred {
+		if hookAction, err := call(); err != nil || hookAction != HookActionContinue {
+			return hookAction, err
+		}
+	}
+	return h.hook.PostDiff(addr, gen, action, priorState, plannedNewState)
+}
+
+func (h *filteredHook) PreProvisionInstance(addr addrs.AbsResourceInstance, state cty.Value) (HookAction, error) {
+	if !h.matchesApplying(addr) {
+		return HookActionContinue, nil
+	}
+	return h.hook.PreProvisionInstance(addr, state)
+}
+
+func (h *filteredHook) PostProvisionInstance(addr addrs.AbsResourceInstance, state cty.Value) (HookAction, error) {
+	if !h.matchesApplying(addr) {
+		return HookActionContinue, nil
+	}
+	return h.hook.PostProvisionInstance(addr, state)
+}
+
+func (h *filteredHook) PreProvisionInstanceStep(addr addrs.AbsResourceInstance, typeName string) (HookAction, error) {
+	if !h.matchesApplying(addr) {
+		return HookActionContinue, nil
+	}
+	return h.hook.PreProvisionInstanceStep(addr, typeName)
+}
+
+func (h *filteredHook) PostProvisionInstanceStep(addr addrs.AbsResourceInstance, typeName string, err error) (HookAction, error) {
+	if !h.matchesApplying(addr) {
+		return HookActionContinue, nil
+	}
+	return h.hook.PostProvisionInstanceStep(addr, typeName, err)
+}
+
+func (h *filteredHook) ProvisionOutput(addr addrs.AbsResourceInstance, typeName string, line string) {
+	if h.matchesApplying(addr) {
+		h.hook.ProvisionOutput(addr, typeName, line)
+	}
+}
+
+func (h *filteredHook) PreRefresh(addr addrs.AbsResourceInstance, gen states.Generation, priorState cty.Value) (HookAction, error) {
+	return h.hook.PreRefresh(addr, gen, priorState)
+}
+
+func (h *filteredHook) PostRefresh(addr addrs.AbsResourceInstance, gen states.Generation, priorState cty.Value, newState cty.Value) (HookAction, error) {
+	return h.hook.PostRefresh(addr, gen, priorState, newState)
+}
+
+func (h *filteredHook) PreImportState(addr addrs.AbsResourceInstance, importID string) (HookAction, error) {
+	return h.hook.PreImportState(addr, importID)
+}
+
+func (h *filteredHook) PostImportState(addr addrs.AbsResourceInstance, imported []providers.ImportedResource) (HookAction, error) {
+	return h.hook.PostImportState(addr, imported)
+}
+
+func (h *filteredHook) PrePlanImport(addr addrs.AbsResourceInstance, importID string) (HookAction, error) {
+	return h.deferUntilDiff(addr, states.CurrentGen, func() (HookAction, error) {
+		return h.hook.PrePlanImport(addr, importID)
+	})
+}
+
+func (h *filteredHook) PostPlanImport(addr addrs.AbsResourceInstance, imported []providers.ImportedResource) (HookAction, error) {
+	return h.deferUntilDiff(addr, states.CurrentGen, func() (HookAction, error) {
+		return h.hook.PostPlanImport(addr, imported)
+	})
+}
+
+func (h *filteredHook) PreApplyImport(addr addrs.AbsResourceInstance, importing plans.ImportingSrc) (HookAction, error) {
+	if !h.matchesPlanned(addr, states.CurrentGen) {
+		return HookActionContinue, nil
+	}
+	return h.hook.PreApplyImport(addr, importing)
+}
+
+func (h *filteredHook) PostApplyImport(addr addrs.AbsResourceInstance, importing plans.ImportingSrc) (HookAction, error) {
+	if !h.matchesPlanned(addr, states.CurrentGen) {
+		return HookActionContinue, nil
+	}
+	return h.hook.PostApplyImport(addr, importing)
+}
+
+func (h *filteredHook) Stopping() {
+	h.hook.Stopping()
+}
+
+func (h *filteredHook) PostStateUpdate(new *states.State) (HookAction, error) {
+	return h.hook.PostStateUpdate(new)
+}
+
+func (h *filteredHook) PostDestroyDeposed(addr addrs.AbsResourceInstance, key states.DeposedKey) (HookAction, error) {
+	l, ok := h.hook.(DeposedDestroyListener)
+	if !ok || !h.matches(plans.Delete) {
+		return HookActionContinue, nil
+	}
+	return l.PostDestroyDeposed(addr, key)
+}
+
+// ShouldApply passes the decision to the wrapped hook if it implements
+// ApplyGate and the actions include plans.Create, since ApplyGate is
+// consulted only for creates.
+func (h *filteredHook) ShouldApply(addr addrs.AbsResourceInstance, plannedValue cty.Value) (bool, error) {
+	gate, ok := h.hook.(ApplyGate)
+	if !ok || !h.matches(plans.Create) {
+		return true, nil
+	}
+	return gate.ShouldApply(addr, plannedValue)
+}
+
+func (h *filteredHook) OnRunAcquired(phase string) {
+	if l, ok := h.hook.(RunListener); ok {
+		l.OnRunAcquired(phase)
+	}
+}
+
+// OnRunReleased also discards any events still held back, because the
+// PostDiff calls they were waiting for won't come once the run is over.
+func (h *filteredHook) OnRunReleased(phase string) {
+	h.mu.Lock()
+	clear(h.deferred)
+	h.mu.Unlock()
+	if l, ok := h.hook.(RunListener); ok {
+		l.OnRunReleased(phase)
+	}
+}
+
+// StateMutation passes the change on if the wrapped hook implements
+// StateMutationListener and the action of the change that caused it
+// matches: either the enclosing PreApply call, or else the planned action,
+// as for a forget.
+func (h *filteredHook) StateMutation(addr addrs.AbsResourceInstance, gen states.Generation, old, new *states.ResourceInstanceObject) (HookAction, error) {
+	l, ok := h.hook.(StateMutationListener)
+	if !ok {
+		return HookActionContinue, nil
+	}
+	if action, applying := h.applying(addr, gen); applying {
+		if !h.matches(action) {
+			return HookActionContinue, nil
+		}
+	} else if !h.matchesPlanned(addr, gen) {
+		return HookActionContinue, nil
+	}
+	return l.StateMutation(addr, gen, old, new)
+}
+
+func (h *filteredHook) ResourceApplied(addr addrs.AbsResourceInstance, newValue cty.Value) (HookAction, error) {
+	l, ok := h.hook.(ResourceApplyListener)
+	if !ok || !h.matchesPlanned(addr, states.CurrentGen) {
+		return HookActionContinue, nil
+	}
+	return l.ResourceApplied(addr, newValue)
+}
+
+func (h *filteredHook) MutatePlannedValue(addr addrs.AbsResourceInstance, planned cty.Value) (cty.Value, error) {
+	m, ok := h.hook.(PlannedValueMutator)
+	if !ok || !h.matchesPlanned(addr, states.CurrentGen) {
+		return planned, nil
+	}
+	return m.MutatePlannedValue(addr, planned)
+}
+
+func (h *filteredHook) ApplyProgress(estimate ApplyProgressEstimate) {
+	if l, ok := h.hook.(ApplyProgressListener); ok {
+		l.ApplyProgress(estimate)
+	}
+}
+
+func (h *filteredHook) PreForgetBatch(instances []addrs.AbsResourceInstance) (HookAction, error) {
+	l, ok := h.hook.(ForgetBatchListener)
+	if !ok || !h.matches(plans.Forget) {
+		return HookActionContinue, nil
+	}
+	return l.PreForgetBatch(instances)
+}
+
+func (h *filteredHook) PostForgetBatch(instances []addrs.AbsResourceInstance) (HookAction, error) {
+	l, ok := h.hook.(ForgetBatchListener)
+	if !ok || !h.matches(plans.Forget) {
+		return HookActionContinue, nil
+	}
+	return l.PostForgetBatch(instances)
+}
+
+func (h *filteredHook) OnGraphBuilt(graph *Graph) (HookAction, error) {
+	l, ok := h.hook.(GraphBuiltListener)
+	if !ok {
+		return HookActionContinue, nil
+	}
+	return l.OnGraphBuilt(graph)
+}
+
+func (h *filteredHook) SetHookContext(values map[string]any) {
+	if r, ok := h.hook.(HookContextReceiver); ok {
+		r.SetHookContext(values)
+	}
+}
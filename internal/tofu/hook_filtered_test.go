@@ -0,0 +1,236 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+)
+
+// filteredTestHook records the PreApply and PostApply calls it receives.
+type filteredTestHook struct {
+	NilHook
+
+	mu   sync.Mutex
+	pre  []string
+	post []string
+}
+
+func (h *filteredTestHook) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pre = append(h.pre, addr.String()+" "+action.String())
+	return HookActionContinue, nil
+}
+
+func (h *filteredTestHook) PostApply(addr addrs.AbsResourceInstance, gen states.Generation, newState cty.Value, err error) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.post = append(h.post, addr.String())
+	return HookActionContinue, nil
+}
+
+func TestFilteredHook(t *testing.T) {
+	m := testModuleInline(t, map[string]string{
+		"main.tf": `
+resource "test_object" "created" {
+  test_string = "new"
+}
+
+resource "test_object" "updated" {
+  test_string = "new"
+}
+`,
+	})
+	state := states.BuildState(func(s *states.SyncState) {
+		for _, name := range []string{"updated", "deleted"} {
+			s.SetResourceInstanceCurrent(
+				mustResourceInstanceAddr("test_object."+name),
+				&states.ResourceInstanceObjectSrc{
+					Status:    states.ObjectReady,
+					AttrsJSON: []byte(`{"test_string":"old"}`),
+				},
+				mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+				addrs.NoKey,
+			)
+		}
+	})
+
+	deletes := &filteredTestHook{}
+	changes := &filteredTestHook{}
+	p := simpleMockProvider()
+	ctx := testContext2(t, &ContextOpts{
+		Hooks: []Hook{
+			FilteredHook([]plans.Action{plans.Delete}, deletes),
+			FilteredHook([]plans.Action{plans.Create, plans.Update}, changes),
+		},
+		Providers: map[addrs.Provider]providers.Factory{
+			addrs.NewDefaultProvider("test"): testProviderFuncFixed(p),
+		},
+	})
+
+	plan, diags := ctx.Plan(context.Background(), m, state, DefaultPlanOpts)
+	assertNoErrors(t, diags)
+	_, diags = ctx.Apply(context.Background(), plan, m)
+	assertNoErrors(t, diags)
+
+	if diff := cmp.Diff([]string{"test_object.deleted Delete"}, deletes.pre); diff != "" {
+		t.Errorf("wrong PreApply calls for deletes\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"test_object.deleted"}, deletes.post); diff != "" {
+		t.Errorf("wrong PostApply calls for deletes\n%s", diff)
+	}
+
+	sort.Strings(changes.pre)
+	sort.Strings(changes.post)
+	if diff := cmp.Diff([]string{"test_object.created Create", "test_object.updated Update"}, changes.pre); diff != "" {
+		t.Errorf("wrong PreApply calls for creates and updates\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"test_object.created", "test_object.updated"}, changes.post); diff != "" {
+		t.Errorf("wrong PostApply calls for creates and updates\n%s", diff)
+	}
+}
+
+func TestFilteredHook_postApplyWithoutPreApply(t *testing.T) {
+	inner := &filteredTestHook{}
+	h := FilteredHook([]plans.Action{plans.Delete}, inner)
+	addr := mustResourceInstanceAddr("test_object.a")
+
+	// A PostApply call without a matching PreApply call has no known
+	// action, and so is never passed on.
+	if _, err := h.PostApply(addr, states.CurrentGen, cty.NilVal, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.post) != 0 {
+		t.Errorf("unexpected PostApply calls: %v", inner.post)
+	}
+
+	// The action is remembered separately for each object, so a deposed
+	// object's delete doesn't affect a create of the current object.
+	deposed := states.DeposedKey("00000001")
+	_, _ = h.PreApply(addr, states.CurrentGen, plans.Create, cty.NilVal, cty.NilVal)
+	_, _ = h.PreApply(addr, deposed, plans.Delete, cty.NilVal, cty.NilVal)
+	_, _ = h.PostApply(addr, states.CurrentGen, cty.NilVal, nil)
+	_, _ = h.PostApply(addr, deposed, cty.NilVal, nil)
+	if diff := cmp.Diff([]string{"test_object.a Delete"}, inner.pre); diff != "" {
+		t.Errorf("wrong PreApply calls\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"test_object.a"}, inner.post); diff != "" {
+		t.Errorf("wrong PostApply calls\n%s", diff)
+	}
+}
+
+func TestFilteredHook_stoppingAndProvisioners(t *testing.T) {
+	creates := &MockHook{}
+	deletes := &MockHook{}
+	hooks := []Hook{
+		FilteredHook([]plans.Action{plans.Create}, creates),
+		FilteredHook([]plans.Action{plans.Delete}, deletes),
+	}
+	addr := mustResourceInstanceAddr("test_object.a")
+
+	for _, h := range hooks {
+		h.Stopping()
+		_, _ = h.PreApply(addr, states.CurrentGen, plans.Create, cty.NilVal, cty.NilVal)
+		_, _ = h.PreProvisionInstance(addr, cty.NilVal)
+		_, _ = h.PreProvisionInstanceStep(addr, "local-exec")
+		h.ProvisionOutput(addr, "local-exec", "hello")
+		_, _ = h.PostProvisionInstanceStep(addr, "local-exec", nil)
+		_, _ = h.PostProvisionInstance(addr, cty.NilVal)
+		_, _ = h.PostApply(addr, states.CurrentGen, cty.NilVal, nil)
+	}
+
+	for name, h := range map[string]*MockHook{"creates": creates, "deletes": deletes} {
+		if !h.StoppingCalled {
+			t.Errorf("Stopping was not passed on to %s", name)
+		}
+	}
+	if !creates.PreProvisionInstanceCalled || !creates.PreProvisionInstanceStepCalled || !creates.ProvisionOutputCalled || !creates.PostProvisionInstanceStepCalled || !creates.PostProvisionInstanceCalled {
+		t.Error("provisioner events for a create were not passed on")
+	}
+	if deletes.PreProvisionInstanceCalled || deletes.PreProvisionInstanceStepCalled || deletes.ProvisionOutputCalled || deletes.PostProvisionInstanceStepCalled || deletes.PostProvisionInstanceCalled {
+		t.Error("provisioner events for a create were passed on to a delete hook")
+	}
+
+	// Provisioner events outside of an apply have no known action.
+	creates.PreProvisionInstanceCalled = false
+	_, _ = hooks[0].PreProvisionInstance(addr, cty.NilVal)
+	if creates.PreProvisionInstanceCalled {
+		t.Error("provisioner event without a PreApply call was passed on")
+	}
+}
+
+func TestFilteredHook_heldUntilDiff(t *testing.T) {
+	creates := &MockHook{}
+	deletes := &MockHook{}
+	hooks := []Hook{
+		FilteredHook([]plans.Action{plans.Create}, creates),
+		FilteredHook([]plans.Action{plans.Delete}, deletes),
+	}
+	addr := mustResourceInstanceAddr("test_object.a")
+
+	for _, h := range hooks {
+		_, _ = h.PrePlanImport(addr, "abc")
+		_, _ = h.PostPlanImport(addr, nil)
+		_, _ = h.PreDiff(addr, states.CurrentGen, cty.NilVal, cty.NilVal)
+	}
+	if creates.PreDiffCalled || creates.PrePlanImportCalled {
+		t.Fatal("events were passed on before the action was known")
+	}
+	for _, h := range hooks {
+		_, _ = h.PostDiff(addr, states.CurrentGen, plans.Create, cty.NilVal, cty.NilVal)
+	}
+
+	if !creates.PrePlanImportCalled || !creates.PostPlanImportCalled || !creates.PreDiffCalled || !creates.PostDiffCalled {
+		t.Error("events for a create were not passed on")
+	}
+	if deletes.PrePlanImportCalled || deletes.PostPlanImportCalled || deletes.PreDiffCalled || deletes.PostDiffCalled {
+		t.Error("events for a create were passed on to a delete hook")
+	}
+}
+
+func TestFilteredHook_plannedActions(t *testing.T) {
+	creates := &MockHook{}
+	deletes := &MockHook{}
+	hooks := []Hook{
+		FilteredHook([]plans.Action{plans.Create}, creates),
+		FilteredHook([]plans.Action{plans.Delete}, deletes),
+	}
+	addr := mustResourceInstanceAddr("test_object.a")
+	changes := plans.NewChanges()
+	changes.SyncWrapper().AppendResourceInstanceChange(&plans.ResourceInstanceChangeSrc{
+		Addr:         addr,
+		PrevRunAddr:  addr,
+		ProviderAddr: mustProviderConfig(`provider["registry.opentofu.org/hashicorp/test"]`),
+		ChangeSrc:    plans.ChangeSrc{Action: plans.CreateThenDelete},
+	})
+
+	for _, h := range hooks {
+		h.(plannedActionsListener).plannedActions(changes)
+		_, _ = h.PreApplyImport(addr, plans.ImportingSrc{ID: "abc"})
+		_, _ = h.PostApplyImport(addr, plans.ImportingSrc{ID: "abc"})
+		_, _ = h.(ResourceApplyListener).ResourceApplied(addr, cty.NilVal)
+	}
+
+	// A planned replace counts as a create for the events that don't
+	// include an action.
+	if !creates.PreApplyImportCalled || !creates.PostApplyImportCalled || !creates.ResourceAppliedCalled {
+		t.Error("events for a planned replace were not passed on to a create hook")
+	}
+	if deletes.PreApplyImportCalled || deletes.PostApplyImportCalled || deletes.ResourceAppliedCalled {
+		t.Error("events for a planned replace were passed on to a delete hook")
+	}
+}
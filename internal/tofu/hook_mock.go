@@ -39,6 +39,28 @@ type MockHook struct {
 	PostApplyReturnError error
 	PostApplyFn          func(addrs.AbsResourceInstance, states.Generation, cty.Value, error) (HookAction, error)
 
+	ResourceAppliedCalled   bool
+	ResourceAppliedAddr     addrs.AbsResourceInstance
+	ResourceAppliedNewValue cty.Value
+	ResourceAppliedReturn   HookAction
+	ResourceAppliedError    error
+
+	PreApplyReplaceCalled          bool
+	PreApplyReplaceAddr            addrs.AbsResourceInstance
+	PreApplyReplaceAction          plans.Action
+	PreApplyReplaceReason          plans.ResourceInstanceChangeActionReason
+	PreApplyReplaceRequiredReplace cty.PathSet
+	PreApplyReplaceReturn          HookAction
+	PreApplyReplaceError           error
+
+	PreApplyValidateCalled       bool
+	PreApplyValidateAddr         addrs.AbsResourceInstance
+	PreApplyValidateAction       plans.Action
+	PreApplyValidatePlannedState cty.Value
+	PreApplyValidateReturn       HookAction
+	PreApplyValidateError        error
+	PreApplyValidateFn           func(addrs.AbsResourceInstance, plans.Action, cty.Value) (HookAction, error)
+
 	PreDiffCalled        bool
 	PreDiffAddr          addrs.AbsResourceInstance
 	PreDiffGen           states.Generation
@@ -123,15 +145,17 @@ type MockHook struct {
 	PostPlanImportReturn HookAction
 	PostPlanImportError  error
 
-	PreApplyImportCalled bool
-	PreApplyImportAddr   addrs.AbsResourceInstance
-	PreApplyImportReturn HookAction
-	PreApplyImportError  error
+	PreApplyImportCalled    bool
+	PreApplyImportAddr      addrs.AbsResourceInstance
+	PreApplyImportImporting plans.ImportingSrc
+	PreApplyImportReturn    HookAction
+	PreApplyImportError     error
 
-	PostApplyImportCalled bool
-	PostApplyImportAddr   addrs.AbsResourceInstance
-	PostApplyImportReturn HookAction
-	PostApplyImportError  error
+	PostApplyImportCalled    bool
+	PostApplyImportAddr      addrs.AbsResourceInstance
+	PostApplyImportImporting plans.ImportingSrc
+	PostApplyImportReturn    HookAction
+	PostApplyImportError     error
 
 	PreApplyForgetCalled bool
 	PreApplyForgetReturn HookAction
@@ -141,15 +165,55 @@ type MockHook struct {
 	PostApplyForgetReturn HookAction
 	PostApplyForgetError  error
 	
+	PreForgetBatchCalled bool
+	PreForgetBatchAddrs  []addrs.AbsResourceInstance
+	PreForgetBatchReturn HookAction
+	PreForgetBatchError  error
+
+	PostForgetBatchCalled bool
+	PostForgetBatchAddrs  []addrs.AbsResourceInstance
+	PostForgetBatchReturn HookAction
+	PostForgetBatchError  error
+
+	OnGraphBuiltCalled bool
+	OnGraphBuiltGraph  *Graph
+	OnGraphBuiltReturn HookAction
+	OnGraphBuiltError  error
+
+	ApplyProgressCalled    bool
+	ApplyProgressEstimates []ApplyProgressEstimate
+
 	StoppingCalled bool
 
 	PostStateUpdateCalled bool
 	PostStateUpdateState  *states.State
 	PostStateUpdateReturn HookAction
 	PostStateUpdateError  error
+
+	StateMutationCalled bool
+	StateMutationAddr   addrs.AbsResourceInstance
+	StateMutationGen    states.Generation
+	StateMutationOld    *states.ResourceInstanceObject
+	StateMutationNew    *states.ResourceInstanceObject
+	StateMutationReturn HookAction
+	StateMutationError  error
+
+	PostDestroyDeposedCalled bool
+	PostDestroyDeposedAddr   addrs.AbsResourceInstance
+	PostDestroyDeposedKey    states.DeposedKey
+	PostDestroyDeposedReturn HookAction
+	PostDestroyDeposedError  error
 }
 
 var _ Hook = (*MockHook)(nil)
+var _ StateMutationListener = (*MockHook)(nil)
+var _ ResourceApplyListener = (*MockHook)(nil)
+var _ ApplyValidator = (*MockHook)(nil)
+var _ ApplyProgressListener = (*MockHook)(nil)
+var _ ForgetBatchListener = (*MockHook)(nil)
+var _ ReplaceReasonListener = (*MockHook)(nil)
+var _ DeposedDestroyListener = (*MockHook)(nil)
+var _ GraphBuiltListener = (*MockHook)(nil)
 
 func (h *MockHook) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
 	h.Lock()
@@ -181,6 +245,44 @@ func (h *MockHook) PostApply(addr addrs.AbsResourceInstance, gen states.Generati
 	return h.PostApplyReturn, h.PostApplyReturnError
 }
 
+func (h *MockHook) ResourceApplied(addr addrs.AbsResourceInstance, newValue cty.Value) (HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.ResourceAppliedCalled = true
+	h.ResourceAppliedAddr = addr
+	h.ResourceAppliedNewValue = newValue
+	return h.ResourceAppliedReturn, h.ResourceAppliedError
+}
+
+func (h *MockHook) PreApplyReplace(addr addrs.AbsResourceInstance, action plans.Action, reason plans.ResourceInstanceChangeActionReason, requiredReplace cty.PathSet) (HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.PreApplyReplaceCalled = true
+	h.PreApplyReplaceAddr = addr
+	h.PreApplyReplaceAction = action
+	h.PreApplyReplaceReason = reason
+	h.PreApplyReplaceRequiredReplace = requiredReplace
+	return h.PreApplyReplaceReturn, h.PreApplyReplaceError
+}
+
+func (h *MockHook) PreApplyValidate(addr addrs.AbsResourceInstance, action plans.Action, plannedNewState cty.Value) (HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.PreApplyValidateCalled = true
+	h.PreApplyValidateAddr = addr
+	h.PreApplyValidateAction = action
+	h.PreApplyValidatePlannedState = plannedNewState
+
+	if h.PreApplyValidateFn != nil {
+		return h.PreApplyValidateFn(addr, action, plannedNewState)
+	}
+
+	return h.PreApplyValidateReturn, h.PreApplyValidateError
+}
+
 func (h *MockHook) PreDiff(addr addrs.AbsResourceInstance, gen states.Generation, priorState, proposedNewState cty.Value) (HookAction, error) {
 	h.Lock()
 	defer h.Unlock()
@@ -323,6 +425,7 @@ func (h *MockHook) PreApplyImport(addr addrs.AbsResourceInstance, importing plan
 
 	h.PreApplyImportCalled = true
 	h.PreApplyImportAddr = addr
+	h.PreApplyImportImporting = importing
 	return h.PreApplyImportReturn, h.PreApplyImportError
 }
 
@@ -332,6 +435,7 @@ func (h *MockHook) PostApplyImport(addr addrs.AbsResourceInstance, importing pla
 
 	h.PostApplyImportCalled = true
 	h.PostApplyImportAddr = addr
+	h.PostApplyImportImporting = importing
 	return h.PostApplyImportReturn, h.PostApplyImportError
 }
 
@@ -352,6 +456,41 @@ func (h *MockHook) PostApplyForget(_ addrs.AbsResourceInstance) (HookAction, err
 }
 
 
+func (h *MockHook) PreForgetBatch(instances []addrs.AbsResourceInstance) (HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.PreForgetBatchCalled = true
+	h.PreForgetBatchAddrs = instances
+	return h.PreForgetBatchReturn, h.PreForgetBatchError
+}
+
+func (h *MockHook) PostForgetBatch(instances []addrs.AbsResourceInstance) (HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.PostForgetBatchCalled = true
+	h.PostForgetBatchAddrs = instances
+	return h.PostForgetBatchReturn, h.PostForgetBatchError
+}
+
+func (h *MockHook) OnGraphBuilt(graph *Graph) (HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.OnGraphBuiltCalled = true
+	h.OnGraphBuiltGraph = graph
+	return h.OnGraphBuiltReturn, h.OnGraphBuiltError
+}
+
+func (h *MockHook) ApplyProgress(estimate ApplyProgressEstimate) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.ApplyProgressCalled = true
+	h.ApplyProgressEstimates = append(h.ApplyProgressEstimates, estimate)
+}
+
 func (h *MockHook) Stopping() {
 	h.Lock()
 	defer h.Unlock()
@@ -367,3 +506,25 @@ func (h *MockHook) PostStateUpdate(new *states.State) (HookAction, error) {
 	h.PostStateUpdateState = new
 	return h.PostStateUpdateReturn, h.PostStateUpdateError
 }
+
+func (h *MockHook) StateMutation(addr addrs.AbsResourceInstance, gen states.Generation, old, new *states.ResourceInstanceObject) (HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.StateMutationCalled = true
+	h.StateMutationAddr = addr
+	h.StateMutationGen = gen
+	h.StateMutationOld = old
+	h.StateMutationNew = new
+	return h.StateMutationReturn, h.StateMutationError
+}
+
+func (h *MockHook) PostDestroyDeposed(addr addrs.AbsResourceInstance, key states.DeposedKey) (HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.PostDestroyDeposedCalled = true
+	h.PostDestroyDeposedAddr = addr
+	h.PostDestroyDeposedKey = key
+	return h.PostDestroyDeposedReturn, h.PostDestroyDeposedError
+}
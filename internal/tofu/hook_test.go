@@ -53,6 +53,27 @@ func (h *testHook) PostApply(addr addrs.AbsResourceInstance, gen states.Generati
 	return HookActionContinue, nil
 }
 
+func (h *testHook) ResourceApplied(addr addrs.AbsResourceInstance, newValue cty.Value) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Calls = append(h.Calls, &testHookCall{"ResourceApplied", addr.String()})
+	return HookActionContinue, nil
+}
+
+func (h *testHook) PreApplyReplace(addr addrs.AbsResourceInstance, action plans.Action, reason plans.ResourceInstanceChangeActionReason, requiredReplace cty.PathSet) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Calls = append(h.Calls, &testHookCall{"PreApplyReplace", addr.String()})
+	return HookActionContinue, nil
+}
+
+func (h *testHook) PreApplyValidate(addr addrs.AbsResourceInstance, action plans.Action, plannedNewState cty.Value) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Calls = append(h.Calls, &testHookCall{"PreApplyValidate", addr.String()})
+	return HookActionContinue, nil
+}
+
 func (h *testHook) PreDiff(addr addrs.AbsResourceInstance, gen states.Generation, priorState, proposedNewState cty.Value) (HookAction, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -157,6 +178,33 @@ func (h *testHook) PostApplyImport(addr addrs.AbsResourceInstance, importing pla
 	return HookActionContinue, nil
 }
 
+func (h *testHook) PreForgetBatch(instances []addrs.AbsResourceInstance) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Calls = append(h.Calls, &testHookCall{"PreForgetBatch", ""})
+	return HookActionContinue, nil
+}
+
+func (h *testHook) PostForgetBatch(instances []addrs.AbsResourceInstance) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Calls = append(h.Calls, &testHookCall{"PostForgetBatch", ""})
+	return HookActionContinue, nil
+}
+
+func (h *testHook) OnGraphBuilt(graph *Graph) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Calls = append(h.Calls, &testHookCall{"OnGraphBuilt", ""})
+	return HookActionContinue, nil
+}
+
+func (h *testHook) ApplyProgress(estimate ApplyProgressEstimate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Calls = append(h.Calls, &testHookCall{"ApplyProgress", ""})
+}
+
 func (h *testHook) Stopping() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -169,3 +217,17 @@ func (h *testHook) PostStateUpdate(new *states.State) (HookAction, error) {
 	h.Calls = append(h.Calls, &testHookCall{"PostStateUpdate", ""})
 	return HookActionContinue, nil
 }
+
+func (h *testHook) StateMutation(addr addrs.AbsResourceInstance, gen states.Generation, old, new *states.ResourceInstanceObject) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Calls = append(h.Calls, &testHookCall{"StateMutation", addr.String()})
+	return HookActionContinue, nil
+}
+
+func (h *testHook) PostDestroyDeposed(addr addrs.AbsResourceInstance, key states.DeposedKey) (HookAction, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Calls = append(h.Calls, &testHookCall{"PostDestroyDeposed", addr.String()})
+	return HookActionContinue, nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/opentofu/opentofu/internal/logging"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// defaultLockCheckInterval is how often ApplyOpts.LockChecker is called if
+// ApplyOpts.LockCheckInterval is not set.
+const defaultLockCheckInterval = 10 * time.Second
+
+// lockWatcher periodically calls a lock checker function for the duration of
+// a graph walk, and interrupts the context's run as soon as the checker
+// reports that the lock has been lost.
+//
+// A nil *lockWatcher is valid and does nothing, so that callers don't need
+// to check whether the current walk has a lock checker.
+type lockWatcher struct {
+	stop chan struct{}
+	wait chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// watchLock starts calling the given checker at the given interval, or at
+// defaultLockCheckInterval if the interval is not positive, until Close is
+// called. It returns nil if checker is nil.
+func (c *Context) watchLock(checker func() error, interval time.Duration) *lockWatcher {
+	if checker == nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = defaultLockCheckInterval
+	}
+
+	w := &lockWatcher{
+		stop: make(chan struct{}),
+		wait: make(chan struct{}),
+	}
+
+	panicHandler := logging.PanicHandlerWithTraceFn()
+	go func() {
+		defer panicHandler()
+		defer close(w.wait)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+
+			if err := checker(); err != nil {
+				log.Printf("[ERROR] tofu: state lock lost, stopping: %s", err)
+				w.mu.Lock()
+				w.err = err
+				w.mu.Unlock()
+
+				c.l.Lock()
+				c.interruptRun()
+				c.l.Unlock()
+				return
+			}
+		}
+	}()
+
+	return w
+}
+
+// Close stops calling the lock checker and returns an error diagnostic if
+// the checker reported that the lock was lost.
+//
+// Close must be called before the run that the watcher might interrupt is
+// released, so that it can't interrupt a later run.
+func (w *lockWatcher) Close() tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if w == nil {
+		return diags
+	}
+
+	close(w.stop)
+	<-w.wait
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		diags = diags.Append(tfdiags.WithCause(tfdiags.Sourceless(
+			tfdiags.Error,
+			"State lock lost during apply",
+			fmt.Sprintf(
+				"OpenTofu stopped applying changes because the state lock is no longer held: %s.\n\nAnother process may now be modifying the same infrastructure. The returned state includes only the changes that completed before the lock was lost, so review it carefully before saving it.",
+				tfdiags.FormatError(w.err),
+			),
+		), w.err))
+	}
+	return diags
+}
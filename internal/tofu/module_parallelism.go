@@ -0,0 +1,88 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/dag"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// moduleSemaphores limits how many resource instance nodes in each module
+// may execute at once during a graph walk, for ApplyOpts.ModuleParallelism.
+//
+// The keys are module addresses as returned by addrs.Module.String. A nil
+// moduleSemaphores is valid and places no limits on any module.
+type moduleSemaphores map[string]Semaphore
+
+func newModuleSemaphores(limits map[string]int) moduleSemaphores {
+	if len(limits) == 0 {
+		return nil
+	}
+	ret := make(moduleSemaphores, len(limits))
+	for module, n := range limits {
+		ret[module] = NewSemaphore(n)
+	}
+	return ret
+}
+
+// For returns the semaphore that limits the module containing the given
+// node, or nil if the node isn't a resource instance node or its module
+// has no limit.
+func (s moduleSemaphores) For(v dag.Vertex) Semaphore {
+	if s == nil {
+		return nil
+	}
+	rn, ok := v.(GraphNodeResourceInstance)
+	if !ok {
+		return nil
+	}
+	return s[rn.ResourceInstanceAddr().Module.Module().String()]
+}
+
+// checkModuleParallelism returns errors for any of the given limits whose
+// module isn't in the given configuration or whose limit isn't positive.
+func checkModuleParallelism(config *configs.Config, limits map[string]int) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if len(limits) == 0 {
+		return diags
+	}
+
+	modules := make(map[string]bool)
+	config.DeepEach(func(c *configs.Config) {
+		modules[c.Path.String()] = true
+	})
+
+	names := make([]string, 0, len(limits))
+	for module := range limits {
+		names = append(names, module)
+	}
+	sort.Strings(names)
+	for _, module := range names {
+		displayName := module
+		if displayName == "" {
+			displayName = "the root module"
+		}
+		switch {
+		case !modules[module]:
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid module parallelism",
+				fmt.Sprintf("Cannot limit the parallelism of %s, because the configuration does not include that module.", displayName),
+			))
+		case limits[module] < 1:
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid module parallelism",
+				fmt.Sprintf("The parallelism limit for %s must be at least 1, but is %d.", displayName, limits[module]),
+			))
+		}
+	}
+	return diags
+}
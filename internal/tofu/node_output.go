@@ -388,6 +388,17 @@ If you do intend to export this data, annotate the output value as sensitive by
 		}
 	}
 
+	if ty, ok := ctx.OutputTypes()[n.Addr.OutputValue.Name]; ok && n.Addr.Module.IsRoot() && !n.DestroyApply && val != cty.NilVal && !diags.HasErrors() {
+		var typeDiags tfdiags.Diagnostics
+		val, typeDiags = convertOutputValue(n.Config, val, ty)
+		diags = diags.Append(typeDiags)
+		if typeDiags.HasErrors() {
+			// The working state starts with the value from the plan, which
+			// must not be kept either.
+			state.RemoveOutputValue(n.Addr)
+		}
+	}
+
 	// handling the interpolation error
 	if diags.HasErrors() {
 		if flagWarnOutputErrors {
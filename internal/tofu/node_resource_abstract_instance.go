@@ -333,6 +333,259 @@ func (n *NodeAbstractResourceInstance) preApplyHook(ctx EvalContext, change *pla
 	return nil
 }
 
+// preApplyReplaceHook tells any ReplaceReasonListener hooks about a planned
+// replace of a managed resource instance, describing why it is being
+// replaced.
+func (n *NodeAbstractResourceInstance) preApplyReplaceHook(ctx EvalContext, change *plans.ResourceInstanceChange) tfdiags.Diagnostics {
+	if n.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode || !change.Action.IsReplace() {
+		return nil
+	}
+
+	var diags tfdiags.Diagnostics
+	diags = diags.Append(ctx.Hook(func(h Hook) (HookAction, error) {
+		if l, ok := h.(ReplaceReasonListener); ok {
+			return l.PreApplyReplace(n.Addr, change.Action, change.ActionReason, change.RequiredReplace)
+		}
+		return HookActionContinue, nil
+	}))
+	return diags
+}
+
+// preApplyValidateHook passes a create or update of a managed resource
+// instance to any ApplyValidator hooks, returning an error if any of them
+// rejects the planned new value.
+func (n *NodeAbstractResourceInstance) preApplyValidateHook(ctx EvalContext, change *plans.ResourceInstanceChange) tfdiags.Diagnostics {
+	if n.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+		return nil
+	}
+	if change.Action != plans.Create && change.Action != plans.Update && !change.Action.IsReplace() {
+		return nil
+	}
+
+	var diags tfdiags.Diagnostics
+	err := ctx.Hook(func(h Hook) (HookAction, error) {
+		if v, ok := h.(ApplyValidator); ok {
+			return v.PreApplyValidate(n.Addr, change.Action, change.After)
+		}
+		return HookActionContinue, nil
+	})
+	if err == nil {
+		return diags
+	}
+	diag := &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Planned change rejected",
+		Detail:   fmt.Sprintf("The planned %s for %s was rejected before it was applied: %s.", change.Action, n.Addr, tfdiags.FormatError(err)),
+	}
+	if n.Config != nil {
+		diag.Subject = &n.Config.DeclRange
+	}
+	diags = diags.Append(diag)
+	return tfdiags.Categorize(diags, tfdiags.CategoryHook)
+}
+
+// evaluatePolicy asks the policy evaluator, if any, whether the given change
+// to a managed resource instance may be applied, returning its diagnostics.
+func (n *NodeAbstractResourceInstance) evaluatePolicy(ctx EvalContext, change *plans.ResourceInstanceChange) tfdiags.Diagnostics {
+	evaluator := ctx.PolicyEvaluator()
+	if evaluator == nil || n.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode || change.Action == plans.NoOp {
+		return nil
+	}
+
+	diags := evaluator.Evaluate(n.Addr, change.After)
+	if diags.HasErrors() {
+		log.Printf("[INFO] evaluatePolicy: policy denied the planned %s for %s", change.Action, n.Addr)
+	}
+	return diags
+}
+
+// shouldApplyHook asks any hooks that implement ApplyGate whether the given
+// planned create should go ahead, returning false if any of them decline.
+//
+// If the create is skipped, shouldApplyHook also notifies any hooks that
+// track skipped changes and returns a warning saying so.
+func (n *NodeAbstractResourceInstance) shouldApplyHook(ctx EvalContext, change *plans.ResourceInstanceChange) (bool, tfdiags.Diagnostics) {
+	if n.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode || change.Action != plans.Create {
+		return true, nil
+	}
+
+	var diags tfdiags.Diagnostics
+	apply := true
+	err := ctx.Hook(func(h Hook) (HookAction, error) {
+		gate, ok := h.(ApplyGate)
+		if !ok {
+			return HookActionContinue, nil
+		}
+		ok, err := gate.ShouldApply(n.Addr, change.After)
+		if err != nil {
+			return HookActionContinue, err
+		}
+		apply = apply && ok
+		return HookActionContinue, nil
+	})
+	if err != nil {
+		diag := &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to decide whether to create resource",
+			Detail:   fmt.Sprintf("A hook failed while deciding whether to create %s: %s.", n.Addr, tfdiags.FormatError(err)),
+		}
+		if n.Config != nil {
+			diag.Subject = &n.Config.DeclRange
+		}
+		diags = diags.Append(diag)
+		return false, tfdiags.Categorize(diags, tfdiags.CategoryHook)
+	}
+	if apply {
+		return true, diags
+	}
+
+	log.Printf("[INFO] shouldApplyHook: skipping create of %s as requested by a hook", n.Addr)
+	diags = diags.Append(ctx.Hook(func(h Hook) (HookAction, error) {
+		if l, ok := h.(applySkipListener); ok {
+			l.applySkipped(n.Addr)
+		}
+		return HookActionContinue, nil
+	}))
+	diag := &hcl.Diagnostic{
+		Severity: hcl.DiagWarning,
+		Summary:  "Resource creation skipped",
+		Detail:   fmt.Sprintf("A hook chose not to create %s during this apply, so it is not tracked in the new state. Any resources that depend on it may fail to apply.", n.Addr),
+	}
+	if n.Config != nil {
+		diag.Subject = &n.Config.DeclRange
+	}
+	diags = diags.Append(diag)
+	return false, diags
+}
+
+// mutatePlannedValueHook gives any hooks that implement PlannedValueMutator
+// the opportunity to replace the planned new value of the given create or
+// update, returning errors if any of them fail or return a value that
+// doesn't conform to the schema. It updates change.After in place.
+func (n *NodeAbstractResourceInstance) mutatePlannedValueHook(ctx EvalContext, change *plans.ResourceInstanceChange, providerSchema providers.ProviderSchema) tfdiags.Diagnostics {
+	if n.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+		return nil
+	}
+	if change.Action != plans.Create && change.Action != plans.Update && !change.Action.IsReplace() {
+		return nil
+	}
+
+	var diags tfdiags.Diagnostics
+	schema, _ := providerSchema.SchemaForResourceAddr(n.Addr.Resource.Resource)
+	if schema == nil {
+		// Should be caught during validation, so we don't bother with a pretty error here
+		return diags.Append(fmt.Errorf("provider does not support resource type %q", n.Addr.Resource.Resource.Type))
+	}
+	ty := schema.ImpliedType()
+	_, plannedMarks := change.After.UnmarkDeepWithPaths()
+
+	invalid := func(detail string, args ...any) tfdiags.Diagnostics {
+		diag := &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid mutated planned value",
+			Detail:   fmt.Sprintf("A hook failed to adjust the planned new value for %s: %s.", n.Addr, fmt.Sprintf(detail, args...)),
+		}
+		if n.Config != nil {
+			diag.Subject = &n.Config.DeclRange
+		}
+		return tfdiags.Categorize(diags.Append(diag), tfdiags.CategoryHook)
+	}
+
+	after := change.After
+	err := ctx.Hook(func(h Hook) (HookAction, error) {
+		mutator, ok := h.(PlannedValueMutator)
+		if !ok {
+			return HookActionContinue, nil
+		}
+		mutated, err := mutator.MutatePlannedValue(n.Addr, after)
+		if err != nil {
+			return HookActionContinue, err
+		}
+		after = mutated
+		return HookActionContinue, nil
+	})
+	if err != nil {
+		return invalid("%s", tfdiags.FormatError(err))
+	}
+	if after == cty.NilVal || after.IsNull() {
+		return invalid("the new value is null")
+	}
+
+	unmarked, marks := after.UnmarkDeepWithPaths()
+	if errs := unmarked.Type().TestConformance(ty); len(errs) > 0 {
+		return invalid("the new value does not conform to the schema for %s: %s", n.Addr.Resource.Resource.Type, tfdiags.FormatError(errs[0]))
+	}
+	change.After = unmarked.MarkWithPaths(append(marks, plannedMarks...))
+	return diags
+}
+
+// suppressAttributes returns a copy of the given new state where any
+// attributes listed for this resource in ApplyOpts.SuppressAttributes retain
+// their values from before the given update.
+//
+// It returns the state unchanged for any action other than an update, or if
+// there are no attributes to suppress.
+func (n *NodeAbstractResourceInstance) suppressAttributes(ctx EvalContext, change *plans.ResourceInstanceChange, state *states.ResourceInstanceObject, providerSchema providers.ProviderSchema) (*states.ResourceInstanceObject, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+	if change.Action != plans.Update || state == nil || state.Value.IsNull() {
+		return state, diags
+	}
+	paths := ctx.SuppressAttributes()[n.Addr.Resource.Resource]
+	if len(paths) == 0 {
+		return state, diags
+	}
+	schema, _ := providerSchema.SchemaForResourceAddr(n.Addr.Resource.Resource)
+	if schema == nil {
+		return state, diags
+	}
+
+	newVal, marks := state.Value.UnmarkDeepWithPaths()
+	priorVal, _ := change.Before.UnmarkDeep()
+	for _, path := range paths {
+		attr := schema.AttributeByPath(path)
+		switch {
+		case attr == nil:
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Warning,
+				"Invalid suppressed attribute",
+				fmt.Sprintf("Cannot suppress changes to %s%s, because the resource type has no such attribute.", n.Addr, tfdiags.FormatCtyPath(path)),
+			))
+			continue
+		case attr.Required:
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Warning,
+				"Cannot suppress required attribute",
+				fmt.Sprintf("Cannot suppress changes to %s%s, because the provider requires its value to match the configuration.", n.Addr, tfdiags.FormatCtyPath(path)),
+			))
+			continue
+		}
+
+		prior, err := path.Apply(priorVal)
+		if err != nil {
+			// The prior object didn't have this attribute, such as if it
+			// belongs to a nested block that didn't exist yet, and so
+			// there's no prior value to retain.
+			continue
+		}
+		log.Printf("[TRACE] suppressAttributes: retaining prior value of %s%s", n.Addr, tfdiags.FormatCtyPath(path))
+		newVal, err = cty.Transform(newVal, func(p cty.Path, v cty.Value) (cty.Value, error) {
+			if p.Equals(path) {
+				return prior, nil
+			}
+			return v, nil
+		})
+		if err != nil {
+			// Should never happen, since our callback never returns errors.
+			diags = diags.Append(err)
+			return state, diags
+		}
+	}
+
+	ret := state.DeepCopy()
+	ret.Value = newVal.MarkWithPaths(marks)
+	return ret, diags
+}
+
 // postApplyHook calls the post-Apply hook
 func (n *NodeAbstractResourceInstance) postApplyHook(ctx EvalContext, state *states.ResourceInstanceObject, err error) tfdiags.Diagnostics {
 	var diags tfdiags.Diagnostics
@@ -353,6 +606,29 @@ func (n *NodeAbstractResourceInstance) postApplyHook(ctx EvalContext, state *sta
 	return diags
 }
 
+// resourceAppliedHook reports a successful create or update of a managed
+// resource instance to any ResourceApplyListener hooks.
+func (n *NodeAbstractResourceInstance) resourceAppliedHook(ctx EvalContext, action plans.Action, state *states.ResourceInstanceObject) tfdiags.Diagnostics {
+	if n.Addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+		return nil
+	}
+	if action != plans.Create && action != plans.Update && !action.IsReplace() {
+		return nil
+	}
+	if state == nil || state.Value.IsNull() {
+		return nil
+	}
+
+	var diags tfdiags.Diagnostics
+	diags = diags.Append(ctx.Hook(func(h Hook) (HookAction, error) {
+		if l, ok := h.(ResourceApplyListener); ok {
+			return l.ResourceApplied(n.Addr, state.Value)
+		}
+		return HookActionContinue, nil
+	}))
+	return diags
+}
+
 type phaseState int
 
 const (
@@ -423,6 +699,17 @@ func (n *NodeAbstractResourceInstance) writeResourceInstanceStateImpl(ctx EvalCo
 		return fmt.Errorf("state of type %s is not applicable to the current operation; this is a bug in OpenTofu", targetState)
 	}
 
+	schema, currentVersion := providerSchema.SchemaForResourceAddr(absAddr.ContainingResource().Resource)
+
+	// Changes to the working state are also reported to any
+	// StateMutationListener hooks, so we need to capture the object we're
+	// about to replace.
+	gen := deposedKey.Generation()
+	var prevObj *states.ResourceInstanceObject
+	if targetState == workingState {
+		prevObj = workingStateObjectForHook(ctx, absAddr, gen, schema)
+	}
+
 	// In spite of the name, this function also handles the non-deposed case
 	// via the writeResourceInstanceState wrapper, by setting deposedKey to
 	// the NotDeposed value (the zero value of DeposedKey).
@@ -441,12 +728,14 @@ func (n *NodeAbstractResourceInstance) writeResourceInstanceStateImpl(ctx EvalCo
 		// No need to encode anything: we'll just write it directly.
 		write(nil)
 		log.Printf("[TRACE] %s: removing state object for %s", logFuncName, absAddr)
+		if targetState == workingState {
+			return stateMutationHook(ctx, absAddr, gen, prevObj, nil)
+		}
 		return nil
 	}
 
 	log.Printf("[TRACE] %s: writing state object for %s", logFuncName, absAddr)
 
-	schema, currentVersion := providerSchema.SchemaForResourceAddr(absAddr.ContainingResource().Resource)
 	if schema == nil {
 		// It shouldn't be possible to get this far in any real scenario
 		// without a schema, but we might end up here in contrived tests that
@@ -460,6 +749,9 @@ func (n *NodeAbstractResourceInstance) writeResourceInstanceStateImpl(ctx EvalCo
 	}
 
 	write(src)
+	if targetState == workingState {
+		return stateMutationHook(ctx, absAddr, gen, prevObj, obj)
+	}
 	return nil
 }
 
@@ -543,6 +835,7 @@ func (n *NodeAbstractResourceInstance) planDestroy(ctx EvalContext, currentState
 
 	// Allow the provider to check the destroy plan, and insert any necessary
 	// private data.
+	ctx.ProviderCallCounter().Record(n.Addr, ProviderCallPlan)
 	resp := provider.PlanResourceChange(providers.PlanResourceChangeRequest{
 		TypeName:         n.Addr.Resource.Resource.Type,
 		Config:           nullVal,
@@ -712,6 +1005,7 @@ func (n *NodeAbstractResourceInstance) refresh(ctx EvalContext, deposedKey state
 		ProviderMeta: metaConfigVal,
 	}
 
+	ctx.ProviderCallCounter().Record(n.Addr, ProviderCallRead)
 	resp := provider.ReadResource(providerReq)
 	if n.Config != nil {
 		resp.Diagnostics = resp.Diagnostics.InConfigBody(n.Config.Config, n.Addr.String())
@@ -945,6 +1239,7 @@ func (n *NodeAbstractResourceInstance) plan(
 		return nil, nil, keyData, diags
 	}
 
+	ctx.ProviderCallCounter().Record(n.Addr, ProviderCallPlan)
 	resp := provider.PlanResourceChange(providers.PlanResourceChangeRequest{
 		TypeName:         n.Addr.Resource.Resource.Type,
 		Config:           unmarkedConfigVal,
@@ -1186,6 +1481,7 @@ func (n *NodeAbstractResourceInstance) plan(
 		// create a new proposed value from the null state and the config
 		proposedNewVal = objchange.ProposedNew(schema, nullPriorVal, unmarkedConfigVal)
 
+		ctx.ProviderCallCounter().Record(n.Addr, ProviderCallPlan)
 		resp = provider.PlanResourceChange(providers.PlanResourceChangeRequest{
 			TypeName:         n.Addr.Resource.Resource.Type,
 			Config:           unmarkedConfigVal,
@@ -1291,6 +1587,7 @@ func (n *NodeAbstractResourceInstance) plan(
 		},
 		ActionReason:    actionReason,
 		RequiredReplace: reqRep,
+		IgnoreChanges:   n.plannedIgnoreChanges(),
 	}
 
 	// Update our return state
@@ -1358,6 +1655,19 @@ func (n *NodeAbstractResource) processIgnoreChanges(prior, config cty.Value, sch
 	return ret, diags
 }
 
+// plannedIgnoreChanges returns a description of the effect of the resource's
+// ignore_changes argument, for recording in a planned change, or nil if this
+// is not a managed resource.
+func (n *NodeAbstractResource) plannedIgnoreChanges() *plans.IgnoreChanges {
+	if n.Config == nil || n.Config.Managed == nil {
+		return nil
+	}
+	return &plans.IgnoreChanges{
+		All:   n.Config.Managed.IgnoreAllChanges,
+		Paths: cty.NewPathSet(traversalsToPaths(n.Config.Managed.IgnoreChanges)...),
+	}
+}
+
 // Convert the hcl.Traversal values we get form the configuration to the
 // cty.Path values we need to operate on the cty.Values
 func traversalsToPaths(traversals []hcl.Traversal) []cty.Path {
@@ -1611,11 +1921,30 @@ func (n *NodeAbstractResourceInstance) readDataSource(ctx EvalContext, configVal
 		ProviderMeta: metaConfigVal,
 	}
 	var resp providers.ReadDataSourceResponse
-	if tfp, ok := provider.(ProviderWithEncryption); ok {
-		// Special case for terraform_remote_state
-		resp = tfp.ReadDataSourceEncrypted(req, n.Addr, ctx.GetEncryption())
+	if injected, ok := ctx.DataSourceResults().GetOk(n.Addr); ok {
+		log.Printf("[TRACE] readDataSource: using the given result for %s instead of reading from provider", n.Addr)
+		var err error
+		resp.State, err = schema.CoerceValue(injected)
+		if err != nil {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid data source result",
+				fmt.Sprintf(
+					"The result given for %s does not conform to the schema of data source %q: %s.",
+					n.Addr, n.Addr.Resource.Resource.Type, tfdiags.FormatError(err),
+				),
+			))
+			return newVal, diags
+		}
 	} else {
-		resp = provider.ReadDataSource(req)
+		ctx.ProviderCallCounter().Record(n.Addr, ProviderCallRead)
+		ctx.ReadSourceRecorder().Record(n.Addr, ReadSourceProvider)
+		if tfp, ok := provider.(ProviderWithEncryption); ok {
+			// Special case for terraform_remote_state
+			resp = tfp.ReadDataSourceEncrypted(req, n.Addr, ctx.GetEncryption())
+		} else {
+			resp = provider.ReadDataSource(req)
+		}
 	}
 	diags = diags.Append(resp.Diagnostics.InConfigBody(config.Config, n.Addr.String()))
 	if diags.HasErrors() {
@@ -1708,6 +2037,9 @@ func (n *NodeAbstractResourceInstance) providerMetas(ctx EvalContext) (cty.Value
 			}
 		}
 	}
+	if seed := ctx.RandomSeed(); seed != nil && !diags.HasErrors() {
+		metaConfigVal = providerMetaWithRandomSeed(metaConfigVal, providerSchema.ProviderMeta.Block, *seed)
+	}
 	return metaConfigVal, diags
 }
 
@@ -2362,9 +2694,8 @@ func (n *NodeAbstractResourceInstance) apply(
 	change *plans.ResourceInstanceChange,
 	applyConfig *configs.Resource,
 	keyData instances.RepetitionData,
-	createBeforeDestroy bool) (*states.ResourceInstanceObject, tfdiags.Diagnostics) {
+	createBeforeDestroy bool) (_ *states.ResourceInstanceObject, diags tfdiags.Diagnostics) {
 
-	var diags tfdiags.Diagnostics
 	if state == nil {
 		state = &states.ResourceInstanceObject{}
 	}
@@ -2377,6 +2708,9 @@ func (n *NodeAbstractResourceInstance) apply(
 		return state, diags
 	}
 
+	endSpan := ctx.ApplyTracer().StartResourceSpan(n.Addr, change.DeposedKey, change.Action)
+	defer func() { endSpan(diags) }()
+
 	provider, providerSchema, err := n.getProvider(ctx)
 	if err != nil {
 		return nil, diags.Append(err)
@@ -2458,7 +2792,17 @@ func (n *NodeAbstractResourceInstance) apply(
 		return newState, diags
 	}
 
-	resp := provider.ApplyResourceChange(providers.ApplyResourceChangeRequest{
+	ctx.ProviderCallCounter().Record(n.Addr, ProviderCallApply)
+	applyCall := ProviderCall(func(_ addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+		return provider.ApplyResourceChange(req)
+	})
+	if timeout := resourceApplyTimeout(ctx, n.Addr, change.Action, configVal, change.Before); timeout > 0 {
+		applyCall = withApplyTimeout(applyCall, provider.Stop, timeout)
+	}
+	if middleware := ctx.ProviderCallMiddleware(); middleware != nil {
+		applyCall = middleware(applyCall)
+	}
+	resp := applyCall(n.Addr, providers.ApplyResourceChangeRequest{
 		TypeName:       n.Addr.Resource.Resource.Type,
 		PriorState:     unmarkedBefore,
 		Config:         unmarkedConfigVal,
@@ -176,6 +176,7 @@ func (n *NodeApplyableResourceInstance) dataResourceExecute(ctx EvalContext) (di
 	if change.Action != plans.Read && change.Action != plans.NoOp {
 		diags = diags.Append(fmt.Errorf("nonsensical planned action %#v for %s; this is a bug in OpenTofu", change.Action, n.Addr))
 	}
+	diags = diags.Append(n.checkPlannedProvider(change))
 
 	// In this particular call to applyDataSource we include our planned
 	// change, which signals that we expect this read to complete fully
@@ -196,6 +197,8 @@ func (n *NodeApplyableResourceInstance) dataResourceExecute(ctx EvalContext) (di
 		if diags.HasErrors() {
 			return diags
 		}
+	} else {
+		ctx.ReadSourceRecorder().Record(n.Addr, ReadSourceState)
 	}
 
 	diags = diags.Append(n.writeChange(ctx, nil, ""))
@@ -247,6 +250,7 @@ func (n *NodeApplyableResourceInstance) managedResourceExecute(ctx EvalContext)
 	if diffApply.Action == plans.Read {
 		diags = diags.Append(fmt.Errorf("nonsensical planned action %#v for %s; this is a bug in OpenTofu", diffApply.Action, n.Addr))
 	}
+	diags = diags.Append(n.checkPlannedProvider(diffApply))
 
 	destroy := (diffApply.Action == plans.Delete || diffApply.Action.IsReplace())
 	// Get the stored action for CBD if we have a plan already
@@ -272,6 +276,9 @@ func (n *NodeApplyableResourceInstance) managedResourceExecute(ctx EvalContext)
 	if diags.HasErrors() {
 		return diags
 	}
+	if state != nil {
+		ctx.ReadSourceRecorder().Record(n.Addr, ReadSourceState)
+	}
 
 	// Get the saved diff
 	diff, err := n.readDiff(ctx, providerSchema)
@@ -289,6 +296,7 @@ func (n *NodeApplyableResourceInstance) managedResourceExecute(ctx EvalContext)
 	}
 
 	// Compare the diffs
+	diags = diags.Append(n.checkPlannedIgnoreChanges(diff, diffApply))
 	diags = diags.Append(n.checkPlannedChange(ctx, diff, diffApply, providerSchema))
 	if diags.HasErrors() {
 		return diags
@@ -302,6 +310,42 @@ func (n *NodeApplyableResourceInstance) managedResourceExecute(ctx EvalContext)
 	// need to deal with other book-keeping such as marking the
 	// change as "complete", and running the author's postconditions.
 
+	diags = diags.Append(n.mutatePlannedValueHook(ctx, diffApply, providerSchema))
+	if diags.HasErrors() {
+		return diags
+	}
+
+	diags = diags.Append(n.preApplyValidateHook(ctx, diffApply))
+	if diags.HasErrors() {
+		return diags
+	}
+
+	diags = diags.Append(n.evaluatePolicy(ctx, diffApply))
+	if diags.HasErrors() {
+		return diags
+	}
+
+	apply, gateDiags := n.shouldApplyHook(ctx, diffApply)
+	diags = diags.Append(gateDiags)
+	if diags.HasErrors() {
+		return diags
+	}
+	if !apply {
+		// We clear out the change so that anything that refers to this
+		// instance sees that it doesn't exist, rather than a pending create.
+		return diags.Append(n.writeChange(ctx, nil, ""))
+	}
+
+	// The create operation comes first for a create_before_destroy replace,
+	// so we report the reason for the replace here. Otherwise the destroy
+	// node has already reported it.
+	if diff.Action == plans.CreateThenDelete {
+		diags = diags.Append(n.preApplyReplaceHook(ctx, diff))
+		if diags.HasErrors() {
+			return diags
+		}
+	}
+
 	diags = diags.Append(n.preApplyHook(ctx, diffApply))
 	if diags.HasErrors() {
 		return diags
@@ -315,6 +359,10 @@ func (n *NodeApplyableResourceInstance) managedResourceExecute(ctx EvalContext)
 
 	state, applyDiags := n.apply(ctx, state, diffApply, n.Config, repeatData, n.CreateBeforeDestroy())
 	diags = diags.Append(applyDiags)
+	if !applyDiags.HasErrors() {
+		state, applyDiags = n.suppressAttributes(ctx, diffApply, state, providerSchema)
+		diags = diags.Append(applyDiags)
+	}
 
 	// We clear the change out here so that future nodes don't see a change
 	// that is already complete.
@@ -382,6 +430,9 @@ func (n *NodeApplyableResourceInstance) managedResourceExecute(ctx EvalContext)
 	}
 
 	diags = diags.Append(n.postApplyHook(ctx, state, diags.Err()))
+	if !diags.HasErrors() {
+		diags = diags.Append(n.resourceAppliedHook(ctx, diffApply.Action, state))
+	}
 	diags = diags.Append(updateStateHook(ctx))
 
 	// Post-conditions might block further progress. We intentionally do this
@@ -402,6 +453,31 @@ func (n *NodeApplyableResourceInstance) managedResourcePostconditions(ctx EvalCo
 	return diags.Append(checkDiags)
 }
 
+// checkPlannedIgnoreChanges returns a warning if the resource's ignore_changes
+// argument has changed since the planned change was created, because then
+// the final planned change may ignore different attributes than the user
+// reviewed.
+//
+// This check is skipped if the planned change did not record its
+// ignore_changes, such as when it is not for a managed resource.
+func (n *NodeApplyableResourceInstance) checkPlannedIgnoreChanges(plannedChange, actualChange *plans.ResourceInstanceChange) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	if plannedChange.IgnoreChanges == nil || actualChange.IgnoreChanges.Equal(plannedChange.IgnoreChanges) {
+		return diags
+	}
+
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Warning,
+		"Resource ignore_changes changed since plan",
+		fmt.Sprintf(
+			"The ignore_changes argument for %s has changed since this plan was created, so OpenTofu will use the new setting when applying the change. The result may differ from what the plan described.",
+			n.Addr,
+		),
+	))
+	return diags
+}
+
 // checkPlannedChange produces errors if the _actual_ expected value is not
 // compatible with what was recorded in the plan.
 //
@@ -469,6 +545,43 @@ func (n *NodeApplyableResourceInstance) checkPlannedChange(ctx EvalContext, plan
 	return diags
 }
 
+// checkPlannedProvider produces a warning if the provider configuration
+// resolved for this resource instance during apply differs from the one that
+// was recorded for it in the plan.
+//
+// This can happen if the configuration was changed between plan and apply
+// in a way that reassigns the resource to a different provider
+// configuration, such as by changing the "provider" argument to refer to a
+// different alias. In that case the planned change will be sent to a
+// different provider configuration than the one that planned it, which might
+// refer to an entirely different endpoint or account.
+func (n *NodeApplyableResourceInstance) checkPlannedProvider(change *plans.ResourceInstanceChange) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	// Some older or hand-constructed plans don't record a provider address
+	// at all, in which case we have nothing to compare with.
+	if change == nil || change.ProviderAddr.Provider.IsZero() {
+		return diags
+	}
+
+	planned := change.ProviderAddr.String()
+	current := n.ResolvedProvider.ProviderConfig.String()
+	if planned == current {
+		return diags
+	}
+
+	log.Printf("[WARN] checkPlannedProvider: %s was planned with %s but is being applied with %s", n.Addr, planned, current)
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Warning,
+		"Resource provider configuration changed since plan",
+		fmt.Sprintf(
+			"The planned change for %s was created using provider configuration %s, but the current configuration associates it with %s. The change will be applied using %s, which may not refer to the same endpoint or account that the plan was created against.\n\nTo avoid unexpected results, create a new plan with the current configuration.",
+			n.Addr, planned, current, current,
+		),
+	))
+	return diags
+}
+
 // maybeTainted takes the resource addr, new value, planned change, and possible
 // error from an apply operation and return a new instance object marked as
 // tainted if it appears that a create operation has failed.
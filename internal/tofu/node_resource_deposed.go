@@ -290,6 +290,14 @@ func (n *NodeDestroyDeposedResourceInstanceObject) Execute(ctx EvalContext, op w
 	}
 
 	diags = diags.Append(n.postApplyHook(ctx, state, diags.Err()))
+	if state == nil && !diags.HasErrors() {
+		diags = diags.Append(ctx.Hook(func(h Hook) (HookAction, error) {
+			if l, ok := h.(DeposedDestroyListener); ok {
+				return l.PostDestroyDeposed(n.Addr, n.DeposedKey)
+			}
+			return HookActionContinue, nil
+		}))
+	}
 
 	return diags.Append(updateStateHook(ctx))
 }
@@ -328,9 +336,10 @@ func (n *NodeDestroyDeposedResourceInstanceObject) writeResourceInstanceState(ct
 
 	if obj == nil {
 		// No need to encode anything: we'll just write it directly.
+		prevObj := n.prevObjectForHook(ctx)
 		state.SetResourceInstanceDeposed(absAddr, key, nil, n.ResolvedProvider.ProviderConfig, n.ResolvedProviderKey)
 		log.Printf("[TRACE] writeResourceInstanceStateDeposed: removing state object for %s deposed %s", absAddr, key)
-		return nil
+		return stateMutationHook(ctx, absAddr, key, prevObj, nil)
 	}
 
 	_, providerSchema, err := getProvider(ctx, n.ResolvedProvider.ProviderConfig, n.ResolvedProviderKey)
@@ -339,6 +348,8 @@ func (n *NodeDestroyDeposedResourceInstanceObject) writeResourceInstanceState(ct
 	}
 
 	schema, currentVersion := providerSchema.SchemaForResourceAddr(absAddr.ContainingResource().Resource)
+	prevObj := workingStateObjectForHook(ctx, absAddr, key, schema)
+
 	if schema == nil {
 		// It shouldn't be possible to get this far in any real scenario
 		// without a schema, but we might end up here in contrived tests that
@@ -352,7 +363,25 @@ func (n *NodeDestroyDeposedResourceInstanceObject) writeResourceInstanceState(ct
 
 	log.Printf("[TRACE] writeResourceInstanceStateDeposed: writing state object for %s deposed %s", absAddr, key)
 	state.SetResourceInstanceDeposed(absAddr, key, src, n.ResolvedProvider.ProviderConfig, n.ResolvedProviderKey)
-	return nil
+	return stateMutationHook(ctx, absAddr, key, prevObj, obj)
+}
+
+// prevObjectForHook returns the deposed object currently in the working
+// state, for reporting its removal to any StateMutationListener hooks.
+//
+// Removing the object doesn't otherwise need the provider's schema, so this
+// looks up the provider only if there is a hook to report to.
+func (n *NodeDestroyDeposedResourceInstanceObject) prevObjectForHook(ctx EvalContext) *states.ResourceInstanceObject {
+	if !hasStateMutationListener(ctx) {
+		return nil
+	}
+	_, providerSchema, err := getProvider(ctx, n.ResolvedProvider.ProviderConfig, n.ResolvedProviderKey)
+	if err != nil {
+		log.Printf("[TRACE] prevObjectForHook: can't get schema for %s deposed %s: %s", n.Addr, n.DeposedKey, err)
+		return nil
+	}
+	schema, _ := providerSchema.SchemaForResourceAddr(n.Addr.ContainingResource().Resource)
+	return workingStateObjectForHook(ctx, n.Addr, n.DeposedKey, schema)
 }
 
 // NodeForgetDeposedResourceInstanceObject represents deposed resource
@@ -409,8 +438,11 @@ func (n *NodeForgetDeposedResourceInstanceObject) Execute(ctx EvalContext, op wa
 		log.Printf("[WARN] NodeForgetDeposedResourceInstanceObject for %s (%s) with no state", n.Addr, n.DeposedKey)
 	}
 
+	archiveForgottenObjects(ctx, n.Addr, n.DeposedKey)
+
 	contextState := ctx.State()
 	contextState.ForgetResourceInstanceDeposed(n.Addr, n.DeposedKey)
 
+	diags = diags.Append(forgetHook(ctx, n.Addr, n.DeposedKey, state))
 	return diags.Append(updateStateHook(ctx))
 }
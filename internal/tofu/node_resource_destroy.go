@@ -180,6 +180,7 @@ func (n *NodeDestroyResourceInstance) managedResourceExecute(ctx EvalContext) (d
 		return diags
 	}
 
+	planned := changeApply
 	changeApply = reducePlan(addr.Resource, changeApply, true)
 	// reducePlan may have simplified our planned change
 	// into a NoOp if it does not require destroying.
@@ -197,6 +198,21 @@ func (n *NodeDestroyResourceInstance) managedResourceExecute(ctx EvalContext) (d
 	if state == nil || state.Value.IsNull() {
 		return diags
 	}
+	ctx.ReadSourceRecorder().Record(addr, ReadSourceState)
+
+	diags = diags.Append(n.evaluatePolicy(ctx, changeApply))
+	if diags.HasErrors() {
+		return diags
+	}
+
+	// The destroy operation comes first for a destroy-then-create replace,
+	// so we report the reason for the replace here.
+	if planned.Action == plans.DeleteThenCreate {
+		diags = diags.Append(n.preApplyReplaceHook(ctx, planned))
+		if diags.HasErrors() {
+			return diags
+		}
+	}
 
 	diags = diags.Append(n.preApplyHook(ctx, changeApply))
 	if diags.HasErrors() {
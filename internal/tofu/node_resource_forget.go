@@ -9,9 +9,9 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/opentofu/opentofu/internal/tfdiags"
-
+	"github.com/opentofu/opentofu/internal/addrs"
 	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
 )
 
 // NodeForgetResourceInstance represents a resource instance that is to be
@@ -66,10 +66,54 @@ func (n *NodeForgetResourceInstance) Execute(ctx EvalContext, op walkOperation)
 		return diags
 	}
 
+	archiveForgottenObjects(ctx, n.Addr, states.NotDeposed)
+
 	contextState := ctx.State()
 	contextState.ForgetResourceInstanceAll(n.Addr)
 
+	diags = diags.Append(forgetHook(ctx, n.Addr, states.CurrentGen, state))
 	diags = diags.Append(updateStateHook(ctx))
 
 	return diags
 }
+
+// archiveForgottenObjects copies the objects that are about to be forgotten
+// for the given resource instance into the forget archive, if the caller
+// of the apply operation asked for one.
+//
+// If deposedKey is states.NotDeposed then this archives the current object
+// and all of the deposed objects of the instance, because forgetting an
+// instance forgets all of its objects. Otherwise it archives only the
+// deposed object with the given key.
+func archiveForgottenObjects(ctx EvalContext, addr addrs.AbsResourceInstance, deposedKey states.DeposedKey) {
+	archive := ctx.ForgetArchive()
+	if archive == nil {
+		return
+	}
+
+	rs := ctx.State().Resource(addr.ContainingResource())
+	if rs == nil {
+		return
+	}
+	is := rs.Instance(addr.Resource.Key)
+	if is == nil {
+		return
+	}
+
+	if deposedKey != states.NotDeposed {
+		if obj := is.Deposed[deposedKey]; obj != nil {
+			log.Printf("[TRACE] archiveForgottenObjects: archiving %s deposed object %s", addr, deposedKey)
+			archive.SetResourceInstanceDeposed(addr, deposedKey, obj, rs.ProviderConfig, is.ProviderKey)
+		}
+		return
+	}
+
+	if is.Current != nil {
+		log.Printf("[TRACE] archiveForgottenObjects: archiving %s current object", addr)
+		archive.SetResourceInstanceCurrent(addr, is.Current, rs.ProviderConfig, is.ProviderKey)
+	}
+	for dk, obj := range is.Deposed {
+		log.Printf("[TRACE] archiveForgottenObjects: archiving %s deposed object %s", addr, dk)
+		archive.SetResourceInstanceDeposed(addr, dk, obj, rs.ProviderConfig, is.ProviderKey)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// checkOutputTypesDeclared returns an error for each of the given type
+// constraints for ApplyOpts.OutputTypes whose name isn't declared as an
+// output value by the root module of the given configuration.
+func checkOutputTypesDeclared(config *configs.Config, types map[string]cty.Type) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if len(types) == 0 {
+		return diags
+	}
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := config.Module.Outputs[name]; !ok {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Undeclared output value",
+				fmt.Sprintf("Cannot check the type of output %q, because the root module does not declare an output value with that name.", name),
+			))
+		}
+	}
+	return diags
+}
+
+// convertOutputValue converts the given value of the given output value to
+// the given type constraint from ApplyOpts.OutputTypes, returning an error
+// if it doesn't conform.
+func convertOutputValue(config *configs.Output, val cty.Value, ty cty.Type) (cty.Value, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	ret, err := convert.Convert(val, ty)
+	if err != nil {
+		var subject *hcl.Range
+		if config.Expr != nil {
+			subject = config.Expr.Range().Ptr()
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid output value type",
+			Detail:   fmt.Sprintf("The value of output %q must be %s: %s.", config.Name, ty.FriendlyNameForConstraint(), tfdiags.FormatError(err)),
+			Subject:  subject,
+		})
+		return val, diags
+	}
+	return ret, diags
+}
@@ -0,0 +1,255 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/states"
+)
+
+// perResourceHooks is a Hook used internally during the apply walk to
+// implement ApplyOpts.PerResourceHooks, by passing each event that concerns
+// a single resource instance to the hook registered for that instance, if
+// any.
+//
+// Events that don't concern a single resource instance, such as
+// PostStateUpdate, are not passed to any of the registered hooks, except
+// for Stopping and the hook context, which all of them receive.
+type perResourceHooks struct {
+	NilHook
+
+	hooks addrs.Map[addrs.AbsResourceInstance, Hook]
+}
+
+var _ Hook = (*perResourceHooks)(nil)
+var _ ApplyGate = (*perResourceHooks)(nil)
+var _ PlannedValueMutator = (*perResourceHooks)(nil)
+var _ ResourceApplyListener = (*perResourceHooks)(nil)
+var _ ApplyValidator = (*perResourceHooks)(nil)
+var _ ReplaceReasonListener = (*perResourceHooks)(nil)
+var _ DeposedDestroyListener = (*perResourceHooks)(nil)
+var _ StateMutationListener = (*perResourceHooks)(nil)
+var _ HookContextReceiver = (*perResourceHooks)(nil)
+
+func newPerResourceHooks(hooks addrs.Map[addrs.AbsResourceInstance, Hook]) *perResourceHooks {
+	if hooks.Len() == 0 {
+		return nil
+	}
+	return &perResourceHooks{hooks: hooks}
+}
+
+// call runs the given function with the hook registered for the given
+// resource instance, if there is one.
+func (h *perResourceHooks) call(addr addrs.AbsResourceInstance, f func(Hook) (HookAction, error)) (HookAction, error) {
+	hook, ok := h.hooks.GetOk(addr)
+	if !ok || hook == nil {
+		return HookActionContinue, nil
+	}
+	return f(hook)
+}
+
+// registered returns each of the registered hooks once, even if it is
+// registered for more than one resource instance.
+func (h *perResourceHooks) registered() []Hook {
+	var ret []Hook
+	for _, hook := range h.hooks.Values() {
+		if hook == nil {
+			continue
+		}
+		// Comparing interface values panics if their dynamic type isn't
+		// comparable, so we can only skip duplicates of those that are.
+		if reflect.TypeOf(hook).Comparable() && slices.Contains(ret, hook) {
+			continue
+		}
+		ret = append(ret, hook)
+	}
+	return ret
+}
+
+func (h *perResourceHooks) Stopping() {
+	for _, hook := range h.registered() {
+		hook.Stopping()
+	}
+}
+
+func (h *perResourceHooks) SetHookContext(values map[string]any) {
+	propagateHookContext(h.registered(), values)
+}
+
+func (h *perResourceHooks) PreApply(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PreApply(addr, gen, action, priorState, plannedNewState)
+	})
+}
+
+func (h *perResourceHooks) PostApply(addr addrs.AbsResourceInstance, gen states.Generation, newState cty.Value, err error) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PostApply(addr, gen, newState, err)
+	})
+}
+
+func (h *perResourceHooks) ResourceApplied(addr addrs.AbsResourceInstance, newValue cty.Value) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		if l, ok := hook.(ResourceApplyListener); ok {
+			return l.ResourceApplied(addr, newValue)
+		}
+		return HookActionContinue, nil
+	})
+}
+
+func (h *perResourceHooks) PreApplyReplace(addr addrs.AbsResourceInstance, action plans.Action, reason plans.ResourceInstanceChangeActionReason, requiredReplace cty.PathSet) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		if l, ok := hook.(ReplaceReasonListener); ok {
+			return l.PreApplyReplace(addr, action, reason, requiredReplace)
+		}
+		return HookActionContinue, nil
+	})
+}
+
+func (h *perResourceHooks) PreApplyValidate(addr addrs.AbsResourceInstance, action plans.Action, plannedNewState cty.Value) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		if v, ok := hook.(ApplyValidator); ok {
+			return v.PreApplyValidate(addr, action, plannedNewState)
+		}
+		return HookActionContinue, nil
+	})
+}
+
+func (h *perResourceHooks) PreDiff(addr addrs.AbsResourceInstance, gen states.Generation, priorState, proposedNewState cty.Value) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PreDiff(addr, gen, priorState, proposedNewState)
+	})
+}
+
+func (h *perResourceHooks) PostDiff(addr addrs.AbsResourceInstance, gen states.Generation, action plans.Action, priorState, plannedNewState cty.Value) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PostDiff(addr, gen, action, priorState, plannedNewState)
+	})
+}
+
+func (h *perResourceHooks) PreProvisionInstance(addr addrs.AbsResourceInstance, state cty.Value) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PreProvisionInstance(addr, state)
+	})
+}
+
+func (h *perResourceHooks) PostProvisionInstance(addr addrs.AbsResourceInstance, state cty.Value) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PostProvisionInstance(addr, state)
+	})
+}
+
+func (h *perResourceHooks) PreProvisionInstanceStep(addr addrs.AbsResourceInstance, typeName string) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PreProvisionInstanceStep(addr, typeName)
+	})
+}
+
+func (h *perResourceHooks) PostProvisionInstanceStep(addr addrs.AbsResourceInstance, typeName string, err error) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PostProvisionInstanceStep(addr, typeName, err)
+	})
+}
+
+func (h *perResourceHooks) ProvisionOutput(addr addrs.AbsResourceInstance, typeName string, line string) {
+	if hook, ok := h.hooks.GetOk(addr); ok && hook != nil {
+		hook.ProvisionOutput(addr, typeName, line)
+	}
+}
+
+func (h *perResourceHooks) PreRefresh(addr addrs.AbsResourceInstance, gen states.Generation, priorState cty.Value) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PreRefresh(addr, gen, priorState)
+	})
+}
+
+func (h *perResourceHooks) PostRefresh(addr addrs.AbsResourceInstance, gen states.Generation, priorState cty.Value, newState cty.Value) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PostRefresh(addr, gen, priorState, newState)
+	})
+}
+
+func (h *perResourceHooks) PreImportState(addr addrs.AbsResourceInstance, importID string) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PreImportState(addr, importID)
+	})
+}
+
+func (h *perResourceHooks) PostImportState(addr addrs.AbsResourceInstance, imported []providers.ImportedResource) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PostImportState(addr, imported)
+	})
+}
+
+func (h *perResourceHooks) PrePlanImport(addr addrs.AbsResourceInstance, importID string) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PrePlanImport(addr, importID)
+	})
+}
+
+func (h *perResourceHooks) PostPlanImport(addr addrs.AbsResourceInstance, imported []providers.ImportedResource) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PostPlanImport(addr, imported)
+	})
+}
+
+func (h *perResourceHooks) PreApplyImport(addr addrs.AbsResourceInstance, importing plans.ImportingSrc) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PreApplyImport(addr, importing)
+	})
+}
+
+func (h *perResourceHooks) PostApplyImport(addr addrs.AbsResourceInstance, importing plans.ImportingSrc) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		return hook.PostApplyImport(addr, importing)
+	})
+}
+
+func (h *perResourceHooks) PostDestroyDeposed(addr addrs.AbsResourceInstance, key states.DeposedKey) (HookAction, error) {
+	return h.call(addr, func(hook Hook) (HookAction, error) {
+		if l, ok := hook.(DeposedDestroyListener); ok {
+			return l.PostDestroyDeposed(addr, key)
+		}
+		return HookActionContinue, nil
+	})
+}
+
+// ShouldApply passes the decision to the hook registered for the given
+// resource instance, if it implements ApplyGate.
+func (h *perResourceHooks) ShouldApply(addr addrs.AbsResourceInstance, plannedValue cty.Value) (bool, error) {
+	gate, ok := h.hooks.Get(addr).(ApplyGate)
+	if !ok {
+		return true, nil
+	}
+	return gate.ShouldApply(addr, plannedValue)
+}
+
+// StateMutation passes the change to the hook registered for the given
+// resource instance, if it implements StateMutationListener.
+func (h *perResourceHooks) StateMutation(addr addrs.AbsResourceInstance, gen states.Generation, old, new *states.ResourceInstanceObject) (HookAction, error) {
+	listener, ok := h.hooks.Get(addr).(StateMutationListener)
+	if !ok {
+		return HookActionContinue, nil
+	}
+	return listener.StateMutation(addr, gen, old, new)
+}
+
+// MutatePlannedValue passes the planned value to the hook registered for
+// the given resource instance, if it implements PlannedValueMutator.
+func (h *perResourceHooks) MutatePlannedValue(addr addrs.AbsResourceInstance, planned cty.Value) (cty.Value, error) {
+	mutator, ok := h.hooks.Get(addr).(PlannedValueMutator)
+	if !ok {
+		return planned, nil
+	}
+	return mutator.MutatePlannedValue(addr, planned)
+}
@@ -0,0 +1,109 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"sync"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/providers"
+)
+
+// ProviderCall represents a single call to a provider's ApplyResourceChange
+// operation on behalf of the resource instance with the given address.
+type ProviderCall func(addr addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse
+
+// ProviderCallMiddleware wraps a ProviderCall to produce another ProviderCall
+// with additional behavior.
+//
+// A middleware will typically perform some additional work before and/or
+// after delegating to next, but it may also return a response of its own
+// without calling next at all.
+type ProviderCallMiddleware func(next ProviderCall) ProviderCall
+
+// ProviderCallKind represents a category of provider operation that can
+// be made on behalf of a specific resource instance.
+type ProviderCallKind int
+
+const (
+	// ProviderCallPlan represents a call to PlanResourceChange.
+	ProviderCallPlan ProviderCallKind = iota
+
+	// ProviderCallRead represents a call to either ReadResource or
+	// ReadDataSource.
+	ProviderCallRead
+
+	// ProviderCallApply represents a call to ApplyResourceChange.
+	ProviderCallApply
+)
+
+// ProviderCallCounts summarizes how many times OpenTofu called each kind
+// of provider operation on behalf of a single resource instance.
+type ProviderCallCounts struct {
+	Plan  int
+	Read  int
+	Apply int
+}
+
+// Total returns the total number of provider calls of all kinds.
+func (c ProviderCallCounts) Total() int {
+	return c.Plan + c.Read + c.Apply
+}
+
+// providerCallCounter tracks the number of provider calls made on behalf of
+// each resource instance during a graph walk.
+//
+// A nil *providerCallCounter is valid and silently discards all records,
+// so that callers don't need to check whether the current walk is
+// interested in provider call counts.
+type providerCallCounter struct {
+	mu     sync.Mutex
+	counts addrs.Map[addrs.AbsResourceInstance, ProviderCallCounts]
+}
+
+func newProviderCallCounter() *providerCallCounter {
+	return &providerCallCounter{
+		counts: addrs.MakeMap[addrs.AbsResourceInstance, ProviderCallCounts](),
+	}
+}
+
+// Record notes that a single provider call of the given kind has been made
+// on behalf of the given resource instance.
+func (c *providerCallCounter) Record(addr addrs.AbsResourceInstance, kind ProviderCallKind) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := c.counts.Get(addr)
+	switch kind {
+	case ProviderCallPlan:
+		counts.Plan++
+	case ProviderCallRead:
+		counts.Read++
+	case ProviderCallApply:
+		counts.Apply++
+	}
+	c.counts.Put(addr, counts)
+}
+
+// Counts returns a snapshot of the call counts recorded so far.
+func (c *providerCallCounter) Counts() addrs.Map[addrs.AbsResourceInstance, ProviderCallCounts] {
+	ret := addrs.MakeMap[addrs.AbsResourceInstance, ProviderCallCounts]()
+	if c == nil {
+		return ret
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, elem := range c.counts.Elems {
+		ret.PutElement(elem)
+	}
+	return ret
+}
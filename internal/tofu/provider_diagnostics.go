@@ -0,0 +1,142 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+var _ providers.Interface = (*categorizingProvider)(nil)
+var _ ProviderWithEncryption = (*categorizingProvider)(nil)
+
+// categorizingProvider is a wrapper around a provider which classifies all
+// of the diagnostics that the provider returns as tfdiags.CategoryProvider,
+// so that callers can distinguish them from the diagnostics that OpenTofu
+// itself produces for the same operations.
+type categorizingProvider struct {
+	// providers.Interface is not embedded to make it safer to extend
+	// the interface without silently breaking categorizingProvider
+	// functionality.
+	internal providers.Interface
+}
+
+func newCategorizingProvider(internal providers.Interface) *categorizingProvider {
+	return &categorizingProvider{internal: internal}
+}
+
+func categorizeProviderDiags(diags tfdiags.Diagnostics) tfdiags.Diagnostics {
+	return tfdiags.Categorize(diags, tfdiags.CategoryProvider)
+}
+
+func (p *categorizingProvider) GetProviderSchema() providers.GetProviderSchemaResponse {
+	resp := p.internal.GetProviderSchema()
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) ValidateProviderConfig(r providers.ValidateProviderConfigRequest) providers.ValidateProviderConfigResponse {
+	resp := p.internal.ValidateProviderConfig(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) ValidateResourceConfig(r providers.ValidateResourceConfigRequest) providers.ValidateResourceConfigResponse {
+	resp := p.internal.ValidateResourceConfig(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) ValidateDataResourceConfig(r providers.ValidateDataResourceConfigRequest) providers.ValidateDataResourceConfigResponse {
+	resp := p.internal.ValidateDataResourceConfig(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) UpgradeResourceState(r providers.UpgradeResourceStateRequest) providers.UpgradeResourceStateResponse {
+	resp := p.internal.UpgradeResourceState(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) ConfigureProvider(r providers.ConfigureProviderRequest) providers.ConfigureProviderResponse {
+	resp := p.internal.ConfigureProvider(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) ReadResource(r providers.ReadResourceRequest) providers.ReadResourceResponse {
+	resp := p.internal.ReadResource(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) PlanResourceChange(r providers.PlanResourceChangeRequest) providers.PlanResourceChangeResponse {
+	resp := p.internal.PlanResourceChange(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) ApplyResourceChange(r providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+	resp := p.internal.ApplyResourceChange(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) ImportResourceState(r providers.ImportResourceStateRequest) providers.ImportResourceStateResponse {
+	resp := p.internal.ImportResourceState(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) ReadDataSource(r providers.ReadDataSourceRequest) providers.ReadDataSourceResponse {
+	resp := p.internal.ReadDataSource(r)
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) ReadDataSourceEncrypted(r providers.ReadDataSourceRequest, path addrs.AbsResourceInstance, enc encryption.Encryption) providers.ReadDataSourceResponse {
+	var resp providers.ReadDataSourceResponse
+	if tfp, ok := p.internal.(ProviderWithEncryption); ok {
+		resp = tfp.ReadDataSourceEncrypted(r, path, enc)
+	} else {
+		resp = p.internal.ReadDataSource(r)
+	}
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) GetFunctions() providers.GetFunctionsResponse {
+	resp := p.internal.GetFunctions()
+	resp.Diagnostics = categorizeProviderDiags(resp.Diagnostics)
+	return resp
+}
+
+func (p *categorizingProvider) CallFunction(r providers.CallFunctionRequest) providers.CallFunctionResponse {
+	return p.internal.CallFunction(r)
+}
+
+func (p *categorizingProvider) Stop() error {
+	return p.internal.Stop()
+}
+
+func (p *categorizingProvider) Close() error {
+	return p.internal.Close()
+}
+
+// providerWarnings returns the warnings from the given diagnostics that
+// were classified as returned by a provider.
+func providerWarnings(diags tfdiags.Diagnostics) tfdiags.Diagnostics {
+	var ret tfdiags.Diagnostics
+	for _, diag := range diags {
+		if diag.Severity() == tfdiags.Warning && tfdiags.DiagnosticCategory(diag) == tfdiags.CategoryProvider {
+			ret = append(ret, diag)
+		}
+	}
+	return ret
+}
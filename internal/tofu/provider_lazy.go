@@ -0,0 +1,176 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+var _ providers.Interface = (*lazyConfiguredProvider)(nil)
+var _ ProviderWithEncryption = (*lazyConfiguredProvider)(nil)
+
+// lazyConfiguredProvider is a wrapper around a provider which defers the
+// call to ConfigureProvider until the first operation that actually requires
+// a configured provider, so that provider instances that are never used
+// are never configured at all.
+//
+// The wrapped provider's ConfigureProvider is called at most once. If it
+// fails then the first operation that triggered it returns the provider's
+// own diagnostics, and all later operations return a short error referring
+// back to that failure.
+type lazyConfiguredProvider struct {
+	// providers.Interface is not embedded to make it safer to extend
+	// the interface without silently breaking lazyConfiguredProvider
+	// functionality.
+	internal providers.Interface
+	addr     string
+
+	mu         sync.Mutex
+	req        *providers.ConfigureProviderRequest
+	configured bool
+	failed     bool
+}
+
+func newLazyConfiguredProvider(internal providers.Interface, addr addrs.AbsProviderConfig, key addrs.InstanceKey) *lazyConfiguredProvider {
+	return &lazyConfiguredProvider{
+		internal: internal,
+		addr:     addr.InstanceString(key),
+	}
+}
+
+// ConfigureProvider records the given request so that it can be sent to the
+// wrapped provider when it's first needed, and always succeeds.
+func (p *lazyConfiguredProvider) ConfigureProvider(req providers.ConfigureProviderRequest) providers.ConfigureProviderResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.req = &req
+	return providers.ConfigureProviderResponse{}
+}
+
+// ensureConfigured configures the wrapped provider using the most recently
+// recorded configuration, if that hasn't been done already.
+func (p *lazyConfiguredProvider) ensureConfigured() tfdiags.Diagnostics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var diags tfdiags.Diagnostics
+	switch {
+	case p.failed:
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Provider configuration failed",
+			fmt.Sprintf("Cannot use %s because its configuration failed earlier in this operation.", p.addr),
+		))
+		return diags
+	case p.configured || p.req == nil:
+		// If we've not been given a configuration then we just pass
+		// through, so that the provider can report that it isn't
+		// configured in its usual way.
+		return diags
+	}
+
+	resp := p.internal.ConfigureProvider(*p.req)
+	diags = diags.Append(resp.Diagnostics)
+	if diags.HasErrors() {
+		p.failed = true
+		return diags
+	}
+	p.configured = true
+	return diags
+}
+
+func (p *lazyConfiguredProvider) ReadResource(r providers.ReadResourceRequest) providers.ReadResourceResponse {
+	if diags := p.ensureConfigured(); diags.HasErrors() {
+		return providers.ReadResourceResponse{NewState: r.PriorState, Diagnostics: diags}
+	}
+	return p.internal.ReadResource(r)
+}
+
+func (p *lazyConfiguredProvider) PlanResourceChange(r providers.PlanResourceChangeRequest) providers.PlanResourceChangeResponse {
+	if diags := p.ensureConfigured(); diags.HasErrors() {
+		return providers.PlanResourceChangeResponse{Diagnostics: diags}
+	}
+	return p.internal.PlanResourceChange(r)
+}
+
+func (p *lazyConfiguredProvider) ApplyResourceChange(r providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+	if diags := p.ensureConfigured(); diags.HasErrors() {
+		return providers.ApplyResourceChangeResponse{NewState: r.PriorState, Diagnostics: diags}
+	}
+	return p.internal.ApplyResourceChange(r)
+}
+
+func (p *lazyConfiguredProvider) ImportResourceState(r providers.ImportResourceStateRequest) providers.ImportResourceStateResponse {
+	if diags := p.ensureConfigured(); diags.HasErrors() {
+		return providers.ImportResourceStateResponse{Diagnostics: diags}
+	}
+	return p.internal.ImportResourceState(r)
+}
+
+func (p *lazyConfiguredProvider) ReadDataSource(r providers.ReadDataSourceRequest) providers.ReadDataSourceResponse {
+	if diags := p.ensureConfigured(); diags.HasErrors() {
+		return providers.ReadDataSourceResponse{Diagnostics: diags}
+	}
+	return p.internal.ReadDataSource(r)
+}
+
+func (p *lazyConfiguredProvider) ReadDataSourceEncrypted(r providers.ReadDataSourceRequest, path addrs.AbsResourceInstance, enc encryption.Encryption) providers.ReadDataSourceResponse {
+	if diags := p.ensureConfigured(); diags.HasErrors() {
+		return providers.ReadDataSourceResponse{Diagnostics: diags}
+	}
+	if tfp, ok := p.internal.(ProviderWithEncryption); ok {
+		return tfp.ReadDataSourceEncrypted(r, path, enc)
+	}
+	return p.internal.ReadDataSource(r)
+}
+
+func (p *lazyConfiguredProvider) CallFunction(r providers.CallFunctionRequest) providers.CallFunctionResponse {
+	if diags := p.ensureConfigured(); diags.HasErrors() {
+		return providers.CallFunctionResponse{Error: diags.Err()}
+	}
+	return p.internal.CallFunction(r)
+}
+
+func (p *lazyConfiguredProvider) GetProviderSchema() providers.GetProviderSchemaResponse {
+	return p.internal.GetProviderSchema()
+}
+
+func (p *lazyConfiguredProvider) ValidateProviderConfig(r providers.ValidateProviderConfigRequest) providers.ValidateProviderConfigResponse {
+	return p.internal.ValidateProviderConfig(r)
+}
+
+func (p *lazyConfiguredProvider) ValidateResourceConfig(r providers.ValidateResourceConfigRequest) providers.ValidateResourceConfigResponse {
+	return p.internal.ValidateResourceConfig(r)
+}
+
+func (p *lazyConfiguredProvider) ValidateDataResourceConfig(r providers.ValidateDataResourceConfigRequest) providers.ValidateDataResourceConfigResponse {
+	return p.internal.ValidateDataResourceConfig(r)
+}
+
+func (p *lazyConfiguredProvider) UpgradeResourceState(r providers.UpgradeResourceStateRequest) providers.UpgradeResourceStateResponse {
+	if diags := p.ensureConfigured(); diags.HasErrors() {
+		return providers.UpgradeResourceStateResponse{Diagnostics: diags}
+	}
+	return p.internal.UpgradeResourceState(r)
+}
+
+func (p *lazyConfiguredProvider) GetFunctions() providers.GetFunctionsResponse {
+	return p.internal.GetFunctions()
+}
+
+func (p *lazyConfiguredProvider) Stop() error {
+	return p.internal.Stop()
+}
+
+func (p *lazyConfiguredProvider) Close() error {
+	return p.internal.Close()
+}
@@ -0,0 +1,47 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/configs/configschema"
+)
+
+// randomSeedProviderMetaAttr is the name of the provider meta attribute
+// through which ApplyOpts.RandomSeed is offered to providers.
+const randomSeedProviderMetaAttr = "random_seed"
+
+// providerMetaWithRandomSeed returns the given provider meta value, which
+// conforms to the given provider meta schema, with its random seed attribute
+// set to the given seed.
+//
+// If the schema doesn't declare a number attribute for the seed then the
+// provider doesn't support seeding and the value is returned unchanged. A
+// null value, for a resource with no provider_meta block, is replaced with
+// an object whose other attributes are null.
+func providerMetaWithRandomSeed(val cty.Value, schema *configschema.Block, seed int64) cty.Value {
+	if schema == nil {
+		return val
+	}
+	attrS, ok := schema.Attributes[randomSeedProviderMetaAttr]
+	if !ok || !attrS.Type.Equals(cty.Number) {
+		return val
+	}
+	if !val.IsKnown() {
+		return val
+	}
+	if val.IsNull() {
+		val = schema.EmptyValue()
+	}
+
+	attrs := val.AsValueMap()
+	if attrs == nil {
+		attrs = make(map[string]cty.Value)
+	}
+	attrs[randomSeedProviderMetaAttr] = cty.NumberIntVal(seed)
+	return cty.ObjectVal(attrs)
+}
@@ -0,0 +1,73 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"sync"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+)
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type ReadSource
+
+// ReadSource represents where OpenTofu got the current object of a resource
+// instance from while applying a change to it.
+type ReadSource int
+
+const (
+	// ReadSourceState means that the object came from the stored state, as
+	// it was when the plan was created.
+	ReadSourceState ReadSource = iota
+
+	// ReadSourceProvider means that the object came from a fresh read from
+	// the provider.
+	ReadSourceProvider
+)
+
+// readSourceRecorder tracks where the current object of each resource
+// instance came from during a graph walk.
+//
+// A nil *readSourceRecorder is valid and silently discards all records,
+// so that callers don't need to check whether the current walk is
+// interested in read sources.
+type readSourceRecorder struct {
+	mu      sync.Mutex
+	sources addrs.Map[addrs.AbsResourceInstance, ReadSource]
+}
+
+func newReadSourceRecorder() *readSourceRecorder {
+	return &readSourceRecorder{
+		sources: addrs.MakeMap[addrs.AbsResourceInstance, ReadSource](),
+	}
+}
+
+// Record notes that the current object of the given resource instance came
+// from the given source, replacing any earlier record for the same instance.
+func (r *readSourceRecorder) Record(addr addrs.AbsResourceInstance, source ReadSource) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources.Put(addr, source)
+}
+
+// Sources returns a snapshot of the sources recorded so far.
+func (r *readSourceRecorder) Sources() addrs.Map[addrs.AbsResourceInstance, ReadSource] {
+	ret := addrs.MakeMap[addrs.AbsResourceInstance, ReadSource]()
+	if r == nil {
+		return ret
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, elem := range r.sources.Elems {
+		ret.PutElement(elem)
+	}
+	return ret
+}
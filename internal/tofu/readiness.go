@@ -0,0 +1,91 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+const (
+	// defaultReadinessCheckInterval is how long OpenTofu waits before
+	// polling a resource instance's readiness for the second time if
+	// ApplyOpts.ReadinessCheckInterval is not set.
+	defaultReadinessCheckInterval = time.Second
+
+	// maxReadinessCheckInterval is the longest that OpenTofu waits between
+	// polls of a resource instance's readiness.
+	maxReadinessCheckInterval = 30 * time.Second
+)
+
+// ReadinessCheck reports whether the remote object for the given resource
+// instance, whose new value was just returned by its provider, is ready to
+// be used. It returns an error if the object will never become ready.
+type ReadinessCheck func(addr addrs.AbsResourceInstance, value cty.Value) (bool, error)
+
+// readinessMiddleware returns a ProviderCallMiddleware that, after each
+// successful ApplyResourceChange call that leaves a remote object, polls the
+// given check until it reports that the object is ready.
+//
+// It waits the given interval, or defaultReadinessCheckInterval if the
+// interval is not positive, before the second poll and then doubles the wait
+// before each subsequent poll, up to maxReadinessCheckInterval. It stops
+// polling with an error if the check fails or if stopCtx is cancelled.
+func readinessMiddleware(check ReadinessCheck, interval time.Duration, stopCtx context.Context) ProviderCallMiddleware {
+	if interval <= 0 {
+		interval = defaultReadinessCheckInterval
+	}
+
+	return func(next ProviderCall) ProviderCall {
+		return func(addr addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+			resp := next(addr, req)
+			if resp.Diagnostics.HasErrors() || resp.NewState == cty.NilVal || resp.NewState.IsNull() {
+				// There's nothing to wait for if the apply failed or if
+				// it destroyed the remote object.
+				return resp
+			}
+
+			wait := interval
+			for polls := 1; ; polls++ {
+				ready, err := check(addr, resp.NewState)
+				if err != nil {
+					resp.Diagnostics = resp.Diagnostics.Append(tfdiags.Sourceless(
+						tfdiags.Error,
+						"Resource readiness check failed",
+						fmt.Sprintf("The provider applied the change for %s, but the readiness check failed: %s.", addr, err),
+					))
+					return resp
+				}
+				if ready {
+					return resp
+				}
+
+				log.Printf("[TRACE] readinessMiddleware: %s is not yet ready after %d polls, waiting %s", addr, polls, wait)
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-stopCtx.Done():
+					timer.Stop()
+					resp.Diagnostics = resp.Diagnostics.Append(tfdiags.Sourceless(
+						tfdiags.Error,
+						"Resource readiness check interrupted",
+						fmt.Sprintf("The provider applied the change for %s, but OpenTofu was interrupted before the object became ready.", addr),
+					))
+					return resp
+				}
+				wait = min(wait*2, maxReadinessCheckInterval)
+			}
+		}
+	}
+}
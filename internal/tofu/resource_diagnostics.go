@@ -0,0 +1,88 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// diagnosticExtraResourceInstance is an interface implemented by values in
+// the Extra field of Diagnostic when the diagnostic was returned by the
+// graph node for a particular resource instance.
+type diagnosticExtraResourceInstance interface {
+	// DiagnosticResourceInstance returns the address of the resource
+	// instance whose graph node returned the diagnostic.
+	DiagnosticResourceInstance() addrs.AbsResourceInstance
+}
+
+// withResourceInstance returns a copy of the given diagnostics where each
+// diagnostic records that it was returned by the graph node for the given
+// resource instance, for diagnosticResourceInstance.
+func withResourceInstance(addr addrs.AbsResourceInstance, diags tfdiags.Diagnostics) tfdiags.Diagnostics {
+	if len(diags) == 0 {
+		return diags
+	}
+
+	ret := make(tfdiags.Diagnostics, len(diags))
+	for i, diag := range diags {
+		ret[i] = tfdiags.Override(diag, diag.Severity(), func() tfdiags.DiagnosticExtraWrapper {
+			return &resourceInstanceDiagExtra{addr: addr}
+		})
+	}
+	return ret
+}
+
+// diagnosticResourceInstance returns the address of the resource instance
+// that the given diagnostic belongs to, as recorded by withResourceInstance,
+// or false if it doesn't belong to any resource instance.
+func diagnosticResourceInstance(diag tfdiags.Diagnostic) (addrs.AbsResourceInstance, bool) {
+	extra := tfdiags.ExtraInfo[diagnosticExtraResourceInstance](diag)
+	if extra == nil {
+		return addrs.AbsResourceInstance{}, false
+	}
+	return extra.DiagnosticResourceInstance(), true
+}
+
+// groupResourceDiagnostics splits the given diagnostics, which are typically
+// all of those returned from an operation, into those that belong to each
+// resource instance and all of the others.
+func groupResourceDiagnostics(all tfdiags.Diagnostics) (addrs.Map[addrs.AbsResourceInstance, tfdiags.Diagnostics], tfdiags.Diagnostics) {
+	byResource := addrs.MakeMap[addrs.AbsResourceInstance, tfdiags.Diagnostics]()
+	var others tfdiags.Diagnostics
+	for _, diag := range all {
+		addr, ok := diagnosticResourceInstance(diag)
+		if !ok {
+			others = others.Append(diag)
+			continue
+		}
+		byResource.Put(addr, byResource.Get(addr).Append(diag))
+	}
+	return byResource, others
+}
+
+// resourceInstanceDiagExtra is the extra info used by withResourceInstance,
+// which wraps any extra info the original diagnostic already had.
+type resourceInstanceDiagExtra struct {
+	addr    addrs.AbsResourceInstance
+	wrapped interface{}
+}
+
+var _ diagnosticExtraResourceInstance = (*resourceInstanceDiagExtra)(nil)
+var _ tfdiags.DiagnosticExtraWrapper = (*resourceInstanceDiagExtra)(nil)
+var _ tfdiags.DiagnosticExtraUnwrapper = (*resourceInstanceDiagExtra)(nil)
+
+func (e *resourceInstanceDiagExtra) DiagnosticResourceInstance() addrs.AbsResourceInstance {
+	return e.addr
+}
+
+func (e *resourceInstanceDiagExtra) WrapDiagnosticExtra(inner interface{}) {
+	e.wrapped = inner
+}
+
+func (e *resourceInstanceDiagExtra) UnwrapDiagnosticExtra() interface{} {
+	return e.wrapped
+}
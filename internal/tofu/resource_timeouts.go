@@ -0,0 +1,143 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// resourceApplyTimeout returns how long OpenTofu itself will wait for the
+// provider to apply a change to the given resource instance before asking
+// the provider to stop, or zero if there is no limit.
+//
+// The timeout defaults to the one for the planned action in the resource's
+// "timeouts" block, if its schema has one: "create" for creates and
+// replacements, "update" for updates and "delete" for deletes, falling back
+// on "default". A delete has no configuration, so its timeout comes from the
+// prior object instead. ApplyOpts.ResourceTimeouts overrides that default
+// for the resource instances it includes.
+//
+// The given values may be marked, null or unknown.
+func resourceApplyTimeout(ctx EvalContext, addr addrs.AbsResourceInstance, action plans.Action, config, prior cty.Value) time.Duration {
+	if timeout, ok := ctx.ResourceTimeouts().GetOk(addr); ok {
+		return max(timeout, 0)
+	}
+
+	var name string
+	obj := config
+	switch action {
+	case plans.Create, plans.CreateThenDelete, plans.DeleteThenCreate:
+		name = "create"
+	case plans.Update:
+		name = "update"
+	case plans.Delete:
+		name = "delete"
+		obj = prior
+	default:
+		return 0
+	}
+	timeouts := configTimeoutsBlock(obj)
+	if timeouts == cty.NilVal {
+		return 0
+	}
+	for _, attr := range []string{name, "default"} {
+		if !timeouts.Type().HasAttribute(attr) {
+			continue
+		}
+		v := timeouts.GetAttr(attr)
+		if v.IsNull() || !v.IsKnown() || v.Type() != cty.String {
+			continue
+		}
+		timeout, err := time.ParseDuration(v.AsString())
+		if err != nil {
+			// The provider validates its own timeouts, so we just leave
+			// any that it would reject for it to report.
+			log.Printf("[WARN] %s: ignoring invalid %s timeout %q: %s", addr, attr, v.AsString(), err)
+			return 0
+		}
+		return max(timeout, 0)
+	}
+	return 0
+}
+
+// configTimeoutsBlock returns the known, non-null object from the "timeouts"
+// block in the given resource object, or cty.NilVal if there isn't one.
+// Providers declare that block either as a single block or as a list of at
+// most one block.
+func configTimeoutsBlock(obj cty.Value) cty.Value {
+	obj, _ = obj.UnmarkDeep()
+	if obj == cty.NilVal || obj.IsNull() || !obj.IsKnown() || !obj.Type().IsObjectType() || !obj.Type().HasAttribute("timeouts") {
+		return cty.NilVal
+	}
+	timeouts := obj.GetAttr("timeouts")
+	if timeouts.IsNull() || !timeouts.IsKnown() {
+		return cty.NilVal
+	}
+	if ty := timeouts.Type(); ty.IsListType() || ty.IsTupleType() {
+		if timeouts.LengthInt() == 0 {
+			return cty.NilVal
+		}
+		timeouts = timeouts.Index(cty.NumberIntVal(0))
+		if timeouts.IsNull() || !timeouts.IsKnown() {
+			return cty.NilVal
+		}
+	}
+	if !timeouts.Type().IsObjectType() {
+		return cty.NilVal
+	}
+	return timeouts
+}
+
+// withApplyTimeout returns a ProviderCall that makes the given call, but
+// calls the given stop function once the given timeout has passed and then
+// waits for the provider to respond.
+//
+// A provider call can't be cancelled on its own, and so stopping it means
+// stopping the whole provider, which also interrupts any other operations
+// in progress for the same provider instance. Waiting for the response
+// means that the state still records whatever the provider reports that it
+// created or changed before it stopped, and so a timeout can't leave a
+// remote object that is missing from the state.
+func withApplyTimeout(next ProviderCall, stop func() error, timeout time.Duration) ProviderCall {
+	return func(addr addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+		done := make(chan providers.ApplyResourceChangeResponse, 1)
+		go func() {
+			done <- next(addr, req)
+		}()
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case resp := <-done:
+			return resp
+		case <-timer.C:
+		}
+
+		log.Printf("[ERROR] %s: provider did not finish applying within %s, so stopping it", addr, timeout)
+		if err := stop(); err != nil {
+			log.Printf("[WARN] %s: failed to stop provider: %s", addr, err)
+		}
+		resp := <-done
+		resp.Diagnostics = resp.Diagnostics.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Resource apply timed out",
+			fmt.Sprintf(
+				"The provider did not finish applying the change to %s within %s, so OpenTofu asked it to stop.\n\nThe new state records the object as the provider reported it when it stopped. Check the object before applying again.",
+				addr, timeout,
+			),
+		))
+		return resp
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/providers"
+)
+
+func TestResourceApplyTimeout(t *testing.T) {
+	addr := mustResourceInstanceAddr("test_object.a")
+	negative := mustResourceInstanceAddr("test_object.negative")
+	timeouts := addrs.MakeMap[addrs.AbsResourceInstance, time.Duration]()
+	timeouts.Put(addr, 5*time.Second)
+	timeouts.Put(negative, -time.Second)
+
+	withTimeouts := func(block cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"id":       cty.StringVal("a"),
+			"timeouts": block,
+		})
+	}
+	config := withTimeouts(cty.ObjectVal(map[string]cty.Value{
+		"create":  cty.StringVal("1m"),
+		"update":  cty.StringVal("2m"),
+		"delete":  cty.StringVal("3m"),
+		"default": cty.StringVal("4m"),
+	}))
+
+	tests := map[string]struct {
+		addr     addrs.AbsResourceInstance
+		timeouts addrs.Map[addrs.AbsResourceInstance, time.Duration]
+		action   plans.Action
+		config   cty.Value
+		prior    cty.Value
+		want     time.Duration
+	}{
+		"set": {
+			addr:     addr,
+			timeouts: timeouts,
+			action:   plans.Create,
+			want:     5 * time.Second,
+		},
+		"negative": {
+			addr:     negative,
+			timeouts: timeouts,
+			action:   plans.Create,
+		},
+		"not in map": {
+			addr:     mustResourceInstanceAddr("test_object.b"),
+			timeouts: timeouts,
+			action:   plans.Create,
+		},
+		"no map": {
+			addr:   addr,
+			action: plans.Create,
+		},
+		"options override config": {
+			addr:     addr,
+			timeouts: timeouts,
+			action:   plans.Create,
+			config:   config,
+			want:     5 * time.Second,
+		},
+		"config create": {
+			addr:   addr,
+			action: plans.Create,
+			config: config,
+			want:   time.Minute,
+		},
+		"config replace": {
+			addr:   addr,
+			action: plans.DeleteThenCreate,
+			config: config,
+			want:   time.Minute,
+		},
+		"config update": {
+			addr:   addr,
+			action: plans.Update,
+			config: config,
+			want:   2 * time.Minute,
+		},
+		"config delete": {
+			addr:   addr,
+			action: plans.Delete,
+			prior:  config,
+			want:   3 * time.Minute,
+		},
+		"config default": {
+			addr:   addr,
+			action: plans.Update,
+			config: withTimeouts(cty.ObjectVal(map[string]cty.Value{
+				"update":  cty.NullVal(cty.String),
+				"default": cty.StringVal("4m"),
+			})),
+			want: 4 * time.Minute,
+		},
+		"config list block": {
+			addr:   addr,
+			action: plans.Create,
+			config: withTimeouts(cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"create": cty.StringVal("1m")}),
+			})),
+			want: time.Minute,
+		},
+		"config marked": {
+			addr:   addr,
+			action: plans.Create,
+			config: config.Mark("sensitive"),
+			want:   time.Minute,
+		},
+		"config unknown": {
+			addr:   addr,
+			action: plans.Create,
+			config: withTimeouts(cty.ObjectVal(map[string]cty.Value{
+				"create": cty.UnknownVal(cty.String),
+			})),
+		},
+		"config invalid": {
+			addr:   addr,
+			action: plans.Create,
+			config: withTimeouts(cty.ObjectVal(map[string]cty.Value{
+				"create": cty.StringVal("soon"),
+			})),
+		},
+		"config null block": {
+			addr:   addr,
+			action: plans.Create,
+			config: withTimeouts(cty.NullVal(cty.Object(map[string]cty.Type{"create": cty.String}))),
+		},
+		"config no-op": {
+			addr:   addr,
+			action: plans.NoOp,
+			config: config,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := &MockEvalContext{ResourceTimeoutsTimeouts: test.timeouts}
+			if got := resourceApplyTimeout(ctx, test.addr, test.action, test.config, test.prior); got != test.want {
+				t.Errorf("wrong timeout %s; want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWithApplyTimeout(t *testing.T) {
+	addr := mustResourceInstanceAddr("test_object.a")
+	req := providers.ApplyResourceChangeRequest{
+		PriorState:   cty.NullVal(cty.Object(map[string]cty.Type{"id": cty.String})),
+		PlannedState: cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal("a")}),
+	}
+
+	t.Run("in time", func(t *testing.T) {
+		call := withApplyTimeout(func(_ addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+			return providers.ApplyResourceChangeResponse{NewState: req.PlannedState}
+		}, func() error {
+			t.Error("provider stopped despite finishing in time")
+			return nil
+		}, time.Hour)
+
+		resp := call(addr, req)
+		if resp.Diagnostics.HasErrors() {
+			t.Fatalf("unexpected errors: %s", resp.Diagnostics.Err())
+		}
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		stopped := make(chan struct{})
+		call := withApplyTimeout(func(_ addrs.AbsResourceInstance, req providers.ApplyResourceChangeRequest) providers.ApplyResourceChangeResponse {
+			<-stopped
+			return providers.ApplyResourceChangeResponse{NewState: req.PlannedState}
+		}, func() error {
+			close(stopped)
+			return nil
+		}, time.Millisecond)
+
+		resp := call(addr, req)
+		if !resp.Diagnostics.HasErrors() {
+			t.Fatal("call succeeded; want timeout error")
+		}
+		if got, want := resp.Diagnostics[0].Description().Summary, "Resource apply timed out"; got != want {
+			t.Errorf("wrong error summary %q; want %q", got, want)
+		}
+		if !resp.NewState.RawEquals(req.PlannedState) {
+			t.Errorf("the provider's new state was discarded; got %#v", resp.NewState)
+		}
+	})
+}
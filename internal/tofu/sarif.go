@@ -0,0 +1,239 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+	"github.com/opentofu/opentofu/version"
+)
+
+// LastApplyDiagnosticsSARIF returns the diagnostics from the most recent
+// call to Apply on this context as a SARIF version 2.1.0 log, for security
+// and compliance tools that consume that format.
+//
+// Diagnostics are grouped into rules by their category, using the category
+// name as the rule ID, such as "provider-error". Each distinct summary of a
+// diagnostic without a category becomes a rule of its own, whose ID is
+// derived from the summary, such as "state-lock-lost-during-apply".
+//
+// Results for diagnostics that belong to a resource instance are listed
+// first, sorted by resource instance address, and name that address as a
+// logical location. Results for diagnostics with a source location in the
+// configuration also include that location.
+//
+// The log has no results if that apply did not set
+// ApplyOpts.CaptureDiagnostics.
+func (c *Context) LastApplyDiagnosticsSARIF() ([]byte, error) {
+	return sarifLog(c.LastApplyDiagnosticsByResource())
+}
+
+// sarifLog returns a SARIF log with a single run describing the given
+// diagnostics, grouped as returned by LastApplyDiagnosticsByResource.
+func sarifLog(byResource addrs.Map[addrs.AbsResourceInstance, tfdiags.Diagnostics], others tfdiags.Diagnostics) ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "OpenTofu",
+				Version:        version.String(),
+				InformationURI: "https://opentofu.org",
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: []sarifResult{},
+	}
+	ruleIndex := make(map[string]int)
+	add := func(diag tfdiags.Diagnostic, addr *addrs.AbsResourceInstance) {
+		desc := diag.Description()
+		rule := sarifRuleFor(diag)
+		idx, ok := ruleIndex[rule.ID]
+		if !ok {
+			idx = len(run.Tool.Driver.Rules)
+			ruleIndex[rule.ID] = idx
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		result := sarifResult{
+			RuleID:    rule.ID,
+			RuleIndex: idx,
+			Level:     "error",
+			Message:   sarifMessage{Text: desc.Summary},
+		}
+		if diag.Severity() == tfdiags.Warning {
+			result.Level = "warning"
+		}
+		if desc.Detail != "" {
+			result.Message.Text += "\n\n" + desc.Detail
+		}
+
+		var loc sarifLocation
+		if rng := diag.Source().Subject; rng != nil {
+			loc.PhysicalLocation = &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(rng.Filename)},
+				Region: sarifRegion{
+					StartLine:   rng.Start.Line,
+					StartColumn: rng.Start.Column,
+					EndLine:     rng.End.Line,
+					EndColumn:   rng.End.Column,
+				},
+			}
+		}
+		if addr != nil {
+			loc.LogicalLocations = []sarifLogicalLocation{
+				{FullyQualifiedName: addr.String(), Kind: "resource"},
+			}
+		}
+		if loc.PhysicalLocation != nil || loc.LogicalLocations != nil {
+			result.Locations = []sarifLocation{loc}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	elems := byResource.Elements()
+	sort.Slice(elems, func(i, j int) bool {
+		return elems[i].Key.Less(elems[j].Key)
+	})
+	for _, elem := range elems {
+		for _, diag := range elem.Value {
+			add(diag, &elem.Key)
+		}
+	}
+	for _, diag := range others {
+		add(diag, nil)
+	}
+
+	return json.MarshalIndent(sarifDocument{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
+
+// sarifCategoryDescriptions are the short descriptions of the SARIF rules
+// for each diagnostic category.
+var sarifCategoryDescriptions = map[tfdiags.Category]string{
+	tfdiags.CategoryProvider: "Problem reported by a provider",
+	tfdiags.CategoryConfig:   "Problem in the configuration",
+	tfdiags.CategoryCore:     "Problem inside OpenTofu",
+	tfdiags.CategoryHook:     "Problem reported by a hook",
+}
+
+// sarifRuleFor returns the SARIF rule for the given diagnostic, which is
+// the rule for its category if it has one, or otherwise a rule derived
+// from its summary.
+func sarifRuleFor(diag tfdiags.Diagnostic) sarifRule {
+	if category := tfdiags.DiagnosticCategory(diag); category != tfdiags.CategoryNone {
+		desc, ok := sarifCategoryDescriptions[category]
+		if !ok {
+			desc = string(category)
+		}
+		return sarifRule{
+			ID:               string(category),
+			ShortDescription: sarifMessage{Text: desc},
+		}
+	}
+	summary := diag.Description().Summary
+	return sarifRule{
+		ID:               sarifRuleID(summary),
+		ShortDescription: sarifMessage{Text: summary},
+	}
+}
+
+// sarifRuleID derives a SARIF rule ID from a diagnostic summary, by
+// lowercasing it and replacing each run of characters other than letters
+// and digits with a single hyphen.
+func sarifRuleID(summary string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(summary) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		hyphen = true
+	}
+	if b.Len() == 0 {
+		return "diagnostic"
+	}
+	return b.String()
+}
+
+// The following types are the subset of the SARIF 2.1.0 object model that
+// sarifLog produces.
+
+type sarifDocument struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
@@ -0,0 +1,187 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+	"github.com/opentofu/opentofu/version"
+)
+
+func TestSarifLog(t *testing.T) {
+	byResource := addrs.MakeMap[addrs.AbsResourceInstance, tfdiags.Diagnostics]()
+	var bDiags, aDiags tfdiags.Diagnostics
+	bDiags = bDiags.Append(errors.New("a failure"))
+	aDiags = aDiags.Append(&hcl.Diagnostic{
+		Severity: hcl.DiagWarning,
+		Summary:  "Deprecated attribute",
+		Detail:   "The attribute \"old\" is deprecated.",
+		Subject: &hcl.Range{
+			Filename: "main.tf",
+			Start:    hcl.Pos{Line: 3, Column: 3, Byte: 30},
+			End:      hcl.Pos{Line: 3, Column: 6, Byte: 33},
+		},
+	})
+	byResource.Put(mustResourceInstanceAddr("test_object.b"), bDiags)
+	byResource.Put(mustResourceInstanceAddr("test_object.a"), aDiags)
+
+	var others tfdiags.Diagnostics
+	others = others.Append(tfdiags.Sourceless(tfdiags.Error, "Deprecated attribute", "Another one."))
+	others = others.Append(tfdiags.Sourceless(tfdiags.Warning, "Applied changes may be incomplete", ""))
+	var providerDiags tfdiags.Diagnostics
+	providerDiags = providerDiags.Append(tfdiags.Sourceless(tfdiags.Error, "Provider produced invalid object", "Bad object."))
+	providerDiags = providerDiags.Append(tfdiags.Sourceless(tfdiags.Error, "Request cancelled", ""))
+	others = others.Append(tfdiags.Categorize(providerDiags, tfdiags.CategoryProvider))
+
+	raw, err := sarifLog(byResource, others)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("result is not valid JSON: %s", err)
+	}
+
+	want := map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []any{
+			map[string]any{
+				"tool": map[string]any{
+					"driver": map[string]any{
+						"name":           "OpenTofu",
+						"version":        version.String(),
+						"informationUri": "https://opentofu.org",
+						"rules": []any{
+							map[string]any{
+								"id":               "deprecated-attribute",
+								"shortDescription": map[string]any{"text": "Deprecated attribute"},
+							},
+							map[string]any{
+								"id":               "a-failure",
+								"shortDescription": map[string]any{"text": "a failure"},
+							},
+							map[string]any{
+								"id":               "applied-changes-may-be-incomplete",
+								"shortDescription": map[string]any{"text": "Applied changes may be incomplete"},
+							},
+							map[string]any{
+								"id":               "provider-error",
+								"shortDescription": map[string]any{"text": "Problem reported by a provider"},
+							},
+						},
+					},
+				},
+				"results": []any{
+					map[string]any{
+						"ruleId":    "deprecated-attribute",
+						"ruleIndex": 0.0,
+						"level":     "warning",
+						"message":   map[string]any{"text": "Deprecated attribute\n\nThe attribute \"old\" is deprecated."},
+						"locations": []any{
+							map[string]any{
+								"physicalLocation": map[string]any{
+									"artifactLocation": map[string]any{"uri": "main.tf"},
+									"region": map[string]any{
+										"startLine":   3.0,
+										"startColumn": 3.0,
+										"endLine":     3.0,
+										"endColumn":   6.0,
+									},
+								},
+								"logicalLocations": []any{
+									map[string]any{"fullyQualifiedName": "test_object.a", "kind": "resource"},
+								},
+							},
+						},
+					},
+					map[string]any{
+						"ruleId":    "a-failure",
+						"ruleIndex": 1.0,
+						"level":     "error",
+						"message":   map[string]any{"text": "a failure"},
+						"locations": []any{
+							map[string]any{
+								"logicalLocations": []any{
+									map[string]any{"fullyQualifiedName": "test_object.b", "kind": "resource"},
+								},
+							},
+						},
+					},
+					map[string]any{
+						"ruleId":    "deprecated-attribute",
+						"ruleIndex": 0.0,
+						"level":     "error",
+						"message":   map[string]any{"text": "Deprecated attribute\n\nAnother one."},
+					},
+					map[string]any{
+						"ruleId":    "applied-changes-may-be-incomplete",
+						"ruleIndex": 2.0,
+						"level":     "warning",
+						"message":   map[string]any{"text": "Applied changes may be incomplete"},
+					},
+					map[string]any{
+						"ruleId":    "provider-error",
+						"ruleIndex": 3.0,
+						"level":     "error",
+						"message":   map[string]any{"text": "Provider produced invalid object\n\nBad object."},
+					},
+					map[string]any{
+						"ruleId":    "provider-error",
+						"ruleIndex": 3.0,
+						"level":     "error",
+						"message":   map[string]any{"text": "Request cancelled"},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong SARIF log\n%s", diff)
+	}
+}
+
+func TestSarifLog_empty(t *testing.T) {
+	raw, err := sarifLog(addrs.MakeMap[addrs.AbsResourceInstance, tfdiags.Diagnostics](), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Runs []struct {
+			Results []any `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("result is not valid JSON: %s", err)
+	}
+	// SARIF requires a results array, even if it's empty, to show that
+	// the tool ran and found nothing.
+	if len(got.Runs) != 1 || got.Runs[0].Results == nil || len(got.Runs[0].Results) != 0 {
+		t.Errorf("wrong runs for no diagnostics\n%s", raw)
+	}
+}
+
+func TestSarifRuleID(t *testing.T) {
+	tests := map[string]string{
+		"State lock lost during apply":       "state-lock-lost-during-apply",
+		"Invalid for_each argument":          "invalid-for-each-argument",
+		"  Provider produced null object!  ": "provider-produced-null-object",
+		"Error: \"quoted\" value (v2)":       "error-quoted-value-v2",
+		"???":                                "diagnostic",
+	}
+	for summary, want := range tests {
+		if got := sarifRuleID(summary); got != want {
+			t.Errorf("wrong rule ID for %q\ngot:  %s\nwant: %s", summary, got, want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"log"
+	"sync"
+
+	"github.com/opentofu/opentofu/internal/dag"
+)
+
+// Scheduler decides which of the graph nodes that are ready to execute
+// is given the next available parallel execution slot during a graph walk.
+//
+// A graph walk visits each node as soon as all of its dependencies have
+// completed, but only as many nodes as the Context's parallelism setting
+// allows can execute at once. By default, the nodes that are waiting for a
+// slot take them in no particular order. A Scheduler can instead choose the
+// order, such as to prioritize certain resources.
+type Scheduler interface {
+	// Next is called each time an execution slot becomes available while
+	// at least one node is waiting for one, and returns the index into
+	// waiting of the node that should execute next.
+	//
+	// Next is never called concurrently with itself during a single walk,
+	// and must not block. The waiting slice must not be retained or
+	// modified.
+	Next(waiting []dag.Vertex) int
+}
+
+// scheduledSemaphore hands out the slots of a Semaphore to waiting graph
+// nodes in the order chosen by a Scheduler.
+//
+// A single dispatch goroutine runs while any nodes are waiting. It acquires
+// each slot as it becomes available and then asks the Scheduler which node
+// should have it, so that the Scheduler can choose between all of the nodes
+// that arrived while the slots were busy.
+type scheduledSemaphore struct {
+	scheduler Scheduler
+	sem       Semaphore
+
+	mu          sync.Mutex
+	waiting     []dag.Vertex
+	ready       []chan struct{}
+	dispatching bool
+}
+
+func newScheduledSemaphore(scheduler Scheduler, sem Semaphore) *scheduledSemaphore {
+	return &scheduledSemaphore{
+		scheduler: scheduler,
+		sem:       sem,
+	}
+}
+
+// Acquire blocks until the Scheduler has given the given node a slot of the
+// underlying semaphore, which the caller must then release as normal.
+func (s *scheduledSemaphore) Acquire(v dag.Vertex) {
+	ready := make(chan struct{})
+
+	s.mu.Lock()
+	s.waiting = append(s.waiting, v)
+	s.ready = append(s.ready, ready)
+	start := !s.dispatching
+	s.dispatching = true
+	s.mu.Unlock()
+
+	if start {
+		go s.dispatch()
+	}
+	<-ready
+}
+
+func (s *scheduledSemaphore) dispatch() {
+	for {
+		s.sem.Acquire()
+
+		s.mu.Lock()
+		if len(s.waiting) == 0 {
+			s.dispatching = false
+			s.mu.Unlock()
+			s.sem.Release()
+			return
+		}
+		i := s.scheduler.Next(s.waiting)
+		if i < 0 || i >= len(s.waiting) {
+			log.Printf("[WARN] Scheduler chose invalid index %d from %d waiting nodes, so using the first", i, len(s.waiting))
+			i = 0
+		}
+		ready := s.ready[i]
+		s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+		s.ready = append(s.ready[:i], s.ready[i+1:]...)
+		s.mu.Unlock()
+
+		close(ready)
+	}
+}
+
+// shuffleScheduler is a Scheduler that gives the available slots to waiting
+// nodes in a pseudo-random order derived from a seed, to help find code
+// that depends on an order of independent nodes that is not guaranteed.
+//
+// Each node's position in the order depends only on the seed and the node's
+// name, and so the same seed always chooses the same node from the same
+// set of waiting nodes, regardless of the order in which they arrived.
+type shuffleScheduler struct {
+	seed int64
+}
+
+var _ Scheduler = shuffleScheduler{}
+
+func (s shuffleScheduler) Next(waiting []dag.Vertex) int {
+	ret := 0
+	retKey := s.key(waiting[0])
+	for i, v := range waiting[1:] {
+		if key := s.key(v); key < retKey || (key == retKey && dag.VertexName(v) < dag.VertexName(waiting[ret])) {
+			ret, retKey = i+1, key
+		}
+	}
+	return ret
+}
+
+func (s shuffleScheduler) key(v dag.Vertex) uint64 {
+	h := fnv.New64a()
+	var seed [8]byte
+	binary.LittleEndian.PutUint64(seed[:], uint64(s.seed))
+	h.Write(seed[:])
+	h.Write([]byte(dag.VertexName(v)))
+	return h.Sum64()
+}
+
+// reverseScheduler is a Scheduler that gives the available slots to waiting
+// nodes in reverse dependency order, to help find code that depends on
+// independent nodes executing in the order that their dependencies imply.
+//
+// It chooses the waiting node with the longest chain of dependencies in the
+// graph, and between nodes with equally long chains chooses the one whose
+// name sorts last.
+type reverseScheduler struct {
+	depths map[dag.Vertex]int
+}
+
+var _ Scheduler = reverseScheduler{}
+
+func newReverseScheduler(g *dag.AcyclicGraph) reverseScheduler {
+	depths := make(map[dag.Vertex]int)
+	// The reverse topological order visits each node's dependencies before
+	// the node itself.
+	for _, v := range g.ReverseTopologicalOrder() {
+		depth := 0
+		for _, dep := range g.DownEdges(v) {
+			depth = max(depth, depths[dep]+1)
+		}
+		depths[v] = depth
+	}
+	return reverseScheduler{depths: depths}
+}
+
+func (s reverseScheduler) Next(waiting []dag.Vertex) int {
+	ret := 0
+	for i, v := range waiting[1:] {
+		depth, retDepth := s.depths[v], s.depths[waiting[ret]]
+		if depth > retDepth || (depth == retDepth && dag.VertexName(v) > dag.VertexName(waiting[ret])) {
+			ret = i + 1
+		}
+	}
+	return ret
+}
@@ -0,0 +1,156 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/opentofu/opentofu/internal/dag"
+)
+
+// testLastNameScheduler is a Scheduler that always chooses the waiting node
+// whose name sorts last, and records each choice.
+type testLastNameScheduler struct {
+	mu     sync.Mutex
+	chosen []string
+}
+
+var _ Scheduler = (*testLastNameScheduler)(nil)
+
+func (s *testLastNameScheduler) Next(waiting []dag.Vertex) int {
+	ret := 0
+	for i, v := range waiting {
+		if dag.VertexName(v) > dag.VertexName(waiting[ret]) {
+			ret = i
+		}
+	}
+	s.mu.Lock()
+	s.chosen = append(s.chosen, dag.VertexName(waiting[ret]))
+	s.mu.Unlock()
+	return ret
+}
+
+func TestScheduledSemaphore(t *testing.T) {
+	sem := NewSemaphore(1)
+	scheduler := &testLastNameScheduler{}
+	s := newScheduledSemaphore(scheduler, sem)
+
+	// We hold the only slot until all of the nodes are waiting, so that the
+	// scheduler must choose between all of them.
+	sem.Acquire()
+
+	var mu sync.Mutex
+	var got []string
+	var wg sync.WaitGroup
+	for _, name := range []string{"b", "c", "a"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Acquire(testSchedulerVertex(name))
+			mu.Lock()
+			got = append(got, name)
+			mu.Unlock()
+			sem.Release()
+		}()
+	}
+	for {
+		s.mu.Lock()
+		n := len(s.waiting)
+		s.mu.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	sem.Release()
+	wg.Wait()
+
+	want := []string{"c", "b", "a"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong execution order\n%s", diff)
+	}
+	if diff := cmp.Diff(want, scheduler.chosen); diff != "" {
+		t.Errorf("wrong scheduler choices\n%s", diff)
+	}
+}
+
+type testSchedulerVertex string
+
+func (v testSchedulerVertex) Name() string {
+	return string(v)
+}
+
+func TestShuffleScheduler(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	// order returns the order in which the given scheduler would give slots
+	// to the given nodes if they were all waiting at once.
+	order := func(s Scheduler, names []string) []string {
+		var waiting []dag.Vertex
+		for _, name := range names {
+			waiting = append(waiting, testSchedulerVertex(name))
+		}
+		var ret []string
+		for len(waiting) > 0 {
+			i := s.Next(waiting)
+			ret = append(ret, dag.VertexName(waiting[i]))
+			waiting = append(waiting[:i], waiting[i+1:]...)
+		}
+		return ret
+	}
+
+	want := order(shuffleScheduler{seed: 42}, names)
+	reversed := make([]string, len(names))
+	for i, name := range names {
+		reversed[len(names)-1-i] = name
+	}
+	for _, input := range [][]string{names, reversed} {
+		if diff := cmp.Diff(want, order(shuffleScheduler{seed: 42}, input)); diff != "" {
+			t.Errorf("same seed gave different order for %v\n%s", input, diff)
+		}
+	}
+
+	distinct := map[string]bool{}
+	for seed := int64(1); seed <= 10; seed++ {
+		distinct[strings.Join(order(shuffleScheduler{seed: seed}, names), "")] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("ten different seeds all gave the same order %v", want)
+	}
+}
+
+func TestReverseScheduler(t *testing.T) {
+	// c depends on b, which depends on a, while d and e are independent.
+	var g dag.AcyclicGraph
+	vertices := map[string]dag.Vertex{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		vertices[name] = testSchedulerVertex(name)
+		g.Add(vertices[name])
+	}
+	g.Connect(dag.BasicEdge(vertices["b"], vertices["a"]))
+	g.Connect(dag.BasicEdge(vertices["c"], vertices["b"]))
+
+	s := newReverseScheduler(&g)
+	var waiting []dag.Vertex
+	for _, name := range []string{"a", "d", "c", "e", "b"} {
+		waiting = append(waiting, vertices[name])
+	}
+	var got []string
+	for len(waiting) > 0 {
+		i := s.Next(waiting)
+		got = append(got, dag.VertexName(waiting[i]))
+		waiting = append(waiting[:i], waiting[i+1:]...)
+	}
+	want := []string{"c", "b", "e", "d", "a"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong order\n%s", diff)
+	}
+}
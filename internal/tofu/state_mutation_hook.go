@@ -0,0 +1,95 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"log"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/configs/configschema"
+	"github.com/opentofu/opentofu/internal/states"
+)
+
+// forgetListener is implemented by internal hooks that need to know when an
+// object is forgotten, since that removes the object from the state without
+// any PreApply or PostApply calls.
+type forgetListener interface {
+	objectForgotten(addr addrs.AbsResourceInstance, gen states.Generation, old *states.ResourceInstanceObject) (HookAction, error)
+}
+
+// stateMutationHook reports to any StateMutationListener hooks that the
+// given object in the working state has changed from old to new.
+//
+// Nothing is reported if both objects are nil, because that represents
+// removing an object that didn't exist in the first place.
+func stateMutationHook(ctx EvalContext, addr addrs.AbsResourceInstance, gen states.Generation, old, new *states.ResourceInstanceObject) error {
+	if old == nil && new == nil {
+		return nil
+	}
+	return ctx.Hook(func(h Hook) (HookAction, error) {
+		if l, ok := h.(StateMutationListener); ok {
+			return l.StateMutation(addr, gen, old, new)
+		}
+		return HookActionContinue, nil
+	})
+}
+
+// forgetHook reports that the given object has been forgotten, both to any
+// StateMutationListener hooks and to any internal forgetListener hooks.
+func forgetHook(ctx EvalContext, addr addrs.AbsResourceInstance, gen states.Generation, old *states.ResourceInstanceObject) error {
+	if old == nil {
+		return nil
+	}
+	return ctx.Hook(func(h Hook) (HookAction, error) {
+		if l, ok := h.(forgetListener); ok {
+			if action, err := l.objectForgotten(addr, gen, old); err != nil || action != HookActionContinue {
+				return action, err
+			}
+		}
+		if l, ok := h.(StateMutationListener); ok {
+			return l.StateMutation(addr, gen, old, nil)
+		}
+		return HookActionContinue, nil
+	})
+}
+
+// hasStateMutationListener returns true if at least one of the hooks in the
+// given context implements StateMutationListener, so that callers can avoid
+// preparing the "old" object when nothing would receive it.
+func hasStateMutationListener(ctx EvalContext) bool {
+	found := false
+	_ = ctx.Hook(func(h Hook) (HookAction, error) {
+		if _, ok := h.(StateMutationListener); ok {
+			found = true
+		}
+		return HookActionContinue, nil
+	})
+	return found
+}
+
+// workingStateObjectForHook returns the decoded form of the given object
+// from the working state, for use as the "old" object in a call to
+// stateMutationHook.
+//
+// The result is nil if there is no such object, if it cannot be decoded
+// using the given schema because it has not yet been upgraded to the
+// provider's current schema version, or if none of the hooks implement
+// StateMutationListener, in which case decoding it would be wasted work.
+func workingStateObjectForHook(ctx EvalContext, addr addrs.AbsResourceInstance, gen states.Generation, schema *configschema.Block) *states.ResourceInstanceObject {
+	if schema == nil || !hasStateMutationListener(ctx) {
+		return nil
+	}
+	src := ctx.State().ResourceInstanceObject(addr, gen)
+	if src == nil {
+		return nil
+	}
+	obj, err := src.Decode(schema.ImpliedType())
+	if err != nil {
+		log.Printf("[TRACE] workingStateObjectForHook: can't decode prior object for %s: %s", addr, err)
+		return nil
+	}
+	return obj
+}
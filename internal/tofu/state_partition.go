@@ -0,0 +1,58 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/states"
+)
+
+// partitionState splits the given state into separate states keyed by the
+// result of calling the given function for each resource instance.
+//
+// Everything that isn't a resource instance, such as the output values and
+// check results, stays in the partition with the empty key, which is
+// therefore always present. A resource whose instances are split across
+// several partitions appears in each of them with only its own instances,
+// and a resource with no instances at all stays in the empty partition. A
+// module that has nothing left in the empty partition is removed from it.
+func partitionState(state *states.State, partitioner func(addrs.AbsResourceInstance) string) map[string]*states.State {
+	ret := map[string]*states.State{
+		"": state.DeepCopy(),
+	}
+	for _, ms := range state.Modules {
+		for _, rs := range ms.Resources {
+			for key, is := range rs.Instances {
+				name := partitioner(rs.Addr.Instance(key))
+				if name == "" {
+					continue
+				}
+
+				defaultMS := ret[""].Module(ms.Addr)
+				defaultRS := defaultMS.Resource(rs.Addr.Resource)
+				delete(defaultRS.Instances, key)
+				if len(defaultRS.Instances) == 0 {
+					defaultMS.RemoveResource(rs.Addr.Resource)
+				}
+				if !ms.Addr.IsRoot() && len(defaultMS.Resources) == 0 && len(defaultMS.OutputValues) == 0 && len(defaultMS.LocalValues) == 0 {
+					ret[""].RemoveModule(ms.Addr)
+				}
+
+				part, ok := ret[name]
+				if !ok {
+					part = states.NewState()
+					ret[name] = part
+				}
+				pms := part.EnsureModule(ms.Addr)
+				if pms.Resource(rs.Addr.Resource) == nil {
+					pms.SetResourceProvider(rs.Addr.Resource, rs.ProviderConfig)
+				}
+				pms.Resource(rs.Addr.Resource).Instances[key] = is.DeepCopy()
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,66 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// taintWarnedResources marks as tainted, in the given state, the current
+// object of each managed resource instance that the given diagnostics from
+// an apply walk include a warning for, as grouped by groupResourceDiagnostics,
+// for ApplyOpts.TaintOnWarning.
+//
+// Only objects that are ready are tainted, because an object that is already
+// tainted will be replaced anyway and an object without a current object has
+// nothing to replace. The result is a warning that lists the tainted
+// resource instances, if there were any.
+func taintWarnedResources(state *states.State, byResource addrs.Map[addrs.AbsResourceInstance, tfdiags.Diagnostics]) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	var tainted []string
+	for _, elem := range byResource.Elems {
+		addr := elem.Key
+		if addr.Resource.Resource.Mode != addrs.ManagedResourceMode || !hasWarnings(elem.Value) {
+			continue
+		}
+		is := state.ResourceInstance(addr)
+		if is == nil || is.Current == nil || is.Current.Status != states.ObjectReady {
+			continue
+		}
+		is.Current.Status = states.ObjectTainted
+		tainted = append(tainted, addr.String())
+	}
+	if len(tainted) == 0 {
+		return diags
+	}
+	sort.Strings(tainted)
+
+	diags = diags.Append(tfdiags.Sourceless(
+		tfdiags.Warning,
+		"Resource instances tainted because of warnings",
+		fmt.Sprintf(
+			"The following resource instances were marked as tainted because applying them produced warnings, and so they will be replaced in the next plan:\n  - %s",
+			strings.Join(tainted, "\n  - "),
+		),
+	))
+	return diags
+}
+
+func hasWarnings(diags tfdiags.Diagnostics) bool {
+	for _, diag := range diags {
+		if diag.Severity() == tfdiags.Warning {
+			return true
+		}
+	}
+	return false
+}
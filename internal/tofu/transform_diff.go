@@ -82,11 +82,17 @@ func (t *DiffTransformer) Transform(g *Graph) error {
 		resourceNodes[addr] = append(resourceNodes[addr], rn)
 	}
 
+	// Bulk forgets can involve a very large number of resource instances,
+	// so we log those in aggregate below rather than one at a time.
+	forgetCount := 0
+
 	for _, rc := range changes.Resources {
 		addr := rc.Addr
 		dk := rc.DeposedKey
 
-		log.Printf("[TRACE] DiffTransformer: found %s change for %s %s", rc.Action, addr, dk)
+		if rc.Action != plans.Forget {
+			log.Printf("[TRACE] DiffTransformer: found %s change for %s %s", rc.Action, addr, dk)
+		}
 
 		// Depending on the action we'll need some different combinations of
 		// nodes, because destroying uses a special node type separate from
@@ -221,20 +227,23 @@ func (t *DiffTransformer) Transform(g *Graph) error {
 					NodeAbstractResourceInstance: abstract,
 					DeposedKey:                   dk,
 				}
-				log.Printf("[TRACE] DiffTransformer: %s will be represented for removal from the state by %s", addr, dag.VertexName(node))
 			} else {
 				node = &NodeForgetDeposedResourceInstanceObject{
 					NodeAbstractResourceInstance: abstract,
 					DeposedKey:                   dk,
 				}
-				log.Printf("[TRACE] DiffTransformer: %s deposed object %s will be represented for removal from the state by %s", addr, dk, dag.VertexName(node))
 			}
 
 			g.Add(node)
+			forgetCount++
 		}
 
 	}
 
+	if forgetCount > 0 {
+		log.Printf("[TRACE] DiffTransformer: %d resource instance object(s) will be removed from the state without being destroyed", forgetCount)
+	}
+
 	log.Printf("[TRACE] DiffTransformer complete")
 
 	return diags.Err()
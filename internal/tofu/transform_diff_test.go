@@ -6,6 +6,9 @@
 package tofu
 
 import (
+	"bytes"
+	"fmt"
+	"log"
 	"strings"
 	"testing"
 
@@ -167,6 +170,62 @@ resource "aws_instance" "foo" {
 	}
 }
 
+func TestDiffTransformer_forgetLogAggregated(t *testing.T) {
+	g := Graph{Path: addrs.RootModuleInstance}
+
+	beforeVal, err := plans.NewDynamicValue(cty.StringVal(""), cty.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterVal, err := plans.NewDynamicValue(cty.NullVal(cty.String), cty.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const count = 100
+	changes := &plans.Changes{}
+	for i := 0; i < count; i++ {
+		changes.Resources = append(changes.Resources, &plans.ResourceInstanceChangeSrc{
+			Addr: addrs.Resource{
+				Mode: addrs.ManagedResourceMode,
+				Type: "aws_instance",
+				Name: "foo",
+			}.Instance(addrs.IntKey(i)).Absolute(addrs.RootModuleInstance),
+			ProviderAddr: addrs.AbsProviderConfig{
+				Provider: addrs.NewDefaultProvider("aws"),
+				Module:   addrs.RootModule,
+			},
+			ChangeSrc: plans.ChangeSrc{
+				Action: plans.Forget,
+				Before: beforeVal,
+				After:  afterVal,
+			},
+		})
+	}
+
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	tf := &DiffTransformer{Changes: changes}
+	if err := tf.Transform(&g); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if got := len(g.Vertices()); got != count {
+		t.Fatalf("wrong number of nodes %d; want %d", got, count)
+	}
+
+	logs := buf.String()
+	if got := strings.Count(logs, "aws_instance.foo["); got != 0 {
+		t.Errorf("logged %d lines about individual forgotten instances; want none\n%s", got, logs)
+	}
+	want := fmt.Sprintf("DiffTransformer: %d resource instance object(s) will be removed from the state", count)
+	if got := strings.Count(logs, want); got != 1 {
+		t.Errorf("logged the aggregated forget line %d times; want once\n%s", got, logs)
+	}
+}
+
 const testTransformDiffBasicStr = `
 aws_instance.foo
 `
@@ -0,0 +1,89 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/dag"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// ApplyEdge is an ordering between two resource instances for
+// ApplyOpts.ExtraEdges: the changes to From are applied before any of the
+// changes to To.
+type ApplyEdge struct {
+	From, To addrs.AbsResourceInstance
+}
+
+// extraEdgesTransformer is a GraphTransformer that adds the orderings from
+// ApplyOpts.ExtraEdges to the graph, making each node that changes the To
+// instance of an edge depend on each node that changes its From instance.
+//
+// An edge whose instances have no changes in the plan has nothing to order,
+// and so it is ignored. An edge that would create a cycle, because the From
+// instance already depends on the To instance, is an error.
+type extraEdgesTransformer struct {
+	Edges []ApplyEdge
+}
+
+func (t *extraEdgesTransformer) Transform(g *Graph) error {
+	if len(t.Edges) == 0 {
+		return nil
+	}
+
+	nodes := addrs.MakeMap[addrs.AbsResourceInstance, []dag.Vertex]()
+	for _, v := range g.Vertices() {
+		if rn, ok := v.(GraphNodeResourceInstance); ok {
+			addr := rn.ResourceInstanceAddr()
+			nodes.Put(addr, append(nodes.Get(addr), v))
+		}
+	}
+
+	var diags tfdiags.Diagnostics
+	for _, edge := range t.Edges {
+		if edge.From.Equal(edge.To) {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid extra apply edge",
+				fmt.Sprintf("Cannot order %s before itself.", edge.From),
+			))
+			continue
+		}
+		froms, tos := nodes.Get(edge.From), nodes.Get(edge.To)
+		if len(froms) == 0 || len(tos) == 0 {
+			log.Printf("[TRACE] extraEdgesTransformer: nothing to order between %s and %s", edge.From, edge.To)
+			continue
+		}
+
+	Edge:
+		for _, to := range tos {
+			// We find the dependents of each node only after connecting the
+			// previous edges, so that the edges together can't form a cycle
+			// either.
+			dependents, err := g.Descendents(to)
+			if err != nil {
+				return err
+			}
+			for _, from := range froms {
+				if dependents.Include(from) {
+					diags = diags.Append(tfdiags.Sourceless(
+						tfdiags.Error,
+						"Invalid extra apply edge",
+						fmt.Sprintf("Cannot apply %s before %s, because %s already depends on %s, and so this ordering would create a dependency cycle.", edge.From, edge.To, dag.VertexName(from), dag.VertexName(to)),
+					))
+					break Edge
+				}
+			}
+			for _, from := range froms {
+				g.Connect(dag.BasicEdge(to, from))
+			}
+		}
+	}
+	return diags.Err()
+}
@@ -0,0 +1,64 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"log"
+
+	"github.com/opentofu/opentofu/internal/dag"
+)
+
+// forgetLastTransformer is a GraphTransformer that makes each node that
+// forgets a resource instance object depend on every node that creates,
+// updates or destroys one, for ApplyOpts.ForgetLast.
+//
+// Because the graph walk skips the nodes that depend on a failed node, the
+// forgets then happen only once all of the other changes have succeeded.
+// A change that itself depends on a forget, such as through a reference to
+// the forgotten resource, keeps that ordering instead, since reversing it
+// would create a cycle.
+type forgetLastTransformer struct {
+	// Enabled must be set for the transformer to do anything, so that it
+	// can be included unconditionally in the apply graph steps.
+	Enabled bool
+}
+
+func (t *forgetLastTransformer) Transform(g *Graph) error {
+	if !t.Enabled {
+		return nil
+	}
+
+	var forgets, others []dag.Vertex
+	for _, v := range g.Vertices() {
+		switch v.(type) {
+		case *NodeForgetResourceInstance, *NodeForgetDeposedResourceInstanceObject:
+			forgets = append(forgets, v)
+		case *NodeApplyableResourceInstance, *NodeDestroyResourceInstance, *NodeDestroyDeposedResourceInstanceObject:
+			others = append(others, v)
+		}
+	}
+	if len(forgets) == 0 || len(others) == 0 {
+		return nil
+	}
+
+	for _, forget := range forgets {
+		// We find the dependents of each forget only after connecting the
+		// previous ones, so that the new edges can't form a cycle through
+		// more than one forget either.
+		dependents, err := g.Descendents(forget)
+		if err != nil {
+			return err
+		}
+		for _, other := range others {
+			if dependents.Include(other) {
+				log.Printf("[TRACE] forgetLastTransformer: %s can't come after %s, which depends on it", dag.VertexName(forget), dag.VertexName(other))
+				continue
+			}
+			g.Connect(dag.BasicEdge(forget, other))
+		}
+	}
+	return nil
+}
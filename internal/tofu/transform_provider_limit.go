@@ -0,0 +1,133 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/opentofu/opentofu/internal/dag"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// providerLimitTransformer is a GraphTransformer that adds edges between
+// provider nodes so that no more than Max of them are open at once during
+// the walk, for ApplyOpts.MaxProviderInstances.
+//
+// The providers are arranged into at most Max chains, in each of which a
+// provider is initialized only once the previous one has been closed, and
+// so the nodes that need a provider wait until it has a free place in a
+// chain. A provider can't follow another whose close node depends on it,
+// such as when the other provider's resources refer to its resources, since
+// that would create a cycle. The first Max providers each start a chain of
+// their own, and if there is no chain that a later provider can join then
+// the transformer returns an error.
+type providerLimitTransformer struct {
+	// Max is the maximum number of providers open at once, or zero for
+	// no limit.
+	Max int
+}
+
+func (t *providerLimitTransformer) Transform(g *Graph) error {
+	if t.Max <= 0 {
+		return nil
+	}
+	if len(g.Cycles()) > 0 {
+		// The cycles will be reported once the graph is built, and we
+		// can't decide on an order for the providers until then.
+		return nil
+	}
+
+	type provider struct {
+		init, close dag.Vertex
+		name        string
+		deps        int
+	}
+	closes := make(map[string]dag.Vertex)
+	for _, v := range g.Vertices() {
+		if cp, ok := v.(GraphNodeCloseProvider); ok {
+			closes[cp.CloseProviderAddr().String()] = v
+		}
+	}
+	var providers []*provider
+	for _, v := range g.Vertices() {
+		pv, ok := v.(*NodeApplyableProvider)
+		if !ok {
+			continue
+		}
+		name := pv.ProviderAddr().String()
+		if closes[name] == nil {
+			continue
+		}
+		providers = append(providers, &provider{init: v, close: closes[name], name: name})
+	}
+	if len(providers) <= t.Max {
+		return nil
+	}
+
+	// Providers whose close nodes depend on fewer of the other providers
+	// go first, since a provider can only follow one that doesn't need it.
+	for _, p := range providers {
+		deps, err := g.Ancestors(p.close)
+		if err != nil {
+			return err
+		}
+		for _, other := range providers {
+			if other != p && deps.Include(other.init) {
+				p.deps++
+			}
+		}
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		if providers[i].deps != providers[j].deps {
+			return providers[i].deps < providers[j].deps
+		}
+		return providers[i].name < providers[j].name
+	})
+
+	var chains []*provider
+Providers:
+	for _, p := range providers {
+		if len(chains) < t.Max {
+			chains = append(chains, p)
+			continue
+		}
+		dependents, err := g.Descendents(p.init)
+		if err != nil {
+			return err
+		}
+		for i, last := range chains {
+			if !dependents.Include(last.close) {
+				g.Connect(dag.BasicEdge(p.init, last.close))
+				chains[i] = p
+				continue Providers
+			}
+		}
+
+		var diags tfdiags.Diagnostics
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Too many provider instances",
+			fmt.Sprintf("Cannot limit the apply to %d provider instances at once, because each of the providers that would need to close before %s can be initialized depends on it.", t.Max, p.name),
+		))
+		return diags.Err()
+	}
+	return nil
+}
+
+// checkMaxProviderInstances returns an error if the given limit for
+// ApplyOpts.MaxProviderInstances is invalid.
+func checkMaxProviderInstances(limit int) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if limit < 0 {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Invalid provider instance limit",
+			fmt.Sprintf("The maximum number of provider instances must not be negative, not %d.", limit),
+		))
+	}
+	return diags
+}
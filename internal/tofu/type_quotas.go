@@ -0,0 +1,101 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// checkTypeQuotas returns an error diagnostic for each resource type in the
+// given quotas that would have more managed resource instances than its
+// quota after applying the given plan, sorted by resource type.
+//
+// The count for each type starts from the current objects in the plan's
+// prior state, adds one for each planned create, and subtracts one for each
+// planned destroy or forget of a current object. Replacements don't change
+// the count, even though a create_before_destroy replacement briefly has
+// two objects.
+func checkTypeQuotas(plan *plans.Plan, quotas map[string]int) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+	if len(quotas) == 0 {
+		return diags
+	}
+
+	types := make([]string, 0, len(quotas))
+	for typeName, quota := range quotas {
+		if quota < 0 {
+			diags = diags.Append(tfdiags.Sourceless(
+				tfdiags.Error,
+				"Invalid type quota",
+				fmt.Sprintf("The quota for resource type %q is %d, but quotas must not be negative.", typeName, quota),
+			))
+			continue
+		}
+		types = append(types, typeName)
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+	sort.Strings(types)
+
+	existing := make(map[string]int)
+	for _, ms := range plan.PriorState.Modules {
+		for _, rs := range ms.Resources {
+			if rs.Addr.Resource.Mode != addrs.ManagedResourceMode {
+				continue
+			}
+			if _, ok := quotas[rs.Addr.Resource.Type]; !ok {
+				continue
+			}
+			for _, is := range rs.Instances {
+				if is.Current != nil {
+					existing[rs.Addr.Resource.Type]++
+				}
+			}
+		}
+	}
+
+	creates := make(map[string]int)
+	removes := make(map[string]int)
+	for _, rc := range plan.Changes.Resources {
+		addr := rc.Addr.Resource.Resource
+		if addr.Mode != addrs.ManagedResourceMode || rc.DeposedKey != states.NotDeposed {
+			continue
+		}
+		if _, ok := quotas[addr.Type]; !ok {
+			continue
+		}
+		switch rc.Action {
+		case plans.Create:
+			creates[addr.Type]++
+		case plans.Delete, plans.Forget:
+			removes[addr.Type]++
+		}
+	}
+
+	for _, typeName := range types {
+		quota := quotas[typeName]
+		total := existing[typeName] + creates[typeName] - removes[typeName]
+		if total <= quota {
+			continue
+		}
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Resource type quota exceeded",
+			fmt.Sprintf(
+				"Applying this plan would leave %d instances of resource type %q, which exceeds its quota of %d. There are %d in the prior state, and the plan creates %d and destroys or forgets %d.",
+				total, typeName, quota, existing[typeName], creates[typeName], removes[typeName],
+			),
+		))
+	}
+	return diags
+}
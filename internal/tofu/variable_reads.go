@@ -0,0 +1,57 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+// Copyright (c) 2023 HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package tofu
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+)
+
+// variableReads tracks which input variables the expressions evaluated
+// during a graph walk referred to.
+//
+// A walk that isn't interested in these has a nil *variableReads, for which
+// Addrs returns nil. The evaluator checks for nil before calling Record, so
+// that such walks don't pay for a lock on every variable read.
+type variableReads struct {
+	mu    sync.Mutex
+	addrs map[string]struct{}
+}
+
+func newVariableReads() *variableReads {
+	return &variableReads{
+		addrs: make(map[string]struct{}),
+	}
+}
+
+// Record notes that an expression read the given input variable.
+func (r *variableReads) Record(addr addrs.AbsInputVariableInstance) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs[addr.String()] = struct{}{}
+}
+
+// Addrs returns the addresses of all of the recorded input variables,
+// such as "var.region" or "module.network.var.cidr", in lexical order.
+func (r *variableReads) Addrs() []string {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.addrs) == 0 {
+		return nil
+	}
+	ret := make([]string, 0, len(r.addrs))
+	for addr := range r.addrs {
+		ret = append(ret, addr)
+	}
+	sort.Strings(ret)
+	return ret
+}